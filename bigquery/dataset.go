@@ -148,6 +148,7 @@ func (d *Dataset) Identifier(f IdentifierFormat) (string, error) {
 func (d *Dataset) Create(ctx context.Context, md *DatasetMetadata) (err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Dataset.Create")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, d.ProjectID, d.DatasetID, "")
 
 	ds, err := md.toBQ()
 	if err != nil {
@@ -223,6 +224,7 @@ func (d *Dataset) DeleteWithContents(ctx context.Context) (err error) {
 func (d *Dataset) deleteInternal(ctx context.Context, deleteContents bool) (err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Dataset.Delete")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, d.ProjectID, d.DatasetID, "")
 
 	call := d.c.bqs.Datasets.Delete(d.ProjectID, d.DatasetID).Context(ctx).DeleteContents(deleteContents)
 	setClientHeader(call.Header())
@@ -238,6 +240,7 @@ func (d *Dataset) deleteInternal(ctx context.Context, deleteContents bool) (err
 func (d *Dataset) Metadata(ctx context.Context) (md *DatasetMetadata, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Dataset.Metadata")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, d.ProjectID, d.DatasetID, "")
 
 	call := d.c.bqs.Datasets.Get(d.ProjectID, d.DatasetID).Context(ctx)
 	setClientHeader(call.Header())
@@ -290,6 +293,7 @@ func bqToDatasetMetadata(d *bq.Dataset, c *Client) (*DatasetMetadata, error) {
 func (d *Dataset) Update(ctx context.Context, dm DatasetMetadataToUpdate, etag string) (md *DatasetMetadata, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Dataset.Update")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, d.ProjectID, d.DatasetID, "")
 
 	ds, err := dm.toBQ()
 	if err != nil {
@@ -95,6 +95,7 @@ func (t *Table) Uploader() *Inserter { return t.Inserter() }
 func (u *Inserter) Put(ctx context.Context, src interface{}) (err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Inserter.Put")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, u.t.ProjectID, u.t.DatasetID, u.t.TableID)
 
 	savers, err := valueSavers(src)
 	if err != nil {
@@ -0,0 +1,49 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+// attrKeyProject is the attribute key of the project of an operation.
+const attrKeyProject = attribute.Key("gcp.project_id")
+
+// setSpanAttributes adds the project, dataset and, if it is not empty, the
+// table of an operation to the current span if OpenTelemetry tracing is
+// enabled.
+func setSpanAttributes(ctx context.Context, projectID, datasetID, tableID string) {
+	if !trace.IsOpenTelemetryTracingEnabled() {
+		return
+	}
+	span := ottrace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemKey.String("bigquery"),
+		attrKeyProject.String(projectID),
+		semconv.DBName(datasetID),
+	}
+	if tableID != "" {
+		attrs = append(attrs, semconv.DBSQLTable(tableID))
+	}
+	span.SetAttributes(attrs...)
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/internal/trace"
+	"github.com/google/go-cmp/cmp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetSpanAttributes(t *testing.T) {
+	defer func(enabled bool) { trace.OpenTelemetryTracingEnabled = enabled }(trace.OpenTelemetryTracingEnabled)
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+
+	for _, enabled := range []bool{false, true} {
+		trace.OpenTelemetryTracingEnabled = enabled
+		ctx, span := tracer.Start(context.Background(), "span")
+		setSpanAttributes(ctx, "p", "d", "t")
+		span.End()
+	}
+
+	spans := sr.Ended()
+	if len(spans[0].Attributes()) != 0 {
+		t.Errorf("attributes were set with OpenTelemetry disabled: %v", spans[0].Attributes())
+	}
+	got := make(map[string]string)
+	for _, kv := range spans[1].Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	want := map[string]string{
+		"db.system":      "bigquery",
+		"gcp.project_id": "p",
+		"db.name":        "d",
+		"db.sql.table":   "t",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("attributes: -want +got:\n%s", diff)
+	}
+}
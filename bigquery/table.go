@@ -567,6 +567,7 @@ func (t *Table) implicitTable() bool {
 func (t *Table) Create(ctx context.Context, tm *TableMetadata) (err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Table.Create")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, t.ProjectID, t.DatasetID, t.TableID)
 
 	table, err := tm.toBQ()
 	if err != nil {
@@ -706,6 +707,7 @@ func WithMetadataView(tmv TableMetadataView) TableMetadataOption {
 func (t *Table) Metadata(ctx context.Context, opts ...TableMetadataOption) (md *TableMetadata, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Table.Metadata")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, t.ProjectID, t.DatasetID, t.TableID)
 
 	tgc := &tableGetCall{
 		call: t.c.bqs.Tables.Get(t.ProjectID, t.DatasetID, t.TableID).Context(ctx),
@@ -782,6 +784,7 @@ func bqToTableMetadata(t *bq.Table, c *Client) (*TableMetadata, error) {
 func (t *Table) Delete(ctx context.Context) (err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Table.Delete")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, t.ProjectID, t.DatasetID, t.TableID)
 
 	call := t.c.bqs.Tables.Delete(t.ProjectID, t.DatasetID, t.TableID).Context(ctx)
 	setClientHeader(call.Header())
@@ -827,6 +830,7 @@ func WithAutoDetectSchema(b bool) TableUpdateOption {
 func (t *Table) Update(ctx context.Context, tm TableMetadataToUpdate, etag string, opts ...TableUpdateOption) (md *TableMetadata, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigquery.Table.Update")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, t.ProjectID, t.DatasetID, t.TableID)
 
 	bqt, err := tm.toBQ()
 	if err != nil {
@@ -164,7 +164,8 @@ func (c *Client) Open(table string) *Table {
 
 // ReadRows reads rows from a table. f is called for each row.
 // If f returns false, the stream is shut down and ReadRows returns.
-// f owns its argument, and f is called serially in order by row key.
+// f owns its argument, and f is called serially in order by row key,
+// or in descending order by row key if ReverseScan is used.
 //
 // By default, the yielded rows will contain all values in all cells.
 // Use RowFilter to limit the cells returned.
@@ -200,6 +201,7 @@ func (t *Table) ReadRows(ctx context.Context, arg RowSet, f func(Row) bool, opts
 			return err
 		}
 		cr := newChunkReader()
+		cr.reversed = req.Reversed
 		for {
 			res, err := stream.Recv()
 			if err == io.EOF {
@@ -207,7 +209,11 @@ func (t *Table) ReadRows(ctx context.Context, arg RowSet, f func(Row) bool, opts
 			}
 			if err != nil {
 				// Reset arg for next Invoke call.
-				arg = arg.retainRowsAfter(prevRowKey)
+				if req.Reversed {
+					arg = arg.retainRowsBefore(prevRowKey)
+				} else {
+					arg = arg.retainRowsAfter(prevRowKey)
+				}
 				attrMap["rowKey"] = prevRowKey
 				attrMap["error"] = err.Error()
 				attrMap["time_secs"] = time.Since(startTime).Seconds()
@@ -294,6 +300,11 @@ type RowSet interface {
 	// given row key or any row key lexicographically less than it.
 	retainRowsAfter(lastRowKey string) RowSet
 
+	// retainRowsBefore returns a new RowSet that does not include the
+	// given row key or any row key lexicographically greater than it.
+	// It is used to resume reverse scans.
+	retainRowsBefore(lastRowKey string) RowSet
+
 	// Valid reports whether this set can cover at least one row.
 	valid() bool
 }
@@ -319,6 +330,19 @@ func (r RowList) retainRowsAfter(lastRowKey string) RowSet {
 	return retryKeys
 }
 
+func (r RowList) retainRowsBefore(lastRowKey string) RowSet {
+	if lastRowKey == "" {
+		return r
+	}
+	var retryKeys RowList
+	for _, key := range r {
+		if key < lastRowKey {
+			retryKeys = append(retryKeys, key)
+		}
+	}
+	return retryKeys
+}
+
 func (r RowList) valid() bool {
 	return len(r) > 0
 }
@@ -360,13 +384,36 @@ func (r RowRange) String() string {
 }
 
 func (r RowRange) proto() *btpb.RowSet {
+	return &btpb.RowSet{RowRanges: []*btpb.RowRange{r.rangeProto()}}
+}
+
+func (r RowRange) rangeProto() *btpb.RowRange {
 	rr := &btpb.RowRange{
 		StartKey: &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte(r.start)},
 	}
 	if !r.Unbounded() {
 		rr.EndKey = &btpb.RowRange_EndKeyOpen{EndKeyOpen: []byte(r.limit)}
 	}
-	return &btpb.RowSet{RowRanges: []*btpb.RowRange{rr}}
+	return rr
+}
+
+// rowRangeFromProto converts a RowRange proto to the equivalent half-open
+// RowRange.
+func rowRangeFromProto(rr *btpb.RowRange) RowRange {
+	var r RowRange
+	switch k := rr.GetStartKey().(type) {
+	case *btpb.RowRange_StartKeyClosed:
+		r.start = string(k.StartKeyClosed)
+	case *btpb.RowRange_StartKeyOpen:
+		r.start = string(k.StartKeyOpen) + "\x00"
+	}
+	switch k := rr.GetEndKey().(type) {
+	case *btpb.RowRange_EndKeyOpen:
+		r.limit = string(k.EndKeyOpen)
+	case *btpb.RowRange_EndKeyClosed:
+		r.limit = string(k.EndKeyClosed) + "\x00"
+	}
+	return r
 }
 
 func (r RowRange) retainRowsAfter(lastRowKey string) RowSet {
@@ -381,6 +428,14 @@ func (r RowRange) retainRowsAfter(lastRowKey string) RowSet {
 	return NewRange(start, r.limit)
 }
 
+func (r RowRange) retainRowsBefore(lastRowKey string) RowSet {
+	if lastRowKey == "" || (!r.Unbounded() && lastRowKey >= r.limit) {
+		return r
+	}
+	// Set the end of the range to the last scanned row, which is excluded.
+	return NewRange(r.start, lastRowKey)
+}
+
 func (r RowRange) valid() bool {
 	return r.Unbounded() || r.start < r.limit
 }
@@ -412,6 +467,21 @@ func (r RowRangeList) retainRowsAfter(lastRowKey string) RowSet {
 	return ranges
 }
 
+func (r RowRangeList) retainRowsBefore(lastRowKey string) RowSet {
+	if lastRowKey == "" {
+		return r
+	}
+	// Return a list of any range that has not yet been completely processed
+	var ranges RowRangeList
+	for _, rr := range r {
+		retained := rr.retainRowsBefore(lastRowKey)
+		if retained.valid() {
+			ranges = append(ranges, retained.(RowRange))
+		}
+	}
+	return ranges
+}
+
 func (r RowRangeList) valid() bool {
 	for _, rr := range r {
 		if rr.valid() {
@@ -552,6 +622,14 @@ type limitRows struct{ limit int64 }
 
 func (lr limitRows) set(settings *readSettings) { settings.req.RowsLimit = lr.limit }
 
+// ReverseScan returns a ReadOption that reads rows in descending order by row
+// key.
+func ReverseScan() ReadOption { return reverseScan{} }
+
+type reverseScan struct{}
+
+func (rs reverseScan) set(settings *readSettings) { settings.req.Reversed = true }
+
 // WithFullReadStats returns a ReadOption that will request FullReadStats
 // and invoke the given callback on the resulting FullReadStats.
 func WithFullReadStats(f FullReadStatsFunc) ReadOption { return withFullReadStats{f} }
@@ -778,24 +856,7 @@ func (t *Table) ApplyBulk(ctx context.Context, rowKeys []string, muts []*Mutatio
 	}
 
 	for _, group := range groupEntries(origEntries, maxMutations) {
-		attrMap := make(map[string]interface{})
-		err = gax.Invoke(ctx, func(ctx context.Context, _ gax.CallSettings) error {
-			attrMap["rowCount"] = len(group)
-			trace.TracePrintf(ctx, attrMap, "Row count in ApplyBulk")
-			err := t.doApplyBulk(ctx, group, opts...)
-			if err != nil {
-				// We want to retry the entire request with the current group
-				return err
-			}
-			group = t.getApplyBulkRetries(group)
-			if len(group) > 0 && len(idempotentRetryCodes) > 0 {
-				// We have at least one mutation that needs to be retried.
-				// Return an arbitrary error that is retryable according to callOptions.
-				return status.Errorf(idempotentRetryCodes[0], "Synthetic error: partial failure of ApplyBulk")
-			}
-			return nil
-		}, retryOptions...)
-		if err != nil {
+		if _, err = t.applyGroup(ctx, group, opts...); err != nil {
 			return nil, err
 		}
 	}
@@ -815,6 +876,33 @@ func (t *Table) ApplyBulk(ctx context.Context, rowKeys []string, muts []*Mutatio
 	return nil, nil
 }
 
+// applyGroup applies a group of entries and retries the entries that failed
+// with a retryable error. If the group cannot be applied, it returns the
+// entries whose outcome is unknown along with the error.
+func (t *Table) applyGroup(ctx context.Context, group []*entryErr, opts ...ApplyOption) ([]*entryErr, error) {
+	attrMap := make(map[string]interface{})
+	err := gax.Invoke(ctx, func(ctx context.Context, _ gax.CallSettings) error {
+		attrMap["rowCount"] = len(group)
+		trace.TracePrintf(ctx, attrMap, "Row count in ApplyBulk")
+		err := t.doApplyBulk(ctx, group, opts...)
+		if err != nil {
+			// We want to retry the entire request with the current group
+			return err
+		}
+		group = t.getApplyBulkRetries(group)
+		if len(group) > 0 && len(idempotentRetryCodes) > 0 {
+			// We have at least one mutation that needs to be retried.
+			// Return an arbitrary error that is retryable according to callOptions.
+			return status.Errorf(idempotentRetryCodes[0], "Synthetic error: partial failure of ApplyBulk")
+		}
+		return nil
+	}, retryOptions...)
+	if err != nil {
+		return group, err
+	}
+	return nil, nil
+}
+
 // getApplyBulkRetries returns the entries that need to be retried
 func (t *Table) getApplyBulkRetries(entries []*entryErr) []*entryErr {
 	var retryEntries []*entryErr
@@ -0,0 +1,325 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bttest
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	statpb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The fake serves the change stream of a table as a single partition that
+// covers all rows. Continuation tokens are the sequence numbers of the
+// entries in the change log of the table.
+//
+// The change log only keeps the changes of the retention period of the
+// table, or of defaultChangeRetention if the table has no change stream
+// config, and at most maxChangeLogEntries changes, so that it does not grow
+// without bound. Reading from a token or a time whose changes have been
+// dropped fails with OutOfRange.
+
+// defaultHeartbeatDuration is used when a ReadChangeStreamRequest has no
+// heartbeat duration.
+const defaultHeartbeatDuration = 10 * time.Second
+
+// changeSourceCluster is reported as the source cluster of all changes.
+const changeSourceCluster = "bttest"
+
+// defaultChangeRetention is the retention period of the changes of tables
+// without a change stream config. It is the default of Cloud Bigtable.
+const defaultChangeRetention = 24 * time.Hour
+
+// maxChangeLogEntries is the maximum number of changes kept for a table.
+var maxChangeLogEntries = 100000
+
+// changeLog records the mutations that were applied to a table in commit order.
+type changeLog struct {
+	mu        sync.Mutex
+	retention time.Duration
+	entries   []*changeEntry
+	// next is the sequence number of the next entry.
+	next int64
+	// dropped is the commit time of the last entry that was dropped.
+	dropped time.Time
+	// appended is closed and replaced whenever an entry is recorded.
+	appended chan struct{}
+}
+
+type changeEntry struct {
+	seq       int64
+	rowKey    string
+	committed time.Time
+	muts      []*btpb.Mutation
+}
+
+func newChangeLog(retention time.Duration) *changeLog {
+	if retention <= 0 {
+		retention = defaultChangeRetention
+	}
+	return &changeLog{retention: retention, appended: make(chan struct{})}
+}
+
+// record adds the mutations that were applied to a row to the log.
+func (l *changeLog) record(rowKey string, muts []*btpb.Mutation) {
+	if len(muts) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	committed := time.Now()
+	if n := len(l.entries); n > 0 && committed.Before(l.entries[n-1].committed) {
+		committed = l.entries[n-1].committed
+	}
+	l.entries = append(l.entries, &changeEntry{
+		seq:       l.next,
+		rowKey:    rowKey,
+		committed: committed,
+		muts:      muts,
+	})
+	l.next++
+	l.dropExpiredLocked(committed)
+	close(l.appended)
+	l.appended = make(chan struct{})
+}
+
+// dropExpiredLocked drops the entries that are older than the retention
+// period, or beyond maxChangeLogEntries. l.mu must be held.
+func (l *changeLog) dropExpiredLocked(now time.Time) {
+	n := sort.Search(len(l.entries), func(i int) bool { return now.Sub(l.entries[i].committed) <= l.retention })
+	if len(l.entries)-n > maxChangeLogEntries {
+		n = len(l.entries) - maxChangeLogEntries
+	}
+	if n == 0 {
+		return
+	}
+	l.dropped = l.entries[n-1].committed
+	// Copy the entries, so that the dropped ones can be collected.
+	l.entries = append([]*changeEntry(nil), l.entries[n:]...)
+}
+
+// since returns the entries with a sequence number larger than seq and a
+// channel that is closed when the next entry is recorded. It returns an
+// OutOfRange error if some of these entries have been dropped.
+func (l *changeLog) since(seq int64) ([]*changeEntry, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first := l.next - int64(len(l.entries))
+	if seq+1 < first {
+		return nil, nil, status.Errorf(codes.OutOfRange, "changes after token %d are past the retention period", seq)
+	}
+	if seq+1 >= l.next {
+		return nil, l.appended, nil
+	}
+	return l.entries[seq+1-first:], l.appended, nil
+}
+
+// lastBefore returns the sequence number of the last entry that was committed
+// before t, or -1 if there is none. It returns an OutOfRange error if t is
+// past the retention period.
+func (l *changeLog) lastBefore(t time.Time) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(t) > l.retention || (!l.dropped.IsZero() && !t.After(l.dropped)) {
+		return 0, status.Errorf(codes.OutOfRange, "start time %v is past the retention period", t)
+	}
+	i := sort.Search(len(l.entries), func(i int) bool { return !l.entries[i].committed.Before(t) })
+	return l.next - int64(len(l.entries)) + int64(i) - 1, nil
+}
+
+func setCellMutation(family string, column []byte, ts int64, value []byte) *btpb.Mutation {
+	return &btpb.Mutation{Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+		FamilyName:      family,
+		ColumnQualifier: column,
+		TimestampMicros: ts,
+		Value:           value,
+	}}}
+}
+
+// deleteFamilyMutations returns a DeleteFromFamily mutation for every family
+// of r. It assumes r.mu is locked.
+func deleteFamilyMutations(r *row) []*btpb.Mutation {
+	var muts []*btpb.Mutation
+	for _, f := range r.sortedFamilies() {
+		muts = append(muts, &btpb.Mutation{Mutation: &btpb.Mutation_DeleteFromFamily_{
+			DeleteFromFamily: &btpb.Mutation_DeleteFromFamily{FamilyName: f.name},
+		}})
+	}
+	return muts
+}
+
+func (s *server) GenerateInitialChangeStreamPartitions(req *btpb.GenerateInitialChangeStreamPartitionsRequest, stream btpb.Bigtable_GenerateInitialChangeStreamPartitionsServer) error {
+	s.mu.Lock()
+	_, ok := s.tables[req.TableName]
+	s.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "table %q not found", req.TableName)
+	}
+	return stream.Send(&btpb.GenerateInitialChangeStreamPartitionsResponse{
+		Partition: &btpb.StreamPartition{RowRange: &btpb.RowRange{
+			StartKey: &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte("")},
+		}},
+	})
+}
+
+func (s *server) ReadChangeStream(req *btpb.ReadChangeStreamRequest, stream btpb.Bigtable_ReadChangeStreamServer) error {
+	s.mu.Lock()
+	tbl, ok := s.tables[req.TableName]
+	s.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "table %q not found", req.TableName)
+	}
+	if req.GetPartition().GetRowRange() == nil {
+		return status.Error(codes.InvalidArgument, "a partition with a row range is required")
+	}
+	partition := req.Partition
+	start, end := rowRangeBounds(partition.RowRange)
+
+	// seq is the sequence number of the last entry that has been sent.
+	var seq int64
+	switch from := req.StartFrom.(type) {
+	case *btpb.ReadChangeStreamRequest_ContinuationTokens:
+		if len(from.ContinuationTokens.GetTokens()) == 0 {
+			return status.Error(codes.InvalidArgument, "no continuation tokens")
+		}
+		seq = -1
+		for _, tok := range from.ContinuationTokens.Tokens {
+			n, err := strconv.ParseInt(tok.Token, 10, 64)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid continuation token %q", tok.Token)
+			}
+			if n > seq {
+				seq = n
+			}
+		}
+	case *btpb.ReadChangeStreamRequest_StartTime:
+		var err error
+		if seq, err = tbl.changes.lastBefore(from.StartTime.AsTime()); err != nil {
+			return err
+		}
+	default:
+		seq, _ = tbl.changes.lastBefore(time.Now())
+	}
+	var endTime time.Time
+	if req.EndTime != nil {
+		endTime = req.EndTime.AsTime()
+	}
+	heartbeat := defaultHeartbeatDuration
+	if d := req.GetHeartbeatDuration().AsDuration(); d > 0 {
+		heartbeat = d
+	}
+
+	closeStream := func() error {
+		return stream.Send(&btpb.ReadChangeStreamResponse{
+			StreamRecord: &btpb.ReadChangeStreamResponse_CloseStream_{
+				CloseStream: &btpb.ReadChangeStreamResponse_CloseStream{Status: &statpb.Status{Code: int32(codes.OK)}},
+			},
+		})
+	}
+	ctx := stream.Context()
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	var endTimer <-chan time.Time
+	if !endTime.IsZero() {
+		t := time.NewTimer(time.Until(endTime))
+		defer t.Stop()
+		endTimer = t.C
+	}
+	for {
+		entries, appended, err := tbl.changes.since(seq)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !endTime.IsZero() && e.committed.After(endTime) {
+				return closeStream()
+			}
+			seq = e.seq
+			if e.rowKey < start || (end != "" && e.rowKey >= end) {
+				continue
+			}
+			if err := stream.Send(dataChangeResponse(e)); err != nil {
+				return err
+			}
+		}
+		if !endTime.IsZero() && !time.Now().Before(endTime) {
+			return closeStream()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-appended:
+		case <-endTimer:
+		case now := <-ticker.C:
+			err := stream.Send(&btpb.ReadChangeStreamResponse{
+				StreamRecord: &btpb.ReadChangeStreamResponse_Heartbeat_{Heartbeat: &btpb.ReadChangeStreamResponse_Heartbeat{
+					ContinuationToken: &btpb.StreamContinuationToken{
+						Partition: partition,
+						Token:     strconv.FormatInt(seq, 10),
+					},
+					EstimatedLowWatermark: timestamppb.New(now),
+				}},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func dataChangeResponse(e *changeEntry) *btpb.ReadChangeStreamResponse {
+	chunks := make([]*btpb.ReadChangeStreamResponse_MutationChunk, len(e.muts))
+	for i, m := range e.muts {
+		chunks[i] = &btpb.ReadChangeStreamResponse_MutationChunk{Mutation: m}
+	}
+	return &btpb.ReadChangeStreamResponse{
+		StreamRecord: &btpb.ReadChangeStreamResponse_DataChange_{DataChange: &btpb.ReadChangeStreamResponse_DataChange{
+			Type:                  btpb.ReadChangeStreamResponse_DataChange_USER,
+			SourceClusterId:       changeSourceCluster,
+			RowKey:                []byte(e.rowKey),
+			CommitTimestamp:       timestamppb.New(e.committed),
+			Chunks:                chunks,
+			Done:                  true,
+			Token:                 strconv.FormatInt(e.seq, 10),
+			EstimatedLowWatermark: timestamppb.New(e.committed),
+		}},
+	}
+}
+
+// rowRangeBounds returns the half-open interval [start, end) of rr. An empty
+// end means that the range is unbounded.
+func rowRangeBounds(rr *btpb.RowRange) (start, end string) {
+	switch sk := rr.StartKey.(type) {
+	case *btpb.RowRange_StartKeyClosed:
+		start = string(sk.StartKeyClosed)
+	case *btpb.RowRange_StartKeyOpen:
+		start = string(sk.StartKeyOpen) + "\x00"
+	}
+	switch ek := rr.EndKey.(type) {
+	case *btpb.RowRange_EndKeyClosed:
+		end = string(ek.EndKeyClosed) + "\x00"
+	case *btpb.RowRange_EndKeyOpen:
+		end = string(ek.EndKeyOpen)
+	}
+	return start, end
+}
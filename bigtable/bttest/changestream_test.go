@@ -0,0 +1,283 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bttest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	btapb "google.golang.org/genproto/googleapis/bigtable/admin/v2"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type MockReadChangeStreamServer struct {
+	ctx       context.Context
+	responses []*btpb.ReadChangeStreamResponse
+	grpc.ServerStream
+}
+
+func (s *MockReadChangeStreamServer) Send(resp *btpb.ReadChangeStreamResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *MockReadChangeStreamServer) Context() context.Context {
+	return s.ctx
+}
+
+func TestReadChangeStream(t *testing.T) {
+	s := &server{
+		tables: make(map[string]*table),
+	}
+	ctx := context.Background()
+	newTbl := btapb.Table{
+		ColumnFamilies: map[string]*btapb.ColumnFamily{
+			"cf": {},
+		},
+	}
+	tbl, err := s.CreateTable(ctx, &btapb.CreateTableRequest{Parent: "cluster", TableId: "t", Table: &newTbl})
+	if err != nil {
+		t.Fatalf("Creating table: %v", err)
+	}
+	start := time.Now()
+	muts := []*btpb.MutateRowRequest{
+		{
+			TableName: tbl.Name,
+			RowKey:    []byte("a"),
+			Mutations: []*btpb.Mutation{{
+				Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+					FamilyName:      "cf",
+					ColumnQualifier: []byte("col"),
+					TimestampMicros: -1,
+					Value:           []byte("v"),
+				}},
+			}},
+		},
+		{
+			TableName: tbl.Name,
+			RowKey:    []byte("z"),
+			Mutations: []*btpb.Mutation{{
+				Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+					FamilyName:      "cf",
+					ColumnQualifier: []byte("col"),
+					TimestampMicros: 1000,
+					Value:           []byte("v"),
+				}},
+			}},
+		},
+		{
+			TableName: tbl.Name,
+			RowKey:    []byte("a"),
+			Mutations: []*btpb.Mutation{{
+				Mutation: &btpb.Mutation_DeleteFromRow_{DeleteFromRow: &btpb.Mutation_DeleteFromRow{}},
+			}},
+		},
+	}
+	for _, req := range muts {
+		if _, err := s.MutateRow(ctx, req); err != nil {
+			t.Fatalf("Populating table: %v", err)
+		}
+	}
+
+	var partitions []*btpb.StreamPartition
+	pmock := &MockGenerateInitialChangeStreamPartitionsServer{}
+	if err := s.GenerateInitialChangeStreamPartitions(&btpb.GenerateInitialChangeStreamPartitionsRequest{TableName: tbl.Name}, pmock); err != nil {
+		t.Fatalf("GenerateInitialChangeStreamPartitions error: %v", err)
+	}
+	for _, res := range pmock.responses {
+		partitions = append(partitions, res.Partition)
+	}
+	if len(partitions) != 1 {
+		t.Fatalf("Got %d partitions, want 1", len(partitions))
+	}
+
+	// Only read the changes of rows before "m".
+	partition := &btpb.StreamPartition{RowRange: &btpb.RowRange{
+		StartKey: &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte("")},
+		EndKey:   &btpb.RowRange_EndKeyOpen{EndKeyOpen: []byte("m")},
+	}}
+	mock := &MockReadChangeStreamServer{ctx: ctx}
+	req := &btpb.ReadChangeStreamRequest{
+		TableName:         tbl.Name,
+		Partition:         partition,
+		StartFrom:         &btpb.ReadChangeStreamRequest_StartTime{StartTime: timestamppb.New(start)},
+		EndTime:           timestamppb.Now(),
+		HeartbeatDuration: durationpb.New(time.Hour),
+	}
+	if err := s.ReadChangeStream(req, mock); err != nil {
+		t.Fatalf("ReadChangeStream error: %v", err)
+	}
+	if got, want := len(mock.responses), 3; got != want {
+		t.Fatalf("Got %d responses, want %d: %v", got, want, mock.responses)
+	}
+	set := mock.responses[0].GetDataChange()
+	if got, want := string(set.GetRowKey()), "a"; got != want {
+		t.Errorf("Row key: got %q, want %q", got, want)
+	}
+	if ts := set.GetChunks()[0].GetMutation().GetSetCell().GetTimestampMicros(); ts <= 0 {
+		t.Errorf("Server timestamp was not resolved: %d", ts)
+	}
+	del := mock.responses[1].GetDataChange()
+	if got, want := del.GetChunks()[0].GetMutation().GetDeleteFromFamily().GetFamilyName(), "cf"; got != want {
+		t.Errorf("Row deletion: got family %q, want %q", got, want)
+	}
+	if code := mock.responses[2].GetCloseStream().GetStatus().GetCode(); code != int32(codes.OK) {
+		t.Errorf("Close stream status: got %v, want OK", code)
+	}
+
+	// Resuming from the token of the first change only returns the deletion.
+	mock = &MockReadChangeStreamServer{ctx: ctx}
+	req.StartFrom = &btpb.ReadChangeStreamRequest_ContinuationTokens{ContinuationTokens: &btpb.StreamContinuationTokens{
+		Tokens: []*btpb.StreamContinuationToken{{Partition: partition, Token: set.Token}},
+	}}
+	if err := s.ReadChangeStream(req, mock); err != nil {
+		t.Fatalf("ReadChangeStream error: %v", err)
+	}
+	if got, want := len(mock.responses), 2; got != want {
+		t.Fatalf("Got %d responses after resuming, want %d: %v", got, want, mock.responses)
+	}
+	if got, want := mock.responses[0].GetDataChange().GetToken(), del.GetToken(); got != want {
+		t.Errorf("Token after resuming: got %q, want %q", got, want)
+	}
+
+	req.StartFrom = &btpb.ReadChangeStreamRequest_ContinuationTokens{ContinuationTokens: &btpb.StreamContinuationTokens{
+		Tokens: []*btpb.StreamContinuationToken{{Partition: partition, Token: "not-a-token"}},
+	}}
+	if err := s.ReadChangeStream(req, &MockReadChangeStreamServer{ctx: ctx}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Invalid token: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestReadChangeStreamHeartbeat(t *testing.T) {
+	s := &server{
+		tables: make(map[string]*table),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	tbl, err := s.CreateTable(ctx, &btapb.CreateTableRequest{Parent: "cluster", TableId: "t", Table: &btapb.Table{}})
+	if err != nil {
+		t.Fatalf("Creating table: %v", err)
+	}
+	mock := &MockReadChangeStreamServer{ctx: ctx}
+	req := &btpb.ReadChangeStreamRequest{
+		TableName: tbl.Name,
+		Partition: &btpb.StreamPartition{RowRange: &btpb.RowRange{
+			StartKey: &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte("")},
+		}},
+		HeartbeatDuration: durationpb.New(10 * time.Millisecond),
+	}
+	if err := s.ReadChangeStream(req, mock); err != context.DeadlineExceeded {
+		t.Fatalf("ReadChangeStream error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(mock.responses) == 0 {
+		t.Fatal("No heartbeats")
+	}
+	for _, res := range mock.responses {
+		if res.GetHeartbeat().GetContinuationToken().GetToken() != "-1" {
+			t.Fatalf("Unexpected response: %v", res)
+		}
+	}
+}
+
+func TestReadChangeStreamRetention(t *testing.T) {
+	defer func(n int) { maxChangeLogEntries = n }(maxChangeLogEntries)
+	maxChangeLogEntries = 2
+	s := &server{
+		tables: make(map[string]*table),
+	}
+	ctx := context.Background()
+	tbl, err := s.CreateTable(ctx, &btapb.CreateTableRequest{Parent: "cluster", TableId: "t", Table: &btapb.Table{
+		ColumnFamilies:     map[string]*btapb.ColumnFamily{"cf": {}},
+		ChangeStreamConfig: &btapb.ChangeStreamConfig{RetentionPeriod: durationpb.New(time.Hour)},
+	}})
+	if err != nil {
+		t.Fatalf("Creating table: %v", err)
+	}
+	start := time.Now()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		req := &btpb.MutateRowRequest{
+			TableName: tbl.Name,
+			RowKey:    []byte(key),
+			Mutations: []*btpb.Mutation{{
+				Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+					FamilyName:      "cf",
+					ColumnQualifier: []byte("col"),
+					TimestampMicros: 1000,
+					Value:           []byte("v"),
+				}},
+			}},
+		}
+		if _, err := s.MutateRow(ctx, req); err != nil {
+			t.Fatalf("Populating table: %v", err)
+		}
+	}
+	if got := len(s.tables[tbl.Name].changes.entries); got != 2 {
+		t.Errorf("Got %d changes in the log, want 2", got)
+	}
+
+	partition := &btpb.StreamPartition{RowRange: &btpb.RowRange{
+		StartKey: &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte("")},
+	}}
+	read := func(from interface{}) ([]*btpb.ReadChangeStreamResponse, error) {
+		req := &btpb.ReadChangeStreamRequest{
+			TableName: tbl.Name,
+			Partition: partition,
+			EndTime:   timestamppb.Now(),
+		}
+		switch from := from.(type) {
+		case string:
+			req.StartFrom = &btpb.ReadChangeStreamRequest_ContinuationTokens{ContinuationTokens: &btpb.StreamContinuationTokens{
+				Tokens: []*btpb.StreamContinuationToken{{Partition: partition, Token: from}},
+			}}
+		case time.Time:
+			req.StartFrom = &btpb.ReadChangeStreamRequest_StartTime{StartTime: timestamppb.New(from)}
+		}
+		mock := &MockReadChangeStreamServer{ctx: ctx}
+		err := s.ReadChangeStream(req, mock)
+		return mock.responses, err
+	}
+
+	// The changes of "a" and "b" have been dropped.
+	for _, from := range []interface{}{"0", start, start.Add(-2 * time.Hour)} {
+		if _, err := read(from); status.Code(err) != codes.OutOfRange {
+			t.Errorf("Reading from %v: got %v, want OutOfRange", from, err)
+		}
+	}
+	res, err := read("1")
+	if err != nil {
+		t.Fatalf("ReadChangeStream error: %v", err)
+	}
+	if len(res) != 3 || string(res[0].GetDataChange().GetRowKey()) != "c" || string(res[1].GetDataChange().GetRowKey()) != "d" {
+		t.Errorf("Got %v, want the changes of c and d", res)
+	}
+}
+
+type MockGenerateInitialChangeStreamPartitionsServer struct {
+	responses []*btpb.GenerateInitialChangeStreamPartitionsResponse
+	grpc.ServerStream
+}
+
+func (s *MockGenerateInitialChangeStreamPartitionsServer) Send(resp *btpb.GenerateInitialChangeStreamPartitionsResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
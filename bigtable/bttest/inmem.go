@@ -499,7 +499,11 @@ func (s *server) ReadRows(req *btpb.ReadRowsRequest, stream btpb.Bigtable_ReadRo
 			rows = append(rows, r)
 		}
 	}
-	sort.Sort(byRowKey(rows))
+	if req.Reversed {
+		sort.Sort(sort.Reverse(byRowKey(rows)))
+	} else {
+		sort.Sort(byRowKey(rows))
+	}
 
 	limit := int(req.RowsLimit)
 	if limit == 0 {
@@ -1035,12 +1039,17 @@ func (s *server) PingAndWarm(ctx context.Context, req *btpb.PingAndWarmRequest)
 	return &btpb.PingAndWarmResponse{}, nil
 }
 
-// applyMutations applies a sequence of mutations to a row.
+// applyMutations applies a sequence of mutations to a row and records them in
+// the change stream of the table.
 // fam should be a snapshot of the keys of tbl.families.
 // It assumes r.mu is locked.
 func applyMutations(tbl *table, r *row, muts []*btpb.Mutation, fs map[string]*columnFamily) error {
-	for _, mut := range muts {
-		switch mut := mut.Mutation.(type) {
+	// applied contains the mutations as they are recorded in the change
+	// stream, with server timestamps resolved.
+	applied := make([]*btpb.Mutation, 0, len(muts))
+	for _, m := range muts {
+		applied = append(applied, m)
+		switch mut := m.Mutation.(type) {
 		default:
 			return fmt.Errorf("can't handle mutation type %T", mut)
 		case *btpb.Mutation_SetCell_:
@@ -1051,6 +1060,7 @@ func applyMutations(tbl *table, r *row, muts []*btpb.Mutation, fs map[string]*co
 			ts := set.TimestampMicros
 			if ts == -1 { // bigtable.ServerTime
 				ts = newTimestamp()
+				applied[len(applied)-1] = setCellMutation(set.FamilyName, set.ColumnQualifier, ts, set.Value)
 			}
 			if !tbl.validTimestamp(ts) {
 				return fmt.Errorf("invalid timestamp %d", ts)
@@ -1114,12 +1124,16 @@ func applyMutations(tbl *table, r *row, muts []*btpb.Mutation, fs map[string]*co
 				}
 			}
 		case *btpb.Mutation_DeleteFromRow_:
+			// Change streams report row deletions as deletions of every
+			// family of the row.
+			applied = append(applied[:len(applied)-1], deleteFamilyMutations(r)...)
 			r.families = make(map[string]*family)
 		case *btpb.Mutation_DeleteFromFamily_:
 			fampre := mut.DeleteFromFamily.FamilyName
 			delete(r.families, fampre)
 		}
 	}
+	tbl.changes.record(r.key, applied)
 	return nil
 }
 
@@ -1169,6 +1183,9 @@ func (s *server) ReadModifyWriteRow(ctx context.Context, req *btpb.ReadModifyWri
 	// This must be done before the row lock, acquired below, is released.
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	// applied contains the new cells as they are recorded in the change
+	// stream.
+	var applied []*btpb.Mutation
 	// Assume all mutations apply to the most recent version of the cell.
 	// TODO(dsymonds): Verify this assumption and document it in the proto.
 	for _, rule := range req.Rules {
@@ -1216,6 +1233,7 @@ func (s *server) ReadModifyWriteRow(ctx context.Context, req *btpb.ReadModifyWri
 
 		// Store the new cell
 		f.cells[col] = appendOrReplaceCell(f.cellsByColumn(col), newCell)
+		applied = append(applied, setCellMutation(fam, rule.ColumnQualifier, newCell.ts, newCell.value))
 
 		// Store a copy for the result row
 		resultFamily := resultRow.getOrCreateFamily(fam, fs[fam].order)
@@ -1223,6 +1241,8 @@ func (s *server) ReadModifyWriteRow(ctx context.Context, req *btpb.ReadModifyWri
 		resultFamily.cells[col] = []cell{newCell} // overwrite the cells
 	}
 
+	tbl.changes.record(rowKey, applied)
+
 	// Build the response using the result row
 	res := &btpb.Row{
 		Key:      req.RowKey,
@@ -1329,6 +1349,7 @@ type table struct {
 	families    map[string]*columnFamily // keyed by plain family name
 	rows        *btree.BTree             // indexed by row key
 	isProtected bool                     // whether this table has deletion protection
+	changes     *changeLog               // mutations in commit order, for change streams
 }
 
 const btreeDegree = 16
@@ -1351,6 +1372,7 @@ func newTable(ctr *btapb.CreateTableRequest) *table {
 		counter:     c,
 		rows:        btree.New(btreeDegree),
 		isProtected: ctr.GetTable().GetDeletionProtection(),
+		changes:     newChangeLog(ctr.GetTable().GetChangeStreamConfig().GetRetentionPeriod().AsDuration()),
 	}
 }
 
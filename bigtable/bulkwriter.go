@@ -0,0 +1,319 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigtable
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/trace"
+	"github.com/golang/protobuf/proto"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+)
+
+// errBulkWriterClosed is returned for mutations that are applied after
+// BulkWriter.Close was called.
+var errBulkWriterClosed = errors.New("bigtable: BulkWriter is closed")
+
+// BulkWriterSettings control the batching and flow control of a BulkWriter.
+// Zero values are replaced by the values in DefaultBulkWriterSettings.
+type BulkWriterSettings struct {
+	// DelayThreshold is the maximum time that a mutation is buffered before
+	// its batch is sent.
+	DelayThreshold time.Duration
+
+	// CountThreshold is the maximum number of rows in a batch.
+	CountThreshold int
+
+	// ByteThreshold is the maximum size of a batch in bytes. A row that is
+	// larger than ByteThreshold is sent in a batch of its own.
+	ByteThreshold int
+
+	// NumGoroutines is the maximum number of concurrent MutateRows requests.
+	NumGoroutines int
+
+	// MaxOutstandingBytes is the maximum number of bytes of mutations that
+	// have been passed to Apply but have not been applied yet. Apply blocks
+	// while the limit is exceeded.
+	MaxOutstandingBytes int
+}
+
+// DefaultBulkWriterSettings holds the default values for BulkWriterSettings.
+var DefaultBulkWriterSettings = BulkWriterSettings{
+	DelayThreshold:      time.Second,
+	CountThreshold:      100,
+	ByteThreshold:       20 << 20,
+	NumGoroutines:       10,
+	MaxOutstandingBytes: 100 << 20,
+}
+
+// ApplyResult is the result of a mutation that was passed to
+// BulkWriter.Apply.
+type ApplyResult struct {
+	ready chan struct{}
+	err   error
+}
+
+func newApplyResult() *ApplyResult {
+	return &ApplyResult{ready: make(chan struct{})}
+}
+
+func (r *ApplyResult) set(err error) {
+	r.err = err
+	close(r.ready)
+}
+
+// Ready returns a channel that is closed when the result is available.
+func (r *ApplyResult) Ready() <-chan struct{} { return r.ready }
+
+// Get blocks until the mutation has been applied, or it failed, or ctx is
+// done. It returns nil if the mutation was applied.
+func (r *ApplyResult) Get(ctx context.Context) error {
+	select {
+	case <-r.ready:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BulkWriter applies individual mutations in batches with MutateRows.
+// Mutations of different rows may be applied in any order. Entries that fail
+// with a retryable error are retried if they are idempotent.
+//
+// A BulkWriter is safe for concurrent use. Call Close when done.
+type BulkWriter struct {
+	t        *Table
+	ctx      context.Context
+	settings BulkWriterSettings
+	opts     []ApplyOption
+	sem      chan struct{} // limits the number of concurrent requests
+
+	mu           sync.Mutex
+	closed       bool
+	pending      []*bulkEntry
+	pendingBytes int
+	pendingMuts  int
+	timer        *time.Timer
+	// outstanding is the number of bytes of all mutations that have been
+	// accepted and not yet completed.
+	outstanding int
+	// released is closed and replaced whenever outstanding decreases.
+	released chan struct{}
+}
+
+type bulkEntry struct {
+	entry  *entryErr
+	size   int
+	result *ApplyResult
+}
+
+// NewBulkWriter returns a BulkWriter for the table. Requests are sent with
+// ctx; canceling it fails all mutations that have not been applied.
+func (t *Table) NewBulkWriter(ctx context.Context, settings BulkWriterSettings, opts ...ApplyOption) *BulkWriter {
+	d := DefaultBulkWriterSettings
+	if settings.DelayThreshold <= 0 {
+		settings.DelayThreshold = d.DelayThreshold
+	}
+	if settings.CountThreshold <= 0 {
+		settings.CountThreshold = d.CountThreshold
+	}
+	if settings.ByteThreshold <= 0 {
+		settings.ByteThreshold = d.ByteThreshold
+	}
+	if settings.NumGoroutines <= 0 {
+		settings.NumGoroutines = d.NumGoroutines
+	}
+	if settings.MaxOutstandingBytes <= 0 {
+		settings.MaxOutstandingBytes = d.MaxOutstandingBytes
+	}
+	return &BulkWriter{
+		t:        t,
+		ctx:      mergeOutgoingMetadata(ctx, t.md),
+		settings: settings,
+		opts:     opts,
+		sem:      make(chan struct{}, settings.NumGoroutines),
+		released: make(chan struct{}),
+	}
+}
+
+// Apply adds a mutation of a row to the current batch and returns a result
+// that reports whether the mutation was applied. If the writer has too many
+// outstanding bytes, Apply blocks until earlier mutations complete or ctx is
+// done.
+//
+// Conditional mutations cannot be applied in bulk and providing one will
+// result in an error.
+func (w *BulkWriter) Apply(ctx context.Context, row string, m *Mutation) *ApplyResult {
+	res := newApplyResult()
+	if m.cond != nil {
+		res.set(errors.New("conditional mutations cannot be applied in bulk"))
+		return res
+	}
+	e := &bulkEntry{
+		entry:  &entryErr{Entry: &btpb.MutateRowsRequest_Entry{RowKey: []byte(row), Mutations: m.ops}},
+		result: res,
+	}
+	e.size = proto.Size(e.entry.Entry)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Always accept an entry if nothing is outstanding, so that entries that
+	// are larger than MaxOutstandingBytes can be applied.
+	for !w.closed && w.outstanding > 0 && w.outstanding+e.size > w.settings.MaxOutstandingBytes {
+		released := w.released
+		w.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			w.mu.Lock()
+			res.set(ctx.Err())
+			return res
+		}
+		w.mu.Lock()
+	}
+	if w.closed {
+		res.set(errBulkWriterClosed)
+		return res
+	}
+	w.outstanding += e.size
+
+	if len(w.pending) > 0 && (len(w.pending)+1 > w.settings.CountThreshold ||
+		w.pendingBytes+e.size > w.settings.ByteThreshold ||
+		w.pendingMuts+len(m.ops) > maxMutations) {
+		w.sendLocked()
+	}
+	w.pending = append(w.pending, e)
+	w.pendingBytes += e.size
+	w.pendingMuts += len(m.ops)
+	if len(w.pending) >= w.settings.CountThreshold || w.pendingBytes >= w.settings.ByteThreshold {
+		w.sendLocked()
+	} else if w.timer == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(w.settings.DelayThreshold, func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if w.timer == timer {
+				w.sendLocked()
+			}
+		})
+		w.timer = timer
+	}
+	return res
+}
+
+// Flush sends all buffered mutations and blocks until all outstanding
+// mutations have completed or ctx is done.
+func (w *BulkWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	w.sendLocked()
+	w.mu.Unlock()
+	return w.wait(ctx)
+}
+
+// Close flushes the writer and waits for all outstanding mutations to
+// complete. Mutations that are applied after Close fail.
+func (w *BulkWriter) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.sendLocked()
+	// Wake up callers of Apply that wait for flow control.
+	close(w.released)
+	w.released = make(chan struct{})
+	w.mu.Unlock()
+	w.wait(context.Background())
+}
+
+// wait blocks until no mutations are outstanding.
+func (w *BulkWriter) wait(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		outstanding, released := w.outstanding, w.released
+		w.mu.Unlock()
+		if outstanding == 0 {
+			return nil
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sendLocked sends the pending entries in a new batch. It assumes w.mu is
+// locked.
+func (w *BulkWriter) sendLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if len(w.pending) == 0 {
+		return
+	}
+	batch := w.pending
+	w.pending = nil
+	w.pendingBytes = 0
+	w.pendingMuts = 0
+	go w.send(batch)
+}
+
+// send applies a batch and reports the results of its entries.
+func (w *BulkWriter) send(batch []*bulkEntry) {
+	var err error
+	select {
+	case w.sem <- struct{}{}:
+		defer func() { <-w.sem }()
+		err = w.apply(batch)
+	case <-w.ctx.Done():
+		err = w.ctx.Err()
+		for _, e := range batch {
+			e.entry.Err = err
+		}
+	}
+
+	size := 0
+	for _, e := range batch {
+		e.result.set(e.entry.Err)
+		size += e.size
+	}
+	w.mu.Lock()
+	w.outstanding -= size
+	close(w.released)
+	w.released = make(chan struct{})
+	w.mu.Unlock()
+}
+
+// apply applies the entries of a batch and stores their errors.
+func (w *BulkWriter) apply(batch []*bulkEntry) (err error) {
+	ctx := trace.StartSpan(w.ctx, "cloud.google.com/go/bigtable/BulkWriter.Apply")
+	defer func() { trace.EndSpan(ctx, err) }()
+
+	group := make([]*entryErr, len(batch))
+	for i, e := range batch {
+		group[i] = e.entry
+	}
+	unknown, err := w.t.applyGroup(ctx, group, w.opts...)
+	for _, e := range unknown {
+		if e.Err == nil {
+			e.Err = err
+		}
+	}
+	return err
+}
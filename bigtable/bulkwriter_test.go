@@ -0,0 +1,164 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigtable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBulkWriter(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mu       sync.Mutex
+		requests []int
+		failNext = true
+	)
+	tbl, cleanup, err := setupFakeServer(grpc.StreamInterceptor(countingInterceptor(&mu, &requests, &failNext)))
+	if err != nil {
+		t.Fatalf("fake server setup: %v", err)
+	}
+	defer cleanup()
+
+	w := tbl.NewBulkWriter(ctx, BulkWriterSettings{CountThreshold: 10, DelayThreshold: time.Hour})
+	var results []*ApplyResult
+	for i := 0; i < 25; i++ {
+		m := NewMutation()
+		m.Set("cf", "col", 1000, []byte("v"))
+		results = append(results, w.Apply(ctx, fmt.Sprintf("row%02d", i), m))
+	}
+	// The last five mutations are sent by Flush.
+	if err := w.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	for i, res := range results {
+		if err := res.Get(ctx); err != nil {
+			t.Errorf("mutation %d: %v", i, err)
+		}
+	}
+	// Batches are sent concurrently, and the failed one is retried.
+	mu.Lock()
+	sort.Ints(requests)
+	if got, want := fmt.Sprint(requests), "[5 10 10]"; got != want {
+		t.Errorf("batch sizes: got %s, want %s", got, want)
+	}
+	mu.Unlock()
+
+	var n int
+	if err := tbl.ReadRows(ctx, RowRange{}, func(Row) bool { n++; return true }); err != nil {
+		t.Fatalf("ReadRows: %v", err)
+	}
+	if n != 25 {
+		t.Errorf("got %d rows, want 25", n)
+	}
+
+	// Conditional mutations are rejected.
+	cond := NewCondMutation(RowKeyFilter("x"), NewMutation(), nil)
+	if err := w.Apply(ctx, "row", cond).Get(ctx); err == nil {
+		t.Error("conditional mutation: got nil, want error")
+	}
+
+	w.Close()
+	m := NewMutation()
+	m.Set("cf", "col", 1000, []byte("v"))
+	if err := w.Apply(ctx, "row", m).Get(ctx); err != errBulkWriterClosed {
+		t.Errorf("Apply after Close: got %v, want %v", err, errBulkWriterClosed)
+	}
+}
+
+// countingInterceptor records the number of entries of each MutateRows
+// request, and fails the first request if *failNext is set.
+func countingInterceptor(mu *sync.Mutex, requests *[]int, failNext *bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasSuffix(info.FullMethod, "MutateRows") {
+			return handler(srv, ss)
+		}
+		mu.Lock()
+		fail := *failNext
+		*failNext = false
+		mu.Unlock()
+		if fail {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		return handler(srv, &recordingStream{ServerStream: ss, record: func(req *btpb.MutateRowsRequest) {
+			mu.Lock()
+			*requests = append(*requests, len(req.Entries))
+			mu.Unlock()
+		}})
+	}
+}
+
+type recordingStream struct {
+	grpc.ServerStream
+	record func(*btpb.MutateRowsRequest)
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if req, ok := m.(*btpb.MutateRowsRequest); ok && err == nil {
+		s.record(req)
+	}
+	return err
+}
+
+func TestBulkWriterFlowControl(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	blocker := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.HasSuffix(info.FullMethod, "MutateRows") {
+			<-release
+		}
+		return handler(srv, ss)
+	}
+	tbl, cleanup, err := setupFakeServer(grpc.StreamInterceptor(blocker))
+	if err != nil {
+		t.Fatalf("fake server setup: %v", err)
+	}
+	defer cleanup()
+
+	w := tbl.NewBulkWriter(ctx, BulkWriterSettings{CountThreshold: 1, MaxOutstandingBytes: 1})
+	m := NewMutation()
+	m.Set("cf", "col", 1000, []byte("v"))
+	first := w.Apply(ctx, "a", m)
+
+	// The first mutation is outstanding, so the second one has to wait.
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := w.Apply(shortCtx, "b", m).Get(ctx); err != context.DeadlineExceeded {
+		t.Errorf("blocked Apply: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := first.Get(ctx); err != nil {
+		t.Fatalf("first mutation: %v", err)
+	}
+	if err := w.Apply(ctx, "b", m).Get(ctx); err != nil {
+		t.Errorf("second mutation: %v", err)
+	}
+	w.Close()
+}
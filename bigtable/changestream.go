@@ -0,0 +1,805 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigtable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/trace"
+	gax "github.com/googleapis/gax-go/v2"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ChangeStreamContinuationToken marks a position in the change stream of a
+// partition. Reading a partition from a continuation token returns the changes
+// that were committed after the position.
+type ChangeStreamContinuationToken struct {
+	Partition RowRange
+	Token     string
+}
+
+func (t ChangeStreamContinuationToken) proto() *btpb.StreamContinuationToken {
+	return &btpb.StreamContinuationToken{
+		Partition: &btpb.StreamPartition{RowRange: t.Partition.rangeProto()},
+		Token:     t.Token,
+	}
+}
+
+func continuationTokenFromProto(t *btpb.StreamContinuationToken) ChangeStreamContinuationToken {
+	return ChangeStreamContinuationToken{
+		Partition: rowRangeFromProto(t.GetPartition().GetRowRange()),
+		Token:     t.GetToken(),
+	}
+}
+
+// ChangeStreamRecord is a record that is returned by a change stream. It is
+// one of *ChangeStreamMutation, *ChangeStreamHeartbeat and
+// *ChangeStreamCloseStream.
+type ChangeStreamRecord interface {
+	isChangeStreamRecord()
+}
+
+// ChangeStreamMutationType is the origin of a ChangeStreamMutation.
+type ChangeStreamMutationType int
+
+const (
+	// ChangeStreamUserMutation is a mutation that was written by a client.
+	ChangeStreamUserMutation ChangeStreamMutationType = iota
+	// ChangeStreamGarbageCollection is a mutation that was made by garbage
+	// collection.
+	ChangeStreamGarbageCollection
+)
+
+// ChangeStreamMutation contains the modifications that were made to one row
+// in one commit.
+type ChangeStreamMutation struct {
+	// Partition is the partition that returned the mutation.
+	Partition RowRange
+	// RowKey is the key of the modified row.
+	RowKey string
+	// Type indicates whether the mutation was made by a client or by garbage
+	// collection.
+	Type ChangeStreamMutationType
+	// SourceClusterID is the cluster on which the mutation was applied. It is
+	// empty for garbage collection.
+	SourceClusterID string
+	// CommitTime is the time at which the mutation was committed.
+	CommitTime time.Time
+	// Tiebreaker orders mutations to the same row with the same CommitTime
+	// that were applied on different clusters. The mutation with the highest
+	// tiebreaker wins.
+	Tiebreaker int32
+	// Mods contains the modifications in the order in which they were
+	// applied.
+	Mods []ChangeStreamMod
+	// Token is the continuation token of the mutation in Partition.
+	Token string
+	// EstimatedLowWatermark is an estimate of the time before which all
+	// mutations of the partition have been returned.
+	EstimatedLowWatermark time.Time
+}
+
+// ChangeStreamHeartbeat is returned periodically by partitions without changes.
+// It contains the position in the change stream that can be used to resume
+// reading the partition.
+type ChangeStreamHeartbeat struct {
+	ContinuationToken     ChangeStreamContinuationToken
+	EstimatedLowWatermark time.Time
+}
+
+// ChangeStreamCloseStream is the last record of the change stream of a
+// partition. If the partition was split or merged, ContinuationTokens
+// contains the positions from which the new partitions must be read.
+type ChangeStreamCloseStream struct {
+	// Partition is the partition that was closed.
+	Partition RowRange
+	// Err is the status with which the stream was closed. It is nil if the
+	// stream reached its end time.
+	Err error
+	// ContinuationTokens are the tokens of the partitions that take over
+	// from Partition.
+	ContinuationTokens []ChangeStreamContinuationToken
+}
+
+func (*ChangeStreamMutation) isChangeStreamRecord()    {}
+func (*ChangeStreamHeartbeat) isChangeStreamRecord()   {}
+func (*ChangeStreamCloseStream) isChangeStreamRecord() {}
+
+// ChangeStreamMod is one modification of a ChangeStreamMutation. It is one of
+// *ChangeStreamSetCell, *ChangeStreamDeleteCells, *ChangeStreamDeleteFamily
+// and *ChangeStreamDeleteRow.
+type ChangeStreamMod interface {
+	isChangeStreamMod()
+}
+
+// ChangeStreamSetCell sets the value of a cell.
+type ChangeStreamSetCell struct {
+	Family    string
+	Column    string
+	Timestamp Timestamp
+	Value     []byte
+}
+
+// ChangeStreamDeleteCells deletes the cells of a column in the timestamp range
+// [Start, End). An End of zero means infinity.
+type ChangeStreamDeleteCells struct {
+	Family string
+	Column string
+	Start  Timestamp
+	End    Timestamp
+}
+
+// ChangeStreamDeleteFamily deletes all cells of a column family.
+type ChangeStreamDeleteFamily struct {
+	Family string
+}
+
+// ChangeStreamDeleteRow deletes all cells of a row.
+type ChangeStreamDeleteRow struct{}
+
+func (*ChangeStreamSetCell) isChangeStreamMod()      {}
+func (*ChangeStreamDeleteCells) isChangeStreamMod()  {}
+func (*ChangeStreamDeleteFamily) isChangeStreamMod() {}
+func (*ChangeStreamDeleteRow) isChangeStreamMod()    {}
+
+func changeStreamModFromProto(m *btpb.Mutation) (ChangeStreamMod, error) {
+	switch m := m.GetMutation().(type) {
+	case *btpb.Mutation_SetCell_:
+		return &ChangeStreamSetCell{
+			Family:    m.SetCell.FamilyName,
+			Column:    string(m.SetCell.ColumnQualifier),
+			Timestamp: Timestamp(m.SetCell.TimestampMicros),
+			Value:     append([]byte(nil), m.SetCell.Value...),
+		}, nil
+	case *btpb.Mutation_DeleteFromColumn_:
+		return &ChangeStreamDeleteCells{
+			Family: m.DeleteFromColumn.FamilyName,
+			Column: string(m.DeleteFromColumn.ColumnQualifier),
+			Start:  Timestamp(m.DeleteFromColumn.GetTimeRange().GetStartTimestampMicros()),
+			End:    Timestamp(m.DeleteFromColumn.GetTimeRange().GetEndTimestampMicros()),
+		}, nil
+	case *btpb.Mutation_DeleteFromFamily_:
+		return &ChangeStreamDeleteFamily{Family: m.DeleteFromFamily.FamilyName}, nil
+	case *btpb.Mutation_DeleteFromRow_:
+		return &ChangeStreamDeleteRow{}, nil
+	}
+	return nil, fmt.Errorf("bigtable: unknown change stream mutation %T", m.GetMutation())
+}
+
+// addChunks appends the modifications in chunks to m. Values of SetCell
+// modifications can be split across several chunks.
+func (m *ChangeStreamMutation) addChunks(chunks []*btpb.ReadChangeStreamResponse_MutationChunk) error {
+	for _, c := range chunks {
+		if info := c.ChunkInfo; info != nil && info.ChunkedValueOffset > 0 {
+			var last *ChangeStreamSetCell
+			if n := len(m.Mods); n > 0 {
+				last, _ = m.Mods[n-1].(*ChangeStreamSetCell)
+			}
+			if last == nil || int(info.ChunkedValueOffset) != len(last.Value) {
+				return fmt.Errorf("bigtable: unexpected value chunk at offset %d for row %q", info.ChunkedValueOffset, m.RowKey)
+			}
+			last.Value = append(last.Value, c.GetMutation().GetSetCell().GetValue()...)
+			continue
+		}
+		mod, err := changeStreamModFromProto(c.Mutation)
+		if err != nil {
+			return err
+		}
+		m.Mods = append(m.Mods, mod)
+	}
+	return nil
+}
+
+// ChangeStreamOption is an option for reading a change stream.
+type ChangeStreamOption interface {
+	set(settings *changeStreamSettings)
+}
+
+type changeStreamSettings struct {
+	startTime time.Time
+	endTime   time.Time
+	heartbeat time.Duration
+	tokens    []ChangeStreamContinuationToken
+}
+
+type changeStreamStartTime time.Time
+
+func (o changeStreamStartTime) set(s *changeStreamSettings) { s.startTime = time.Time(o) }
+
+// ChangeStreamStartTime returns a ChangeStreamOption that starts reading the
+// change stream at t. It is ignored if continuation tokens are given. The
+// default is the current time.
+func ChangeStreamStartTime(t time.Time) ChangeStreamOption { return changeStreamStartTime(t) }
+
+type changeStreamEndTime time.Time
+
+func (o changeStreamEndTime) set(s *changeStreamSettings) { s.endTime = time.Time(o) }
+
+// ChangeStreamEndTime returns a ChangeStreamOption that stops reading the
+// change stream at t. By default the change stream is read until the context
+// is done.
+func ChangeStreamEndTime(t time.Time) ChangeStreamOption { return changeStreamEndTime(t) }
+
+type changeStreamHeartbeat time.Duration
+
+func (o changeStreamHeartbeat) set(s *changeStreamSettings) { s.heartbeat = time.Duration(o) }
+
+// ChangeStreamHeartbeatDuration returns a ChangeStreamOption that sets the
+// interval at which partitions without changes return a heartbeat.
+func ChangeStreamHeartbeatDuration(d time.Duration) ChangeStreamOption {
+	return changeStreamHeartbeat(d)
+}
+
+type changeStreamTokens []ChangeStreamContinuationToken
+
+func (o changeStreamTokens) set(s *changeStreamSettings) { s.tokens = o }
+
+// ChangeStreamContinuationTokens returns a ChangeStreamOption that resumes
+// reading a partition from the given continuation tokens. A partition that is
+// the result of a merge must be read with the tokens of all its parents.
+func ChangeStreamContinuationTokens(tokens ...ChangeStreamContinuationToken) ChangeStreamOption {
+	return changeStreamTokens(tokens)
+}
+
+// ChangeStreamPartitions returns the partitions that together cover the change
+// stream of the table. Each partition can be read with ReadChangeStream.
+func (t *Table) ChangeStreamPartitions(ctx context.Context) ([]RowRange, error) {
+	ctx = mergeOutgoingMetadata(ctx, t.md)
+	var partitions []RowRange
+	err := gax.Invoke(ctx, func(ctx context.Context, _ gax.CallSettings) error {
+		partitions = nil
+		req := &btpb.GenerateInitialChangeStreamPartitionsRequest{
+			TableName:    t.c.fullTableName(t.table),
+			AppProfileId: t.c.appProfile,
+		}
+		ctx, cancel := context.WithCancel(ctx) // for aborting the stream
+		defer cancel()
+
+		stream, err := t.c.client.GenerateInitialChangeStreamPartitions(ctx, req)
+		if err != nil {
+			return err
+		}
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			partitions = append(partitions, rowRangeFromProto(res.GetPartition().GetRowRange()))
+		}
+	}, retryOptions...)
+	return partitions, err
+}
+
+// ReadChangeStream reads the change stream of one partition of the table and
+// calls f for each record. If f returns false, the stream is shut down and
+// ReadChangeStream returns. f is called serially in commit order.
+//
+// Transient errors are retried from the last record that was returned, so f
+// is not called twice for the same record. ReadChangeStream returns after the
+// partition has been closed, which is signalled by a *ChangeStreamCloseStream
+// record, or when the server ends the stream without closing the partition,
+// which it does periodically. Use ChangeStreamReader to follow partitions as
+// they are split and merged, and to reopen streams that were ended.
+func (t *Table) ReadChangeStream(ctx context.Context, partition RowRange, f func(ChangeStreamRecord) bool, opts ...ChangeStreamOption) (err error) {
+	ctx = mergeOutgoingMetadata(ctx, t.md)
+	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigtable.ReadChangeStream")
+	defer func() { trace.EndSpan(ctx, err) }()
+
+	var settings changeStreamSettings
+	for _, opt := range opts {
+		opt.set(&settings)
+	}
+	// lastToken is the position of the last record that was passed to f. A
+	// retry resumes from there.
+	var lastToken *btpb.StreamContinuationToken
+	err = gax.Invoke(ctx, func(ctx context.Context, _ gax.CallSettings) error {
+		req := &btpb.ReadChangeStreamRequest{
+			TableName:    t.c.fullTableName(t.table),
+			AppProfileId: t.c.appProfile,
+			Partition:    &btpb.StreamPartition{RowRange: partition.rangeProto()},
+		}
+		switch {
+		case lastToken != nil:
+			req.StartFrom = &btpb.ReadChangeStreamRequest_ContinuationTokens{
+				ContinuationTokens: &btpb.StreamContinuationTokens{Tokens: []*btpb.StreamContinuationToken{lastToken}},
+			}
+		case len(settings.tokens) > 0:
+			tokens := make([]*btpb.StreamContinuationToken, len(settings.tokens))
+			for i, tok := range settings.tokens {
+				tokens[i] = tok.proto()
+			}
+			req.StartFrom = &btpb.ReadChangeStreamRequest_ContinuationTokens{
+				ContinuationTokens: &btpb.StreamContinuationTokens{Tokens: tokens},
+			}
+		case !settings.startTime.IsZero():
+			req.StartFrom = &btpb.ReadChangeStreamRequest_StartTime{StartTime: timestamppb.New(settings.startTime)}
+		}
+		if !settings.endTime.IsZero() {
+			req.EndTime = timestamppb.New(settings.endTime)
+		}
+		if settings.heartbeat > 0 {
+			req.HeartbeatDuration = durationpb.New(settings.heartbeat)
+		}
+		ctx, cancel := context.WithCancel(ctx) // for aborting the stream
+		defer cancel()
+
+		stream, err := t.c.client.ReadChangeStream(ctx, req)
+		if err != nil {
+			return err
+		}
+		// m is the mutation that is being assembled from one or more
+		// DataChange messages.
+		var m *ChangeStreamMutation
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			var rec ChangeStreamRecord
+			switch r := res.StreamRecord.(type) {
+			case *btpb.ReadChangeStreamResponse_DataChange_:
+				dc := r.DataChange
+				if m == nil {
+					m = &ChangeStreamMutation{
+						Partition:       partition,
+						RowKey:          string(dc.RowKey),
+						SourceClusterID: dc.SourceClusterId,
+						CommitTime:      dc.CommitTimestamp.AsTime(),
+						Tiebreaker:      dc.Tiebreaker,
+					}
+					if dc.Type == btpb.ReadChangeStreamResponse_DataChange_GARBAGE_COLLECTION {
+						m.Type = ChangeStreamGarbageCollection
+					}
+				}
+				if err := m.addChunks(dc.Chunks); err != nil {
+					// No need to prepare for a retry, this is an unretryable error.
+					return err
+				}
+				if !dc.Done {
+					continue
+				}
+				m.Token = dc.Token
+				if dc.EstimatedLowWatermark != nil {
+					m.EstimatedLowWatermark = dc.EstimatedLowWatermark.AsTime()
+				}
+				lastToken = &btpb.StreamContinuationToken{Partition: req.Partition, Token: dc.Token}
+				rec, m = m, nil
+			case *btpb.ReadChangeStreamResponse_Heartbeat_:
+				hb := r.Heartbeat
+				lastToken = hb.ContinuationToken
+				rec = &ChangeStreamHeartbeat{
+					ContinuationToken:     continuationTokenFromProto(hb.ContinuationToken),
+					EstimatedLowWatermark: hb.EstimatedLowWatermark.AsTime(),
+				}
+			case *btpb.ReadChangeStreamResponse_CloseStream_:
+				cs := &ChangeStreamCloseStream{
+					Partition: partition,
+					Err:       status.ErrorProto(r.CloseStream.Status),
+				}
+				for _, tok := range r.CloseStream.ContinuationTokens {
+					cs.ContinuationTokens = append(cs.ContinuationTokens, continuationTokenFromProto(tok))
+				}
+				rec = cs
+			default:
+				continue
+			}
+			if !f(rec) {
+				// Cancel and drain stream.
+				cancel()
+				for {
+					if _, err := stream.Recv(); err != nil {
+						// The stream has ended. We don't return an error
+						// because the caller has intentionally interrupted the read.
+						return nil
+					}
+				}
+			}
+		}
+	}, retryOptions...)
+	return err
+}
+
+// A ChangeStreamReader reads the change stream of a table across all its
+// partitions. It follows partitions as they are split and merged, and keeps
+// track of the position of every partition, so that reading can be resumed
+// from a ChangeStreamCheckpoint.
+//
+// A ChangeStreamReader is safe to use concurrently.
+type ChangeStreamReader struct {
+	t    *Table
+	opts []ChangeStreamOption
+
+	mu sync.Mutex
+	// partitions contains the partitions that are being read, keyed by
+	// RowRange.String().
+	partitions map[string]*changeStreamPartition
+	// pending contains the new partitions that are waiting for all their
+	// parents to be closed.
+	pending map[string]*changeStreamPartition
+	// initialized is false until the initial partitions are known.
+	initialized bool
+	reading     bool
+}
+
+type changeStreamPartition struct {
+	partition RowRange
+	// tokens are the positions from which the partition is read. It is empty
+	// for initial partitions that are read from the start time.
+	tokens []ChangeStreamContinuationToken
+	// covered are the ranges of the parents that have been closed. A pending
+	// partition is read once its parents cover its whole range.
+	covered []RowRange
+	running bool
+}
+
+// NewChangeStreamReader returns a ChangeStreamReader for the change stream of
+// the table. ChangeStreamContinuationTokens options are ignored.
+func (t *Table) NewChangeStreamReader(opts ...ChangeStreamOption) *ChangeStreamReader {
+	return &ChangeStreamReader{
+		t:          t,
+		opts:       opts,
+		partitions: map[string]*changeStreamPartition{},
+		pending:    map[string]*changeStreamPartition{},
+	}
+}
+
+// ResumeChangeStreamReader returns a ChangeStreamReader that continues from
+// the given checkpoint. ChangeStreamStartTime and
+// ChangeStreamContinuationTokens options are ignored.
+func (t *Table) ResumeChangeStreamReader(cp *ChangeStreamCheckpoint, opts ...ChangeStreamOption) *ChangeStreamReader {
+	r := t.NewChangeStreamReader(opts...)
+	r.initialized = true
+	for _, p := range cp.partitions {
+		p := &changeStreamPartition{
+			partition: p.partition,
+			tokens:    append([]ChangeStreamContinuationToken(nil), p.tokens...),
+			covered:   append([]RowRange(nil), p.covered...),
+		}
+		if p.covered == nil {
+			r.partitions[p.partition.String()] = p
+		} else {
+			r.pending[p.partition.String()] = p
+		}
+	}
+	return r
+}
+
+// Read reads the change stream and calls f for each record until all
+// partitions have been closed, ctx is done, or f returns false. f is called
+// serially, and records of the same partition are passed in commit order.
+//
+// If Read returns an error, Checkpoint can be used to resume reading with a
+// new ChangeStreamReader. Records that were passed to f after the checkpoint
+// was taken may be passed again.
+func (r *ChangeStreamReader) Read(ctx context.Context, f func(ChangeStreamRecord) bool) (err error) {
+	r.mu.Lock()
+	if r.reading {
+		r.mu.Unlock()
+		return errors.New("bigtable: ChangeStreamReader.Read is already running")
+	}
+	r.reading = true
+	initialized := r.initialized
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.reading = false
+		for _, p := range r.partitions {
+			p.running = false
+		}
+		r.mu.Unlock()
+	}()
+
+	if !initialized {
+		partitions, err := r.t.ChangeStreamPartitions(ctx)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		for _, p := range partitions {
+			r.partitions[p.String()] = &changeStreamPartition{partition: p}
+		}
+		r.initialized = true
+		r.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		fmu      sync.Mutex // serializes calls to f
+		errOnce  sync.Once
+		firstErr error
+		stopped  bool
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	var start func()
+	start = func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for key, p := range r.partitions {
+			if p.running {
+				continue
+			}
+			p.running = true
+			wg.Add(1)
+			go func(key string, p *changeStreamPartition) {
+				defer wg.Done()
+				closed, err := r.readPartition(ctx, key, p, func(rec ChangeStreamRecord) bool {
+					fmu.Lock()
+					defer fmu.Unlock()
+					if stopped {
+						return false
+					}
+					if !f(rec) {
+						stopped = true
+						cancel()
+						return false
+					}
+					return true
+				})
+				if err != nil {
+					fail(err)
+					return
+				}
+				if closed && ctx.Err() == nil {
+					start()
+				}
+			}(key, p)
+		}
+	}
+	start()
+	wg.Wait()
+	if stopped {
+		return nil
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// readPartition reads one partition until it is closed. It reports whether
+// the partition was closed, in which case its successors have been added to
+// r.partitions or r.pending.
+//
+// The server may end a stream without closing the partition. Unless an end
+// time was requested, the partition is then read again from its last
+// position.
+func (r *ChangeStreamReader) readPartition(ctx context.Context, key string, p *changeStreamPartition, f func(ChangeStreamRecord) bool) (closed bool, err error) {
+	var settings changeStreamSettings
+	for _, opt := range r.opts {
+		opt.set(&settings)
+	}
+	for {
+		var stopped bool
+		closed, stopped, err = r.readPartitionOnce(ctx, key, p, f)
+		if err != nil || closed || stopped || !settings.endTime.IsZero() {
+			return closed, err
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+	}
+}
+
+// readPartitionOnce reads one stream of a partition. It also reports whether
+// f returned false.
+func (r *ChangeStreamReader) readPartitionOnce(ctx context.Context, key string, p *changeStreamPartition, f func(ChangeStreamRecord) bool) (closed, stopped bool, err error) {
+	r.mu.Lock()
+	opts := append([]ChangeStreamOption(nil), r.opts...)
+	if len(p.tokens) > 0 {
+		opts = append(opts, ChangeStreamContinuationTokens(p.tokens...))
+	}
+	r.mu.Unlock()
+
+	var closeErr error
+	err = r.t.ReadChangeStream(ctx, p.partition, func(rec ChangeStreamRecord) bool {
+		cs, isClose := rec.(*ChangeStreamCloseStream)
+		if isClose && cs.Err != nil && len(cs.ContinuationTokens) == 0 {
+			closeErr = cs.Err
+		}
+		if !f(rec) {
+			stopped = true
+			return false
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		switch rec := rec.(type) {
+		case *ChangeStreamMutation:
+			p.tokens = []ChangeStreamContinuationToken{{Partition: p.partition, Token: rec.Token}}
+		case *ChangeStreamHeartbeat:
+			p.tokens = []ChangeStreamContinuationToken{rec.ContinuationToken}
+		case *ChangeStreamCloseStream:
+			if closeErr != nil {
+				return true
+			}
+			delete(r.partitions, key)
+			for _, tok := range rec.ContinuationTokens {
+				r.addSuccessorLocked(p.partition, tok)
+			}
+			closed = true
+		}
+		return true
+	}, opts...)
+	if err != nil {
+		return false, stopped, err
+	}
+	if closeErr != nil {
+		return false, stopped, closeErr
+	}
+	return closed, stopped, nil
+}
+
+// addSuccessorLocked records that the closed parent partition continues in
+// the partition of tok. The new partition is read once it is covered by the
+// ranges of its closed parents. r.mu must be held.
+func (r *ChangeStreamReader) addSuccessorLocked(parent RowRange, tok ChangeStreamContinuationToken) {
+	key := tok.Partition.String()
+	p, ok := r.pending[key]
+	if !ok {
+		p = &changeStreamPartition{partition: tok.Partition}
+		r.pending[key] = p
+	}
+	p.tokens = append(p.tokens, tok)
+	p.covered = append(p.covered, parent)
+	if rangesCover(p.covered, p.partition) {
+		delete(r.pending, key)
+		p.covered = nil
+		r.partitions[key] = p
+	}
+}
+
+// rangesCover reports whether the union of ranges contains all rows of target.
+func rangesCover(ranges []RowRange, target RowRange) bool {
+	sorted := append([]RowRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	pos := target.start
+	for _, rr := range sorted {
+		if rr.start > pos {
+			return false
+		}
+		if rr.Unbounded() {
+			return true
+		}
+		if rr.limit > pos {
+			pos = rr.limit
+		}
+		if !target.Unbounded() && pos >= target.limit {
+			return true
+		}
+	}
+	return false
+}
+
+// Checkpoint returns the current position of the reader in the change stream.
+// It can be called while Read is running.
+func (r *ChangeStreamReader) Checkpoint() *ChangeStreamCheckpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := &ChangeStreamCheckpoint{}
+	for _, m := range []map[string]*changeStreamPartition{r.partitions, r.pending} {
+		for _, p := range m {
+			cp.partitions = append(cp.partitions, &changeStreamPartition{
+				partition: p.partition,
+				tokens:    append([]ChangeStreamContinuationToken(nil), p.tokens...),
+				covered:   append([]RowRange(nil), p.covered...),
+			})
+		}
+	}
+	sort.Slice(cp.partitions, func(i, j int) bool { return cp.partitions[i].partition.start < cp.partitions[j].partition.start })
+	return cp
+}
+
+// A ChangeStreamCheckpoint is the position of a ChangeStreamReader in a change
+// stream. Use Table.ResumeChangeStreamReader to continue reading from it.
+//
+// A ChangeStreamCheckpoint can be stored with MarshalBinary and restored with
+// UnmarshalBinary.
+type ChangeStreamCheckpoint struct {
+	partitions []*changeStreamPartition
+}
+
+// Partitions returns the partitions that are read or waiting to be read when
+// reading is resumed from the checkpoint.
+func (cp *ChangeStreamCheckpoint) Partitions() []RowRange {
+	var rs []RowRange
+	for _, p := range cp.partitions {
+		rs = append(rs, p.partition)
+	}
+	return rs
+}
+
+type checkpointRange struct {
+	Start []byte
+	Limit []byte
+}
+
+type checkpointToken struct {
+	Partition checkpointRange
+	Token     string
+}
+
+type checkpointPartition struct {
+	Partition checkpointRange
+	Tokens    []checkpointToken
+	Covered   []checkpointRange
+}
+
+func toCheckpointRange(r RowRange) checkpointRange {
+	return checkpointRange{Start: []byte(r.start), Limit: []byte(r.limit)}
+}
+
+func (r checkpointRange) rowRange() RowRange {
+	return NewRange(string(r.Start), string(r.Limit))
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (cp *ChangeStreamCheckpoint) MarshalBinary() ([]byte, error) {
+	var ps []checkpointPartition
+	for _, p := range cp.partitions {
+		c := checkpointPartition{Partition: toCheckpointRange(p.partition)}
+		for _, t := range p.tokens {
+			c.Tokens = append(c.Tokens, checkpointToken{Partition: toCheckpointRange(t.Partition), Token: t.Token})
+		}
+		for _, r := range p.covered {
+			c.Covered = append(c.Covered, toCheckpointRange(r))
+		}
+		ps = append(ps, c)
+	}
+	return json.Marshal(ps)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (cp *ChangeStreamCheckpoint) UnmarshalBinary(data []byte) error {
+	var ps []checkpointPartition
+	if err := json.Unmarshal(data, &ps); err != nil {
+		return fmt.Errorf("bigtable: invalid change stream checkpoint: %v", err)
+	}
+	cp.partitions = nil
+	for _, c := range ps {
+		p := &changeStreamPartition{partition: c.Partition.rowRange()}
+		for _, t := range c.Tokens {
+			p.tokens = append(p.tokens, ChangeStreamContinuationToken{Partition: t.Partition.rowRange(), Token: t.Token})
+		}
+		for _, r := range c.Covered {
+			p.covered = append(p.covered, r.rowRange())
+		}
+		cp.partitions = append(cp.partitions, p)
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigtable
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+	rpcpb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// failAfterStream fails a server stream after it has sent n messages.
+type failAfterStream struct {
+	grpc.ServerStream
+	n int
+}
+
+func (s *failAfterStream) SendMsg(m interface{}) error {
+	if s.n == 0 {
+		return status.Error(codes.Unavailable, "stream broken")
+	}
+	s.n--
+	return s.ServerStream.SendMsg(m)
+}
+
+func TestReadChangeStream(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		reqs []*btpb.ReadChangeStreamRequest
+	)
+	// Break the first change stream after one record.
+	errInjector := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasSuffix(info.FullMethod, "ReadChangeStream") {
+			return handler(srv, ss)
+		}
+		mu.Lock()
+		first := len(reqs) == 0
+		mu.Unlock()
+		recorder := &requestRecorder{ServerStream: ss, record: func(m interface{}) {
+			mu.Lock()
+			reqs = append(reqs, m.(*btpb.ReadChangeStreamRequest))
+			mu.Unlock()
+		}}
+		if first {
+			return handler(srv, &failAfterStream{ServerStream: recorder, n: 1})
+		}
+		return handler(srv, recorder)
+	}
+	tbl, cleanup, err := setupFakeServer(grpc.StreamInterceptor(errInjector))
+	if err != nil {
+		t.Fatalf("fake server setup: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+	for _, row := range []string{"row1", "row2"} {
+		mut := NewMutation()
+		mut.Set("cf", "col", 1000, []byte(row))
+		if err := tbl.Apply(ctx, row, mut); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	mut := NewMutation()
+	mut.DeleteCellsInColumn("cf", "col")
+	if err := tbl.Apply(ctx, "row1", mut); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	end := time.Now()
+
+	partitions, err := tbl.ChangeStreamPartitions(ctx)
+	if err != nil {
+		t.Fatalf("ChangeStreamPartitions: %v", err)
+	}
+	if got, want := len(partitions), 1; got != want {
+		t.Fatalf("got %d partitions, want %d", got, want)
+	}
+	var got []*ChangeStreamMutation
+	var closed *ChangeStreamCloseStream
+	err = tbl.ReadChangeStream(ctx, partitions[0], func(rec ChangeStreamRecord) bool {
+		switch rec := rec.(type) {
+		case *ChangeStreamMutation:
+			got = append(got, rec)
+		case *ChangeStreamCloseStream:
+			closed = rec
+		}
+		return true
+	}, ChangeStreamStartTime(start), ChangeStreamEndTime(end))
+	if err != nil {
+		t.Fatalf("ReadChangeStream: %v", err)
+	}
+	if closed == nil || closed.Err != nil {
+		t.Fatalf("stream was not closed without error: %v", closed)
+	}
+	var keys []string
+	for _, m := range got {
+		keys = append(keys, m.RowKey)
+	}
+	if want := []string{"row1", "row2", "row1"}; !cmp.Equal(keys, want) {
+		t.Fatalf("row keys: got %v, want %v", keys, want)
+	}
+	wantMods := []ChangeStreamMod{&ChangeStreamSetCell{Family: "cf", Column: "col", Timestamp: 1000, Value: []byte("row1")}}
+	if diff := cmp.Diff(got[0].Mods, wantMods); diff != "" {
+		t.Errorf("mods mismatch (-got +want):\n%s", diff)
+	}
+	wantMods = []ChangeStreamMod{&ChangeStreamDeleteCells{Family: "cf", Column: "col"}}
+	if diff := cmp.Diff(got[2].Mods, wantMods); diff != "" {
+		t.Errorf("mods mismatch (-got +want):\n%s", diff)
+	}
+
+	if got, want := len(reqs), 2; got != want {
+		t.Fatalf("got %d requests, want %d", got, want)
+	}
+	tokens := reqs[1].GetContinuationTokens().GetTokens()
+	if len(tokens) != 1 || tokens[0].Token != got[0].Token {
+		t.Errorf("retry did not resume from the first record: %v", reqs[1].StartFrom)
+	}
+}
+
+// errEndStream is returned by endAfterStream to end a stream successfully.
+var errEndStream = errors.New("end stream")
+
+// endAfterStream ends a server stream after it has sent n messages.
+type endAfterStream struct {
+	grpc.ServerStream
+	n int
+}
+
+func (s *endAfterStream) SendMsg(m interface{}) error {
+	if s.n == 0 {
+		return errEndStream
+	}
+	s.n--
+	return s.ServerStream.SendMsg(m)
+}
+
+func TestChangeStreamReaderReopensEndedStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var (
+		mu   sync.Mutex
+		reqs []*btpb.ReadChangeStreamRequest
+	)
+	// End the first change stream after one record, without a CloseStream.
+	ender := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasSuffix(info.FullMethod, "ReadChangeStream") {
+			return handler(srv, ss)
+		}
+		mu.Lock()
+		first := len(reqs) == 0
+		mu.Unlock()
+		recorder := &requestRecorder{ServerStream: ss, record: func(m interface{}) {
+			mu.Lock()
+			reqs = append(reqs, m.(*btpb.ReadChangeStreamRequest))
+			mu.Unlock()
+		}}
+		if !first {
+			return handler(srv, recorder)
+		}
+		if err := handler(srv, &endAfterStream{ServerStream: recorder, n: 1}); err != errEndStream {
+			return err
+		}
+		return nil
+	}
+	tbl, cleanup, err := setupFakeServer(grpc.StreamInterceptor(ender))
+	if err != nil {
+		t.Fatalf("fake server setup: %v", err)
+	}
+	defer cleanup()
+
+	start := time.Now()
+	for _, row := range []string{"row1", "row2"} {
+		mut := NewMutation()
+		mut.Set("cf", "col", 1000, []byte(row))
+		if err := tbl.Apply(ctx, row, mut); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+
+	var (
+		keys   []string
+		tokens []string
+	)
+	err = tbl.NewChangeStreamReader(ChangeStreamStartTime(start)).Read(ctx, func(rec ChangeStreamRecord) bool {
+		if m, ok := rec.(*ChangeStreamMutation); ok {
+			keys = append(keys, m.RowKey)
+			tokens = append(tokens, m.Token)
+		}
+		return len(keys) < 2
+	})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := []string{"row1", "row2"}; !cmp.Equal(keys, want) {
+		t.Fatalf("row keys: got %v, want %v", keys, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := len(reqs), 2; got != want {
+		t.Fatalf("got %d requests, want %d", got, want)
+	}
+	got := reqs[1].GetContinuationTokens().GetTokens()
+	if len(got) != 1 || got[0].Token != tokens[0] {
+		t.Errorf("reopened stream did not resume from the first record: %v", reqs[1].StartFrom)
+	}
+}
+
+type requestRecorder struct {
+	grpc.ServerStream
+	record func(m interface{})
+}
+
+func (s *requestRecorder) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.record(m)
+	return nil
+}
+
+// mergingServer serves two initial partitions that are merged into one.
+type mergingServer struct {
+	btpb.UnimplementedBigtableServer
+
+	mu    sync.Mutex
+	reads map[string][]string // tokens by partition
+}
+
+var (
+	leftPartition   = NewRange("", "m")
+	rightPartition  = InfiniteRange("m")
+	mergedPartition = InfiniteRange("")
+)
+
+func (s *mergingServer) GenerateInitialChangeStreamPartitions(req *btpb.GenerateInitialChangeStreamPartitionsRequest, stream btpb.Bigtable_GenerateInitialChangeStreamPartitionsServer) error {
+	for _, p := range []RowRange{leftPartition, rightPartition} {
+		if err := stream.Send(&btpb.GenerateInitialChangeStreamPartitionsResponse{Partition: &btpb.StreamPartition{RowRange: p.rangeProto()}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mergingServer) ReadChangeStream(req *btpb.ReadChangeStreamRequest, stream btpb.Bigtable_ReadChangeStreamServer) error {
+	partition := rowRangeFromProto(req.Partition.RowRange)
+	var tokens []string
+	for _, tok := range req.GetContinuationTokens().GetTokens() {
+		tokens = append(tokens, tok.Token)
+	}
+	s.mu.Lock()
+	s.reads[partition.String()] = tokens
+	s.mu.Unlock()
+
+	key := partition.start
+	if key == "" {
+		key = "a"
+	}
+	dataChange := &btpb.ReadChangeStreamResponse{StreamRecord: &btpb.ReadChangeStreamResponse_DataChange_{DataChange: &btpb.ReadChangeStreamResponse_DataChange{
+		RowKey:          []byte(key),
+		CommitTimestamp: timestamppb.Now(),
+		Chunks: []*btpb.ReadChangeStreamResponse_MutationChunk{
+			{
+				ChunkInfo: &btpb.ReadChangeStreamResponse_MutationChunk_ChunkInfo{ChunkedValueSize: 6},
+				Mutation: &btpb.Mutation{Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+					FamilyName: "cf", ColumnQualifier: []byte("col"), Value: []byte("val"),
+				}}},
+			},
+		},
+		Token: partition.String() + "-1",
+	}}}
+	// The value of the cell is split across two messages.
+	valueRest := &btpb.ReadChangeStreamResponse{StreamRecord: &btpb.ReadChangeStreamResponse_DataChange_{DataChange: &btpb.ReadChangeStreamResponse_DataChange{
+		Type: btpb.ReadChangeStreamResponse_DataChange_CONTINUATION,
+		Chunks: []*btpb.ReadChangeStreamResponse_MutationChunk{
+			{
+				ChunkInfo: &btpb.ReadChangeStreamResponse_MutationChunk_ChunkInfo{ChunkedValueSize: 6, ChunkedValueOffset: 3, LastChunk: true},
+				Mutation: &btpb.Mutation{Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+					FamilyName: "cf", ColumnQualifier: []byte("col"), Value: []byte("ue!"),
+				}}},
+			},
+		},
+		Done:  true,
+		Token: partition.String() + "-1",
+	}}}
+	closeStream := &btpb.ReadChangeStreamResponse_CloseStream{Status: &rpcpb.Status{Code: int32(codes.OK)}}
+	if partition != mergedPartition {
+		closeStream.Status.Code = int32(codes.OutOfRange)
+		closeStream.ContinuationTokens = []*btpb.StreamContinuationToken{{
+			Partition: &btpb.StreamPartition{RowRange: mergedPartition.rangeProto()},
+			Token:     partition.String() + "-2",
+		}}
+	}
+	for _, res := range []*btpb.ReadChangeStreamResponse{
+		dataChange,
+		valueRest,
+		{StreamRecord: &btpb.ReadChangeStreamResponse_CloseStream_{CloseStream: closeStream}},
+	} {
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestChangeStreamReaderFollowsMerge(t *testing.T) {
+	ctx := context.Background()
+	fake := &mergingServer{reads: map[string][]string{}}
+	srv := grpc.NewServer()
+	btpb.RegisterBigtableServer(srv, fake)
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(ctx, "client", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	tbl := client.Open("table")
+
+	r := tbl.NewChangeStreamReader()
+	var (
+		values     = map[string]string{}
+		checkpoint []byte
+	)
+	err = r.Read(ctx, func(rec ChangeStreamRecord) bool {
+		switch rec := rec.(type) {
+		case *ChangeStreamMutation:
+			values[rec.Partition.String()] = string(rec.Mods[0].(*ChangeStreamSetCell).Value)
+		case *ChangeStreamCloseStream:
+			if rec.Partition == leftPartition && checkpoint == nil {
+				b, err := r.Checkpoint().MarshalBinary()
+				if err != nil {
+					t.Errorf("MarshalBinary: %v", err)
+				}
+				checkpoint = b
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := map[string]string{
+		leftPartition.String():   "value!",
+		rightPartition.String():  "value!",
+		mergedPartition.String(): "value!",
+	}
+	if diff := cmp.Diff(values, want); diff != "" {
+		t.Errorf("values mismatch (-got +want):\n%s", diff)
+	}
+	gotTokens := fake.reads[mergedPartition.String()]
+	if len(gotTokens) != 2 {
+		t.Errorf("merged partition was read with tokens %v, want the tokens of both parents", gotTokens)
+	}
+	if got := r.Checkpoint().Partitions(); len(got) != 0 {
+		t.Errorf("checkpoint after reading all partitions: got %v, want none", got)
+	}
+
+	// The checkpoint was taken before the left partition was closed, so
+	// resuming reads the left partition from its last token.
+	var cp ChangeStreamCheckpoint
+	if err := cp.UnmarshalBinary(checkpoint); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	fake.reads = map[string][]string{}
+	if err := tbl.ResumeChangeStreamReader(&cp).Read(ctx, func(ChangeStreamRecord) bool { return true }); err != nil {
+		t.Fatalf("Read after resume: %v", err)
+	}
+	if got, want := fake.reads[leftPartition.String()], []string{leftPartition.String() + "-1"}; !cmp.Equal(got, want) {
+		t.Errorf("resumed tokens: got %v, want %v", got, want)
+	}
+}
+
+func TestRangesCover(t *testing.T) {
+	for _, test := range []struct {
+		ranges []RowRange
+		target RowRange
+		want   bool
+	}{
+		{[]RowRange{InfiniteRange("")}, NewRange("a", "b"), true},
+		{[]RowRange{NewRange("", "m")}, InfiniteRange(""), false},
+		{[]RowRange{InfiniteRange("m"), NewRange("", "m")}, InfiniteRange(""), true},
+		{[]RowRange{NewRange("a", "c"), NewRange("d", "f")}, NewRange("a", "f"), false},
+		{[]RowRange{NewRange("a", "d"), NewRange("c", "f")}, NewRange("b", "e"), true},
+	} {
+		if got := rangesCover(test.ranges, test.target); got != test.want {
+			t.Errorf("rangesCover(%v, %v) = %v, want %v", test.ranges, test.target, got, test.want)
+		}
+	}
+}
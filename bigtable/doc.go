@@ -48,6 +48,15 @@ To read a single row, use the ReadRow helper method:
 	}
 	// TODO: use r.
 
+The ReverseScan option returns rows in descending row key order. To scan a
+large portion of a table faster, ParallelReadRows splits the rows at the keys
+returned by SampleRowKeys and reads the parts concurrently:
+
+	err = tbl.ParallelReadRows(ctx, bigtable.InfiniteRange(""), func(r Row) bool {
+		// TODO: do something with r.
+		return true // Keep going.
+	}, bigtable.ParallelReadConfig{Parallelism: 8, Ordered: true})
+
 # Writing
 
 This API exposes two distinct forms of writing to a Bigtable: a Mutation and a
@@ -86,6 +95,37 @@ To increment an encoded value in one cell:
 	}
 	// TODO: use r.
 
+To write many rows, a BulkWriter batches individual mutations into MutateRows
+requests and limits the number of outstanding bytes:
+
+	w := tbl.NewBulkWriter(ctx, bigtable.BulkWriterSettings{})
+	res := w.Apply(ctx, "com.google.cloud", mut)
+	w.Close()
+	if err := res.Get(ctx); err != nil {
+		// TODO: handle err.
+	}
+
+# Change streams
+
+A table with a change stream records every mutation that is applied to it.
+ChangeStreamReader reads the change stream across all partitions of the table,
+follows partitions as they are split and merged, and can be resumed from a
+ChangeStreamCheckpoint:
+
+	r := tbl.NewChangeStreamReader(bigtable.ChangeStreamStartTime(start))
+	err := r.Read(ctx, func(rec bigtable.ChangeStreamRecord) bool {
+		if m, ok := rec.(*bigtable.ChangeStreamMutation); ok {
+			// TODO: do something with m.
+		}
+		return true // Keep going.
+	})
+	if err != nil {
+		// TODO: handle err, and resume from r.Checkpoint().
+	}
+
+Use ChangeStreamPartitions and ReadChangeStream to distribute the partitions
+of a change stream across several processes.
+
 # Retries
 
 If a read or write operation encounters a transient error it will be retried
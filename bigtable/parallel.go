@@ -0,0 +1,304 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigtable
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+
+	"cloud.google.com/go/internal/trace"
+	btpb "google.golang.org/genproto/googleapis/bigtable/v2"
+)
+
+// orderedBufferSize is the number of rows that are buffered for each partition
+// that is read ahead of the partition that is being delivered.
+const orderedBufferSize = 100
+
+// ParallelReadConfig configures Table.ParallelReadRows.
+type ParallelReadConfig struct {
+	// Parallelism is the maximum number of concurrent ReadRows calls.
+	// If zero, runtime.GOMAXPROCS(0) is used.
+	Parallelism int
+
+	// Ordered delivers rows in row key order, or in descending row key order
+	// if ReverseScan is used. Partitions are still read concurrently, and rows
+	// of later partitions are buffered until they can be delivered.
+	// If false, rows are delivered in the order in which they are received.
+	Ordered bool
+}
+
+// ParallelReadRows reads the rows of arg with several concurrent ReadRows
+// calls. It splits arg into partitions at the row keys returned by
+// SampleRowKeys, and reads up to config.Parallelism partitions at once.
+//
+// f is called serially. If f returns false, all reads are stopped and
+// ParallelReadRows returns. If LimitRows is used, it limits the total number
+// of rows that are passed to f.
+func (t *Table) ParallelReadRows(ctx context.Context, arg RowSet, f func(Row) bool, config ParallelReadConfig, opts ...ReadOption) (err error) {
+	ctx = trace.StartSpan(ctx, "cloud.google.com/go/bigtable.ParallelReadRows")
+	defer func() { trace.EndSpan(ctx, err) }()
+
+	if !arg.valid() {
+		return nil
+	}
+	splitKeys, err := t.SampleRowKeys(ctx)
+	if err != nil {
+		return err
+	}
+	parts := splitRowSet(arg, splitKeys)
+
+	settings := makeReadSettings(&btpb.ReadRowsRequest{})
+	for _, opt := range opts {
+		opt.set(&settings)
+	}
+	if settings.req.Reversed {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+	parallelism := config.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	// deliver passes rows to f and enforces the row limit across partitions.
+	var (
+		mu        sync.Mutex
+		delivered int64
+		stopped   bool
+	)
+	deliver := func(r Row) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return false
+		}
+		if !f(r) {
+			stopped = true
+			return false
+		}
+		delivered++
+		if limit := settings.req.RowsLimit; limit > 0 && delivered >= limit {
+			stopped = true
+			return false
+		}
+		return true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if config.Ordered {
+		err = t.readPartsOrdered(ctx, parts, parallelism, deliver, opts)
+	} else {
+		err = t.readPartsUnordered(ctx, parts, parallelism, deliver, opts)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		// The caller or the row limit interrupted the scan.
+		return nil
+	}
+	return err
+}
+
+// readPartsUnordered reads the parts with a pool of workers and passes rows to
+// deliver as they are received.
+func (t *Table) readPartsUnordered(ctx context.Context, parts []RowSet, parallelism int, deliver func(Row) bool, opts []ReadOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan RowSet)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < parallelism && i < len(parts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range work {
+				err := t.ReadRows(ctx, part, func(r Row) bool {
+					if !deliver(r) {
+						cancel()
+						return false
+					}
+					return true
+				}, opts...)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+loop:
+	for _, part := range parts {
+		select {
+		case work <- part:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// readPartsOrdered reads up to parallelism parts concurrently and passes their
+// rows to deliver one part after the other.
+func (t *Table) readPartsOrdered(ctx context.Context, parts []RowSet, parallelism int, deliver func(Row) bool, opts []ReadOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type partResult struct {
+		rows chan Row
+		err  error // valid once rows is closed
+	}
+	results := make([]*partResult, len(parts))
+	for i := range results {
+		results[i] = &partResult{rows: make(chan Row, orderedBufferSize)}
+	}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	// Parts acquire a slot in order, so that the part that is being delivered
+	// is always being read.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, part := range parts {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				for _, res := range results[i:] {
+					res.err = ctx.Err()
+					close(res.rows)
+				}
+				return
+			}
+			wg.Add(1)
+			go func(part RowSet, res *partResult) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				res.err = t.ReadRows(ctx, part, func(r Row) bool {
+					select {
+					case res.rows <- r:
+						return true
+					case <-ctx.Done():
+						return false
+					}
+				}, opts...)
+				close(res.rows)
+			}(part, results[i])
+		}
+	}()
+	defer wg.Wait()
+
+	for _, res := range results {
+		for r := range res.rows {
+			if !deliver(r) {
+				cancel()
+				return nil
+			}
+		}
+		if res.err != nil {
+			cancel()
+			return res.err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitRowSet splits arg at the given row keys. The returned row sets are
+// valid, disjoint, and ordered by row key. Together they contain the same rows
+// as arg.
+func splitRowSet(arg RowSet, splitKeys []string) []RowSet {
+	keys := append([]string(nil), splitKeys...)
+	sort.Strings(keys)
+	var segments []RowRange
+	start := ""
+	for _, k := range keys {
+		if k == "" || k == start {
+			continue
+		}
+		segments = append(segments, NewRange(start, k))
+		start = k
+	}
+	segments = append(segments, InfiniteRange(start))
+
+	var parts []RowSet
+	for _, seg := range segments {
+		if part := restrictRowSet(arg, seg); part != nil && part.valid() {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// restrictRowSet returns the rows of arg that are contained in seg.
+func restrictRowSet(arg RowSet, seg RowRange) RowSet {
+	switch arg := arg.(type) {
+	case RowList:
+		var keys RowList
+		for _, k := range arg {
+			if seg.Contains(k) {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	case RowRange:
+		return intersectRanges(arg, seg)
+	case RowRangeList:
+		var ranges RowRangeList
+		for _, rr := range arg {
+			if r := intersectRanges(rr, seg); r.valid() {
+				ranges = append(ranges, r)
+			}
+		}
+		if len(ranges) == 1 {
+			return ranges[0]
+		}
+		return ranges
+	}
+	// Unknown row sets are read as a single part.
+	if seg.start == "" {
+		return arg
+	}
+	return nil
+}
+
+// intersectRanges returns the rows that are contained in both a and b.
+func intersectRanges(a, b RowRange) RowRange {
+	r := a
+	if b.start > r.start {
+		r.start = b.start
+	}
+	if !b.Unbounded() && (r.Unbounded() || b.limit < r.limit) {
+		r.limit = b.limit
+	}
+	return r
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bigtable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/internal/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitRowSet(t *testing.T) {
+	splitKeys := []string{"m", "", "f", "m"}
+	for _, test := range []struct {
+		arg  RowSet
+		want []RowSet
+	}{
+		{
+			arg:  InfiniteRange(""),
+			want: []RowSet{NewRange("", "f"), NewRange("f", "m"), InfiniteRange("m")},
+		},
+		{
+			arg:  NewRange("c", "h"),
+			want: []RowSet{NewRange("c", "f"), NewRange("f", "h")},
+		},
+		{
+			arg:  RowList{"a", "g", "h", "z"},
+			want: []RowSet{RowList{"a"}, RowList{"g", "h"}, RowList{"z"}},
+		},
+		{
+			arg: RowRangeList{NewRange("a", "b"), NewRange("e", "g"), NewRange("x", "z")},
+			want: []RowSet{
+				RowRangeList{NewRange("a", "b"), NewRange("e", "f")},
+				NewRange("f", "g"),
+				NewRange("x", "z"),
+			},
+		},
+	} {
+		got := splitRowSet(test.arg, splitKeys)
+		if !testutil.Equal(got, test.want, cmp.AllowUnexported(RowRange{})) {
+			t.Errorf("splitRowSet(%v): got %v, want %v", test.arg, got, test.want)
+		}
+	}
+}
+
+func TestParallelReadRows(t *testing.T) {
+	ctx := context.Background()
+	tbl, cleanup, err := setupFakeServer()
+	if err != nil {
+		t.Fatalf("fake server setup: %v", err)
+	}
+	defer cleanup()
+
+	var keys []string
+	var muts []*Mutation
+	for i := 0; i < 500; i++ {
+		keys = append(keys, fmt.Sprintf("row%03d", i))
+		mut := NewMutation()
+		mut.Set("cf", "col", 1000, []byte("v"))
+		muts = append(muts, mut)
+	}
+	errs, err := tbl.ApplyBulk(ctx, keys, muts)
+	if err != nil || errs != nil {
+		t.Fatalf("ApplyBulk: %v %v", err, errs)
+	}
+	reversed := make([]string, len(keys))
+	for i, k := range keys {
+		reversed[len(keys)-1-i] = k
+	}
+
+	for _, test := range []struct {
+		desc    string
+		ordered bool
+		opts    []ReadOption
+		want    []string
+	}{
+		{desc: "ordered", ordered: true, want: keys},
+		{desc: "reversed", ordered: true, opts: []ReadOption{ReverseScan()}, want: reversed},
+		{desc: "unordered", want: keys},
+		{desc: "limit", ordered: true, opts: []ReadOption{LimitRows(42)}, want: keys[:42]},
+	} {
+		var (
+			mu  sync.Mutex
+			got []string
+		)
+		err := tbl.ParallelReadRows(ctx, RowRange{}, func(r Row) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, r.Key())
+			return true
+		}, ParallelReadConfig{Parallelism: 4, Ordered: test.ordered}, test.opts...)
+		if err != nil {
+			t.Fatalf("%s: ParallelReadRows: %v", test.desc, err)
+		}
+		if !test.ordered {
+			sort.Strings(got)
+		}
+		if !testutil.Equal(got, test.want) {
+			t.Errorf("%s: got %d rows %v, want %d rows", test.desc, len(got), got, len(test.want))
+		}
+	}
+
+	// Stopping early returns without an error.
+	var n int
+	err = tbl.ParallelReadRows(ctx, RowRange{}, func(r Row) bool {
+		n++
+		return n < 10
+	}, ParallelReadConfig{Parallelism: 4})
+	if err != nil {
+		t.Fatalf("ParallelReadRows stopped early: %v", err)
+	}
+	if n != 10 {
+		t.Errorf("ParallelReadRows stopped early: got %d rows, want 10", n)
+	}
+}
@@ -67,6 +67,8 @@ type chunkReader struct {
 	curVal    []byte
 	curRow    Row
 	lastKey   string
+	// reversed is true if rows are expected in descending key order.
+	reversed bool
 }
 
 // newChunkReader returns a new chunkReader for handling read rows responses.
@@ -200,8 +202,13 @@ func (cr *chunkReader) validateNewRow(cc *btpb.ReadRowsResponse_CellChunk) error
 	if cc.RowKey == nil || cc.FamilyName == nil || cc.Qualifier == nil {
 		return fmt.Errorf("missing key field for new row %v", cc)
 	}
-	if cr.lastKey != "" && cr.lastKey >= string(cc.RowKey) {
-		return fmt.Errorf("out of order row key: %q, %q", cr.lastKey, string(cc.RowKey))
+	if cr.lastKey != "" {
+		if !cr.reversed && cr.lastKey >= string(cc.RowKey) {
+			return fmt.Errorf("out of order row key: %q, %q", cr.lastKey, string(cc.RowKey))
+		}
+		if cr.reversed && cr.lastKey <= string(cc.RowKey) {
+			return fmt.Errorf("out of order row key in reverse scan: %q, %q", cr.lastKey, string(cc.RowKey))
+		}
 	}
 	return nil
 }
@@ -368,6 +368,32 @@ func TestRetainRowsAfter(t *testing.T) {
 	}
 }
 
+func TestRetainRowsBefore(t *testing.T) {
+	prevRowRange := NewRange("a", "z")
+	prevRowKey := "m"
+	want := NewRange("a", "m")
+	got := prevRowRange.retainRowsBefore(prevRowKey)
+	if !testutil.Equal(want, got, cmp.AllowUnexported(RowRange{})) {
+		t.Errorf("range retry: got %v, want %v", got, want)
+	}
+
+	prevRowRangeList := RowRangeList{NewRange("a", "d"), NewRange("e", "g"), NewRange("h", "l")}
+	prevRowKey = "f"
+	wantRowRangeList := RowRangeList{NewRange("a", "d"), NewRange("e", "f")}
+	got = prevRowRangeList.retainRowsBefore(prevRowKey)
+	if !testutil.Equal(wantRowRangeList, got, cmp.AllowUnexported(RowRange{})) {
+		t.Errorf("range list retry: got %v, want %v", got, wantRowRangeList)
+	}
+
+	prevRowList := RowList{"a", "b", "c", "d", "e", "f"}
+	prevRowKey = "e"
+	wantList := RowList{"a", "b", "c", "d"}
+	got = prevRowList.retainRowsBefore(prevRowKey)
+	if !testutil.Equal(wantList, got) {
+		t.Errorf("list retry: got %v, want %v", got, wantList)
+	}
+}
+
 func TestRetryReadRows(t *testing.T) {
 	ctx := context.Background()
 
@@ -430,6 +456,52 @@ func TestRetryReadRows(t *testing.T) {
 	}
 }
 
+func TestRetryReadRowsReversed(t *testing.T) {
+	ctx := context.Background()
+
+	errCount := 0
+	errInjector := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasSuffix(info.FullMethod, "ReadRows") {
+			return handler(ctx, ss)
+		}
+		req := new(btpb.ReadRowsRequest)
+		must(ss.RecvMsg(req))
+		if !req.Reversed {
+			t.Errorf("request %d is not reversed", errCount)
+		}
+		var err error
+		switch errCount {
+		case 0:
+			// Write two rows then error
+			must(writeReadRowsResponse(ss, "y", "x"))
+			err = status.Errorf(codes.Unavailable, "")
+		case 1:
+			if want, got := "x", string(req.Rows.RowRanges[0].GetEndKeyOpen()); want != got {
+				t.Errorf("reversed retry: got end key %q, want %q", got, want)
+			}
+			must(writeReadRowsResponse(ss, "c", "b"))
+		}
+		errCount++
+		return err
+	}
+
+	tbl, cleanup, err := setupFakeServer(grpc.StreamInterceptor(errInjector))
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("fake server setup: %v", err)
+	}
+
+	var got []string
+	must(tbl.ReadRows(ctx, NewRange("a", "z"), func(r Row) bool {
+		got = append(got, r.Key())
+		return true
+	}, ReverseScan()))
+	want := []string{"y", "x", "c", "b"}
+	if !testutil.Equal(got, want) {
+		t.Errorf("reversed retry: got %v, want %v", got, want)
+	}
+}
+
 func writeReadRowsResponse(ss grpc.ServerStream, rowKeys ...string) error {
 	var chunks []*btpb.ReadRowsResponse_CellChunk
 	for _, key := range rowKeys {
@@ -95,6 +95,65 @@ func (d Date) DaysSince(s Date) (days int) {
 	return int(deltaUnix / 86400)
 }
 
+// AddMonths returns the date that is n months in the future.
+// n can also be negative to go into the past.
+//
+// Unlike time.Time.AddDate, AddMonths does not normalize a day that is out of
+// range for the resulting month: it uses the last day of that month instead.
+// For example, one month after January 31 is the last day of February.
+func (d Date) AddMonths(n int) Date {
+	// Work from the first day of the month, so that AddDate does not
+	// normalize the day into the following month.
+	t := time.Date(d.Year, d.Month, 1, 0, 0, 0, 0, time.UTC).AddDate(0, n, 0)
+	r := DateOf(t)
+	r.Day = d.Day
+	if last := daysIn(r.Year, r.Month); r.Day > last {
+		r.Day = last
+	}
+	return r
+}
+
+// AddYears returns the date that is n years in the future.
+// n can also be negative to go into the past.
+//
+// As with AddMonths, a day that is out of range for the resulting month is
+// replaced by the last day of that month, so one year after February 29 is
+// February 28.
+func (d Date) AddYears(n int) Date {
+	return d.AddMonths(12 * n)
+}
+
+// daysIn returns the number of days in a month of a year.
+func daysIn(year int, month time.Month) int {
+	// Day 0 of the next month is the last day of this one.
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// Weekday returns the day of the week of the date.
+func (d Date) Weekday() time.Weekday {
+	return d.In(time.UTC).Weekday()
+}
+
+// ISOWeek returns the ISO 8601 year and week number in which the date
+// occurs. Week ranges from 1 to 53. Jan 01 to Jan 03 of year n might belong
+// to week 52 or 53 of year n-1, and Dec 29 to Dec 31 might belong to week 1
+// of year n+1.
+func (d Date) ISOWeek() (year, week int) {
+	return d.In(time.UTC).ISOWeek()
+}
+
+// Compare compares d and d2. If d is before d2, it returns -1; if d is after
+// d2, it returns +1; if they're the same, it returns 0.
+func (d Date) Compare(d2 Date) int {
+	switch {
+	case d.Before(d2):
+		return -1
+	case d.After(d2):
+		return +1
+	}
+	return 0
+}
+
 // Before reports whether d occurs before d2.
 func (d Date) Before(d2 Date) bool {
 	if d.Year != d2.Year {
@@ -130,6 +189,45 @@ func (d *Date) UnmarshalText(data []byte) error {
 	return err
 }
 
+// A DateRange represents the dates from Start to End, including both.
+// The range is empty if End is before Start.
+type DateRange struct {
+	Start Date
+	End   Date
+}
+
+// Contains reports whether d is in the range.
+func (r DateRange) Contains(d Date) bool {
+	return !d.Before(r.Start) && !d.After(r.End)
+}
+
+// Len returns the number of dates in the range.
+func (r DateRange) Len() int {
+	if r.End.Before(r.Start) {
+		return 0
+	}
+	return r.End.DaysSince(r.Start) + 1
+}
+
+// Dates returns the dates in the range, in order.
+func (r DateRange) Dates() []Date {
+	var ds []Date
+	r.Do(func(d Date) bool {
+		ds = append(ds, d)
+		return true
+	})
+	return ds
+}
+
+// Do calls f for each date in the range, in order, until f returns false.
+func (r DateRange) Do(f func(Date) bool) {
+	for d := r.Start; !d.After(r.End); d = d.AddDays(1) {
+		if !f(d) {
+			return
+		}
+	}
+}
+
 // A Time represents a time with nanosecond precision.
 //
 // This type does not include location information, and therefore does not
@@ -209,6 +307,38 @@ func (t Time) After(t2 Time) bool {
 	return t2.Before(t)
 }
 
+// Compare compares t and t2. If t is before t2, it returns -1; if t is after
+// t2, it returns +1; if they're the same, it returns 0.
+func (t Time) Compare(t2 Time) int {
+	switch {
+	case t.Before(t2):
+		return -1
+	case t.After(t2):
+		return +1
+	}
+	return 0
+}
+
+// Add returns the time of day that is d later than t, wrapping around
+// midnight. d can also be negative to go back in time. For example, adding
+// two hours to 23:00:00 gives 01:00:00.
+func (t Time) Add(d time.Duration) Time {
+	const day = 24 * time.Hour
+	since := time.Duration(t.Hour)*time.Hour + time.Duration(t.Minute)*time.Minute +
+		time.Duration(t.Second)*time.Second + time.Duration(t.Nanosecond)
+	// Reduce d first, so that the sum cannot overflow.
+	since = (since + d%day) % day
+	if since < 0 {
+		since += day
+	}
+	return Time{
+		Hour:       int(since / time.Hour),
+		Minute:     int(since % time.Hour / time.Minute),
+		Second:     int(since % time.Minute / time.Second),
+		Nanosecond: int(since % time.Second),
+	}
+}
+
 // MarshalText implements the encoding.TextMarshaler interface.
 // The output is the result of t.String().
 func (t Time) MarshalText() ([]byte, error) {
@@ -232,7 +362,8 @@ type DateTime struct {
 	Time Time
 }
 
-// Note: We deliberately do not embed Date into DateTime, to avoid promoting AddDays and Sub.
+// Note: We deliberately do not embed Date into DateTime, to avoid promoting
+// AddDays, AddMonths and the comparison methods of Date.
 
 // DateTimeOf returns the DateTime in which a time occurs in that time's location.
 func DateTimeOf(t time.Time) DateTime {
@@ -302,6 +433,28 @@ func (dt DateTime) After(dt2 DateTime) bool {
 	return dt2.Before(dt)
 }
 
+// Compare compares dt and dt2. If dt is before dt2, it returns -1; if dt is
+// after dt2, it returns +1; if they're the same, it returns 0.
+func (dt DateTime) Compare(dt2 DateTime) int {
+	if c := dt.Date.Compare(dt2.Date); c != 0 {
+		return c
+	}
+	return dt.Time.Compare(dt2.Time)
+}
+
+// Add returns the datetime dt+d. Since civil days have exactly 24 hours,
+// adding 24 hours always gives the same time on the next day.
+func (dt DateTime) Add(d time.Duration) DateTime {
+	return DateTimeOf(dt.In(time.UTC).Add(d))
+}
+
+// Sub returns the duration dt-dt2. If the result exceeds the maximum (or
+// minimum) value that can be stored in a Duration, the maximum (or minimum)
+// duration will be returned.
+func (dt DateTime) Sub(dt2 DateTime) time.Duration {
+	return dt.In(time.UTC).Sub(dt2.In(time.UTC))
+}
+
 // IsZero reports whether datetime fields are set to their default value.
 func (dt DateTime) IsZero() bool {
 	return dt.Date.IsZero() && dt.Time.IsZero()
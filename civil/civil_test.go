@@ -15,6 +15,8 @@
 package civil
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"testing"
 	"time"
@@ -532,3 +534,233 @@ func TestUnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestDateAddMonths(t *testing.T) {
+	for _, test := range []struct {
+		start  Date
+		months int
+		want   Date
+	}{
+		{Date{2014, 5, 9}, 0, Date{2014, 5, 9}},
+		{Date{2014, 5, 9}, 1, Date{2014, 6, 9}},
+		{Date{2014, 11, 30}, 2, Date{2015, 1, 30}},
+		{Date{2015, 1, 31}, 1, Date{2015, 2, 28}},
+		{Date{2016, 1, 31}, 1, Date{2016, 2, 29}},
+		{Date{2016, 3, 31}, -1, Date{2016, 2, 29}},
+		{Date{2016, 3, 31}, 1, Date{2016, 4, 30}},
+		{Date{2015, 1, 15}, -13, Date{2013, 12, 15}},
+	} {
+		if got := test.start.AddMonths(test.months); got != test.want {
+			t.Errorf("%v.AddMonths(%d) = %v, want %v", test.start, test.months, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		start Date
+		years int
+		want  Date
+	}{
+		{Date{2014, 5, 9}, 1, Date{2015, 5, 9}},
+		{Date{2016, 2, 29}, 1, Date{2017, 2, 28}},
+		{Date{2016, 2, 29}, 4, Date{2020, 2, 29}},
+		{Date{2016, 2, 29}, -1, Date{2015, 2, 28}},
+	} {
+		if got := test.start.AddYears(test.years); got != test.want {
+			t.Errorf("%v.AddYears(%d) = %v, want %v", test.start, test.years, got, test.want)
+		}
+	}
+}
+
+func TestDateWeekday(t *testing.T) {
+	for _, test := range []struct {
+		date    Date
+		weekday time.Weekday
+		isoYear int
+		isoWeek int
+	}{
+		{Date{2023, 1, 1}, time.Sunday, 2022, 52},
+		{Date{2023, 1, 2}, time.Monday, 2023, 1},
+		{Date{2020, 12, 31}, time.Thursday, 2020, 53},
+		{Date{2024, 12, 30}, time.Monday, 2025, 1},
+	} {
+		if got := test.date.Weekday(); got != test.weekday {
+			t.Errorf("%v.Weekday() = %v, want %v", test.date, got, test.weekday)
+		}
+		if y, w := test.date.ISOWeek(); y != test.isoYear || w != test.isoWeek {
+			t.Errorf("%v.ISOWeek() = %d, %d, want %d, %d", test.date, y, w, test.isoYear, test.isoWeek)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	d1, d2 := Date{2016, 12, 31}, Date{2017, 1, 1}
+	t1, t2 := Time{5, 6, 7, 8}, Time{5, 6, 7, 9}
+	for _, test := range []struct {
+		desc string
+		got  int
+		want int
+	}{
+		{"date before", d1.Compare(d2), -1},
+		{"date after", d2.Compare(d1), +1},
+		{"date equal", d1.Compare(d1), 0},
+		{"time before", t1.Compare(t2), -1},
+		{"time after", t2.Compare(t1), +1},
+		{"time equal", t1.Compare(t1), 0},
+		{"datetime date before", DateTime{d1, t2}.Compare(DateTime{d2, t1}), -1},
+		{"datetime time after", DateTime{d1, t2}.Compare(DateTime{d1, t1}), +1},
+		{"datetime equal", DateTime{d1, t1}.Compare(DateTime{d1, t1}), 0},
+	} {
+		if test.got != test.want {
+			t.Errorf("%s: got %d, want %d", test.desc, test.got, test.want)
+		}
+	}
+}
+
+func TestDateRange(t *testing.T) {
+	r := DateRange{Date{2016, 2, 27}, Date{2016, 3, 1}}
+	want := []Date{{2016, 2, 27}, {2016, 2, 28}, {2016, 2, 29}, {2016, 3, 1}}
+	if got := r.Dates(); !cmp.Equal(got, want) {
+		t.Errorf("%v.Dates() = %v, want %v", r, got, want)
+	}
+	if got := r.Len(); got != len(want) {
+		t.Errorf("%v.Len() = %d, want %d", r, got, len(want))
+	}
+	for _, test := range []struct {
+		date Date
+		want bool
+	}{
+		{Date{2016, 2, 26}, false},
+		{Date{2016, 2, 27}, true},
+		{Date{2016, 2, 29}, true},
+		{Date{2016, 3, 1}, true},
+		{Date{2016, 3, 2}, false},
+	} {
+		if got := r.Contains(test.date); got != test.want {
+			t.Errorf("%v.Contains(%v) = %t, want %t", r, test.date, got, test.want)
+		}
+	}
+
+	var n int
+	r.Do(func(Date) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("Do: got %d calls, want 2", n)
+	}
+
+	empty := DateRange{Date{2016, 3, 1}, Date{2016, 2, 27}}
+	if got := empty.Len(); got != 0 {
+		t.Errorf("%v.Len() = %d, want 0", empty, got)
+	}
+	if got := empty.Dates(); got != nil {
+		t.Errorf("%v.Dates() = %v, want nil", empty, got)
+	}
+}
+
+func TestTimeAdd(t *testing.T) {
+	for _, test := range []struct {
+		start Time
+		d     time.Duration
+		want  Time
+	}{
+		{Time{5, 6, 7, 8}, 0, Time{5, 6, 7, 8}},
+		{Time{5, 6, 7, 8}, time.Hour + time.Nanosecond, Time{6, 6, 7, 9}},
+		{Time{23, 0, 0, 0}, 2 * time.Hour, Time{1, 0, 0, 0}},
+		{Time{1, 0, 0, 0}, -2 * time.Hour, Time{23, 0, 0, 0}},
+		{Time{12, 0, 0, 0}, 48*time.Hour + time.Second, Time{12, 0, 1, 0}},
+		{Time{0, 0, 0, 0}, -time.Nanosecond, Time{23, 59, 59, 999999999}},
+	} {
+		got := test.start.Add(test.d)
+		if got != test.want || !got.IsValid() {
+			t.Errorf("%v.Add(%v) = %v, want %v", test.start, test.d, got, test.want)
+		}
+	}
+}
+
+func TestDateTimeArithmetic(t *testing.T) {
+	for _, test := range []struct {
+		start DateTime
+		d     time.Duration
+		end   DateTime
+	}{
+		{
+			DateTime{Date{2016, 3, 12}, Time{12, 0, 0, 0}},
+			24 * time.Hour,
+			DateTime{Date{2016, 3, 13}, Time{12, 0, 0, 0}},
+		},
+		{
+			DateTime{Date{2016, 12, 31}, Time{23, 59, 59, 0}},
+			time.Second,
+			DateTime{Date{2017, 1, 1}, Time{0, 0, 0, 0}},
+		},
+		{
+			DateTime{Date{2016, 3, 1}, Time{0, 30, 0, 0}},
+			-time.Hour,
+			DateTime{Date{2016, 2, 29}, Time{23, 30, 0, 0}},
+		},
+	} {
+		if got := test.start.Add(test.d); got != test.end {
+			t.Errorf("%v.Add(%v) = %v, want %v", test.start, test.d, got, test.end)
+		}
+		if got := test.end.Sub(test.start); got != test.d {
+			t.Errorf("%v.Sub(%v) = %v, want %v", test.end, test.start, got, test.d)
+		}
+	}
+}
+
+func TestSQL(t *testing.T) {
+	var _ driver.Valuer = Date{}
+	var _ sql.Scanner = &Date{}
+
+	d := Date{1987, 4, 15}
+	tm := Time{18, 54, 2, 500}
+	dt := DateTime{d, tm}
+	for _, test := range []struct {
+		value driver.Valuer
+		want  string
+	}{
+		{d, "1987-04-15"},
+		{tm, "18:54:02.000000500"},
+		{dt, "1987-04-15T18:54:02.000000500"},
+	} {
+		got, err := test.value.Value()
+		if err != nil || got != test.want {
+			t.Errorf("%#v.Value() = %v, %v, want %q, nil", test.value, got, err, test.want)
+		}
+	}
+
+	loc := time.FixedZone("UTC+2", 2*3600)
+	for _, test := range []struct {
+		src  interface{}
+		ptr  sql.Scanner
+		want interface{}
+	}{
+		{"1987-04-15", &Date{}, &d},
+		{[]byte("1987-04-15"), &Date{}, &d},
+		{time.Date(1987, 4, 15, 23, 0, 0, 0, loc), &Date{}, &d},
+		{"18:54:02.0000005", &Time{}, &tm},
+		{[]byte("18:54:02.0000005"), &Time{}, &tm},
+		{time.Date(1, 1, 1, 18, 54, 2, 500, loc), &Time{}, &tm},
+		{"1987-04-15T18:54:02.0000005", &DateTime{}, &dt},
+		{"1987-04-15 18:54:02.0000005", &DateTime{}, &dt},
+		{[]byte("1987-04-15 18:54:02.0000005"), &DateTime{}, &dt},
+		{time.Date(1987, 4, 15, 18, 54, 2, 500, loc), &DateTime{}, &dt},
+	} {
+		if err := test.ptr.Scan(test.src); err != nil {
+			t.Errorf("Scan(%#v): %v", test.src, err)
+			continue
+		}
+		if !cmp.Equal(test.ptr, test.want) {
+			t.Errorf("Scan(%#v): got %#v, want %#v", test.src, test.ptr, test.want)
+		}
+	}
+
+	for _, bad := range []interface{}{nil, int64(1), "bad", []byte("1987-04-15x")} {
+		for _, ptr := range []sql.Scanner{&Date{}, &Time{}, &DateTime{}} {
+			if ptr.Scan(bad) == nil {
+				t.Errorf("%T.Scan(%#v): got nil, want error", ptr, bad)
+			}
+		}
+	}
+}
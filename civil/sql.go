@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package civil
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Value implements the database/sql/driver.Valuer interface.
+// The value is the result of d.String().
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan implements the database/sql.Scanner interface. It accepts a
+// time.Time, whose date is taken in its location, or a string or []byte in a
+// format accepted by ParseDate.
+func (d *Date) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case time.Time:
+		*d = DateOf(src)
+		return nil
+	case string:
+		return d.UnmarshalText([]byte(src))
+	case []byte:
+		return d.UnmarshalText(src)
+	}
+	return scanError(src, "Date")
+}
+
+// Value implements the database/sql/driver.Valuer interface.
+// The value is the result of t.String().
+func (t Time) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
+// Scan implements the database/sql.Scanner interface. It accepts a
+// time.Time, whose time of day is taken in its location, or a string or
+// []byte in a format accepted by ParseTime.
+func (t *Time) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case time.Time:
+		*t = TimeOf(src)
+		return nil
+	case string:
+		return t.UnmarshalText([]byte(src))
+	case []byte:
+		return t.UnmarshalText(src)
+	}
+	return scanError(src, "Time")
+}
+
+// Value implements the database/sql/driver.Valuer interface.
+// The value is the result of dt.String().
+func (dt DateTime) Value() (driver.Value, error) {
+	return dt.String(), nil
+}
+
+// Scan implements the database/sql.Scanner interface. It accepts a
+// time.Time, which is taken in its location, or a string or []byte in a
+// format accepted by ParseDateTime. Since many databases separate the date
+// and the time with a space, a space is also accepted in place of the 'T'.
+func (dt *DateTime) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case time.Time:
+		*dt = DateTimeOf(src)
+		return nil
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	default:
+		return scanError(src, "DateTime")
+	}
+	if len(s) > 10 && s[10] == ' ' {
+		s = s[:10] + "T" + s[11:]
+	}
+	var err error
+	*dt, err = ParseDateTime(s)
+	return err
+}
+
+func scanError(src interface{}, typ string) error {
+	if src == nil {
+		return fmt.Errorf("civil: cannot scan NULL into %s", typ)
+	}
+	return fmt.Errorf("civil: cannot scan %T into %s", src, typ)
+}
@@ -26,6 +26,7 @@ import (
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -34,6 +35,7 @@ import (
 	debuglet "cloud.google.com/go/cmd/go-cloud-debug-agent/internal/controller"
 	"cloud.google.com/go/cmd/go-cloud-debug-agent/internal/debug"
 	"cloud.google.com/go/cmd/go-cloud-debug-agent/internal/debug/local"
+	"cloud.google.com/go/cmd/go-cloud-debug-agent/internal/localcontroller"
 	"cloud.google.com/go/cmd/go-cloud-debug-agent/internal/valuecollector"
 	"cloud.google.com/go/compute/metadata"
 	"golang.org/x/oauth2"
@@ -51,8 +53,22 @@ var (
 	projectID = flag.String("projectid", "", "Project ID."+
 		"  If this is not set, it is read from the GCP metadata server.")
 	serviceAccountFile = flag.String("serviceaccountfile", "", "File containing JSON service account credentials.")
+	localMode          = flag.Bool("local", false, "Run without the Cloud Debugger service."+
+		"  Breakpoints are read from -breakpointsfile or set through the -listen address,"+
+		" and snapshots are written to -snapshotdir.")
+	breakpointsFile = flag.String("breakpointsfile", "", "In local mode, file containing a JSON array of breakpoints.")
+	snapshotDir     = flag.String("snapshotdir", ".", "In local mode, directory to which JSON snapshots are written.")
+	listenAddr      = flag.String("listen", "", "In local mode, optional address on which to serve an HTTP endpoint"+
+		" to set breakpoints and read snapshots, as in localhost:8090.")
 )
 
+// breakpointController is implemented by the Debuglet Controller client and
+// by the local controller.
+type breakpointController interface {
+	List(ctx context.Context) (*cd.ListActiveBreakpointsResponse, error)
+	Update(ctx context.Context, breakpointID string, bp *cd.Breakpoint) error
+}
+
 const (
 	maxCapturedStackFrames = 50
 	maxCapturedVariables   = 1000
@@ -67,6 +83,55 @@ func main() {
 		flag.Usage()
 		return
 	}
+	ctx := context.Background()
+	var c breakpointController
+	if *localMode {
+		c = newLocalController()
+	} else {
+		c = newCloudController(ctx)
+	}
+	prog, err := local.New(args[0])
+	if err != nil {
+		log.Fatal("Error loading program: ", err)
+	}
+	// Load the program, but don't actually start it running yet.
+	if _, err = prog.Run(args[1:]...); err != nil {
+		log.Fatal("Error loading program: ", err)
+	}
+	bs := breakpoints.NewBreakpointStore(prog)
+
+	// Seed the random number generator.
+	rand.Seed(time.Now().UnixNano())
+
+	// Now we want to do two things: run the user's program, and start sending
+	// List requests periodically to the Debuglet Controller to get breakpoints
+	// to set.
+	//
+	// We want to give the Debuglet Controller a chance to give us breakpoints
+	// before we start the program, otherwise we would miss any breakpoint
+	// triggers that occur during program startup -- for example, a breakpoint on
+	// the first line of main. But if the Debuglet Controller is not responding or
+	// is returning errors, we don't want to delay starting the program
+	// indefinitely.
+	//
+	// We pass a channel to breakpointListLoop, which will close it when the first
+	// List call finishes.  Then we wait until either the channel is closed or a
+	// 5-second timer has finished before starting the program.
+	ch := make(chan bool)
+	// Start a goroutine that sends List requests to the Debuglet Controller, and
+	// sets any breakpoints it gets back.
+	go breakpointListLoop(ctx, c, bs, ch)
+	// Wait until 5 seconds have passed or breakpointListLoop has closed ch.
+	select {
+	case <-time.After(5 * time.Second):
+	case <-ch:
+	}
+	// Run the debuggee.
+	programLoop(ctx, c, bs, prog)
+}
+
+// newCloudController returns a client of the Debuglet Controller service.
+func newCloudController(ctx context.Context) *debuglet.Controller {
 	if *projectNumber == "" {
 		var err error
 		*projectNumber, err = metadata.NumericProjectID()
@@ -86,7 +151,6 @@ func main() {
 		log.Print("Reading source context file: ", err)
 	}
 	var ts oauth2.TokenSource
-	ctx := context.Background()
 	if *serviceAccountFile != "" {
 		if ts, err = serviceAcctTokenSource(ctx, *serviceAccountFile, cd.CloudDebuggerScope); err != nil {
 			log.Fatalf("Error getting credentials from file %s: %v", *serviceAccountFile, err)
@@ -107,44 +171,29 @@ func main() {
 	if err != nil {
 		log.Fatal("Error connecting to Cloud Debugger: ", err)
 	}
-	prog, err := local.New(args[0])
-	if err != nil {
-		log.Fatal("Error loading program: ", err)
+	return c
+}
+
+// newLocalController returns a controller that reads breakpoints from a file
+// or an HTTP endpoint, and writes snapshots to a directory.
+func newLocalController() *localcontroller.Controller {
+	if *breakpointsFile == "" && *listenAddr == "" {
+		log.Fatal("Local mode needs -breakpointsfile or -listen")
 	}
-	// Load the program, but don't actually start it running yet.
-	if _, err = prog.Run(args[1:]...); err != nil {
-		log.Fatal("Error loading program: ", err)
+	c, err := localcontroller.NewController(localcontroller.Options{
+		BreakpointsFile: *breakpointsFile,
+		SnapshotDir:     *snapshotDir,
+		Verbose:         *verbose,
+	})
+	if err != nil {
+		log.Fatal("Error starting local controller: ", err)
 	}
-	bs := breakpoints.NewBreakpointStore(prog)
-
-	// Seed the random number generator.
-	rand.Seed(time.Now().UnixNano())
-
-	// Now we want to do two things: run the user's program, and start sending
-	// List requests periodically to the Debuglet Controller to get breakpoints
-	// to set.
-	//
-	// We want to give the Debuglet Controller a chance to give us breakpoints
-	// before we start the program, otherwise we would miss any breakpoint
-	// triggers that occur during program startup -- for example, a breakpoint on
-	// the first line of main. But if the Debuglet Controller is not responding or
-	// is returning errors, we don't want to delay starting the program
-	// indefinitely.
-	//
-	// We pass a channel to breakpointListLoop, which will close it when the first
-	// List call finishes.  Then we wait until either the channel is closed or a
-	// 5-second timer has finished before starting the program.
-	ch := make(chan bool)
-	// Start a goroutine that sends List requests to the Debuglet Controller, and
-	// sets any breakpoints it gets back.
-	go breakpointListLoop(ctx, c, bs, ch)
-	// Wait until 5 seconds have passed or breakpointListLoop has closed ch.
-	select {
-	case <-time.After(5 * time.Second):
-	case <-ch:
+	if *listenAddr != "" {
+		go func() {
+			log.Fatal(http.ListenAndServe(*listenAddr, c))
+		}()
 	}
-	// Run the debuggee.
-	programLoop(ctx, c, bs, prog)
+	return c
 }
 
 // usage prints a usage message to stderr and exits.
@@ -184,7 +233,7 @@ func readSourceContextFile(filename string) ([]*cd.SourceContext, error) {
 // in the program.
 //
 // After the first List call finishes, ch is closed.
-func breakpointListLoop(ctx context.Context, c *debuglet.Controller, bs *breakpoints.BreakpointStore, first chan bool) {
+func breakpointListLoop(ctx context.Context, c breakpointController, bs *breakpoints.BreakpointStore, first chan bool) {
 	const (
 		avgTimeBetweenCalls = time.Second
 		errorDelay          = 5 * time.Second
@@ -250,7 +299,7 @@ func breakpointListLoop(ctx context.Context, c *debuglet.Controller, bs *breakpo
 // programLoop runs the program being debugged to completion.  When a breakpoint's
 // conditions are satisfied, it sends an Update RPC to the Debuglet Controller.
 // The function returns when the program exits and all Update RPCs have finished.
-func programLoop(ctx context.Context, c *debuglet.Controller, bs *breakpoints.BreakpointStore, prog debug.Program) {
+func programLoop(ctx context.Context, c breakpointController, bs *breakpoints.BreakpointStore, prog debug.Program) {
 	var wg sync.WaitGroup
 	for {
 		// Run the program until it hits a breakpoint or exits.
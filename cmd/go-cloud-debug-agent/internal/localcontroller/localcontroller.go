@@ -0,0 +1,433 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package localcontroller is a replacement for the Debuglet Controller
+// service that works without any cloud service. Breakpoints are read from a
+// local file, or set through an HTTP endpoint served by the agent, and the
+// captured snapshots are written as JSON files to a directory.
+//
+// Breakpoints and snapshots use the JSON encoding of the Breakpoint resource
+// of the Cloud Debugger API, for example
+//
+//	{
+//	  "id": "b1",
+//	  "location": {"path": "main.go", "line": 42},
+//	  "condition": "n > 10",
+//	  "expressions": ["req.URL"]
+//	}
+//
+// The HTTP endpoint, served by Controller.ServeHTTP, supports:
+//
+//	GET    /breakpoints        lists the active breakpoints
+//	POST   /breakpoints        adds the breakpoint in the request body
+//	DELETE /breakpoints/<id>   removes a breakpoint
+//	GET    /snapshots/<id>     returns the snapshot of a breakpoint
+//
+// Breakpoint IDs must not be reused: once a breakpoint has been hit or
+// removed, a breakpoint with the same ID is ignored by the agent.
+package localcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/cmd/go-cloud-debug-agent/internal/controller"
+	cd "google.golang.org/api/clouddebugger/v2"
+)
+
+var (
+	// listTimeout is how long List waits for the list of breakpoints to
+	// change before returning controller.ErrListUnchanged.
+	listTimeout = 30 * time.Second
+	// filePollInterval is how often List checks the breakpoints file for
+	// changes.
+	filePollInterval = time.Second
+	// nowFunc is stubbed to be overrideable for testing.
+	nowFunc = time.Now
+)
+
+// Options configures a Controller.
+type Options struct {
+	// BreakpointsFile, if not empty, is a file containing a JSON array of
+	// breakpoints. The file is read again when it changes; breakpoints that
+	// are removed from it are removed from the program.
+	BreakpointsFile string
+
+	// SnapshotDir is the directory to which snapshots are written, as
+	// <breakpoint ID>.json. It is created if needed.
+	SnapshotDir string
+
+	// Verbose determines whether to log the changes to the breakpoints.
+	Verbose bool
+}
+
+// Controller provides breakpoints to the agent, and receives their updates,
+// with the List and Update methods of a controller.Controller.
+type Controller struct {
+	opts Options
+
+	mu          sync.Mutex
+	fileBps     map[string]*cd.Breakpoint // from the breakpoints file
+	fileModTime time.Time
+	fileSize    int64
+	httpBps     map[string]*cd.Breakpoint // added through the HTTP endpoint
+	removed     map[string]bool           // reported as final to the agent
+	final       map[string]bool           // have a snapshot, or an error
+	nextID      int
+	version     int           // incremented when the list of breakpoints changes
+	listed      int           // version last returned by List
+	changed     chan struct{} // closed when the list of breakpoints changes
+	mux         *http.ServeMux
+}
+
+// NewController returns a Controller with the given options. It returns an
+// error if the breakpoints file cannot be read, or the snapshot directory
+// cannot be created.
+func NewController(o Options) (*Controller, error) {
+	if o.SnapshotDir == "" {
+		o.SnapshotDir = "."
+	}
+	if err := os.MkdirAll(o.SnapshotDir, 0755); err != nil {
+		return nil, err
+	}
+	c := &Controller{
+		opts:    o,
+		fileBps: make(map[string]*cd.Breakpoint),
+		httpBps: make(map[string]*cd.Breakpoint),
+		removed: make(map[string]bool),
+		final:   make(map[string]bool),
+		version: 1,
+		changed: make(chan struct{}),
+	}
+	if err := c.reloadFile(); err != nil {
+		return nil, err
+	}
+	c.mux = http.NewServeMux()
+	c.mux.HandleFunc("/breakpoints", c.handleBreakpoints)
+	c.mux.HandleFunc("/breakpoints/", c.handleBreakpoint)
+	c.mux.HandleFunc("/snapshots/", c.handleSnapshot)
+	return c, nil
+}
+
+// List returns the current list of breakpoints, including the breakpoints
+// that have been removed, with IsFinalState set. If the list has not changed
+// since the previous call, List waits for it to change, and returns
+// controller.ErrListUnchanged if it does not change in time.
+func (c *Controller) List(ctx context.Context) (*cd.ListActiveBreakpointsResponse, error) {
+	timeout := time.NewTimer(listTimeout)
+	defer timeout.Stop()
+	for {
+		if err := c.reloadFile(); err != nil {
+			log.Printf("Reading breakpoints file: %v", err)
+		}
+		c.mu.Lock()
+		if c.version != c.listed {
+			c.listed = c.version
+			resp := &cd.ListActiveBreakpointsResponse{Breakpoints: c.breakpointsLocked(true)}
+			c.mu.Unlock()
+			return resp, nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(filePollInterval):
+		case <-timeout.C:
+			return nil, controller.ErrListUnchanged
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Update writes the state of a breakpoint to the snapshot directory. If the
+// breakpoint is in its final state, because it has been hit or could not be
+// set, it is no longer active.
+func (c *Controller) Update(_ context.Context, breakpointID string, bp *cd.Breakpoint) error {
+	if bp.IsFinalState && bp.FinalTime == "" {
+		bp.FinalTime = nowFunc().UTC().Format(time.RFC3339Nano)
+	}
+	data, err := json.MarshalIndent(bp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(c.snapshotPath(breakpointID), data); err != nil {
+		return err
+	}
+	if bp.IsFinalState {
+		c.mu.Lock()
+		c.final[breakpointID] = true
+		c.mu.Unlock()
+	}
+	if c.opts.Verbose {
+		log.Printf("Wrote snapshot of breakpoint %s to %s", breakpointID, c.snapshotPath(breakpointID))
+	}
+	return nil
+}
+
+// snapshotPath returns the path of the snapshot of a breakpoint.
+func (c *Controller) snapshotPath(id string) string {
+	// IDs are chosen by users, so keep them from naming other files.
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, id)
+	return filepath.Join(c.opts.SnapshotDir, name+".json")
+}
+
+// writeFile writes a file atomically, so that readers never see a partial
+// snapshot.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// breakpointsLocked returns copies of the active breakpoints, sorted by ID,
+// and if withRemoved is true, the removed breakpoints with IsFinalState
+// set. c.mu must be held.
+func (c *Controller) breakpointsLocked(withRemoved bool) []*cd.Breakpoint {
+	bps := make(map[string]*cd.Breakpoint)
+	for id, bp := range c.fileBps {
+		bps[id] = bp
+	}
+	for id, bp := range c.httpBps {
+		bps[id] = bp
+	}
+	var res []*cd.Breakpoint
+	for id, bp := range bps {
+		if c.removed[id] || c.final[id] {
+			continue
+		}
+		// The agent modifies the breakpoints it is given.
+		bpCopy := *bp
+		res = append(res, &bpCopy)
+	}
+	if withRemoved {
+		for id := range c.removed {
+			res = append(res, &cd.Breakpoint{Id: id, IsFinalState: true})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res
+}
+
+// changedLocked records a change of the list of breakpoints. c.mu must be
+// held.
+func (c *Controller) changedLocked() {
+	c.version++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// reloadFile reads the breakpoints file if it has changed since it was last
+// read.
+func (c *Controller) reloadFile() error {
+	if c.opts.BreakpointsFile == "" {
+		return nil
+	}
+	fi, err := os.Stat(c.opts.BreakpointsFile)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	unchanged := fi.ModTime().Equal(c.fileModTime) && fi.Size() == c.fileSize
+	// Even if the file is invalid, don't read it again until it changes.
+	c.fileModTime, c.fileSize = fi.ModTime(), fi.Size()
+	c.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	data, err := os.ReadFile(c.opts.BreakpointsFile)
+	if err != nil {
+		return err
+	}
+	var list []*cd.Breakpoint
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("parsing %s: %v", c.opts.BreakpointsFile, err)
+	}
+	bps := make(map[string]*cd.Breakpoint)
+	for i, bp := range list {
+		if err := validate(bp); err != nil {
+			return fmt.Errorf("%s: breakpoint %d: %v", c.opts.BreakpointsFile, i, err)
+		}
+		if bps[bp.Id] != nil {
+			return fmt.Errorf("%s: duplicate breakpoint ID %q", c.opts.BreakpointsFile, bp.Id)
+		}
+		bps[bp.Id] = bp
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.fileBps {
+		if bps[id] == nil && c.httpBps[id] == nil {
+			c.removed[id] = true
+		}
+	}
+	for id, bp := range bps {
+		if c.opts.Verbose && c.fileBps[id] == nil {
+			log.Printf("Breakpoint %s at %s:%d read from %s", id, bp.Location.Path, bp.Location.Line, c.opts.BreakpointsFile)
+		}
+		if bp.CreateTime == "" {
+			bp.CreateTime = nowFunc().UTC().Format(time.RFC3339Nano)
+		}
+	}
+	c.fileBps = bps
+	c.changedLocked()
+	return nil
+}
+
+// validate checks that a breakpoint can be set. The breakpoint store of
+// the agent expects a location.
+func validate(bp *cd.Breakpoint) error {
+	if bp.Id == "" {
+		return errors.New("missing id")
+	}
+	if bp.Location == nil || bp.Location.Path == "" || bp.Location.Line <= 0 {
+		return errors.New("missing location path or line")
+	}
+	if bp.IsFinalState {
+		return errors.New("isFinalState is set")
+	}
+	return nil
+}
+
+// ServeHTTP serves the HTTP endpoint that manages the breakpoints.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+func (c *Controller) handleBreakpoints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.mu.Lock()
+		bps := c.breakpointsLocked(false)
+		c.mu.Unlock()
+		if bps == nil {
+			bps = []*cd.Breakpoint{}
+		}
+		writeJSON(w, http.StatusOK, bps)
+	case http.MethodPost:
+		var bp cd.Breakpoint
+		if err := json.NewDecoder(r.Body).Decode(&bp); err != nil {
+			http.Error(w, fmt.Sprintf("parsing breakpoint: %v", err), http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if bp.Id == "" {
+			for bp.Id == "" || c.known(bp.Id) {
+				c.nextID++
+				bp.Id = fmt.Sprintf("bp-%d", c.nextID)
+			}
+		} else if c.known(bp.Id) {
+			http.Error(w, fmt.Sprintf("breakpoint %q already exists", bp.Id), http.StatusConflict)
+			return
+		}
+		if err := validate(&bp); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bp.CreateTime = nowFunc().UTC().Format(time.RFC3339Nano)
+		c.httpBps[bp.Id] = &bp
+		c.changedLocked()
+		if c.opts.Verbose {
+			log.Printf("Breakpoint %s at %s:%d added", bp.Id, bp.Location.Path, bp.Location.Line)
+		}
+		writeJSON(w, http.StatusCreated, &bp)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// known reports whether a breakpoint ID has been used. c.mu must be held.
+func (c *Controller) known(id string) bool {
+	return c.fileBps[id] != nil || c.httpBps[id] != nil || c.removed[id] || c.final[id]
+}
+
+func (c *Controller) handleBreakpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/breakpoints/")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if (c.fileBps[id] == nil && c.httpBps[id] == nil) || c.removed[id] || c.final[id] {
+		http.Error(w, fmt.Sprintf("no active breakpoint %q", id), http.StatusNotFound)
+		return
+	}
+	c.removed[id] = true
+	c.changedLocked()
+	if c.opts.Verbose {
+		log.Printf("Breakpoint %s removed", id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Controller) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := os.ReadFile(c.snapshotPath(strings.TrimPrefix(r.URL.Path, "/snapshots/")))
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "no snapshot", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
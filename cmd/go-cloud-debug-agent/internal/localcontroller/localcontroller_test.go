@@ -0,0 +1,244 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cmd/go-cloud-debug-agent/internal/controller"
+	cd "google.golang.org/api/clouddebugger/v2"
+)
+
+func init() {
+	listTimeout = 50 * time.Millisecond
+	filePollInterval = 10 * time.Millisecond
+}
+
+// listIDs calls List, and returns the IDs of the breakpoints, with a "-"
+// prefix for the final ones.
+func listIDs(t *testing.T, c *Controller) []string {
+	t.Helper()
+	resp, err := c.List(context.Background())
+	if err == controller.ErrListUnchanged {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, bp := range resp.Breakpoints {
+		if bp.IsFinalState {
+			ids = append(ids, "-"+bp.Id)
+		} else {
+			ids = append(ids, bp.Id)
+		}
+	}
+	return ids
+}
+
+func checkIDs(t *testing.T, c *Controller, want ...string) {
+	t.Helper()
+	got := listIDs(t, c)
+	if strings.Join(got, ",") != strings.Join(want, ",") || (got == nil) != (want == nil) {
+		t.Errorf("List: got %q, want %q", got, want)
+	}
+}
+
+func writeBreakpoints(t *testing.T, path string, bps ...*cd.Breakpoint) {
+	t.Helper()
+	data, err := json.Marshal(bps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time changes.
+	mtime := time.Now().Add(time.Duration(len(bps)) * time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func bp(id string, line int64) *cd.Breakpoint {
+	return &cd.Breakpoint{Id: id, Location: &cd.SourceLocation{Path: "main.go", Line: line}}
+}
+
+func TestBreakpointsFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "breakpoints.json")
+	writeBreakpoints(t, file, bp("b1", 10), bp("b2", 20))
+	c, err := NewController(Options{BreakpointsFile: file, SnapshotDir: filepath.Join(dir, "snapshots")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, c, "b1", "b2")
+	// Nothing changed.
+	checkIDs(t, c)
+
+	writeBreakpoints(t, file, bp("b2", 20), bp("b3", 30), bp("b4", 40))
+	checkIDs(t, c, "-b1", "b2", "b3", "b4")
+
+	// An invalid file is ignored.
+	if err := os.WriteFile(file, []byte("[{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, c)
+
+	if _, err := NewController(Options{BreakpointsFile: file, SnapshotDir: dir}); err == nil {
+		t.Error("NewController with invalid file: got nil, want error")
+	}
+	writeBreakpoints(t, file, &cd.Breakpoint{Id: "b5"})
+	if _, err := NewController(Options{BreakpointsFile: file, SnapshotDir: dir}); err == nil {
+		t.Error("NewController with missing location: got nil, want error")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "breakpoints.json")
+	writeBreakpoints(t, file, bp("b1", 10), bp("../b2", 20))
+	c, err := NewController(Options{BreakpointsFile: file, SnapshotDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, c, "../b2", "b1")
+
+	for _, id := range []string{"b1", "../b2"} {
+		snap := bp(id, 10)
+		snap.IsFinalState = true
+		snap.StackFrames = []*cd.StackFrame{{Function: "main.main"}}
+		if err := c.Update(context.Background(), id, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "b1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got cd.Breakpoint
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != "b1" || !got.IsFinalState || got.FinalTime == "" || len(got.StackFrames) != 1 {
+		t.Errorf("snapshot: got %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".._b2.json")); err != nil {
+		t.Errorf("snapshot of ../b2: %v", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewController(Options{SnapshotDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	post := func(body string) (*cd.Breakpoint, int) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/breakpoints", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var bp cd.Breakpoint
+		if resp.StatusCode == http.StatusCreated {
+			if err := json.NewDecoder(resp.Body).Decode(&bp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &bp, resp.StatusCode
+	}
+	do := func(method, path string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The first call returns immediately, even without breakpoints.
+	if resp, err := c.List(context.Background()); err != nil || len(resp.Breakpoints) != 0 {
+		t.Errorf("first List: got %v, %v, want no breakpoints", resp, err)
+	}
+
+	bp1, code := post(`{"id": "b1", "location": {"path": "main.go", "line": 10}}`)
+	if code != http.StatusCreated || bp1.Id != "b1" || bp1.CreateTime == "" {
+		t.Errorf("POST: got %d, %+v", code, bp1)
+	}
+	bp2, code := post(`{"location": {"path": "main.go", "line": 20}}`)
+	if code != http.StatusCreated || bp2.Id == "" {
+		t.Errorf("POST without id: got %d, %+v", code, bp2)
+	}
+	for _, body := range []string{
+		`{"id": "b1", "location": {"path": "main.go", "line": 30}}`,
+		`{"id": "b3"}`,
+		`{`,
+	} {
+		if _, code := post(body); code != http.StatusConflict && code != http.StatusBadRequest {
+			t.Errorf("POST %s: got %d, want error", body, code)
+		}
+	}
+	checkIDs(t, c, "b1", bp2.Id)
+
+	if code := do(http.MethodDelete, "/breakpoints/b1"); code != http.StatusNoContent {
+		t.Errorf("DELETE: got %d", code)
+	}
+	if code := do(http.MethodDelete, "/breakpoints/b1"); code != http.StatusNotFound {
+		t.Errorf("second DELETE: got %d", code)
+	}
+	checkIDs(t, c, "-b1", bp2.Id)
+
+	// There is no snapshot until the breakpoint is hit.
+	if code := do(http.MethodGet, "/snapshots/"+bp2.Id); code != http.StatusNotFound {
+		t.Errorf("GET snapshot before capture: got %d", code)
+	}
+	bp2.IsFinalState = true
+	if err := c.Update(context.Background(), bp2.Id, bp2); err != nil {
+		t.Fatal(err)
+	}
+	if code := do(http.MethodGet, "/snapshots/"+bp2.Id); code != http.StatusOK {
+		t.Errorf("GET snapshot: got %d", code)
+	}
+	resp, err := http.Get(srv.URL + "/breakpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var active []*cd.Breakpoint
+	if err := json.NewDecoder(resp.Body).Decode(&active); err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 0 {
+		t.Errorf("GET /breakpoints after capture: got %d breakpoints, want 0", len(active))
+	}
+}
@@ -54,6 +54,7 @@ func newDatastoreClient(conn grpc.ClientConnInterface, projectID string) pb.Data
 func (dc *datastoreClient) Lookup(ctx context.Context, in *pb.LookupRequest, opts ...grpc.CallOption) (res *pb.LookupResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/datastore.datastoreClient.Lookup")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, in.GetProjectId(), in.GetDatabaseId(), "Lookup")
 
 	err = dc.invoke(ctx, func(ctx context.Context) error {
 		res, err = dc.c.Lookup(ctx, in, opts...)
@@ -65,6 +66,7 @@ func (dc *datastoreClient) Lookup(ctx context.Context, in *pb.LookupRequest, opt
 func (dc *datastoreClient) RunQuery(ctx context.Context, in *pb.RunQueryRequest, opts ...grpc.CallOption) (res *pb.RunQueryResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/datastore.datastoreClient.RunQuery")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, in.GetProjectId(), in.GetDatabaseId(), "RunQuery")
 
 	err = dc.invoke(ctx, func(ctx context.Context) error {
 		res, err = dc.c.RunQuery(ctx, in, opts...)
@@ -76,6 +78,7 @@ func (dc *datastoreClient) RunQuery(ctx context.Context, in *pb.RunQueryRequest,
 func (dc *datastoreClient) BeginTransaction(ctx context.Context, in *pb.BeginTransactionRequest, opts ...grpc.CallOption) (res *pb.BeginTransactionResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/datastore.datastoreClient.BeginTransaction")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, in.GetProjectId(), in.GetDatabaseId(), "BeginTransaction")
 
 	err = dc.invoke(ctx, func(ctx context.Context) error {
 		res, err = dc.c.BeginTransaction(ctx, in, opts...)
@@ -87,6 +90,7 @@ func (dc *datastoreClient) BeginTransaction(ctx context.Context, in *pb.BeginTra
 func (dc *datastoreClient) Commit(ctx context.Context, in *pb.CommitRequest, opts ...grpc.CallOption) (res *pb.CommitResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/datastore.datastoreClient.Commit")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, in.GetProjectId(), in.GetDatabaseId(), "Commit")
 
 	err = dc.invoke(ctx, func(ctx context.Context) error {
 		res, err = dc.c.Commit(ctx, in, opts...)
@@ -98,6 +102,7 @@ func (dc *datastoreClient) Commit(ctx context.Context, in *pb.CommitRequest, opt
 func (dc *datastoreClient) Rollback(ctx context.Context, in *pb.RollbackRequest, opts ...grpc.CallOption) (res *pb.RollbackResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/datastore.datastoreClient.Rollback")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, in.GetProjectId(), in.GetDatabaseId(), "Rollback")
 
 	err = dc.invoke(ctx, func(ctx context.Context) error {
 		res, err = dc.c.Rollback(ctx, in, opts...)
@@ -109,6 +114,7 @@ func (dc *datastoreClient) Rollback(ctx context.Context, in *pb.RollbackRequest,
 func (dc *datastoreClient) AllocateIds(ctx context.Context, in *pb.AllocateIdsRequest, opts ...grpc.CallOption) (res *pb.AllocateIdsResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/datastore.datastoreClient.AllocateIds")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, in.GetProjectId(), in.GetDatabaseId(), "AllocateIds")
 
 	err = dc.invoke(ctx, func(ctx context.Context) error {
 		res, err = dc.c.AllocateIds(ctx, in, opts...)
@@ -0,0 +1,45 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+// attrKeyProject is the attribute key of the project of an operation.
+const attrKeyProject = attribute.Key("gcp.project_id")
+
+// setSpanAttributes adds the project, database and operation of an RPC to
+// the current span if OpenTelemetry tracing is enabled.
+func setSpanAttributes(ctx context.Context, projectID, databaseID, operation string) {
+	if !trace.IsOpenTelemetryTracingEnabled() {
+		return
+	}
+	span := ottrace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		semconv.DBSystemKey.String("datastore"),
+		attrKeyProject.String(projectID),
+		semconv.DBName(databaseID),
+		semconv.DBOperation(operation),
+	)
+}
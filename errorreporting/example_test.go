@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net/http"
 
 	"cloud.google.com/go/errorreporting"
 )
@@ -47,6 +48,25 @@ func Example() {
 	}
 }
 
+func ExampleClient_HTTPMiddleware() {
+	ctx := context.Background()
+	ec, err := errorreporting.NewClient(ctx, "my-gcp-project", errorreporting.Config{
+		ServiceName: "myservice",
+	})
+	if err != nil {
+		// TODO: handle error
+	}
+	defer ec.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Panics are reported, and the client receives a 500 response.
+	})
+	// Report panics and 5xx responses of all handlers.
+	h := ec.HTTPMiddleware(mux, errorreporting.MiddlewareConfig{ReportErrorResponses: true})
+	log.Fatal(http.ListenAndServe(":8080", h))
+}
+
 func doSomething() error {
 	return errors.New("something went wrong")
 }
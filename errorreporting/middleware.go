@@ -0,0 +1,298 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errorreporting
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	pb "cloud.google.com/go/errorreporting/apiv1beta1/errorreportingpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// MiddlewareConfig configures the HTTP middleware and gRPC interceptors of a
+// Client.
+type MiddlewareConfig struct {
+	// ReportErrorResponses causes HTTP responses with 5xx status codes and
+	// gRPC errors to be reported, in addition to panics.
+	ReportErrorResponses bool
+
+	// RePanic causes a panic to be re-raised after it is reported. By
+	// default, the panic is recovered: an HTTP handler responds with 500
+	// Internal Server Error if it has not written a response yet, and a gRPC
+	// method returns an error with code Internal. A gRPC server does not
+	// recover panics, so a re-raised panic terminates the program.
+	RePanic bool
+
+	// User returns an identifier for the user of a request, which is
+	// included in the error reports. Optional.
+	User func(context.Context) string
+}
+
+// HTTPMiddleware returns a handler that calls h and reports its panics,
+// along with the method, URL, user agent, remote IP and response status of
+// the request.
+func (c *Client) HTTPMiddleware(h http.Handler, cfg MiddlewareConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				if cfg.ReportErrorResponses && sw.status >= 500 {
+					err := fmt.Errorf("%s %s: %d %s", r.Method, r.URL.Path, sw.status, http.StatusText(sw.status))
+					c.reportHTTP(r, sw.status, err, requestStack(), cfg)
+				}
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// The handler aborted the response deliberately.
+				panic(rec)
+			}
+			stack := panicStack()
+			code := sw.status
+			if !sw.wroteHeader {
+				code = http.StatusInternalServerError
+			}
+			c.reportHTTP(r, code, panicError(rec), stack, cfg)
+			if cfg.RePanic {
+				panic(rec)
+			}
+			if !sw.wroteHeader {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
+
+func (c *Client) reportHTTP(r *http.Request, code int, err error, stack []byte, cfg MiddlewareConfig) {
+	e := Entry{Error: err, Req: r, Stack: stack}
+	if cfg.User != nil {
+		e.User = cfg.User(r.Context())
+	}
+	req := c.newRequest(e)
+	req.Event.Context.HttpRequest.ResponseStatusCode = int32(code)
+	c.bundler.Add(req, 1)
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+		w.wroteHeader = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.status = http.StatusOK
+			w.wroteHeader = true
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying ResponseWriter does.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("errorreporting: ResponseWriter does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that reports the panics
+// of unary methods, along with the method, user agent and peer address of
+// the call.
+func (c *Client) UnaryServerInterceptor(cfg MiddlewareConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = c.recoverGRPC(ctx, info.FullMethod, rec, cfg)
+			}
+		}()
+		resp, err = handler(ctx, req)
+		if err != nil && cfg.ReportErrorResponses {
+			c.reportGRPC(ctx, info.FullMethod, err, requestStack(), cfg)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that reports the panics
+// of streaming methods, along with the method, user agent and peer address
+// of the call.
+func (c *Client) StreamServerInterceptor(cfg MiddlewareConfig) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := ss.Context()
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = c.recoverGRPC(ctx, info.FullMethod, rec, cfg)
+			}
+		}()
+		err = handler(srv, ss)
+		if err != nil && cfg.ReportErrorResponses {
+			c.reportGRPC(ctx, info.FullMethod, err, requestStack(), cfg)
+		}
+		return err
+	}
+}
+
+// recoverGRPC reports a recovered panic of a gRPC method, and re-raises it
+// or returns the error of the method.
+func (c *Client) recoverGRPC(ctx context.Context, method string, rec interface{}, cfg MiddlewareConfig) error {
+	c.reportGRPC(ctx, method, panicError(rec), panicStack(), cfg)
+	if cfg.RePanic {
+		// The report must be sent before the program terminates.
+		c.Flush()
+		panic(rec)
+	}
+	return status.Errorf(codes.Internal, "panic in %s", method)
+}
+
+func (c *Client) reportGRPC(ctx context.Context, method string, err error, stack []byte, cfg MiddlewareConfig) {
+	e := Entry{Error: fmt.Errorf("%s: %w", method, err), Stack: stack}
+	if cfg.User != nil {
+		e.User = cfg.User(ctx)
+	}
+	req := c.newRequest(e)
+	if req.Event.Context == nil {
+		req.Event.Context = &pb.ErrorContext{}
+	}
+	hr := &pb.HttpRequestContext{
+		Method:             "POST",
+		Url:                method,
+		ResponseStatusCode: int32(httpStatus(status.Code(err))),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			hr.UserAgent = ua[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		hr.RemoteIp = p.Addr.String()
+	}
+	req.Event.Context.HttpRequest = hr
+	c.bundler.Add(req, 1)
+}
+
+// httpStatus returns the HTTP status that corresponds to a gRPC code. A
+// panic has code Unknown and is reported as 500.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// panicError returns the error that describes a recovered panic.
+func panicError(rec interface{}) error {
+	if err, ok := rec.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", rec)
+}
+
+// panicStack returns the stack of a panicking goroutine, starting at the
+// function that panicked. It must be called by the deferred function that
+// recovers the panic.
+func panicStack() []byte {
+	s := debug.Stack()
+	return chopFrames(s, []byte("\npanic("))
+}
+
+// requestStack returns the stack of the goroutine, starting at the caller of
+// requestStack.
+func requestStack() []byte {
+	s := debug.Stack()
+	s = chopFrames(s, []byte("\nruntime/debug.Stack("))
+	return chopFrames(s, []byte("\ncloud.google.com/go/errorreporting.requestStack("))
+}
+
+// chopFrames removes the frames of a stack trace up to and including the
+// first frame whose function line starts with prefix. It returns s if there
+// is no such frame.
+func chopFrames(s, prefix []byte) []byte {
+	lfFirst := bytes.IndexByte(s, '\n')
+	i := bytes.Index(s, prefix)
+	if lfFirst == -1 || i == -1 {
+		return s
+	}
+	rest := s[i+1:]
+	// Skip the function line and the file line of the frame.
+	for n := 0; n < 2; n++ {
+		lf := bytes.IndexByte(rest, '\n')
+		if lf == -1 {
+			return s
+		}
+		rest = rest[lf+1:]
+	}
+	out := make([]byte, 0, lfFirst+1+len(rest))
+	out = append(out, s[:lfFirst+1]...)
+	return append(out, rest...)
+}
@@ -0,0 +1,256 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errorreporting
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "cloud.google.com/go/errorreporting/apiv1beta1/errorreportingpb"
+	"cloud.google.com/go/internal/testutil"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// recordingClient records all reports.
+type recordingClient struct {
+	mu   sync.Mutex
+	reqs []*pb.ReportErrorEventRequest
+}
+
+func (c *recordingClient) ReportErrorEvent(ctx context.Context, req *pb.ReportErrorEventRequest, _ ...gax.CallOption) (*pb.ReportErrorEventResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reqs = append(c.reqs, req)
+	return &pb.ReportErrorEventResponse{}, nil
+}
+
+func (c *recordingClient) Close() error { return nil }
+
+// reports flushes the client and returns the reports.
+func (c *recordingClient) reports(ec *Client) []*pb.ReportErrorEventRequest {
+	ec.Flush()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.reqs
+	c.reqs = nil
+	return r
+}
+
+func newRecordingClient(t *testing.T) (*Client, *recordingClient) {
+	rc := &recordingClient{}
+	newClient = func(ctx context.Context, opts ...option.ClientOption) (client, error) {
+		return rc, nil
+	}
+	c, err := NewClient(context.Background(), testutil.ProjID(), defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, rc
+}
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
+
+func TestHTTPMiddlewarePanic(t *testing.T) {
+	c, rc := newRecordingClient(t)
+	cfg := MiddlewareConfig{User: func(context.Context) string { return "user" }}
+	h := c.HTTPMiddleware(http.HandlerFunc(panickingHandler), cfg)
+	r := httptest.NewRequest("GET", "/path?q=1", nil)
+	r.Header.Set("User-Agent", "agent")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want 500", w.Code)
+	}
+	reports := rc.reports(c)
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	ev := reports[0].Event
+	if !strings.HasPrefix(ev.Message, "panic: boom\n") {
+		t.Errorf("got message %q, want it to start with the panic", ev.Message)
+	}
+	// The stack starts at the function that panicked.
+	lines := strings.Split(ev.Message, "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[2], "cloud.google.com/go/errorreporting.panickingHandler(") {
+		t.Errorf("got stack %q, want it to start at panickingHandler", ev.Message)
+	}
+	hr := ev.Context.HttpRequest
+	if hr.Method != "GET" || hr.Url != "example.com/path?q=1" || hr.UserAgent != "agent" || hr.RemoteIp == "" || hr.ResponseStatusCode != 500 {
+		t.Errorf("got request context %v", hr)
+	}
+	if ev.Context.User != "user" {
+		t.Errorf("got user %q, want user", ev.Context.User)
+	}
+}
+
+func TestHTTPMiddlewareRePanic(t *testing.T) {
+	c, rc := newRecordingClient(t)
+	h := c.HTTPMiddleware(http.HandlerFunc(panickingHandler), MiddlewareConfig{RePanic: true})
+	func() {
+		defer func() {
+			if rec := recover(); rec != "boom" {
+				t.Errorf("got panic %v, want boom", rec)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	if n := len(rc.reports(c)); n != 1 {
+		t.Errorf("got %d reports, want 1", n)
+	}
+
+	// An aborted handler is not reported.
+	h = c.HTTPMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) }), MiddlewareConfig{})
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	if n := len(rc.reports(c)); n != 0 {
+		t.Errorf("got %d reports for ErrAbortHandler, want 0", n)
+	}
+}
+
+func TestHTTPMiddlewareErrorResponses(t *testing.T) {
+	c, rc := newRecordingClient(t)
+	var code int
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	})
+	for _, report := range []bool{false, true} {
+		h := c.HTTPMiddleware(inner, MiddlewareConfig{ReportErrorResponses: report})
+		for _, code = range []int{200, 404, 503} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/x", nil))
+		}
+		reports := rc.reports(c)
+		if !report {
+			if len(reports) != 0 {
+				t.Errorf("got %d reports, want 0", len(reports))
+			}
+			continue
+		}
+		if len(reports) != 1 {
+			t.Fatalf("got %d reports, want 1", len(reports))
+		}
+		ev := reports[0].Event
+		if !strings.HasPrefix(ev.Message, "POST /x: 503 Service Unavailable\n") || ev.Context.HttpRequest.ResponseStatusCode != 503 {
+			t.Errorf("got report %v", ev)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	c, rc := newRecordingClient(t)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-agent", "grpc-go"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+
+	i := c.UnaryServerInterceptor(MiddlewareConfig{ReportErrorResponses: true})
+	_, err := i(ctx, nil, info, func(context.Context, interface{}) (interface{}, error) {
+		panic(errors.New("boom"))
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("got %v, want Internal", err)
+	}
+	_, err = i(ctx, nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "no")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+	if _, err := i(ctx, nil, info, func(context.Context, interface{}) (interface{}, error) { return 1, nil }); err != nil {
+		t.Fatal(err)
+	}
+	reports := rc.reports(c)
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reports))
+	}
+	if got := reports[0].Event; !strings.HasPrefix(got.Message, "/pkg.Service/Method: panic: boom\n") || got.Context.HttpRequest.ResponseStatusCode != 500 {
+		t.Errorf("got report %v", got)
+	}
+	hr := reports[1].Event.Context.HttpRequest
+	if hr.Url != "/pkg.Service/Method" || hr.UserAgent != "grpc-go" || hr.RemoteIp != "10.0.0.1:1234" || hr.ResponseStatusCode != 404 {
+		t.Errorf("got request context %v", hr)
+	}
+
+	i = c.UnaryServerInterceptor(MiddlewareConfig{})
+	if _, err := i(ctx, nil, info, func(context.Context, interface{}) (interface{}, error) { return nil, errors.New("x") }); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if n := len(rc.reports(c)); n != 0 {
+		t.Errorf("got %d reports of errors without ReportErrorResponses, want 0", n)
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	c, rc := newRecordingClient(t)
+	info := &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Stream"}
+	ss := fakeServerStream{ctx: context.Background()}
+	i := c.StreamServerInterceptor(MiddlewareConfig{RePanic: true})
+	func() {
+		defer func() {
+			if rec := recover(); rec != "boom" {
+				t.Errorf("got panic %v, want boom", rec)
+			}
+		}()
+		i(nil, ss, info, func(interface{}, grpc.ServerStream) error { panic("boom") })
+	}()
+	reports := rc.reports(c)
+	if len(reports) != 1 || !strings.HasPrefix(reports[0].Event.Message, "/pkg.Service/Stream: panic: boom\n") {
+		t.Errorf("got reports %v", reports)
+	}
+}
+
+func TestChopFrames(t *testing.T) {
+	in := []byte(`goroutine 1 [running]:
+runtime/debug.Stack()
+	/go/runtime/debug/stack.go:24 +0x5e
+main.recoverer()
+	/src/main.go:10 +0x1d
+panic({0x4a1ea0?, 0x4d9d88?})
+	/go/runtime/panic.go:914 +0x21f
+main.f()
+	/src/main.go:20 +0x25
+`)
+	want := `goroutine 1 [running]:
+main.f()
+	/src/main.go:20 +0x25
+`
+	if got := string(chopFrames(in, []byte("\npanic("))); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := chopFrames(in, []byte("\nmissing(")); string(got) != string(in) {
+		t.Errorf("got %q, want the input", got)
+	}
+}
@@ -77,6 +77,7 @@ func (d *DocumentRef) Collection(id string) *CollectionRef {
 func (d *DocumentRef) Get(ctx context.Context) (_ *DocumentSnapshot, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/firestore.DocumentRef.Get")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setDocumentSpanAttributes(ctx, d)
 
 	if d == nil {
 		return nil, errNilDocRef
@@ -137,6 +138,7 @@ func (d *DocumentRef) Get(ctx context.Context) (_ *DocumentSnapshot, err error)
 func (d *DocumentRef) Create(ctx context.Context, data interface{}) (_ *WriteResult, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/firestore.DocumentRef.Create")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setDocumentSpanAttributes(ctx, d)
 
 	ws, err := d.newCreateWrites(data)
 	if err != nil {
@@ -169,6 +171,7 @@ func (d *DocumentRef) newCreateWrites(data interface{}) ([]*pb.Write, error) {
 func (d *DocumentRef) Set(ctx context.Context, data interface{}, opts ...SetOption) (_ *WriteResult, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/firestore.DocumentRef.Set")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setDocumentSpanAttributes(ctx, d)
 
 	ws, err := d.newSetWrites(data, opts)
 	if err != nil {
@@ -249,6 +252,7 @@ func fpvsFromData(v reflect.Value, prefix FieldPath, fpvs *[]fpv) {
 func (d *DocumentRef) Delete(ctx context.Context, preconds ...Precondition) (_ *WriteResult, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/firestore.DocumentRef.Delete")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setDocumentSpanAttributes(ctx, d)
 
 	ws, err := d.newDeleteWrites(preconds)
 	if err != nil {
@@ -674,6 +678,7 @@ func (u *Update) process() (fpv, error) {
 func (d *DocumentRef) Update(ctx context.Context, updates []Update, preconds ...Precondition) (_ *WriteResult, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/firestore.DocumentRef.Update")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setDocumentSpanAttributes(ctx, d)
 
 	ws, err := d.newUpdatePathWrites(updates, preconds)
 	if err != nil {
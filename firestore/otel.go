@@ -0,0 +1,59 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firestore
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys of the OpenTelemetry spans of the client.
+const (
+	attrKeyProject    = attribute.Key("gcp.project_id")
+	attrKeyDocument   = attribute.Key("gcp.firestore.document")
+	attrKeyCollection = attribute.Key("gcp.firestore.collection")
+)
+
+// setSpanAttributes adds the database of c and the resource path of an
+// operation to the current span if OpenTelemetry tracing is enabled. key
+// tells whether the path is a document or a collection.
+func setSpanAttributes(ctx context.Context, c *Client, key attribute.Key, path string) {
+	if c == nil || !trace.IsOpenTelemetryTracingEnabled() {
+		return
+	}
+	span := ottrace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		semconv.DBSystemKey.String("firestore"),
+		attrKeyProject.String(c.projectID),
+		semconv.DBName(c.databaseID),
+		key.String(path),
+	)
+}
+
+// setDocumentSpanAttributes calls setSpanAttributes for the document d, which
+// may be nil.
+func setDocumentSpanAttributes(ctx context.Context, d *DocumentRef) {
+	if d == nil || d.Parent == nil {
+		return
+	}
+	setSpanAttributes(ctx, d.Parent.c, attrKeyDocument, d.shortPath)
+}
@@ -915,6 +915,7 @@ func (it *queryDocumentIterator) next() (_ *DocumentSnapshot, err error) {
 	if it.streamClient == nil {
 		it.ctx = trace.StartSpan(it.ctx, "cloud.google.com/go/firestore.Query.RunQuery")
 		defer func() { trace.EndSpan(it.ctx, err) }()
+		setSpanAttributes(it.ctx, client, attrKeyCollection, it.q.path)
 
 		sq, err := it.q.toProto()
 		if err != nil {
@@ -27,6 +27,12 @@
 //     You will get back the recorded responses.
 //  3. Close the Replayer when you're done.
 //
+// By default, a request is replayed only if a recorded request has the same
+// method, URL, headers and body. Use the methods of Recorder to remove or
+// scrub the parts of requests that vary from run to run, and the methods of
+// Replayer to relax matching. If no recorded request matches, the error
+// describes the differences from the nearest recorded request.
+//
 // This package is EXPERIMENTAL and is subject to change or removal without notice.
 // It requires Go version 1.8 or higher.
 package httpreplay
@@ -36,6 +42,7 @@ package httpreplay
 import (
 	"context"
 	"net/http"
+	"regexp"
 
 	"cloud.google.com/go/httpreplay/internal/proxy"
 	"google.golang.org/api/option"
@@ -96,6 +103,22 @@ func (r *Recorder) ClearQueryParams(patterns ...string) {
 	r.proxy.ClearQueryParams(patterns)
 }
 
+// ScrubURL will replace the matches of re in request URLs with replacement in
+// the log, and on replay before matching. The replacement may refer to
+// submatches as in regexp.Regexp.Expand. Use ScrubURL for URL path elements
+// that are random or secret, such as generated resource names.
+func (r *Recorder) ScrubURL(re *regexp.Regexp, replacement string) {
+	r.proxy.ScrubURL(re, replacement)
+}
+
+// ScrubBody will replace the matches of re in request bodies with replacement
+// in the log, and on replay before matching. The replacement may refer to
+// submatches as in regexp.Regexp.Expand. Each part of a multipart body is
+// scrubbed separately.
+func (r *Recorder) ScrubBody(re *regexp.Regexp, replacement string) {
+	r.proxy.ScrubBody(re, replacement)
+}
+
 // Client returns an http.Client to be used for recording. Provide authentication options
 // like option.WithTokenSource as you normally would, or omit them to use Application Default
 // Credentials.
@@ -147,6 +170,28 @@ func (r *Replayer) IgnoreHeader(h string) {
 	r.proxy.IgnoreHeader(h)
 }
 
+// MatchUnordered will match requests to endpoints whose URL, without the query
+// string, matches any of the patterns by method and URL only, ignoring their
+// headers and bodies. Use MatchUnordered for requests that are made
+// concurrently or whose bodies vary from run to run. A request gets the
+// response of a recorded request with the same body if there is one;
+// otherwise the recorded responses for an endpoint are replayed in the order
+// they were recorded.
+//
+// Pattern is taken literally except for *, which matches any sequence of characters.
+func (r *Replayer) MatchUnordered(patterns ...string) {
+	r.proxy.MatchUnordered(patterns)
+}
+
+// IgnoreJSONFields will not use the fields at the given paths when matching
+// request bodies that are JSON. A path is a sequence of field names separated
+// by dots, like "jobReference.jobId", and applies to each element of an array.
+//
+// Each name is taken literally except for *, which matches any sequence of characters.
+func (r *Replayer) IgnoreJSONFields(paths ...string) {
+	r.proxy.IgnoreJSONFields(paths)
+}
+
 // Close closes the replayer.
 func (r *Replayer) Close() error {
 	return r.proxy.Close()
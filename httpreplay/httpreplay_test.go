@@ -26,6 +26,8 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
@@ -411,6 +413,204 @@ func TestRemoveAndClear(t *testing.T) {
 	}
 }
 
+func TestScrubAndMatch(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.URL.Path, b)
+	}))
+	defer srv.Close()
+
+	replayFilename := tempFilename(t, "TestScrubAndMatch*.replay")
+	defer os.Remove(replayFilename)
+
+	// do makes a request for each call, and returns the responses.
+	type call struct{ path, body string }
+	do := func(hc *http.Client, calls []call) ([]string, error) {
+		var got []string
+		for _, c := range calls {
+			res, err := hc.Post(srv.URL+c.path, "application/json", bytes.NewBufferString(c.body))
+			if err != nil {
+				return nil, err
+			}
+			b, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if res.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("%s: status %d", c.path, res.StatusCode)
+			}
+			got = append(got, string(b))
+		}
+		return got, nil
+	}
+
+	ctx := context.Background()
+	rec, err := httpreplay.NewRecorder(replayFilename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.ScrubURL(regexp.MustCompile(`job-\d+`), "job-ID")
+	rec.ScrubBody(regexp.MustCompile(`"token":"[^"]*"`), `"token":""`)
+	hc, err := rec.Client(ctx, option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := do(hc, []call{
+		{"/jobs/job-1", `{"token":"a1","requestId":"r1","query":"q"}`},
+		{"/upload", `chunk 1`},
+		{"/upload", `chunk 2`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		desc        string
+		calls       []call
+		wantSuccess bool
+		wantOrder   []int // indexes of the recorded responses
+	}{
+		{
+			"scrubbed and ignored values differ",
+			[]call{
+				{"/jobs/job-2", `{"token":"b2","requestId":"r2","query":"q"}`},
+				{"/upload", `chunk 2`},
+				{"/upload", `chunk 1`},
+			},
+			true,
+			// Requests to the unordered endpoint get the responses of the
+			// recorded requests with the same body.
+			[]int{0, 2, 1},
+		},
+		{
+			"unordered bodies differ",
+			[]call{
+				{"/jobs/job-2", `{"token":"b2","requestId":"r2","query":"q"}`},
+				{"/upload", `chunk 3`},
+				{"/upload", `chunk 4`},
+			},
+			true,
+			// Otherwise they are replayed in the order they were recorded.
+			[]int{0, 1, 2},
+		},
+		{
+			"other value differs",
+			[]call{{"/jobs/job-1", `{"token":"a1","requestId":"r1","query":"x"}`}},
+			false,
+			nil,
+		},
+	} {
+		rep, err := httpreplay.NewReplayer(replayFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep.IgnoreJSONFields("requestId")
+		rep.MatchUnordered(srv.URL + "/upload")
+		hc, err := rep.Client(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := do(hc, test.calls)
+		rep.Close()
+		if (err == nil) != test.wantSuccess {
+			t.Errorf("%s: got error %v, wanted success=%t", test.desc, err, test.wantSuccess)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var wantRes []string
+		for _, i := range test.wantOrder {
+			wantRes = append(wantRes, want[i])
+		}
+		if !testutil.Equal(got, wantRes) {
+			t.Errorf("%s: got %q, want %q", test.desc, got, wantRes)
+		}
+	}
+}
+
+func TestMatchUnorderedConcurrent(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "response to %s", b)
+	}))
+	defer srv.Close()
+
+	replayFilename := tempFilename(t, "TestMatchUnorderedConcurrent*.replay")
+	defer os.Remove(replayFilename)
+
+	// post makes a POST request to the unordered endpoint, and returns the
+	// response.
+	post := func(hc *http.Client, body string) (string, error) {
+		res, err := hc.Post(srv.URL+"/batch", "text/plain", bytes.NewBufferString(body))
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s: status %d", body, res.StatusCode)
+		}
+		return string(b), nil
+	}
+
+	ctx := context.Background()
+	rec, err := httpreplay.NewRecorder(replayFilename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc, err := rec.Client(ctx, option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, body := range bodies {
+		if _, err := post(hc, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := httpreplay.NewReplayer(replayFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rep.Close()
+	rep.MatchUnordered(srv.URL + "/batch")
+	hc, err = rep.Client(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, body := range bodies {
+		body := body
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := post(hc, body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want := "response to " + body; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func tempFilename(t *testing.T, pattern string) string {
 	f, err := ioutil.TempFile("", pattern)
 	if err != nil {
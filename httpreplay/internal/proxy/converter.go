@@ -30,11 +30,24 @@ import (
 // of this package, while removing or redacting information.
 type Converter struct {
 	// These all apply to both headers and trailers.
-	ClearHeaders          []tRegexp // replace matching headers with "CLEARED"
-	RemoveRequestHeaders  []tRegexp // remove matching headers in requests
-	RemoveResponseHeaders []tRegexp // remove matching headers in responses
-	ClearParams           []tRegexp // replace matching query params with "CLEARED"
-	RemoveParams          []tRegexp // remove matching query params
+	ClearHeaders          []tRegexp  // replace matching headers with "CLEARED"
+	RemoveRequestHeaders  []tRegexp  // remove matching headers in requests
+	RemoveResponseHeaders []tRegexp  // remove matching headers in responses
+	ClearParams           []tRegexp  // replace matching query params with "CLEARED"
+	RemoveParams          []tRegexp  // remove matching query params
+	ScrubURLs             []scrubber `json:",omitempty"` // rewrite request URLs
+	ScrubBodies           []scrubber `json:",omitempty"` // rewrite request body parts
+}
+
+// A scrubber replaces the matches of a regexp with a replacement, which may
+// refer to submatches as in regexp.Regexp.Expand.
+type scrubber struct {
+	Regexp      tRegexp
+	Replacement string
+}
+
+func (s scrubber) scrub(b []byte) []byte {
+	return s.Regexp.ReplaceAll(b, []byte(s.Replacement))
 }
 
 // A regexp that can be marshaled to and from text.
@@ -68,6 +81,14 @@ func (c *Converter) registerClearParams(pat string) {
 	c.ClearParams = append(c.ClearParams, pattern(pat))
 }
 
+func (c *Converter) registerScrubURL(re *regexp.Regexp, repl string) {
+	c.ScrubURLs = append(c.ScrubURLs, scrubber{tRegexp{re}, repl})
+}
+
+func (c *Converter) registerScrubBody(re *regexp.Regexp, repl string) {
+	c.ScrubBodies = append(c.ScrubBodies, scrubber{tRegexp{re}, repl})
+}
+
 var (
 	defaultRemoveRequestHeaders = []string{
 		"Authorization", // not only is it secret, but it is probably missing on replay
@@ -142,9 +163,18 @@ func (c *Converter) convertRequest(req *http.Request) (*Request, error) {
 	}
 	url2 := *req.URL
 	url2.RawQuery = scrubQuery(url2.RawQuery, c.ClearParams, c.RemoveParams)
+	u := []byte(url2.String())
+	for _, s := range c.ScrubURLs {
+		u = s.scrub(u)
+	}
+	for i := range parts {
+		for _, s := range c.ScrubBodies {
+			parts[i] = s.scrub(parts[i])
+		}
+	}
 	return &Request{
 		Method:    req.Method,
-		URL:       url2.String(),
+		URL:       string(u),
 		Header:    scrubHeaders(req.Header, c.ClearHeaders, c.RemoveRequestHeaders),
 		MediaType: mediaType,
 		BodyParts: parts,
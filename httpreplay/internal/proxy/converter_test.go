@@ -16,9 +16,11 @@ package proxy
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
 	"testing"
 
 	"cloud.google.com/go/internal/testutil"
@@ -80,6 +82,53 @@ func TestConvertRequest(t *testing.T) {
 	}
 }
 
+func TestConvertRequestScrub(t *testing.T) {
+	body := []byte(`{"name":"bucket-1692803482","id":"abc"}`)
+
+	conv := defaultConverter()
+	conv.registerScrubURL(regexp.MustCompile(`bucket-\d+`), "bucket-SCRUBBED")
+	conv.registerScrubBody(regexp.MustCompile(`"(bucket)-\d+"`), `"$1-SCRUBBED"`)
+	// The scrubbers must survive saving to the log.
+	b, err := json.Marshal(conv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv = &Converter{}
+	if err := json.Unmarshal(b, conv); err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := url.Parse("https://www.example.com/b/bucket-1692803482/o?alt=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &http.Request{
+		Method: "POST",
+		URL:    url,
+		Body:   ioutil.NopCloser(bytes.NewReader(body)),
+		Header: http.Header{"Content-Type": {"application/json"}},
+	}
+	got, err := conv.convertRequest(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://www.example.com/b/bucket-SCRUBBED/o?alt=json"; got.URL != want {
+		t.Errorf("URL: got %q, want %q", got.URL, want)
+	}
+	wantParts := [][]byte{[]byte(`{"name":"bucket-SCRUBBED","id":"abc"}`)}
+	if diff := cmp.Diff(got.BodyParts, wantParts); diff != "" {
+		t.Error(diff)
+	}
+	// The body that is sent should not be scrubbed.
+	sent, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sent, body) {
+		t.Errorf("sent body: got %s, want %s", sent, body)
+	}
+}
+
 func TestPattern(t *testing.T) {
 	for _, test := range []struct {
 		in, want string
@@ -0,0 +1,242 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// matchOptions determines how incoming requests are matched to recorded
+// requests during replay.
+type matchOptions struct {
+	ignoreHeaders    map[string]bool // headers the user has asked to ignore
+	unordered        []tRegexp       // URLs of endpoints matched by method and URL only
+	ignoreJSONFields [][]tRegexp     // paths of fields ignored in JSON bodies
+}
+
+func newMatchOptions() *matchOptions {
+	return &matchOptions{ignoreHeaders: map[string]bool{}}
+}
+
+// Report whether the incoming request in matches the candidate request cand.
+func (o *matchOptions) requestsMatch(in, cand *Request) bool {
+	if in.Method != cand.Method {
+		return false
+	}
+	if in.URL != cand.URL {
+		return false
+	}
+	if o.isUnordered(in) {
+		return true
+	}
+	if in.MediaType != cand.MediaType {
+		return false
+	}
+	if !o.bodiesMatch(in.BodyParts, cand.BodyParts) {
+		return false
+	}
+	// Check headers last. See DebugHeaders.
+	return headersMatch(in.Header, cand.Header, o.ignoreHeaders)
+}
+
+// findMatch returns the index of the first unreplayed call whose request
+// matches in, or -1 if there is none. For unordered endpoints, a call whose
+// request also has the same body is preferred, so that concurrent requests
+// with different bodies get their own responses.
+func (o *matchOptions) findMatch(in *Request, calls []*call) int {
+	unordered := o.isUnordered(in)
+	first := -1
+	for i, c := range calls {
+		if c == nil || !o.requestsMatch(in, c.req) {
+			continue
+		}
+		if !unordered || (in.MediaType == c.req.MediaType && o.bodiesMatch(in.BodyParts, c.req.BodyParts)) {
+			return i
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	return first
+}
+
+// isUnordered reports whether the URL of a request, without its query
+// string, matches an unordered endpoint.
+func (o *matchOptions) isUnordered(r *Request) bool {
+	if len(o.unordered) == 0 {
+		return false
+	}
+	return match(urlPath(r.URL), o.unordered)
+}
+
+func (o *matchOptions) bodiesMatch(in, cand [][]byte) bool {
+	if len(in) != len(cand) {
+		return false
+	}
+	for i, p1 := range in {
+		if !o.bodyPartsMatch(p1, cand[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// bodyPartsMatch compares body parts byte by byte, or structurally without
+// the ignored fields if they are both JSON.
+func (o *matchOptions) bodyPartsMatch(in, cand []byte) bool {
+	if bytes.Equal(in, cand) {
+		return true
+	}
+	if len(o.ignoreJSONFields) == 0 {
+		return false
+	}
+	var v1, v2 interface{}
+	if json.Unmarshal(in, &v1) != nil || json.Unmarshal(cand, &v2) != nil {
+		return false
+	}
+	for _, path := range o.ignoreJSONFields {
+		deleteJSONField(v1, path)
+		deleteJSONField(v2, path)
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+// deleteJSONField deletes the fields at a path from a decoded JSON value.
+// The path applies to each element of an array.
+func deleteJSONField(v interface{}, path []tRegexp) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			if !path[0].MatchString(k) {
+				continue
+			}
+			if len(path) == 1 {
+				delete(v, k)
+			} else {
+				deleteJSONField(x, path[1:])
+			}
+		}
+	case []interface{}:
+		for _, x := range v {
+			deleteJSONField(x, path)
+		}
+	}
+}
+
+// mismatchError returns an error that describes the differences between an
+// incoming request and the nearest unreplayed recorded request.
+func (o *matchOptions) mismatchError(in *Request, calls []*call) error {
+	best, bestScore := -1, -1
+	for i, c := range calls {
+		if c == nil {
+			continue
+		}
+		if s := o.similarity(in, c.req); s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	if best < 0 {
+		return fmt.Errorf("httpreplay: no recorded request matches %s %s: all recorded requests have been replayed", in.Method, in.URL)
+	}
+	cand := calls[best].req
+	var b strings.Builder
+	fmt.Fprintf(&b, "httpreplay: no recorded request matches %s %s\nnearest unreplayed recorded request is #%d, %s %s:", in.Method, in.URL, best, cand.Method, cand.URL)
+	if in.Method != cand.Method {
+		fmt.Fprintf(&b, "\n  method: got %s, recorded %s", in.Method, cand.Method)
+	}
+	if in.URL != cand.URL {
+		fmt.Fprintf(&b, "\n  URL: got %s, recorded %s", in.URL, cand.URL)
+	}
+	if in.MediaType != cand.MediaType {
+		fmt.Fprintf(&b, "\n  media type: got %q, recorded %q", in.MediaType, cand.MediaType)
+	}
+	if len(in.BodyParts) != len(cand.BodyParts) {
+		fmt.Fprintf(&b, "\n  body: got %d parts, recorded %d", len(in.BodyParts), len(cand.BodyParts))
+	} else {
+		for i, p := range in.BodyParts {
+			if !o.bodyPartsMatch(p, cand.BodyParts[i]) {
+				fmt.Fprintf(&b, "\n  body part %d: got %s, recorded %s", i, abbrev(p), abbrev(cand.BodyParts[i]))
+			}
+		}
+	}
+	for _, d := range headerDiffs(in.Header, cand.Header, o.ignoreHeaders) {
+		fmt.Fprintf(&b, "\n  %s", d)
+	}
+	return errors.New(b.String())
+}
+
+// similarity scores how close a recorded request is to an incoming request.
+// The method and URL count the most, since they identify the endpoint.
+func (o *matchOptions) similarity(in, cand *Request) int {
+	s := 0
+	if in.Method == cand.Method {
+		s += 8
+	}
+	if in.URL == cand.URL {
+		s += 4
+	} else if urlPath(in.URL) == urlPath(cand.URL) {
+		s += 2
+	}
+	if in.MediaType == cand.MediaType && o.bodiesMatch(in.BodyParts, cand.BodyParts) {
+		s++
+	}
+	return s
+}
+
+func urlPath(u string) string {
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		return u[:i]
+	}
+	return u
+}
+
+// abbrev quotes a body part, abbreviating it if it is long.
+func abbrev(b []byte) string {
+	const max = 200
+	if len(b) > max {
+		return fmt.Sprintf("%q... (%d bytes)", b[:max], len(b))
+	}
+	return fmt.Sprintf("%q", b)
+}
+
+// headerDiffs describes the differences between the headers of an incoming
+// request and a recorded request, in the order of the header names.
+func headerDiffs(in, cand http.Header, ignores map[string]bool) []string {
+	var diffs []string
+	for k, v1 := range in {
+		if ignores[k] {
+			continue
+		}
+		if v2, ok := cand[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("header %s: got %v, not recorded", k, v1))
+		} else if !reflect.DeepEqual(v1, v2) {
+			diffs = append(diffs, fmt.Sprintf("header %s: got %v, recorded %v", k, v1, v2))
+		}
+	}
+	for k, v2 := range cand {
+		if _, ok := in[k]; !ok && !ignores[k] {
+			diffs = append(diffs, fmt.Sprintf("header %s: missing, recorded %v", k, v2))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
@@ -28,6 +28,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -50,10 +51,14 @@ type Proxy struct {
 	// Initial state of the client.
 	Initial []byte
 
-	mproxy        *martian.Proxy
-	filename      string          // for log
-	logger        *Logger         // for recording only
-	ignoreHeaders map[string]bool // headers the user has asked to ignore
+	mproxy   *martian.Proxy
+	filename string  // for log
+	logger   *Logger // for recording only
+
+	// mu guards match, which is read by the replayer while it serves
+	// requests, and the calls of the replayer.
+	mu    sync.Mutex
+	match *matchOptions // for replaying only
 }
 
 // ForRecording returns a Proxy configured to record.
@@ -123,10 +128,10 @@ func newProxy(filename string) (*Proxy, error) {
 	mproxy := martian.NewProxy()
 	mproxy.SetMITM(config)
 	return &Proxy{
-		mproxy:        mproxy,
-		CACert:        cert,
-		filename:      filename,
-		ignoreHeaders: map[string]bool{},
+		mproxy:   mproxy,
+		CACert:   cert,
+		filename: filename,
+		match:    newMatchOptions(),
 	}, nil
 }
 
@@ -197,7 +202,59 @@ func (p *Proxy) ClearQueryParams(patterns []string) {
 // IgnoreHeader will cause h to be ignored during matching on replay.
 // Deprecated: use RemoveRequestHeaders instead.
 func (p *Proxy) IgnoreHeader(h string) {
-	p.ignoreHeaders[http.CanonicalHeaderKey(h)] = true
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.match.ignoreHeaders[http.CanonicalHeaderKey(h)] = true
+}
+
+// MatchUnordered causes requests to endpoints whose URL, without the query
+// string, matches any of the patterns to be matched on replay by method and
+// URL only, regardless of their headers and bodies. A recorded request with
+// the same body is preferred. Pattern is taken literally except for *, which
+// matches any sequence of characters.
+func (p *Proxy) MatchUnordered(patterns []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pat := range patterns {
+		p.match.unordered = append(p.match.unordered, pattern(pat))
+	}
+}
+
+// IgnoreJSONFields causes the fields at the given paths to be ignored when
+// comparing JSON request bodies on replay. A path is a sequence of field
+// names separated by dots, and applies to each element of an array. Each
+// name is taken literally except for *, which matches any sequence of
+// characters.
+func (p *Proxy) IgnoreJSONFields(paths []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, path := range paths {
+		var pats []tRegexp
+		for _, name := range strings.Split(path, ".") {
+			pats = append(pats, pattern(name))
+		}
+		p.match.ignoreJSONFields = append(p.match.ignoreJSONFields, pats)
+	}
+}
+
+// ScrubURL will replace the matches of re in request URLs with replacement,
+// which may refer to submatches as in regexp.Regexp.Expand. The query
+// parameters are removed and cleared first.
+//
+// This only needs to be called during recording; the scrubbers will be saved
+// to the log and applied to requests on replay before they are matched.
+func (p *Proxy) ScrubURL(re *regexp.Regexp, replacement string) {
+	p.logger.log.Converter.registerScrubURL(re, replacement)
+}
+
+// ScrubBody will replace the matches of re in request bodies with
+// replacement, which may refer to submatches as in regexp.Regexp.Expand.
+// Each part of a multipart body is scrubbed separately.
+//
+// This only needs to be called during recording; the scrubbers will be saved
+// to the log and applied to requests on replay before they are matched.
+func (p *Proxy) ScrubBody(re *regexp.Regexp, replacement string) {
+	p.logger.log.Converter.registerScrubBody(re, replacement)
 }
 
 // Close closes the proxy. If the proxy is recording, it also writes the log.
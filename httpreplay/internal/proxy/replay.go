@@ -15,7 +15,6 @@
 package proxy
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -44,9 +43,10 @@ func ForReplaying(filename string, port int) (*Proxy, error) {
 	}
 	p.Initial = lg.Initial
 	p.mproxy.SetRoundTripper(&replayRoundTripper{
-		calls:         calls,
-		ignoreHeaders: p.ignoreHeaders,
-		conv:          lg.Converter,
+		mu:    &p.mu,
+		calls: calls,
+		opts:  p.match,
+		conv:  lg.Converter,
 	})
 
 	// Debug logging.
@@ -127,10 +127,10 @@ func constructCalls(lg *Log) ([]*call, error) {
 }
 
 type replayRoundTripper struct {
-	mu            sync.Mutex
-	calls         []*call
-	ignoreHeaders map[string]bool
-	conv          *Converter
+	mu    *sync.Mutex // the mutex of the proxy, which also guards opts
+	calls []*call
+	opts  *matchOptions
+	conv  *Converter
 }
 
 func (r *replayRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -143,39 +143,12 @@ func (r *replayRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	for i, call := range r.calls {
-		if call == nil {
-			continue
-		}
-		if requestsMatch(creq, call.req, r.ignoreHeaders) {
-			r.calls[i] = nil // nil out this call so we don't reuse it
-			return toHTTPResponse(call.res, req), nil
-		}
-	}
-	return nil, fmt.Errorf("no matching request for %+v", req)
-}
-
-// Report whether the incoming request in matches the candidate request cand.
-func requestsMatch(in, cand *Request, ignoreHeaders map[string]bool) bool {
-	if in.Method != cand.Method {
-		return false
-	}
-	if in.URL != cand.URL {
-		return false
-	}
-	if in.MediaType != cand.MediaType {
-		return false
-	}
-	if len(in.BodyParts) != len(cand.BodyParts) {
-		return false
-	}
-	for i, p1 := range in.BodyParts {
-		if !bytes.Equal(p1, cand.BodyParts[i]) {
-			return false
-		}
+	if i := r.opts.findMatch(creq, r.calls); i >= 0 {
+		call := r.calls[i]
+		r.calls[i] = nil // nil out this call so we don't reuse it
+		return toHTTPResponse(call.res, req), nil
 	}
-	// Check headers last. See DebugHeaders.
-	return headersMatch(in.Header, cand.Header, ignoreHeaders)
+	return nil, r.opts.mismatchError(creq, r.calls)
 }
 
 // DebugHeaders helps to determine whether a header should be ignored.
@@ -16,6 +16,7 @@ package proxy
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"cloud.google.com/go/internal/testutil"
@@ -99,3 +100,108 @@ func TestHeadersMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestRequestsMatch(t *testing.T) {
+	req := func(url, body string) *Request {
+		return &Request{
+			Method:    "POST",
+			URL:       url,
+			Header:    http.Header{"A": {"x"}},
+			MediaType: "application/json",
+			BodyParts: [][]byte{[]byte(body)},
+		}
+	}
+	const url = "https://example.com/upload/b/x/o?uploadType=multipart"
+	opts := newMatchOptions()
+	opts.unordered = []tRegexp{pattern("https://example.com/upload/*")}
+	opts.ignoreJSONFields = [][]tRegexp{
+		{pattern("requestId")},
+		{pattern("items"), pattern("*Id")},
+	}
+	for _, test := range []struct {
+		desc     string
+		in, cand *Request
+		want     bool
+	}{
+		{
+			"same",
+			req("https://example.com/a", `{"k":1}`),
+			req("https://example.com/a", `{"k":1}`),
+			true,
+		},
+		{
+			"different body",
+			req("https://example.com/a", `{"k":1}`),
+			req("https://example.com/a", `{"k":2}`),
+			false,
+		},
+		{
+			"different URL",
+			req("https://example.com/a", `{"k":1}`),
+			req("https://example.com/b", `{"k":1}`),
+			false,
+		},
+		{
+			"ignored JSON fields",
+			req("https://example.com/a", `{"k": 1, "requestId": "r1", "items": [{"jobId": "j1", "v": 2}]}`),
+			req("https://example.com/a", `{"requestId":"r2","k":1,"items":[{"jobId":"j2","v":2}]}`),
+			true,
+		},
+		{
+			"ignored JSON fields, different other field",
+			req("https://example.com/a", `{"k":1,"items":[{"jobId":"j1","v":2}]}`),
+			req("https://example.com/a", `{"k":1,"items":[{"jobId":"j1","v":3}]}`),
+			false,
+		},
+		{
+			"ignored field name not at path",
+			req("https://example.com/a", `{"k":{"requestId":"r1"}}`),
+			req("https://example.com/a", `{"k":{"requestId":"r2"}}`),
+			false,
+		},
+		{
+			"unordered endpoint, different body",
+			req(url, `chunk 1`),
+			req(url, `chunk 2`),
+			true,
+		},
+		{
+			"unordered endpoint, different query",
+			req(url, `chunk 1`),
+			req(url+"&x=1", `chunk 1`),
+			false,
+		},
+	} {
+		if got := opts.requestsMatch(test.in, test.cand); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestMismatchError(t *testing.T) {
+	in := &Request{
+		Method:    "POST",
+		URL:       "https://example.com/b?alt=json",
+		Header:    http.Header{"A": {"x"}},
+		BodyParts: [][]byte{[]byte("body")},
+	}
+	calls := []*call{
+		{req: &Request{Method: "GET", URL: "https://example.com/b?alt=json", BodyParts: [][]byte{[]byte("body")}}},
+		{req: &Request{Method: "POST", URL: "https://example.com/b", Header: http.Header{"A": {"y"}}, BodyParts: [][]byte{[]byte("other")}}},
+		nil, // replayed
+	}
+	got := newMatchOptions().mismatchError(in, calls).Error()
+	want := `httpreplay: no recorded request matches POST https://example.com/b?alt=json
+nearest unreplayed recorded request is #1, POST https://example.com/b:
+  URL: got https://example.com/b?alt=json, recorded https://example.com/b
+  body part 0: got "body", recorded "other"
+  header A: got [x], recorded [y]`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	got = newMatchOptions().mismatchError(in, []*call{nil}).Error()
+	if !strings.Contains(got, "all recorded requests have been replayed") {
+		t.Errorf("got %q, want error about replayed requests", got)
+	}
+}
@@ -0,0 +1,783 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iam
+
+// This file implements the subset of the Common Expression Language (CEL)
+// that IAM Conditions use. See https://github.com/google/cel-spec and
+// https://cloud.google.com/iam/docs/conditions-overview.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Token kinds.
+const (
+	tokEOF = iota
+	tokIdent
+	tokInt
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind int
+	text string // the identifier, the punctuation, or the value of a string
+	pos  int
+}
+
+// lex splits a CEL expression into tokens.
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j], i})
+			i = j
+		case unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(s) && unicode.IsDigit(rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{tokInt, s[i:j], i})
+			i = j
+		case c == '"' || c == '\'':
+			str, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("at offset %d: %v", i, err)
+			}
+			toks = append(toks, token{tokString, str, i})
+			i += n
+		default:
+			p := ""
+			for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "(", ")", ".", ","} {
+				if strings.HasPrefix(s[i:], op) {
+					p = op
+					break
+				}
+			}
+			if p == "" {
+				return nil, fmt.Errorf("at offset %d: unexpected character %q", i, c)
+			}
+			toks = append(toks, token{tokPunct, p, i})
+			i += len(p)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(s)}), nil
+}
+
+// lexString reads a quoted string at the start of s, and returns its value
+// and its length in s.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("newline in string")
+		case c == '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			switch s[i] {
+			case '\\', '\'', '"', '`', '?':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				return "", 0, fmt.Errorf("unsupported escape sequence \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// A node is a node of the syntax tree of an expression.
+type node interface {
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct{ v interface{} }
+
+type identNode struct{ name string }
+
+// selectNode selects a field of an object, as in request.time.
+type selectNode struct {
+	operand node
+	field   string
+}
+
+// callNode calls a function, or a method of target if it is not nil.
+type callNode struct {
+	target node
+	fn     string
+	args   []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+// parser is a recursive descent parser of CEL expressions:
+//
+//	expr    = and { "||" and }
+//	and     = rel { "&&" rel }
+//	rel     = add [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) add ]
+//	add     = unary { ( "+" | "-" ) unary }
+//	unary   = ( "!" | "-" ) unary | member
+//	member  = primary { "." IDENT [ "(" args ")" ] }
+//	primary = IDENT [ "(" args ")" ] | INT | STRING | "(" expr ")"
+type parser struct {
+	toks []token
+	i    int
+}
+
+func parseCEL(s string) (node, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("at offset %d: unexpected %q", t.pos, t.text)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the punctuation ops.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokPunct {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.i++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return fmt.Errorf("unexpected end of expression, want %q", op)
+		}
+		return fmt.Errorf("at offset %d: unexpected %q, want %q", t.pos, t.text, op)
+	}
+	return nil
+}
+
+func (p *parser) expr() (node, error) {
+	return p.binary(p.and, "||")
+}
+
+func (p *parser) and() (node, error) {
+	return p.binary(p.rel, "&&")
+}
+
+func (p *parser) add() (node, error) {
+	return p.binary(p.unary, "+", "-")
+}
+
+// binary parses a left-associative sequence of operands separated by ops.
+func (p *parser) binary(operand func() (node, error), ops ...string) (node, error) {
+	n, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return n, nil
+		}
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		n = &binaryNode{op, n, r}
+	}
+}
+
+func (p *parser) rel() (node, error) {
+	n, err := p.add()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.accept("==", "!=", "<", "<=", ">", ">="); ok {
+		r, err := p.add()
+		if err != nil {
+			return nil, err
+		}
+		n = &binaryNode{op, n, r}
+	}
+	return n, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op, n}, nil
+	}
+	return p.member()
+}
+
+func (p *parser) member() (node, error) {
+	n, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); !ok {
+			return n, nil
+		}
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("at offset %d: unexpected %q, want a field or method name", t.pos, t.text)
+		}
+		if _, ok := p.accept("("); ok {
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			n = &callNode{n, t.text, args}
+		} else {
+			n = &selectNode{n, t.text}
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{nil, t.text, args}, nil
+		}
+		return &identNode{t.text}, nil
+	case tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", t.pos, err)
+		}
+		return &literalNode{n}, nil
+	case tokString:
+		return &literalNode{t.text}, nil
+	case tokPunct:
+		if t.text == "(" {
+			n, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("at offset %d: unexpected %q", t.pos, t.text)
+}
+
+// args parses the arguments of a call, after the opening parenthesis.
+func (p *parser) args() ([]node, error) {
+	var args []node
+	if _, ok := p.accept(")"); ok {
+		return nil, nil
+	}
+	for {
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, n)
+		if _, ok := p.accept(")"); ok {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Evaluation. Values are represented by bool, int64, string, time.Time,
+// time.Duration and map[string]interface{} for objects.
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.v, nil
+}
+
+func (n *identNode) eval(env map[string]interface{}) (interface{}, error) {
+	v, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("undeclared reference to %q", n.name)
+	}
+	return v, nil
+}
+
+func (n *selectNode) eval(env map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot select field %q of %s", n.field, typeName(v))
+	}
+	f, ok := obj[n.field]
+	if !ok {
+		return nil, fmt.Errorf("no such attribute %q", n.field)
+	}
+	return f, nil
+}
+
+func (n *unaryNode) eval(env map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case bool:
+		if n.op == "!" {
+			return !v, nil
+		}
+	case int64:
+		if n.op == "-" {
+			return -v, nil
+		}
+	case time.Duration:
+		if n.op == "-" {
+			return -v, nil
+		}
+	}
+	return nil, fmt.Errorf("no matching overload for %s%s", n.op, typeName(v))
+}
+
+func (n *binaryNode) eval(env map[string]interface{}) (interface{}, error) {
+	if n.op == "&&" || n.op == "||" {
+		return n.evalLogical(env)
+	}
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==", "!=":
+		eq, err := equal(l, r)
+		if err != nil {
+			return nil, err
+		}
+		return eq == (n.op == "=="), nil
+	case "<", "<=", ">", ">=":
+		c, err := compare(l, r)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "+":
+		switch l := l.(type) {
+		case int64:
+			if r, ok := r.(int64); ok {
+				return l + r, nil
+			}
+		case string:
+			if r, ok := r.(string); ok {
+				return l + r, nil
+			}
+		case time.Time:
+			if r, ok := r.(time.Duration); ok {
+				return l.Add(r), nil
+			}
+		case time.Duration:
+			switch r := r.(type) {
+			case time.Duration:
+				return l + r, nil
+			case time.Time:
+				return r.Add(l), nil
+			}
+		}
+	case "-":
+		switch l := l.(type) {
+		case int64:
+			if r, ok := r.(int64); ok {
+				return l - r, nil
+			}
+		case time.Time:
+			switch r := r.(type) {
+			case time.Time:
+				return l.Sub(r), nil
+			case time.Duration:
+				return l.Add(-r), nil
+			}
+		case time.Duration:
+			if r, ok := r.(time.Duration); ok {
+				return l - r, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no matching overload for %s %s %s", typeName(l), n.op, typeName(r))
+}
+
+// evalLogical evaluates && and ||. As in CEL, they are commutative: an error
+// on one side is ignored if the other side determines the result.
+func (n *binaryNode) evalLogical(env map[string]interface{}) (interface{}, error) {
+	short := n.op == "||" // the value of an operand that determines the result
+	var firstErr error
+	for _, operand := range []node{n.left, n.right} {
+		v, err := operand.eval(env)
+		if err == nil {
+			b, ok := v.(bool)
+			if !ok {
+				err = fmt.Errorf("no matching overload for %s on %s", n.op, typeName(v))
+			} else if b == short {
+				return short, nil
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return !short, nil
+}
+
+func equal(l, r interface{}) (bool, error) {
+	switch l := l.(type) {
+	case bool:
+		if r, ok := r.(bool); ok {
+			return l == r, nil
+		}
+	case time.Time:
+		if r, ok := r.(time.Time); ok {
+			return l.Equal(r), nil
+		}
+	case int64, string, time.Duration:
+		if c, err := compare(l, r); err == nil {
+			return c == 0, nil
+		}
+	}
+	return false, fmt.Errorf("no matching overload for %s == %s", typeName(l), typeName(r))
+}
+
+func compare(l, r interface{}) (int, error) {
+	switch l := l.(type) {
+	case int64:
+		if r, ok := r.(int64); ok {
+			return compareOrdered(l < r, l > r), nil
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	case time.Time:
+		if r, ok := r.(time.Time); ok {
+			return compareOrdered(l.Before(r), l.After(r)), nil
+		}
+	case time.Duration:
+		if r, ok := r.(time.Duration); ok {
+			return compareOrdered(l < r, l > r), nil
+		}
+	}
+	return 0, fmt.Errorf("no matching overload for comparing %s and %s", typeName(l), typeName(r))
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return +1
+	}
+	return 0
+}
+
+func (n *callNode) eval(env map[string]interface{}) (interface{}, error) {
+	var args []interface{}
+	for _, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	if n.target == nil {
+		return callFunction(n.fn, args)
+	}
+	target, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch t := target.(type) {
+	case string:
+		return callStringMethod(t, n.fn, args)
+	case time.Time:
+		return callTimestampMethod(t, n.fn, args)
+	}
+	return nil, fmt.Errorf("no matching overload for %s.%s", typeName(target), n.fn)
+}
+
+func callFunction(fn string, args []interface{}) (interface{}, error) {
+	var s string
+	if len(args) == 1 {
+		s, _ = args[0].(string)
+	}
+	switch fn {
+	case "timestamp":
+		if len(args) != 1 {
+			break
+		}
+		if t, ok := args[0].(time.Time); ok {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp: %v", err)
+		}
+		return t, nil
+	case "duration":
+		if len(args) != 1 {
+			break
+		}
+		if d, ok := args[0].(time.Duration); ok {
+			return d, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("duration: %v", err)
+		}
+		return d, nil
+	case "size":
+		if len(args) == 1 {
+			if _, ok := args[0].(string); ok {
+				return int64(len([]rune(s))), nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("undeclared reference to function %q", fn)
+	}
+	return nil, fmt.Errorf("no matching overload for %s(%s)", fn, typeNames(args))
+}
+
+func callStringMethod(s, fn string, args []interface{}) (interface{}, error) {
+	if len(args) == 0 && fn == "size" {
+		return int64(len([]rune(s))), nil
+	}
+	var arg string
+	ok := len(args) == 1
+	if ok {
+		arg, ok = args[0].(string)
+	}
+	if ok {
+		switch fn {
+		case "startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(s, arg), nil
+		case "contains":
+			return strings.Contains(s, arg), nil
+		case "matches":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("matches: %v", err)
+			}
+			return re.MatchString(s), nil
+		case "extract":
+			return extract(s, arg)
+		}
+	}
+	return nil, fmt.Errorf("no matching overload for string.%s(%s)", fn, typeNames(args))
+}
+
+// extract implements the extract function of IAM Conditions. The template
+// contains a single variable in braces, as in "projects/_/buckets/{name}/",
+// and extract returns the part of s that matches the variable, or the empty
+// string if s does not match. The text before the variable must occur in s,
+// and the text after it must occur after that; the shortest match is used.
+func extract(s, template string) (string, error) {
+	i := strings.IndexByte(template, '{')
+	j := strings.IndexByte(template, '}')
+	if i < 0 || j < i || strings.ContainsAny(template[j+1:], "{}") {
+		return "", fmt.Errorf("extract: template %q must contain one variable in braces", template)
+	}
+	prefix, suffix := template[:i], template[j+1:]
+	k := strings.Index(s, prefix)
+	if k < 0 {
+		return "", nil
+	}
+	rest := s[k+len(prefix):]
+	if suffix == "" {
+		return rest, nil
+	}
+	l := strings.Index(rest, suffix)
+	if l < 0 {
+		return "", nil
+	}
+	return rest[:l], nil
+}
+
+func callTimestampMethod(t time.Time, fn string, args []interface{}) (interface{}, error) {
+	loc := time.UTC
+	switch len(args) {
+	case 0:
+	case 1:
+		tz, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("no matching overload for timestamp.%s(%s)", fn, typeNames(args))
+		}
+		var err error
+		if loc, err = loadLocation(tz); err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+	default:
+		return nil, fmt.Errorf("no matching overload for timestamp.%s(%s)", fn, typeNames(args))
+	}
+	t = t.In(loc)
+	var v int
+	switch fn {
+	case "getFullYear":
+		v = t.Year()
+	case "getMonth": // zero-based
+		v = int(t.Month()) - 1
+	case "getDate": // one-based
+		v = t.Day()
+	case "getDayOfMonth": // zero-based
+		v = t.Day() - 1
+	case "getDayOfWeek": // zero-based, Sunday is 0
+		v = int(t.Weekday())
+	case "getDayOfYear": // zero-based
+		v = t.YearDay() - 1
+	case "getHours":
+		v = t.Hour()
+	case "getMinutes":
+		v = t.Minute()
+	case "getSeconds":
+		v = t.Second()
+	case "getMilliseconds":
+		v = t.Nanosecond() / 1e6
+	default:
+		return nil, fmt.Errorf("no matching overload for timestamp.%s", fn)
+	}
+	return int64(v), nil
+}
+
+// loadLocation returns the location of a CEL time zone, which is an IANA
+// name such as "Europe/Berlin" or a UTC offset such as "+01:00".
+func loadLocation(tz string) (*time.Location, error) {
+	if len(tz) > 0 && (tz[0] == '+' || tz[0] == '-') {
+		t, err := time.Parse("-07:00", tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q", tz)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+	return time.LoadLocation(tz)
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case string:
+		return "string"
+	case time.Time:
+		return "timestamp"
+	case time.Duration:
+		return "duration"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func typeNames(vs []interface{}) string {
+	var names []string
+	for _, v := range vs {
+		names = append(names, typeName(v))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iam
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "google.golang.org/genproto/googleapis/iam/v1"
+)
+
+// A Condition is a parsed IAM Condition expression, which can be evaluated
+// locally, for instance to test the conditional bindings of a Policy3.
+//
+// The expression language is a subset of the Common Expression Language
+// (CEL) that IAM Conditions use. The supported expressions are:
+//
+//   - the attributes request.time, resource.name, resource.type and
+//     resource.service;
+//   - bool, integer and string literals, and parentheses;
+//   - the operators &&, ||, !, ==, !=, <, <=, > and >=, unary minus, and
+//     binary plus and minus, which add and subtract integers, durations and
+//     timestamps, and concatenate strings;
+//   - the functions timestamp, duration and size;
+//   - the string methods startsWith, endsWith, contains, matches, extract
+//     and size;
+//   - the timestamp methods getFullYear, getMonth, getDate, getDayOfMonth,
+//     getDayOfWeek, getDayOfYear, getHours, getMinutes, getSeconds and
+//     getMilliseconds, with an optional time zone.
+//
+// Other attributes, such as resource tags and API attributes, and other CEL
+// features, such as the operators *, /, %, ?: and in, lists, maps and
+// floating-point numbers, are not supported.
+type Condition struct {
+	expr string
+	root node
+}
+
+// ParseCondition parses an IAM Condition expression, such as
+//
+//	request.time < timestamp("2024-01-01T00:00:00Z") &&
+//	    resource.name.startsWith("projects/_/buckets/my-bucket/")
+func ParseCondition(expr string) (*Condition, error) {
+	root, err := parseCEL(expr)
+	if err != nil {
+		return nil, fmt.Errorf("iam: parsing condition %q: %v", expr, err)
+	}
+	return &Condition{expr: expr, root: root}, nil
+}
+
+// String returns the expression of the condition.
+func (c *Condition) String() string {
+	return c.expr
+}
+
+// A Resource describes a resource for the evaluation of conditions.
+type Resource struct {
+	// Name is the full resource name, as in
+	// "projects/_/buckets/my-bucket/objects/my-object". It is the value of
+	// resource.name.
+	Name string
+
+	// Type is the type of the resource, as in "storage.googleapis.com/Object".
+	// It is the value of resource.type.
+	Type string
+
+	// Service is the service of the resource, as in "storage.googleapis.com".
+	// It is the value of resource.service.
+	Service string
+}
+
+// Eval evaluates the condition for a request at time t on resource r. It
+// returns an error if the expression does not evaluate to a bool, for
+// instance because it uses an unsupported attribute.
+func (c *Condition) Eval(t time.Time, r Resource) (bool, error) {
+	env := map[string]interface{}{
+		"request": map[string]interface{}{
+			"time": t,
+		},
+		"resource": map[string]interface{}{
+			"name":    r.Name,
+			"type":    r.Type,
+			"service": r.Service,
+		},
+	}
+	v, err := c.root.eval(env)
+	if err != nil {
+		return false, fmt.Errorf("iam: evaluating condition %q: %v", c.expr, err)
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("iam: evaluating condition %q: got %s, want bool", c.expr, typeName(v))
+	}
+	return b, nil
+}
+
+// An AccessRequest is a request to access a resource, for
+// Policy3.CheckAccess.
+type AccessRequest struct {
+	// Member is the principal making the request, as in
+	// "user:alice@example.com" or "serviceAccount:sa@p.iam.gserviceaccount.com".
+	Member string
+
+	// Permission is the permission that the request needs, as in
+	// "storage.objects.get".
+	Permission string
+
+	// Time is the time of the request, the value of request.time.
+	Time time.Time
+
+	// Resource is the resource that the request accesses.
+	Resource Resource
+}
+
+// CheckAccess reports whether the policy grants the permission of req to
+// its member, evaluating the conditions of the bindings locally. The
+// permissions of each role are given by roles; bindings whose roles are not
+// in roles grant nothing.
+//
+// A binding applies to req.Member if it lists the member, allUsers, or
+// allAuthenticatedUsers, or if it lists a domain (as in "domain:example.com")
+// that matches the email address of the member. Group memberships are not
+// resolved.
+//
+// If no binding grants the permission and a condition could not be
+// evaluated, CheckAccess returns false and the error.
+func (p *Policy3) CheckAccess(req *AccessRequest, roles map[RoleName][]string) (bool, error) {
+	var firstErr error
+	for _, b := range p.Bindings {
+		if !hasPermission(roles[RoleName(b.Role)], req.Permission) || !bindingHasMember(b, req.Member) {
+			continue
+		}
+		ok, err := evalBindingCondition(b, req)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, firstErr
+}
+
+func hasPermission(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func bindingHasMember(b *pb.Binding, member string) bool {
+	domain := ""
+	if i := strings.LastIndexByte(member, '@'); i >= 0 && strings.Contains(member[:i], ":") {
+		domain = "domain:" + member[i+1:]
+	}
+	for _, m := range b.Members {
+		switch {
+		case m == member, m == AllUsers:
+			return true
+		case m == AllAuthenticatedUsers && member != AllUsers && member != "":
+			return true
+		case domain != "" && m == domain:
+			return true
+		}
+	}
+	return false
+}
+
+// evalBindingCondition evaluates the condition of a binding, if it has one.
+func evalBindingCondition(b *pb.Binding, req *AccessRequest) (bool, error) {
+	expr := b.GetCondition().GetExpression()
+	if expr == "" {
+		return true, nil
+	}
+	c, err := ParseCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	return c.Eval(req.Time, req.Resource)
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iam
+
+import (
+	"testing"
+	"time"
+
+	pb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/genproto/googleapis/type/expr"
+)
+
+func TestConditionEval(t *testing.T) {
+	// A Monday.
+	now := time.Date(2023, 1, 2, 15, 4, 5, 6e6, time.UTC)
+	res := Resource{
+		Name:    "projects/_/buckets/my-bucket/objects/reports/q1.csv",
+		Type:    "storage.googleapis.com/Object",
+		Service: "storage.googleapis.com",
+	}
+	for _, test := range []struct {
+		expr string
+		want bool
+	}{
+		{`true`, true},
+		{`!true`, false},
+		{`request.time < timestamp("2024-01-01T00:00:00Z")`, true},
+		{`request.time >= timestamp('2024-01-01T00:00:00Z')`, false},
+		{`request.time == timestamp("2023-01-02T16:04:05.006+01:00")`, true},
+		{`request.time + duration("9h") > timestamp("2023-01-03T00:00:00Z")`, true},
+		{`timestamp("2023-01-03T00:00:00Z") - request.time < duration("8h")`, false},
+		{`request.time.getHours() == 15`, true},
+		{`request.time.getHours("Europe/Berlin") == 16`, true},
+		{`request.time.getHours("-05:00") == 10`, true},
+		{`request.time.getDayOfWeek() == 1 && request.time.getMonth() == 0`, true},
+		{`request.time.getDate() == 2 && request.time.getDayOfMonth() == 1`, true},
+		{`request.time.getFullYear() == 2023 && request.time.getDayOfYear() == 1`, true},
+		{`request.time.getMinutes() == 4 && request.time.getSeconds() == 5 && request.time.getMilliseconds() == 6`, true},
+		{`resource.name.startsWith("projects/_/buckets/my-bucket/")`, true},
+		{`resource.name.endsWith(".csv") && resource.name.contains("/reports/")`, true},
+		{`resource.name.matches("objects/reports/q[1-4]\\.csv$")`, true},
+		{`resource.name.extract("/buckets/{name}/") == "my-bucket"`, true},
+		{`resource.name.extract("/objects/{name}") == "reports/q1.csv"`, true},
+		{`resource.name.extract("/tables/{name}/") == ""`, true},
+		{`resource.type == "storage.googleapis.com/Object" && resource.service == "storage.googleapis.com"`, true},
+		{`resource.type != "storage.googleapis.com/Bucket" || false`, true},
+		{`-(1 + 2) - -3 == 0`, true},
+		{`"a" + 'b' < "b" && size("héllo") == 5 && "abc".size() == 3`, true},
+		// Errors on one side of && and || are ignored if the other side
+		// determines the result.
+		{`request.ip == "1.2.3.4" || true`, true},
+		{`false && request.ip == "1.2.3.4"`, false},
+	} {
+		c, err := ParseCondition(test.expr)
+		if err != nil {
+			t.Errorf("ParseCondition(%q): %v", test.expr, err)
+			continue
+		}
+		got, err := c.Eval(now, res)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %t, want %t", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`request.time <`,
+		`(true`,
+		`true true`,
+		`"unterminated`,
+		`request.time # 1`,
+		`resource.name.`,
+		`f(1,)`,
+	} {
+		if _, err := ParseCondition(expr); err == nil {
+			t.Errorf("ParseCondition(%q): got nil, want error", expr)
+		}
+	}
+
+	for _, expr := range []string{
+		`request.ip == "1.2.3.4"`,
+		`origin.ip == "1.2.3.4"`,
+		`resource.name == 1`,
+		`resource.name`,
+		`timestamp("not a time") < request.time`,
+		`request.time.getHours("Nowhere/Nothing") == 1`,
+		`resource.name.extract("no variable") == ""`,
+		`unknown(resource.name)`,
+		`request.ip == "1.2.3.4" && true`,
+	} {
+		c, err := ParseCondition(expr)
+		if err != nil {
+			t.Errorf("ParseCondition(%q): %v", expr, err)
+			continue
+		}
+		if _, err := c.Eval(time.Now(), Resource{}); err == nil {
+			t.Errorf("%q: got nil, want error", expr)
+		}
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	roles := map[RoleName][]string{
+		"roles/storage.objectViewer": {"storage.objects.get", "storage.objects.list"},
+		"roles/storage.objectAdmin":  {"storage.objects.get", "storage.objects.list", "storage.objects.delete"},
+	}
+	p := &Policy3{Bindings: []*pb.Binding{
+		{
+			Role:    "roles/storage.objectViewer",
+			Members: []string{"domain:example.com"},
+		},
+		{
+			Role:    "roles/storage.objectAdmin",
+			Members: []string{"user:alice@example.com"},
+			Condition: &expr.Expr{
+				Title:      "expires",
+				Expression: `request.time < timestamp("2024-01-01T00:00:00Z") && resource.name.startsWith("projects/_/buckets/b/objects/tmp/")`,
+			},
+		},
+		{
+			Role:    "roles/storage.objectAdmin",
+			Members: []string{"user:bob@example.com"},
+			Condition: &expr.Expr{
+				Expression: `request.ip == "1.2.3.4"`,
+			},
+		},
+	}}
+	before := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tmp := Resource{Name: "projects/_/buckets/b/objects/tmp/x"}
+	for _, test := range []struct {
+		desc    string
+		req     AccessRequest
+		want    bool
+		wantErr bool
+	}{
+		{"domain member", AccessRequest{Member: "user:carol@example.com", Permission: "storage.objects.get"}, true, false},
+		{"other domain", AccessRequest{Member: "user:dave@example.org", Permission: "storage.objects.get"}, false, false},
+		{"permission not in role", AccessRequest{Member: "user:carol@example.com", Permission: "storage.objects.delete"}, false, false},
+		{"condition true", AccessRequest{"user:alice@example.com", "storage.objects.delete", before, tmp}, true, false},
+		{"condition false on time", AccessRequest{"user:alice@example.com", "storage.objects.delete", after, tmp}, false, false},
+		{"condition false on resource", AccessRequest{"user:alice@example.com", "storage.objects.delete", before, Resource{Name: "projects/_/buckets/b/objects/x"}}, false, false},
+		{"condition error", AccessRequest{"user:bob@example.com", "storage.objects.delete", before, tmp}, false, true},
+		{"error ignored if granted", AccessRequest{"user:bob@example.com", "storage.objects.get", before, tmp}, true, false},
+	} {
+		got, err := p.CheckAccess(&test.req, roles)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("%s: got %t, %v, want %t and error %t", test.desc, got, err, test.want, test.wantErr)
+		}
+	}
+
+	public := &Policy3{Bindings: []*pb.Binding{{Role: "roles/storage.objectViewer", Members: []string{AllUsers}}}}
+	if ok, err := public.CheckAccess(&AccessRequest{Member: AllUsers, Permission: "storage.objects.get"}, roles); !ok || err != nil {
+		t.Errorf("allUsers: got %t, %v, want true", ok, err)
+	}
+}
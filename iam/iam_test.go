@@ -15,11 +15,21 @@
 package iam
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"sort"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/internal/testutil"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/api/googleapi"
+	pb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestPolicy(t *testing.T) {
@@ -85,3 +95,188 @@ func checkMembers(p *Policy, role RoleName, wantMembers []string) (string, bool)
 	}
 	return "", true
 }
+
+// fakeClient is a client that stores a policy, and checks etags like the
+// service.
+type fakeClient struct {
+	policy *pb.Policy
+	gets   int
+	sets   int
+	// beforeSet is called by Set before it checks the etag, to simulate
+	// concurrent updates.
+	beforeSet func()
+	// conflictErr is returned by Set on an etag mismatch. If it is nil, Set
+	// returns an error with code Aborted.
+	conflictErr error
+}
+
+func (c *fakeClient) Get(ctx context.Context, resource string) (*pb.Policy, error) {
+	return c.GetWithVersion(ctx, resource, 1)
+}
+
+func (c *fakeClient) GetWithVersion(_ context.Context, _ string, _ int32) (*pb.Policy, error) {
+	c.gets++
+	return proto.Clone(c.policy).(*pb.Policy), nil
+}
+
+func (c *fakeClient) Set(_ context.Context, _ string, p *pb.Policy) error {
+	c.sets++
+	if c.beforeSet != nil {
+		c.beforeSet()
+	}
+	if !bytes.Equal(p.Etag, c.policy.Etag) {
+		if c.conflictErr != nil {
+			return c.conflictErr
+		}
+		return status.Error(codes.Aborted, "etag mismatch")
+	}
+	c.policy = proto.Clone(p).(*pb.Policy)
+	c.policy.Etag = []byte(fmt.Sprint(c.sets))
+	return nil
+}
+
+func (c *fakeClient) Test(context.Context, string, []string) ([]string, error) {
+	return nil, nil
+}
+
+func TestUpdate(t *testing.T) {
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+	sleep = func(context.Context, time.Duration) error { return nil }
+	ctx := context.Background()
+
+	c := &fakeClient{policy: &pb.Policy{Etag: []byte("0")}}
+	// The first Set conflicts with a concurrent update.
+	c.beforeSet = func() {
+		c.beforeSet = nil
+		c.policy.Bindings = []*pb.Binding{{Role: string(Viewer), Members: []string{"user:other"}}}
+		c.policy.Etag = []byte("other")
+	}
+	h := InternalNewHandleClient(c, "resource")
+	err := h.Update(ctx, func(p *Policy) error {
+		p.Add("user:me", Viewer)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := (&Policy{InternalProto: c.policy}).Members(Viewer)
+	sort.Strings(got)
+	if want := []string{"user:me", "user:other"}; !testutil.Equal(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+	if c.gets != 2 || c.sets != 2 {
+		t.Errorf("got %d gets and %d sets, want 2 and 2", c.gets, c.sets)
+	}
+
+	// An unchanged policy is not set.
+	c.gets, c.sets = 0, 0
+	if err := h.Update(ctx, func(p *Policy) error { p.Add("user:me", Viewer); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if c.sets != 0 {
+		t.Errorf("got %d sets for an unchanged policy, want 0", c.sets)
+	}
+
+	// Errors from f are returned.
+	errF := errors.New("f")
+	if err := h.Update(ctx, func(*Policy) error { return errF }); err != errF {
+		t.Errorf("got %v, want %v", err, errF)
+	}
+
+	// Persistent conflicts eventually fail.
+	c.gets, c.sets = 0, 0
+	var conflict func()
+	conflict = func() {
+		c.policy.Etag = append(c.policy.Etag, 'x')
+		c.beforeSet = conflict
+	}
+	c.beforeSet = conflict
+	err = h.V3().Update(ctx, func(p *Policy3) error {
+		p.Bindings = append(p.Bindings, &pb.Binding{Role: string(Owner), Members: []string{"user:me"}})
+		return nil
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("got %v, want code Aborted", err)
+	}
+	if c.sets != maxUpdateAttempts {
+		t.Errorf("got %d sets, want %d", c.sets, maxUpdateAttempts)
+	}
+}
+
+func TestUpdateHTTPConflict(t *testing.T) {
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+	sleep = func(context.Context, time.Duration) error { return nil }
+
+	// Clients of JSON APIs, like the storage client, report conflicts with
+	// an HTTP status.
+	c := &fakeClient{
+		policy:      &pb.Policy{Etag: []byte("0")},
+		conflictErr: &googleapi.Error{Code: http.StatusPreconditionFailed, Message: "Precondition Failed"},
+	}
+	c.beforeSet = func() {
+		c.beforeSet = nil
+		c.policy.Etag = []byte("other")
+	}
+	h := InternalNewHandleClient(c, "resource")
+	err := h.Update(context.Background(), func(p *Policy) error {
+		p.Add("user:me", Viewer)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.sets != 2 {
+		t.Errorf("got %d sets, want 2", c.sets)
+	}
+}
+
+func TestIsConflict(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{status.Error(codes.Aborted, ""), true},
+		{status.Error(codes.FailedPrecondition, ""), true},
+		{status.Error(codes.PermissionDenied, ""), false},
+		{&googleapi.Error{Code: http.StatusPreconditionFailed}, true},
+		{&googleapi.Error{Code: http.StatusConflict}, true},
+		{fmt.Errorf("wrapped: %w", &googleapi.Error{Code: http.StatusConflict}), true},
+		{&googleapi.Error{Code: http.StatusForbidden}, false},
+		{errors.New("other"), false},
+	} {
+		if got := isConflict(test.err); got != test.want {
+			t.Errorf("isConflict(%v): got %t, want %t", test.err, got, test.want)
+		}
+	}
+}
+
+func TestAddRemoveMembers(t *testing.T) {
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+	sleep = func(context.Context, time.Duration) error { return nil }
+	ctx := context.Background()
+	c1 := &fakeClient{policy: &pb.Policy{}}
+	c2 := &fakeClient{policy: &pb.Policy{Bindings: []*pb.Binding{{Role: string(Editor), Members: []string{"user:a"}}}}}
+	handles := []*Handle{InternalNewHandleClient(c1, "r1"), InternalNewHandleClient(c2, "r2")}
+
+	if err := AddMembers(ctx, handles, Editor, "user:a", "user:b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*fakeClient{c1, c2} {
+		got := (&Policy{InternalProto: c.policy}).Members(Editor)
+		sort.Strings(got)
+		if want := []string{"user:a", "user:b"}; !testutil.Equal(got, want) {
+			t.Errorf("got members %v, want %v", got, want)
+		}
+	}
+
+	c2.beforeSet = func() { c2.policy.Etag = append(c2.policy.Etag, 'x') }
+	err := RemoveMembers(ctx, handles, Editor, "user:a")
+	merr, ok := err.(MultiError)
+	if !ok || len(merr) != 2 || merr[0] != nil || status.Code(merr[1]) != codes.Aborted {
+		t.Fatalf("got %v, want a MultiError with code Aborted for r2", err)
+	}
+	if got, want := (&Policy{InternalProto: c1.policy}).Members(Editor), []string{"user:b"}; !testutil.Equal(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+}
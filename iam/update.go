@@ -0,0 +1,193 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/googleapi"
+	pb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxUpdateAttempts is the number of times Update tries to set a policy
+// before giving up on conflicts.
+const maxUpdateAttempts = 10
+
+// sleep is stubbed to be overrideable for testing.
+var sleep = gax.Sleep
+
+// Update modifies the IAM policy of the resource. It retrieves the policy,
+// calls f to modify it, and sets the modified policy. The modification only
+// succeeds if the policy has not changed since it was retrieved. If it has,
+// Update retries with backoff, retrieving the policy again and calling f
+// with it, until the modification succeeds, ctx is done, or a number of
+// attempts have failed.
+//
+// If f returns an error, Update returns it without setting the policy. If f
+// leaves the policy unchanged, the policy is not set. As f may be called
+// several times, it should only depend on the policy it is given; methods
+// like Policy.Add and Policy.Remove are suitable.
+func (h *Handle) Update(ctx context.Context, f func(*Policy) error) error {
+	return retryOnConflict(ctx, func() (bool, error) {
+		p, err := h.Policy(ctx)
+		if err != nil {
+			return false, err
+		}
+		orig := proto.Clone(p.InternalProto)
+		if err := f(p); err != nil {
+			return false, err
+		}
+		if proto.Equal(orig, p.InternalProto) {
+			return false, nil
+		}
+		err = h.SetPolicy(ctx, p)
+		return isConflict(err), err
+	})
+}
+
+// Update modifies the IAM policy of the resource, as described for
+// Handle.Update.
+func (h *Handle3) Update(ctx context.Context, f func(*Policy3) error) error {
+	return retryOnConflict(ctx, func() (bool, error) {
+		p, err := h.Policy(ctx)
+		if err != nil {
+			return false, err
+		}
+		orig := proto.Clone(&pb.Policy{Bindings: p.Bindings})
+		if err := f(p); err != nil {
+			return false, err
+		}
+		if proto.Equal(orig, &pb.Policy{Bindings: p.Bindings}) {
+			return false, nil
+		}
+		err = h.SetPolicy(ctx, p)
+		return isConflict(err), err
+	})
+}
+
+// retryOnConflict calls attempt until it succeeds, it fails without a
+// conflict, or maxUpdateAttempts attempts have failed.
+func retryOnConflict(ctx context.Context, attempt func() (conflict bool, err error)) error {
+	bo := gax.Backoff{
+		Initial:    100 * time.Millisecond,
+		Max:        10 * time.Second,
+		Multiplier: 2,
+	}
+	for i := 1; ; i++ {
+		conflict, err := attempt()
+		if !conflict || i == maxUpdateAttempts {
+			return err
+		}
+		if err := sleep(ctx, bo.Pause()); err != nil {
+			return err
+		}
+	}
+}
+
+// isConflict reports whether an error from setting a policy means that the
+// policy has changed since it was retrieved. Clients of JSON APIs, such as
+// the storage client, report an etag mismatch with an HTTP status of 412 or
+// 409.
+func isConflict(err error) bool {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code == http.StatusPreconditionFailed || gerr.Code == http.StatusConflict
+	}
+	switch status.Code(err) {
+	case codes.Aborted, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+// AddMembers adds members to role r in the policies of the resources of
+// handles, using Update. Members that already have the role are skipped, so
+// AddMembers can safely be called again after a partial failure.
+//
+// The policies are updated in order. If some updates fail, AddMembers
+// returns a MultiError, and continues with the other resources.
+func AddMembers(ctx context.Context, handles []*Handle, r RoleName, members ...string) error {
+	return updateAll(ctx, handles, func(p *Policy) error {
+		for _, m := range members {
+			p.Add(m, r)
+		}
+		return nil
+	})
+}
+
+// RemoveMembers removes members from role r in the policies of the
+// resources of handles, using Update. Members that do not have the role are
+// skipped, so RemoveMembers can safely be called again after a partial
+// failure.
+//
+// The policies are updated in order. If some updates fail, RemoveMembers
+// returns a MultiError, and continues with the other resources.
+func RemoveMembers(ctx context.Context, handles []*Handle, r RoleName, members ...string) error {
+	return updateAll(ctx, handles, func(p *Policy) error {
+		for _, m := range members {
+			p.Remove(m, r)
+		}
+		return nil
+	})
+}
+
+func updateAll(ctx context.Context, handles []*Handle, f func(*Policy) error) error {
+	errs := make(MultiError, len(handles))
+	failed := false
+	for i, h := range handles {
+		if err := h.Update(ctx, f); err != nil {
+			errs[i] = err
+			failed = true
+		}
+	}
+	if failed {
+		return errs
+	}
+	return nil
+}
+
+// MultiError is returned by AddMembers and RemoveMembers when the updates
+// of some policies fail. Errors will be in a one-to-one correspondence with
+// the handles; successful updates will have a nil entry.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	s, n := "", 0
+	for _, e := range m {
+		if e != nil {
+			if n == 0 {
+				s = e.Error()
+			}
+			n++
+		}
+	}
+	switch n {
+	case 0:
+		return "(0 errors)"
+	case 1:
+		return s
+	case 2:
+		return s + " (and 1 other error)"
+	}
+	return fmt.Sprintf("%s (and %d other errors)", s, n-1)
+}
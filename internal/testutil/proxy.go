@@ -0,0 +1,360 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// A FaultRule describes a fault that a Proxy injects into the calls that
+// match it.
+//
+// A rule with only a Delay slows the matching calls down. A rule with a Code,
+// Disconnect or AfterMessages makes them fail: if AfterMessages is zero, the
+// call fails without reaching the backend; otherwise it is forwarded, and
+// fails after AfterMessages response messages have been sent to the client.
+type FaultRule struct {
+	// Method is the full name of the method that the rule applies to, as in
+	// "/google.pubsub.v1.Publisher/Publish". If it ends with a slash, as in
+	// "/google.pubsub.v1.Publisher/", the rule applies to all the methods of
+	// the service. If it is empty, the rule applies to all methods.
+	Method string
+
+	// Calls are the numbers of the calls of the method that the rule applies
+	// to, counting from 1. If Calls is empty, the rule applies to all calls.
+	Calls []int
+
+	// Delay is how long the proxy waits before handling the call.
+	Delay time.Duration
+
+	// Code and Message are the status of the failed call. If Code is OK and
+	// AfterMessages is not zero, the stream ends successfully, but early.
+	Code    codes.Code
+	Message string
+
+	// Disconnect closes the connection of the client abruptly, as if it had
+	// been reset, instead of returning a status.
+	Disconnect bool
+
+	// AfterMessages is the number of response messages that are forwarded to
+	// the client before the fault is injected. For a unary call, an
+	// AfterMessages of 1 means that the backend handles the call, but the
+	// client does not see the response.
+	AfterMessages int
+}
+
+func (r *FaultRule) matches(method string, call int) bool {
+	switch {
+	case r.Method == "", r.Method == method:
+	case strings.HasSuffix(r.Method, "/") && strings.HasPrefix(method, r.Method):
+	default:
+		return false
+	}
+	if len(r.Calls) == 0 {
+		return true
+	}
+	for _, c := range r.Calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *FaultRule) fails() bool {
+	return r.Code != codes.OK || r.Disconnect || r.AfterMessages > 0
+}
+
+// A Proxy is an in-process gRPC proxy, listening on a system-chosen port on
+// the local loopback interface, which forwards all calls to a backend, such
+// as a fake server, and injects faults into them according to rules. Proxies
+// are for testing the retry logic of clients, and are not intended to be used
+// in production code.
+//
+// To test a client, create a Proxy for its fake server, add rules, and
+// connect the client to the proxy with no security:
+//
+//	proxy, err := NewProxy(srv.Addr)
+//	...
+//	defer proxy.Close()
+//	proxy.AddRule(FaultRule{
+//		Method: "/google.pubsub.v1.Publisher/Publish",
+//		Calls:  []int{1, 2},
+//		Code:   codes.Unavailable,
+//	})
+//	client, err := pubsub.NewClient(ctx, "P",
+//		option.WithEndpoint(proxy.Addr),
+//		option.WithoutAuthentication(),
+//		option.WithGRPCDialOption(grpc.WithInsecure()))
+//	...
+//	if got := proxy.Calls("/google.pubsub.v1.Publisher/Publish"); got != 3 {
+//		...
+//	}
+//
+// Messages are forwarded without being decoded, so a Proxy works with any
+// service.
+type Proxy struct {
+	Addr string
+
+	srv     *Server
+	l       *connTrackingListener
+	backend *grpc.ClientConn
+
+	mu    sync.Mutex
+	rules []FaultRule
+	calls map[string]int
+}
+
+// NewProxy creates a Proxy forwarding calls to the gRPC server at backendAddr,
+// and starts it. The backend is dialed without TLS, unless opts say otherwise.
+func NewProxy(backendAddr string, opts ...grpc.DialOption) (*Proxy, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	backend, err := grpc.Dial(backendAddr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	p := &Proxy{
+		Addr:    l.Addr().String(),
+		l:       &connTrackingListener{Listener: l, conns: make(map[string]net.Conn)},
+		backend: backend,
+		calls:   make(map[string]int),
+	}
+	p.srv = &Server{
+		Addr: p.Addr,
+		Port: parsePort(p.Addr),
+		l:    p.l,
+		Gsrv: grpc.NewServer(grpc.UnknownServiceHandler(p.handle), grpc.ForceServerCodec(rawCodec{})),
+	}
+	p.srv.Start()
+	return p, nil
+}
+
+// AddRule adds a rule to the proxy. For each call, the first rule that
+// matches it applies.
+func (p *Proxy) AddRule(r FaultRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, r)
+}
+
+// Reset removes the rules of the proxy, and resets its call counts.
+func (p *Proxy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = nil
+	p.calls = make(map[string]int)
+}
+
+// Calls returns the number of calls of a method that the proxy has received,
+// including the failed ones. The method is named as in FaultRule.Method.
+func (p *Proxy) Calls(method string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[method]
+}
+
+// Close shuts down the proxy. It does not close the backend.
+func (p *Proxy) Close() {
+	p.srv.Close()
+	p.backend.Close()
+}
+
+// handle handles every call, streaming or not, as a bidirectional stream.
+func (p *Proxy) handle(_ interface{}, ss grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(ss)
+	if !ok {
+		return status.Error(codes.Internal, "testutil: proxy: no method in stream")
+	}
+	rule := p.rule(method)
+	if rule == nil {
+		return p.forward(ss, method, nil)
+	}
+	if rule.Delay > 0 {
+		t := time.NewTimer(rule.Delay)
+		select {
+		case <-t.C:
+		case <-ss.Context().Done():
+			t.Stop()
+			return status.FromContextError(ss.Context().Err()).Err()
+		}
+	}
+	switch {
+	case !rule.fails():
+		return p.forward(ss, method, nil)
+	case rule.AfterMessages == 0:
+		return p.inject(ss, rule)
+	default:
+		return p.forward(ss, method, rule)
+	}
+}
+
+// rule counts a call of method, and returns the rule that applies to it, or
+// nil.
+func (p *Proxy) rule(method string) *FaultRule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls[method]++
+	for i := range p.rules {
+		if p.rules[i].matches(method, p.calls[method]) {
+			r := p.rules[i]
+			return &r
+		}
+	}
+	return nil
+}
+
+// inject fails a call as described by rule.
+func (p *Proxy) inject(ss grpc.ServerStream, rule *FaultRule) error {
+	if rule.Disconnect {
+		if pr, ok := peer.FromContext(ss.Context()); ok {
+			p.l.closeConn(pr.Addr.String())
+		}
+		return status.Error(codes.Unavailable, "testutil: proxy closed the connection")
+	}
+	return status.Error(rule.Code, rule.Message)
+}
+
+// forward forwards a call to the backend. If rule is not nil, the call fails
+// after rule.AfterMessages responses.
+func (p *Proxy) forward(ss grpc.ServerStream, method string, rule *FaultRule) error {
+	ctx, cancel := context.WithCancel(ss.Context())
+	defer cancel()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = metadata.NewOutgoingContext(ctx, md.Copy())
+	}
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	cs, err := p.backend.NewStream(ctx, desc, method, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	// Forward the requests. The backend reports any failure, so errors are
+	// only used to stop.
+	go func() {
+		for {
+			var m []byte
+			if err := ss.RecvMsg(&m); err != nil {
+				if err == io.EOF {
+					cs.CloseSend()
+				}
+				return
+			}
+			if err := cs.SendMsg(&m); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Forward the responses.
+	if md, err := cs.Header(); err == nil && len(md) > 0 {
+		if err := ss.SendHeader(md); err != nil {
+			return err
+		}
+	}
+	for n := 0; ; n++ {
+		if rule != nil && n == rule.AfterMessages {
+			return p.inject(ss, rule)
+		}
+		var m []byte
+		if err := cs.RecvMsg(&m); err != nil {
+			ss.SetTrailer(cs.Trailer())
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := ss.SendMsg(&m); err != nil {
+			return err
+		}
+	}
+}
+
+// rawCodec passes messages through without decoding them. Messages are
+// *[]byte.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("testutil: proxy: cannot marshal %T", v)
+	}
+	return *m, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("testutil: proxy: cannot unmarshal into %T", v)
+	}
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+// Name returns the name of the proto codec, so that clients and servers see
+// the usual content type.
+func (rawCodec) Name() string { return "proto" }
+
+// connTrackingListener records the connections it accepts, so that they can
+// be closed abruptly.
+type connTrackingListener struct {
+	net.Listener
+
+	mu    sync.Mutex
+	conns map[string]net.Conn // by remote address
+}
+
+func (l *connTrackingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	l.conns[c.RemoteAddr().String()] = c
+	l.mu.Unlock()
+	return c, nil
+}
+
+func (l *connTrackingListener) closeConn(remoteAddr string) {
+	l.mu.Lock()
+	c := l.conns[remoteAddr]
+	delete(l.conns, remoteAddr)
+	l.mu.Unlock()
+	if c == nil {
+		return
+	}
+	// Discard unsent data, so that the client sees a reset.
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.SetLinger(0)
+	}
+	c.Close()
+}
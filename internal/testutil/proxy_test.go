@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	checkMethod = "/grpc.health.v1.Health/Check"
+	watchMethod = "/grpc.health.v1.Health/Watch"
+)
+
+func newTestProxy(t *testing.T) (*Proxy, healthpb.HealthClient, *health.Server) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv.Gsrv, hs)
+	srv.Start()
+
+	proxy, err := NewProxy(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(proxy.Close)
+	conn, err := grpc.Dial(proxy.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proxy, healthpb.NewHealthClient(conn), hs
+}
+
+func TestProxyFaults(t *testing.T) {
+	ctx := context.Background()
+	proxy, client, _ := newTestProxy(t)
+	proxy.AddRule(FaultRule{Method: checkMethod, Calls: []int{2, 3}, Code: codes.Unavailable, Message: "try again"})
+	proxy.AddRule(FaultRule{Method: checkMethod, Calls: []int{4}, Disconnect: true})
+	proxy.AddRule(FaultRule{Method: checkMethod, Calls: []int{5}, Code: codes.Aborted, AfterMessages: 1})
+	proxy.AddRule(FaultRule{Method: "/grpc.health.v1.Health/", Calls: []int{6}, Code: codes.Internal})
+
+	for i, want := range []codes.Code{codes.OK, codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.Aborted, codes.Internal, codes.OK} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if got := status.Code(err); got != want {
+			t.Errorf("call %d: got %v (%v), want %v", i+1, got, err, want)
+		}
+		if err == nil && resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("call %d: got status %v, want SERVING", i+1, resp.Status)
+		}
+	}
+	if got, want := proxy.Calls(checkMethod), 7; got != want {
+		t.Errorf("Calls: got %d, want %d", got, want)
+	}
+
+	proxy.Reset()
+	if got := proxy.Calls(checkMethod); got != 0 {
+		t.Errorf("Calls after Reset: got %d, want 0", got)
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("after Reset: %v", err)
+	}
+}
+
+func TestProxyDelay(t *testing.T) {
+	proxy, client, _ := newTestProxy(t)
+	proxy.AddRule(FaultRule{Method: checkMethod, Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+
+	proxy.Reset()
+	proxy.AddRule(FaultRule{Delay: 10 * time.Millisecond})
+	start := time.Now()
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("call took %v, want at least 10ms", d)
+	}
+}
+
+func TestProxyStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	proxy, client, hs := newTestProxy(t)
+	proxy.AddRule(FaultRule{Method: watchMethod, Calls: []int{1}, Code: codes.Unavailable, AfterMessages: 2})
+
+	for _, wantErr := range []codes.Code{codes.Unavailable, codes.DeadlineExceeded} {
+		hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		sctx, scancel := context.WithTimeout(ctx, time.Second)
+		stream, err := client.Watch(sctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("got %v, want NOT_SERVING", resp.Status)
+		}
+		// The first stream is dropped; the second one stays open until its
+		// deadline.
+		if _, err := stream.Recv(); status.Code(err) != wantErr {
+			t.Errorf("got %v, want %v", err, wantErr)
+		}
+		scancel()
+	}
+}
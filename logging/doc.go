@@ -111,6 +111,15 @@ You may want use a standard log.Logger in your program.
 	stdlg := lg.StandardLogger(logging.Info)
 	stdlg.Println("some info")
 
+# Structured Logging with log/slog
+
+With Go 1.21 or later, a Logger can be used as the handler of a slog.Logger.
+Attributes and groups are written to the JSON payload of the entries, and
+levels are mapped to severities.
+
+	slg := slog.New(lg.SlogHandler(&slog.HandlerOptions{AddSource: true}))
+	slg.InfoContext(ctx, "request handled", "path", "/home", "status", 200)
+
 # Log Levels
 
 An Entry may have one of a number of severity levels associated with it.
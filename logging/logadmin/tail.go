@@ -0,0 +1,250 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logadmin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// defaultBufferWindow is the default time that the service buffers entries
+// before it streams them, to order late entries.
+const defaultBufferWindow = 2 * time.Second
+
+// SuppressionReason is the reason that the service omitted log entries from
+// a tail session.
+type SuppressionReason int32
+
+const (
+	// RateLimited means that the entries were omitted because of the rate
+	// limits of tail sessions.
+	RateLimited = SuppressionReason(logpb.TailLogEntriesResponse_SuppressionInfo_RATE_LIMIT)
+	// NotConsumed means that the entries were omitted because the client did
+	// not read them fast enough.
+	NotConsumed = SuppressionReason(logpb.TailLogEntriesResponse_SuppressionInfo_NOT_CONSUMED)
+)
+
+func (r SuppressionReason) String() string {
+	return logpb.TailLogEntriesResponse_SuppressionInfo_Reason(r).String()
+}
+
+// BufferWindow sets the time that the service buffers log entries before it
+// streams them to Tail, so that entries that arrive late are returned in
+// order. It must be at most one minute. The default is two seconds. It is
+// ignored by Entries.
+func BufferWindow(d time.Duration) EntriesOption { return bufferWindow(d) }
+
+type bufferWindow time.Duration
+
+func (bufferWindow) set(*logpb.ListLogEntriesRequest) {}
+
+// Tail returns a TailIterator that returns log entries as they are ingested.
+// The entries are restricted to those from the project passed to NewClient,
+// unless a ProjectIDs or ResourceNames option is given, and to those that
+// match the Filter option, if any. Unlike Entries, Tail does not add a
+// default timestamp filter. NewestFirst is ignored. Requires ReadScope or
+// AdminScope.
+//
+// Tail reconnects when the stream of entries breaks, and does not return
+// entries that it has already returned. Cancel ctx to end the tail session.
+func (c *Client) Tail(ctx context.Context, opts ...EntriesOption) *TailIterator {
+	lreq := &logpb.ListLogEntriesRequest{ResourceNames: []string{c.parent}}
+	window := defaultBufferWindow
+	for _, opt := range opts {
+		opt.set(lreq)
+		if bw, ok := opt.(bufferWindow); ok {
+			window = time.Duration(bw)
+		}
+	}
+	return &TailIterator{
+		ctx: ctx,
+		open: func(ctx context.Context) (logpb.LoggingServiceV2_TailLogEntriesClient, error) {
+			return c.lClient.TailLogEntries(ctx)
+		},
+		resourceNames: lreq.ResourceNames,
+		filter:        lreq.Filter,
+		window:        window,
+		seen:          make(map[entryID]time.Time),
+		suppressed:    make(map[SuppressionReason]int64),
+		backoff:       tailBackoff,
+	}
+}
+
+// tailBackoff is the backoff between attempts to reopen a tail stream.
+var tailBackoff = gax.Backoff{
+	Initial:    time.Second,
+	Max:        32 * time.Second,
+	Multiplier: 2,
+}
+
+// A TailIterator returns log entries as they are ingested.
+type TailIterator struct {
+	ctx           context.Context
+	open          func(context.Context) (logpb.LoggingServiceV2_TailLogEntriesClient, error)
+	resourceNames []string
+	filter        string
+	window        time.Duration
+	backoff       gax.Backoff
+
+	stream logpb.LoggingServiceV2_TailLogEntriesClient
+	items  []*logging.Entry
+	err    error
+
+	// seen holds the entries that were returned in the last buffer window
+	// before latest, which may be streamed again after a reconnection.
+	seen       map[entryID]time.Time
+	latest     time.Time
+	suppressed map[SuppressionReason]int64
+}
+
+// entryID identifies a log entry.
+type entryID struct {
+	logName  string
+	insertID string
+}
+
+// Next returns the next log entry. It blocks until an entry is available.
+// It returns an error if the stream of entries breaks and cannot be
+// reopened, or if the context passed to Tail is done. Once Next returns an
+// error, all subsequent calls return the same error.
+func (it *TailIterator) Next() (*logging.Entry, error) {
+	for len(it.items) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		it.err = it.fetch()
+	}
+	e := it.items[0]
+	it.items = it.items[1:]
+	return e, nil
+}
+
+// Suppressed returns the number of entries that the service omitted from the
+// tail session since the last call to Suppressed, by reason. The counts are
+// lower bounds.
+func (it *TailIterator) Suppressed() map[SuppressionReason]int64 {
+	s := it.suppressed
+	it.suppressed = make(map[SuppressionReason]int64)
+	return s
+}
+
+// fetch receives the next response from the stream, reopening the stream if
+// it breaks.
+func (it *TailIterator) fetch() error {
+	for {
+		var err error
+		if it.stream == nil {
+			err = it.openStream()
+		}
+		if err == nil {
+			var resp *logpb.TailLogEntriesResponse
+			resp, err = it.stream.Recv()
+			if err == nil {
+				it.backoff = tailBackoff
+				return it.add(resp)
+			}
+			it.stream = nil
+		}
+		if it.ctx.Err() != nil {
+			return it.ctx.Err()
+		}
+		if !retryableTailError(err) {
+			return err
+		}
+		if err := gax.Sleep(it.ctx, it.backoff.Pause()); err != nil {
+			return err
+		}
+	}
+}
+
+func (it *TailIterator) openStream() error {
+	stream, err := it.open(it.ctx)
+	if err != nil {
+		return err
+	}
+	req := &logpb.TailLogEntriesRequest{
+		ResourceNames: it.resourceNames,
+		Filter:        it.filter,
+		BufferWindow:  durationpb.New(it.window),
+	}
+	if !it.latest.IsZero() {
+		// Resume from the oldest entry that may not have been streamed yet.
+		ts := fmt.Sprintf(`timestamp >= "%s"`, it.latest.Add(-it.window).UTC().Format(time.RFC3339Nano))
+		if req.Filter == "" {
+			req.Filter = ts
+		} else {
+			req.Filter = fmt.Sprintf("(%s) AND %s", req.Filter, ts)
+		}
+	}
+	if err := stream.Send(req); err != nil {
+		return err
+	}
+	it.stream = stream
+	return nil
+}
+
+// add adds the entries of a response that have not been returned before.
+func (it *TailIterator) add(resp *logpb.TailLogEntriesResponse) error {
+	for _, si := range resp.SuppressionInfo {
+		it.suppressed[SuppressionReason(si.Reason)] += int64(si.SuppressedCount)
+	}
+	for _, le := range resp.Entries {
+		e, err := fromLogEntry(le)
+		if err != nil {
+			return err
+		}
+		if e.InsertID != "" {
+			id := entryID{logName: le.LogName, insertID: e.InsertID}
+			if _, ok := it.seen[id]; ok {
+				continue
+			}
+			it.seen[id] = e.Timestamp
+		}
+		if e.Timestamp.After(it.latest) {
+			it.latest = e.Timestamp
+		}
+		it.items = append(it.items, e)
+	}
+	// Forget the entries that are too old to be streamed again.
+	cutoff := it.latest.Add(-it.window)
+	for id, ts := range it.seen {
+		if ts.Before(cutoff) {
+			delete(it.seen, id)
+		}
+	}
+	return nil
+}
+
+// retryableTailError reports whether the tail stream should be reopened
+// after err.
+func retryableTailError(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logadmin
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// tailServer serves TailLogEntries from a script. Each stream sends the
+// responses of the next element of the script and then fails with the
+// error, or blocks if the error is nil.
+type tailServer struct {
+	logpb.UnimplementedLoggingServiceV2Server
+
+	mu       sync.Mutex
+	script   []tailStream
+	requests []*logpb.TailLogEntriesRequest
+}
+
+type tailStream struct {
+	responses []*logpb.TailLogEntriesResponse
+	err       error
+}
+
+func (s *tailServer) TailLogEntries(stream logpb.LoggingServiceV2_TailLogEntriesServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var ts tailStream
+	if len(s.script) > 0 {
+		ts, s.script = s.script[0], s.script[1:]
+	}
+	s.mu.Unlock()
+	for _, resp := range ts.responses {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	if ts.err != nil {
+		return ts.err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func newTailClient(t *testing.T, srv *tailServer) *Client {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gsrv := grpc.NewServer()
+	logpb.RegisterLoggingServiceV2Server(gsrv, srv)
+	go gsrv.Serve(l)
+	t.Cleanup(gsrv.Stop)
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(context.Background(), "projects/P", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func tailEntry(id string, sec int64) *logpb.LogEntry {
+	return &logpb.LogEntry{
+		LogName:   "projects/P/logs/tail",
+		InsertId:  id,
+		Timestamp: &timestamppb.Timestamp{Seconds: sec},
+		Payload:   &logpb.LogEntry_TextPayload{TextPayload: id},
+	}
+}
+
+func TestTail(t *testing.T) {
+	defer func(b gax.Backoff) { tailBackoff = b }(tailBackoff)
+	tailBackoff = gax.Backoff{Initial: time.Millisecond}
+
+	srv := &tailServer{script: []tailStream{
+		{
+			responses: []*logpb.TailLogEntriesResponse{
+				{Entries: []*logpb.LogEntry{tailEntry("a", 100), tailEntry("b", 101)}},
+				{SuppressionInfo: []*logpb.TailLogEntriesResponse_SuppressionInfo{
+					{Reason: logpb.TailLogEntriesResponse_SuppressionInfo_RATE_LIMIT, SuppressedCount: 3},
+				}},
+			},
+			err: status.Error(codes.Unavailable, "stream broke"),
+		},
+		{
+			// After reconnecting, entries of the buffer window are streamed
+			// again.
+			responses: []*logpb.TailLogEntriesResponse{
+				{Entries: []*logpb.LogEntry{tailEntry("b", 101), tailEntry("c", 102)}},
+			},
+		},
+	}}
+	c := newTailClient(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := c.Tail(ctx, Filter(`severity >= ERROR`), ResourceNames([]string{"projects/Q"}), BufferWindow(time.Second))
+	var got []string
+	for len(got) < 3 {
+		e, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Payload.(string))
+	}
+	if strings.Join(got, "") != "abc" {
+		t.Errorf("got entries %v, want a, b and c", got)
+	}
+	if s := it.Suppressed(); s[RateLimited] != 3 {
+		t.Errorf("got suppressed %v, want 3 rate limited", s)
+	}
+	if s := it.Suppressed(); len(s) != 0 {
+		t.Errorf("got suppressed %v after Suppressed, want none", s)
+	}
+
+	srv.mu.Lock()
+	reqs := srv.requests
+	srv.mu.Unlock()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(reqs))
+	}
+	if got := reqs[0]; got.Filter != "severity >= ERROR" || got.ResourceNames[0] != "projects/Q" || got.BufferWindow.AsDuration() != time.Second {
+		t.Errorf("got first request %v", got)
+	}
+	if got, want := reqs[1].Filter, `(severity >= ERROR) AND timestamp >= "1970-01-01T00:01:40Z"`; got != want {
+		t.Errorf("got filter after reconnecting %q, want %q", got, want)
+	}
+
+	cancel()
+	if _, err := it.Next(); err != context.Canceled {
+		t.Errorf("got %v after cancel, want context.Canceled", err)
+	}
+}
+
+func TestTailPermanentError(t *testing.T) {
+	srv := &tailServer{script: []tailStream{{err: status.Error(codes.PermissionDenied, "no")}}}
+	c := newTailClient(t, srv)
+	it := c.Tail(context.Background())
+	if _, err := it.Next(); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v, want PermissionDenied", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if got := srv.requests[0]; got.ResourceNames[0] != "projects/P" || got.Filter != "" {
+		t.Errorf("got request %v, want the project of the client and no filter", got)
+	}
+}
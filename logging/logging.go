@@ -661,12 +661,16 @@ func (l *Logger) Log(e Entry) {
 		l.client.error(err)
 		return
 	}
+	l.logEntry(ent)
+}
 
+// logEntry buffers a LogEntry for output to the logging service, or writes
+// it to the redirect writer.
+func (l *Logger) logEntry(ent *logpb.LogEntry) {
 	entries, _ := l.instrumentLogs([]*logpb.LogEntry{ent})
 	if l.redirectOutputWriter != nil {
 		for _, ent = range entries {
-			err = serializeEntryToWriter(ent, l.redirectOutputWriter)
-			if err != nil {
+			if err := serializeEntryToWriter(ent, l.redirectOutputWriter); err != nil {
 				l.client.error(err)
 			}
 		}
@@ -0,0 +1,209 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery
+
+import (
+	"strconv"
+	"strings"
+)
+
+// An Expr is a node of the syntax tree of a query. Its String method returns
+// the query in canonical form, which parses to an equal tree.
+type Expr interface {
+	String() string
+	isExpr()
+}
+
+// And is a conjunction of terms. Adjacent terms of a query are implicitly
+// joined by AND.
+type And struct {
+	Terms []Expr
+}
+
+// Or is a disjunction of terms. OR binds more tightly than AND.
+type Or struct {
+	Terms []Expr
+}
+
+// Not is the negation of an expression, written as NOT or a leading minus
+// sign.
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares a field of a log entry with a value, such as
+// severity >= ERROR.
+type Comparison struct {
+	Field Field
+	Op    Op
+	Value Value
+}
+
+// Global is a bare value, which matches entries with any field that contains
+// the value.
+type Global struct {
+	Value Value
+}
+
+// Call is a call of a function, such as log_id("stdout") or
+// sample(insertId, 0.1).
+type Call struct {
+	Name string
+	Args []Arg
+}
+
+func (*And) isExpr()        {}
+func (*Or) isExpr()         {}
+func (*Not) isExpr()        {}
+func (*Comparison) isExpr() {}
+func (*Global) isExpr()     {}
+func (*Call) isExpr()       {}
+
+// Op is a comparison operator.
+type Op string
+
+// The comparison operators.
+const (
+	Eq        Op = "="
+	Ne        Op = "!="
+	Lt        Op = "<"
+	Le        Op = "<="
+	Gt        Op = ">"
+	Ge        Op = ">="
+	Has       Op = ":"
+	Regexp    Op = "=~"
+	NotRegexp Op = "!~"
+)
+
+// An Arg is an argument of a function: a Field or a Value.
+type Arg interface {
+	String() string
+	isArg()
+}
+
+// Field is the path of a field of a log entry, such as
+// jsonPayload.user.name. Path elements that are not identifiers, such as the
+// keys of labels, are quoted in queries.
+type Field []string
+
+// Value is a value in a query. Quoted values are written in double quotes.
+type Value struct {
+	Text   string
+	Quoted bool
+}
+
+func (Field) isArg() {}
+func (Value) isArg() {}
+
+func (x *And) String() string {
+	var b strings.Builder
+	for i, t := range x.Terms {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(t.String())
+	}
+	return b.String()
+}
+
+func (x *Or) String() string {
+	var b strings.Builder
+	for i, t := range x.Terms {
+		if i > 0 {
+			b.WriteString(" OR ")
+		}
+		if _, ok := t.(*And); ok {
+			b.WriteString("(" + t.String() + ")")
+		} else {
+			b.WriteString(t.String())
+		}
+	}
+	return b.String()
+}
+
+func (x *Not) String() string {
+	switch x.Expr.(type) {
+	case *And, *Or:
+		return "NOT (" + x.Expr.String() + ")"
+	}
+	return "NOT " + x.Expr.String()
+}
+
+func (x *Comparison) String() string {
+	if x.Op == Has {
+		return x.Field.String() + ":" + x.Value.String()
+	}
+	return x.Field.String() + " " + string(x.Op) + " " + x.Value.String()
+}
+
+func (x *Global) String() string { return x.Value.String() }
+
+func (x *Call) String() string {
+	var args []string
+	for _, a := range x.Args {
+		args = append(args, a.String())
+	}
+	return x.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (f Field) String() string {
+	var elems []string
+	for _, e := range f {
+		if isIdent(e) {
+			elems = append(elems, e)
+		} else {
+			elems = append(elems, strconv.Quote(e))
+		}
+	}
+	return strings.Join(elems, ".")
+}
+
+func (v Value) String() string {
+	if v.Quoted || !isBareWord(v.Text) {
+		return strconv.Quote(v.Text)
+	}
+	return v.Text
+}
+
+// isIdent reports whether s is a path element that need not be quoted.
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isBareWord reports whether s can be written as a value without quotes.
+func isBareWord(s string) bool {
+	if s == "" || s == "AND" || s == "OR" || s == "NOT" || s[0] == '-' {
+		return false
+	}
+	for _, c := range s {
+		if !isWordChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordChar reports whether c may appear in an unquoted word.
+func isWordChar(c rune) bool {
+	return c > ' ' && !strings.ContainsRune(`()"=!<>:,~\`, c)
+}
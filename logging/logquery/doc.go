@@ -0,0 +1,58 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package logquery parses and evaluates queries of the Cloud Logging query
+language, as used by logadmin.Filter, sinks, exclusions and logs-based
+metrics. See https://cloud.google.com/logging/docs/view/logging-query-language.
+
+Parse parses a query into a syntax tree of Exprs, whose String methods print
+the query in canonical form. Validate checks that a parsed query refers to
+fields of log entries and has valid values, so that mistakes in filters can be
+found before they are sent to the service:
+
+	x, err := logquery.Parse(`severity >= ERROR AND resource.type = "gce_instance"`)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	if err := logquery.Validate(x); err != nil {
+		// TODO: Handle error.
+	}
+
+A Matcher evaluates a query against log entries locally, for example to test
+the filter of a sink, or to select the entries that a program logs with
+RedirectAsJSON:
+
+	m, err := logquery.Compile(`jsonPayload.user = "alice" OR severity = CRITICAL`)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	if ok, err := m.MatchEntry(&entry); err == nil && ok {
+		// ...
+	}
+
+As in the service, NOT has the highest precedence, followed by OR and then
+AND, and adjacent terms are joined by AND. The comparison operators are =, !=,
+<, <=, >, >=, : (has), =~ and !~ (regular expressions). Severities are
+compared by level and timestamps by time. The has operator, bare values and
+SEARCH match substrings, ignoring case. The functions log_id, sample,
+ip_in_net and SEARCH are supported.
+
+The evaluation of a Matcher approximates the service. In particular, the
+service matches bare values and the has operator by tokens rather than by
+substrings, and sample may select different entries.
+
+This package is EXPERIMENTAL and is subject to change without notice.
+*/
+package logquery // import "cloud.google.com/go/logging/logquery"
@@ -0,0 +1,60 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery_test
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/logquery"
+)
+
+func ExampleParse() {
+	x, err := logquery.Parse(`severity>=ERROR resource.type="gce_instance" OR resource.type="k8s_container"`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := logquery.Validate(x); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(x)
+	// Output: severity >= ERROR AND resource.type = "gce_instance" OR resource.type = "k8s_container"
+}
+
+func ExampleMatcher_MatchEntry() {
+	m, err := logquery.Compile(`severity >= WARNING jsonPayload.user = "alice"`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, e := range []logging.Entry{
+		{Severity: logging.Error, Payload: map[string]string{"user": "alice"}},
+		{Severity: logging.Info, Payload: map[string]string{"user": "alice"}},
+		{Severity: logging.Error, Payload: map[string]string{"user": "bob"}},
+	} {
+		ok, err := m.MatchEntry(&e)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(ok)
+	}
+	// Output:
+	// true
+	// false
+	// false
+}
@@ -0,0 +1,388 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// A Matcher evaluates a query against log entries. It is safe for concurrent
+// use.
+type Matcher struct {
+	expr    Expr
+	regexps map[*Comparison]*regexp.Regexp
+	nets    map[*Call]*net.IPNet
+}
+
+// Compile parses and validates a query, and returns a Matcher for it.
+func Compile(query string) (*Matcher, error) {
+	x, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return NewMatcher(x)
+}
+
+// NewMatcher validates a parsed query, and returns a Matcher for it. A nil
+// Expr matches every entry.
+func NewMatcher(x Expr) (*Matcher, error) {
+	if err := Validate(x); err != nil {
+		return nil, err
+	}
+	m := &Matcher{
+		expr:    x,
+		regexps: make(map[*Comparison]*regexp.Regexp),
+		nets:    make(map[*Call]*net.IPNet),
+	}
+	if x != nil {
+		m.prepare(x)
+	}
+	return m, nil
+}
+
+// prepare compiles the regular expressions and networks of a valid query.
+func (m *Matcher) prepare(x Expr) {
+	switch x := x.(type) {
+	case *And:
+		for _, t := range x.Terms {
+			m.prepare(t)
+		}
+	case *Or:
+		for _, t := range x.Terms {
+			m.prepare(t)
+		}
+	case *Not:
+		m.prepare(x.Expr)
+	case *Comparison:
+		if x.Op == Regexp || x.Op == NotRegexp {
+			m.regexps[x] = regexp.MustCompile(x.Value.Text)
+		}
+	case *Call:
+		if x.Name == "ip_in_net" {
+			_, n, _ := net.ParseCIDR(x.Args[1].(Value).Text)
+			m.nets[x] = n
+		}
+	}
+}
+
+// Expr returns the parsed query of m.
+func (m *Matcher) Expr() Expr { return m.expr }
+
+// String returns the query of m in canonical form.
+func (m *Matcher) String() string {
+	if m.expr == nil {
+		return ""
+	}
+	return m.expr.String()
+}
+
+// Match reports whether a log entry matches the query.
+func (m *Matcher) Match(e *logpb.LogEntry) bool {
+	if m.expr == nil {
+		return true
+	}
+	fields, err := fieldsOf(e)
+	if err != nil {
+		return false
+	}
+	return m.eval(m.expr, fields)
+}
+
+// MatchEntry reports whether an Entry matches the query. The entry is
+// converted as if it were written by a Logger, so an entry without a
+// timestamp has the current time, and an entry without a LogName does not
+// match queries of logName. MatchEntry returns an error if the entry cannot
+// be converted.
+func (m *Matcher) MatchEntry(e *logging.Entry) (bool, error) {
+	ec := *e
+	ec.LogName = ""
+	parent := "projects/-"
+	if i := strings.Index(e.LogName, "/logs/"); i > 0 {
+		parent = e.LogName[:i]
+	}
+	le, err := logging.ToLogEntry(ec, parent)
+	if err != nil {
+		return false, err
+	}
+	if e.LogName != "" {
+		// Entry.LogName has an unescaped log ID.
+		le.LogName = parent + "/logs/" + strings.ReplaceAll(strings.TrimPrefix(e.LogName, parent+"/logs/"), "/", "%2F")
+	}
+	return m.Match(le), nil
+}
+
+func (m *Matcher) eval(x Expr, e map[string]interface{}) bool {
+	switch x := x.(type) {
+	case *And:
+		for _, t := range x.Terms {
+			if !m.eval(t, e) {
+				return false
+			}
+		}
+		return true
+	case *Or:
+		for _, t := range x.Terms {
+			if m.eval(t, e) {
+				return true
+			}
+		}
+		return false
+	case *Not:
+		return !m.eval(x.Expr, e)
+	case *Comparison:
+		return m.compare(x, e)
+	case *Global:
+		return contains(e, strings.ToLower(x.Value.Text))
+	case *Call:
+		return m.call(x, e)
+	}
+	return false
+}
+
+// compare evaluates a comparison. A comparison of a missing field is false,
+// except for != and !~, which are the negations of = and =~. A comparison
+// of a repeated field is true if it is true for any element.
+func (m *Matcher) compare(c *Comparison, e map[string]interface{}) bool {
+	switch c.Op {
+	case Ne:
+		return !m.compareOp(c, Eq, e)
+	case NotRegexp:
+		return !m.compareOp(c, Regexp, e)
+	}
+	return m.compareOp(c, c.Op, e)
+}
+
+func (m *Matcher) compareOp(c *Comparison, op Op, e map[string]interface{}) bool {
+	for _, v := range lookup(e, c.Field) {
+		if op == Has && c.Value.Text == "*" && !c.Value.Quoted {
+			return true
+		}
+		if _, ok := v.(map[string]interface{}); ok {
+			if op == Has {
+				// A field with subfields has a value if any subfield has it.
+				if contains(v, strings.ToLower(c.Value.Text)) {
+					return true
+				}
+			}
+			continue
+		}
+		s := fmt.Sprint(v)
+		switch op {
+		case Has:
+			if strings.Contains(strings.ToLower(s), strings.ToLower(c.Value.Text)) {
+				return true
+			}
+		case Regexp:
+			if m.regexps[c].MatchString(s) {
+				return true
+			}
+		default:
+			if r, ok := compareValues(c.Field, s, c.Value.Text); ok && compareResult(op, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compareValues compares the value of a field with a value of a query.
+// Severities are compared by level, timestamps by time and numbers
+// numerically; other values are compared as strings.
+func compareValues(f Field, s, v string) (int, bool) {
+	if len(f) == 1 {
+		switch camelCase(f[0]) {
+		case "severity":
+			a, ok1 := severityLevel(s)
+			b, ok2 := severityLevel(v)
+			return compareFloats(float64(a), float64(b)), ok1 && ok2
+		case "timestamp", "receiveTimestamp":
+			a, err1 := parseTime(s)
+			b, err2 := parseTime(v)
+			if err1 != nil || err2 != nil {
+				return 0, false
+			}
+			return compareTimes(a, b), true
+		}
+	}
+	if a, err := strconv.ParseFloat(s, 64); err == nil {
+		if b, err := strconv.ParseFloat(v, 64); err == nil {
+			return compareFloats(a, b), true
+		}
+	}
+	return strings.Compare(s, v), true
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareResult(op Op, c int) bool {
+	switch op {
+	case Eq:
+		return c == 0
+	case Lt:
+		return c < 0
+	case Le:
+		return c <= 0
+	case Gt:
+		return c > 0
+	case Ge:
+		return c >= 0
+	}
+	return false
+}
+
+func (m *Matcher) call(c *Call, e map[string]interface{}) bool {
+	switch c.Name {
+	case "log_id":
+		id := strings.ReplaceAll(c.Args[0].(Value).Text, "/", "%2F")
+		for _, v := range lookup(e, Field{"logName"}) {
+			if strings.HasSuffix(fmt.Sprint(v), "/logs/"+id) {
+				return true
+			}
+		}
+	case "sample":
+		vs := lookup(e, c.Args[0].(Field))
+		if len(vs) == 0 {
+			return false
+		}
+		frac, _ := strconv.ParseFloat(c.Args[1].(Value).Text, 64)
+		h := sha256.Sum256([]byte(fmt.Sprint(vs[0])))
+		return float64(binary.BigEndian.Uint64(h[:]))/math.MaxUint64 < frac
+	case "ip_in_net":
+		for _, v := range lookup(e, c.Args[0].(Field)) {
+			if ip := net.ParseIP(fmt.Sprint(v)); ip != nil && m.nets[c].Contains(ip) {
+				return true
+			}
+		}
+	case "SEARCH":
+		text := strings.ToLower(c.Args[len(c.Args)-1].(Value).Text)
+		if len(c.Args) == 1 {
+			return contains(e, text)
+		}
+		for _, v := range lookup(e, c.Args[0].(Field)) {
+			if contains(v, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether a value, or any value nested in it, contains a
+// lowercase string, ignoring case.
+func contains(v interface{}, s string) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, x := range v {
+			if contains(x, s) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, x := range v {
+			if contains(x, s) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(strings.ToLower(fmt.Sprint(v)), s)
+}
+
+// lookup returns the values of a field. The elements of repeated fields are
+// returned individually. Field names may be in snake_case.
+func lookup(v interface{}, f Field) []interface{} {
+	if len(f) == 0 {
+		if a, ok := v.([]interface{}); ok {
+			return a
+		}
+		return []interface{}{v}
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		x, ok := v[f[0]]
+		if !ok {
+			x, ok = v[camelCase(f[0])]
+		}
+		if !ok {
+			return nil
+		}
+		return lookup(x, f[1:])
+	case []interface{}:
+		var vs []interface{}
+		for _, x := range v {
+			vs = append(vs, lookup(x, f)...)
+		}
+		return vs
+	}
+	return nil
+}
+
+// fieldsOf returns the fields of a log entry in the JSON form that queries
+// refer to.
+func fieldsOf(e *logpb.LogEntry) (map[string]interface{}, error) {
+	b, err := protojson.Marshal(e)
+	if err != nil {
+		// The type of the proto payload is not linked into this binary, so
+		// the entry is matched without it.
+		e = proto.Clone(e).(*logpb.LogEntry)
+		e.Payload = nil
+		if b, err = protojson.Marshal(e); err != nil {
+			return nil, err
+		}
+	}
+	// Numbers are decoded as json.Number, so that they keep their text: as
+	// float64, 1000000 would be printed as 1e+06.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
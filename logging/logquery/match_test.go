@@ -0,0 +1,163 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMatch(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]interface{}{
+		"user":  map[string]interface{}{"name": "Alice", "age": 42},
+		"items": []interface{}{"apple", "pear"},
+		"count": 1000000,
+		"id":    98765432,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &logpb.LogEntry{
+		LogName:   "projects/P/logs/cloudaudit.googleapis.com%2Factivity",
+		Resource:  &mrpb.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": "P"}},
+		Timestamp: timestamppb.New(time.Date(2023, 5, 1, 12, 0, 0, 123456789, time.UTC)),
+		Severity:  logtypepb.LogSeverity_WARNING,
+		InsertId:  "id1",
+		Labels:    map[string]string{"env": "prod", "k8s.io/app": "web"},
+		Payload:   &logpb.LogEntry_JsonPayload{JsonPayload: payload},
+		HttpRequest: &logtypepb.HttpRequest{
+			RequestMethod: "GET",
+			Status:        503,
+			ResponseSize:  1024,
+			RemoteIp:      "10.1.2.3",
+		},
+	}
+	for _, test := range []struct {
+		query string
+		want  bool
+	}{
+		{``, true},
+		{`logName = "projects/P/logs/cloudaudit.googleapis.com%2Factivity"`, true},
+		{`log_id("cloudaudit.googleapis.com/activity")`, true},
+		{`log_id("stdout")`, false},
+		{`severity >= WARNING`, true},
+		{`severity > warning`, false},
+		{`severity < ERROR`, true},
+		{`severity = 400`, true},
+		{`timestamp >= "2023-05-01T00:00:00Z"`, true},
+		{`timestamp < "2023-05-01"`, false},
+		{`timestamp = "2023-05-01T12:00:00.123456789Z"`, true},
+		{`timestamp > "2023-05-01T12:00:00.123456788Z"`, true},
+		{`timestamp < "2023-05-01T12:00:00.12345679Z"`, true},
+		{`timestamp = "2023-05-01T14:00:00.123456789+02:00"`, true},
+		{`resource.type = global`, true},
+		{`resource.labels.project_id = P`, true},
+		{`labels."k8s.io/app" = web`, true},
+		{`labels.env = prod AND labels.env != dev`, true},
+		{`labels.missing != x`, true},
+		{`labels.missing = x`, false},
+		{`labels.env:*`, true},
+		{`labels.missing:*`, false},
+		{`labels: prod`, true},
+		{`jsonPayload.user.name = Alice`, true},
+		{`json_payload.user.name: ali`, true},
+		{`jsonPayload.user.age > 9`, true},
+		{`jsonPayload.items = pear`, true},
+		{`jsonPayload.count = 1000000`, true},
+		{`jsonPayload.count:1000000`, true},
+		{`jsonPayload.id:987`, true},
+		{`jsonPayload.id =~ "^9876"`, true},
+		{`jsonPayload.id =~ "e\\+"`, false},
+		{`"1000000"`, true},
+		{`98765432`, true},
+		{`jsonPayload.user.name =~ "^A.*e$"`, true},
+		{`jsonPayload.user.name !~ "^A"`, false},
+		{`httpRequest.status >= 500 http_request.request_method = GET`, true},
+		{`httpRequest.responseSize = 1024`, true},
+		{`ip_in_net(httpRequest.remoteIp, "10.0.0.0/8")`, true},
+		{`ip_in_net(httpRequest.remoteIp, "192.168.0.0/16")`, false},
+		{`severity = ERROR OR httpRequest.status = 503`, true},
+		{`severity = ERROR OR httpRequest.status = 503 AND labels.env = dev`, false},
+		{`NOT severity = WARNING`, false},
+		{`-severity = WARNING`, false},
+		{`"alice"`, true},
+		{`pear apple`, true},
+		{`banana`, false},
+		{`SEARCH("PEAR")`, true},
+		{`SEARCH(labels, "pear")`, false},
+		{`sample(insertId, 1)`, true},
+		{`sample(insertId, 0)`, false},
+		{`sample(trace, 1)`, false},
+	} {
+		m, err := Compile(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got := m.Match(e); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	m, err := Compile(`sample(insertId, 0.25)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for i := 0; i < 1000; i++ {
+		if m.Match(&logpb.LogEntry{InsertId: fmt.Sprint(i)}) {
+			n++
+		}
+	}
+	if n < 150 || n > 350 {
+		t.Errorf("sampled %d of 1000 entries, want about 250", n)
+	}
+}
+
+func TestMatchEntry(t *testing.T) {
+	m, err := Compile(`log_id("a/b") severity >= ERROR jsonPayload.code = 7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &logging.Entry{
+		LogName:  "projects/P/logs/a/b",
+		Severity: logging.Error,
+		Payload:  map[string]interface{}{"code": 7},
+	}
+	if ok, err := m.MatchEntry(e); err != nil || !ok {
+		t.Errorf("got %t, %v, want true", ok, err)
+	}
+	if e.LogName != "projects/P/logs/a/b" {
+		t.Errorf("MatchEntry modified the entry")
+	}
+	e.Severity = logging.Info
+	if ok, err := m.MatchEntry(e); err != nil || ok {
+		t.Errorf("got %t, %v, want false", ok, err)
+	}
+	e.Payload = make(chan int)
+	if _, err := m.MatchEntry(e); err == nil {
+		t.Error("got nil, want error for a bad payload")
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is an error in a query.
+type Error struct {
+	Offset int // byte offset in the query
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("logquery: offset %d: %s", e.Offset, e.Msg)
+}
+
+// Parse parses a query. It returns nil for a query without terms, which
+// matches every entry. Parse only checks the syntax of the query; use
+// Validate to check its fields and values.
+func Parse(query string) (Expr, error) {
+	toks, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return x, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokMinus
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string   // the value of a string, or the text of other tokens
+	path  []string // the path elements of a word
+	start int
+	end   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators are the comparison operators, longest first.
+var operators = []Op{Le, Ge, Ne, Regexp, NotRegexp, Eq, Lt, Gt, Has}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "--"):
+			// A comment extends to the end of the line.
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '(' || c == ')' || c == ',':
+			kind := map[byte]tokenKind{'(': tokLParen, ')': tokRParen, ',': tokComma}[c]
+			i++
+			toks = append(toks, token{kind: kind, text: s[start:i], start: start, end: i})
+		case c == '-':
+			i++
+			toks = append(toks, token{kind: tokMinus, text: "-", start: start, end: i})
+		case c == '"':
+			str, n, err := lexString(s, i)
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			toks = append(toks, token{kind: tokString, text: str, start: start, end: i})
+		default:
+			if op := operatorAt(s[i:]); op != "" {
+				i += len(op)
+				toks = append(toks, token{kind: tokOp, text: string(op), start: start, end: i})
+				continue
+			}
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			if !isWordChar(r) {
+				return nil, &Error{Offset: i, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+			t, err := lexWord(s, i)
+			if err != nil {
+				return nil, err
+			}
+			i = t.end
+			toks = append(toks, t)
+		}
+	}
+	return append(toks, token{kind: tokEOF, start: len(s), end: len(s)}), nil
+}
+
+// lexString lexes the quoted string at s[i:]. It returns the string and the
+// length of its quoted form.
+func lexString(s string, i int) (string, int, error) {
+	var b strings.Builder
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '"':
+			return b.String(), j + 1 - i, nil
+		case '\\':
+			j++
+			if j == len(s) {
+				break
+			}
+			switch s[j] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[j])
+			}
+		default:
+			b.WriteByte(s[j])
+		}
+	}
+	return "", 0, &Error{Offset: i, Msg: "unterminated string"}
+}
+
+// lexWord lexes the word at s[i:]. The elements of the path of a word are
+// separated by dots and may be quoted strings, as in labels."a.b".
+func lexWord(s string, i int) (token, error) {
+	t := token{kind: tokWord, start: i}
+	for {
+		var elem string
+		if i < len(s) && s[i] == '"' {
+			str, n, err := lexString(s, i)
+			if err != nil {
+				return token{}, err
+			}
+			elem = str
+			i += n
+		} else {
+			j := i
+			for j < len(s) && s[j] != '.' && operatorAt(s[j:]) == "" {
+				r, size := utf8.DecodeRuneInString(s[j:])
+				if !isWordChar(r) {
+					break
+				}
+				j += size
+			}
+			elem = s[i:j]
+			i = j
+		}
+		t.path = append(t.path, elem)
+		if i+1 < len(s) && s[i] == '.' && (s[i+1] == '"' || isWordChar(rune(s[i+1]))) {
+			i++
+			continue
+		}
+		if i < len(s) && s[i] == '.' {
+			// A trailing dot, as in "1.".
+			i++
+			t.path = append(t.path, "")
+		}
+		break
+	}
+	t.end = i
+	t.text = s[t.start:t.end]
+	return t, nil
+}
+
+func operatorAt(s string) Op {
+	for _, op := range operators {
+		if strings.HasPrefix(s, string(op)) {
+			return op
+		}
+	}
+	return ""
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &Error{Offset: t.start, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokWord && t.text == kw
+}
+
+// parseAnd parses a conjunction, the operator with the lowest precedence.
+func (p *parser) parseAnd() (Expr, error) {
+	var terms []Expr
+	for {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, x)
+		if p.isKeyword("AND") {
+			p.next()
+			continue
+		}
+		if t := p.peek(); t.kind == tokEOF || t.kind == tokRParen {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &And{Terms: terms}, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	var terms []Expr
+	for {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, x)
+		if !p.isKeyword("OR") {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &Or{Terms: terms}, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.isKeyword("NOT") || p.peek().kind == tokMinus {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: x}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		x, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if r := p.next(); r.kind != tokRParen {
+			return nil, p.errorf(r, "expected ), found %s", r)
+		}
+		return x, nil
+	case tokString:
+		return &Global{Value: Value{Text: t.text, Quoted: true}}, nil
+	case tokWord:
+		if t.text == "AND" || t.text == "OR" {
+			return nil, p.errorf(t, "unexpected %s", t.text)
+		}
+		if n := p.peek(); n.kind == tokLParen && n.start == t.end && len(t.path) == 1 {
+			return p.parseCall(t)
+		}
+		if p.peek().kind != tokOp {
+			return &Global{Value: Value{Text: t.text}}, nil
+		}
+		op := Op(p.next().text)
+		v, err := p.parseValue(op)
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Field: Field(t.path), Op: op, Value: v}, nil
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
+
+// parseValue parses the value of a comparison.
+func (p *parser) parseValue(op Op) (Value, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return Value{Text: t.text, Quoted: true}, nil
+	case tokMinus:
+		// A negative number.
+		if w := p.peek(); w.kind == tokWord && w.start == t.end {
+			p.next()
+			return Value{Text: "-" + w.text}, nil
+		}
+	case tokWord:
+		if t.text != "AND" && t.text != "OR" && t.text != "NOT" {
+			return Value{Text: t.text}, nil
+		}
+	}
+	return Value{}, p.errorf(t, "expected value after %s, found %s", op, t)
+}
+
+func (p *parser) parseCall(name token) (Expr, error) {
+	p.next() // (
+	c := &Call{Name: name.text}
+	if p.peek().kind == tokRParen {
+		p.next()
+		return c, nil
+	}
+	for {
+		t := p.next()
+		switch t.kind {
+		case tokString:
+			c.Args = append(c.Args, Value{Text: t.text, Quoted: true})
+		case tokWord:
+			if isIdent(t.path[0]) {
+				c.Args = append(c.Args, Field(t.path))
+			} else {
+				c.Args = append(c.Args, Value{Text: t.text})
+			}
+		default:
+			return nil, p.errorf(t, "expected argument of %s, found %s", c.Name, t)
+		}
+		switch t := p.next(); t.kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return c, nil
+		default:
+			return nil, p.errorf(t, "expected , or ), found %s", t)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery
+
+import (
+	"testing"
+
+	"cloud.google.com/go/internal/testutil"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want Expr
+	}{
+		{"", nil},
+		{"  -- only a comment", nil},
+		{
+			`severity>=ERROR`,
+			&Comparison{Field: Field{"severity"}, Op: Ge, Value: Value{Text: "ERROR"}},
+		},
+		{
+			`labels."k8s.io/app" = "web"`,
+			&Comparison{Field: Field{"labels", "k8s.io/app"}, Op: Eq, Value: Value{Text: "web", Quoted: true}},
+		},
+		{
+			`jsonPayload.n > -1.5`,
+			&Comparison{Field: Field{"jsonPayload", "n"}, Op: Gt, Value: Value{Text: "-1.5"}},
+		},
+		{
+			`a = 1 b = 2 OR c = 3`,
+			&And{Terms: []Expr{
+				&Comparison{Field: Field{"a"}, Op: Eq, Value: Value{Text: "1"}},
+				&Or{Terms: []Expr{
+					&Comparison{Field: Field{"b"}, Op: Eq, Value: Value{Text: "2"}},
+					&Comparison{Field: Field{"c"}, Op: Eq, Value: Value{Text: "3"}},
+				}},
+			}},
+		},
+		{
+			`NOT a:* AND -"x y"`,
+			&And{Terms: []Expr{
+				&Not{Expr: &Comparison{Field: Field{"a"}, Op: Has, Value: Value{Text: "*"}}},
+				&Not{Expr: &Global{Value: Value{Text: "x y", Quoted: true}}},
+			}},
+		},
+		{
+			`sample(insertId, 0.25) -- a comment
+			log_id("cloudaudit.googleapis.com/activity")`,
+			&And{Terms: []Expr{
+				&Call{Name: "sample", Args: []Arg{Field{"insertId"}, Value{Text: "0.25"}}},
+				&Call{Name: "log_id", Args: []Arg{Value{Text: "cloudaudit.googleapis.com/activity", Quoted: true}}},
+			}},
+		},
+		{
+			`(a = 1 AND b = 2) OR c =~ "^x\"y"`,
+			&Or{Terms: []Expr{
+				&And{Terms: []Expr{
+					&Comparison{Field: Field{"a"}, Op: Eq, Value: Value{Text: "1"}},
+					&Comparison{Field: Field{"b"}, Op: Eq, Value: Value{Text: "2"}},
+				}},
+				&Comparison{Field: Field{"c"}, Op: Regexp, Value: Value{Text: `^x"y`, Quoted: true}},
+			}},
+		},
+	} {
+		got, err := Parse(test.in)
+		if err != nil {
+			t.Errorf("%s: %v", test.in, err)
+			continue
+		}
+		if diff := testutil.Diff(got, test.want); diff != "" {
+			t.Errorf("%s: got=-, want=+:\n%s", test.in, diff)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{`severity>=ERROR`, `severity >= ERROR`},
+		{`a:"b"`, `a:"b"`},
+		{`labels."k8s.io/app"="web"`, `labels."k8s.io/app" = "web"`},
+		{`a=1 b=2`, `a = 1 AND b = 2`},
+		{`(a=1 b=2) OR c=3`, `(a = 1 AND b = 2) OR c = 3`},
+		{`a=1 (b=2 OR c=3)`, `a = 1 AND b = 2 OR c = 3`},
+		{`-(a=1 OR b=2)`, `NOT (a = 1 OR b = 2)`},
+		{`NOT NOT x`, `NOT NOT x`},
+		{`log_id( "stdout" )`, `log_id("stdout")`},
+		{`textPayload = "AND"`, `textPayload = "AND"`},
+		{`jsonPayload.n > -1`, `jsonPayload.n > "-1"`},
+	} {
+		x, err := Parse(test.in)
+		if err != nil {
+			t.Errorf("%s: %v", test.in, err)
+			continue
+		}
+		got := x.String()
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.in, got, test.want)
+		}
+		// The canonical form parses to the same tree.
+		y, err := Parse(got)
+		if err != nil {
+			t.Errorf("%s: %v", got, err)
+			continue
+		}
+		if diff := testutil.Diff(y.String(), got); diff != "" {
+			t.Errorf("%s: reparsed form differs: %s", got, diff)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		offset int
+	}{
+		{`severity =`, 10},
+		{`(severity = ERROR`, 17},
+		{`severity = ERROR)`, 16},
+		{`logName = "projects/P`, 10},
+		{`AND a`, 0},
+		{`a = AND b = c`, 4},
+		{`a = 1 OR`, 8},
+		{`f(a b)`, 4},
+		{`a = b ~`, 6},
+	} {
+		_, err := Parse(test.in)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: got %v, want *Error", test.in, err)
+			continue
+		}
+		if e.Offset != test.offset {
+			t.Errorf("%s: got offset %d, want %d (%v)", test.in, e.Offset, test.offset, e)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		in string
+		ok bool
+	}{
+		{`severity >= ERROR`, true},
+		{`severity >= ERR`, false},
+		{`severity: ERR`, true},
+		{`severity = 500`, true},
+		{`timestamp >= "2023-05-01T00:00:00Z" timestamp < "2023-05-02"`, true},
+		{`timestamp >= yesterday`, false},
+		{`http_request.status = 500`, true},
+		{`jsonPayload.a.b.c = x`, true},
+		{`textPayload.a = x`, false},
+		{`payload = x`, false},
+		{`labels.x =~ "("`, false},
+		{`sample(insertId, 0.5)`, true},
+		{`sample(insertId, 2)`, false},
+		{`sample("x", 0.5)`, false},
+		{`ip_in_net(httpRequest.remoteIp, "10.0.0.0/8")`, true},
+		{`ip_in_net(httpRequest.remoteIp, "10.0.0.0")`, false},
+		{`log_id("stdout")`, true},
+		{`log_id(stdout, stderr)`, false},
+		{`SEARCH(jsonPayload, "x")`, true},
+		{`unknown(x)`, false},
+	} {
+		x, err := Parse(test.in)
+		if err != nil {
+			t.Errorf("%s: %v", test.in, err)
+			continue
+		}
+		err = Validate(x)
+		if got := err == nil; got != test.ok {
+			t.Errorf("%s: got %v, want ok=%t", test.in, err, test.ok)
+		}
+	}
+}
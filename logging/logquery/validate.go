@@ -0,0 +1,238 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logquery
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// entryFields are the fields of a LogEntry, in the form used by queries.
+// The fields with a true value have arbitrary subfields.
+var entryFields = map[string]bool{
+	"logName":          false,
+	"resource":         true,
+	"timestamp":        false,
+	"receiveTimestamp": false,
+	"severity":         false,
+	"insertId":         false,
+	"httpRequest":      true,
+	"labels":           true,
+	"operation":        true,
+	"trace":            false,
+	"spanId":           false,
+	"traceSampled":     false,
+	"sourceLocation":   true,
+	"split":            true,
+	"textPayload":      false,
+	"jsonPayload":      true,
+	"protoPayload":     true,
+	"errorGroups":      true,
+}
+
+// Validate checks that a parsed query refers to fields of log entries, and
+// that its values, regular expressions and function calls are valid. Field
+// names may be written in camelCase or snake_case.
+func Validate(x Expr) error {
+	if x == nil {
+		return nil
+	}
+	return validate(x)
+}
+
+func validate(x Expr) error {
+	switch x := x.(type) {
+	case *And:
+		for _, t := range x.Terms {
+			if err := validate(t); err != nil {
+				return err
+			}
+		}
+	case *Or:
+		for _, t := range x.Terms {
+			if err := validate(t); err != nil {
+				return err
+			}
+		}
+	case *Not:
+		return validate(x.Expr)
+	case *Comparison:
+		return validateComparison(x)
+	case *Global:
+		if x.Value.Text == "" {
+			return fmt.Errorf("logquery: empty value")
+		}
+	case *Call:
+		return validateCall(x)
+	default:
+		return fmt.Errorf("logquery: unknown expression %T", x)
+	}
+	return nil
+}
+
+func validateField(f Field) error {
+	if len(f) == 0 {
+		return fmt.Errorf("logquery: empty field")
+	}
+	name := camelCase(f[0])
+	subfields, ok := entryFields[name]
+	if !ok {
+		return fmt.Errorf("logquery: unknown field %s", f)
+	}
+	if !subfields && len(f) > 1 {
+		return fmt.Errorf("logquery: field %s has no subfield %s", name, f[1])
+	}
+	for _, e := range f[1:] {
+		if e == "" {
+			return fmt.Errorf("logquery: empty element in field %s", f)
+		}
+	}
+	return nil
+}
+
+func validateComparison(c *Comparison) error {
+	if err := validateField(c.Field); err != nil {
+		return err
+	}
+	v := c.Value.Text
+	if c.Op == Has && v == "*" && !c.Value.Quoted {
+		return nil
+	}
+	switch c.Op {
+	case Regexp, NotRegexp:
+		if _, err := regexp.Compile(v); err != nil {
+			return fmt.Errorf("logquery: %s: %v", c, err)
+		}
+		return nil
+	case Has:
+		return nil
+	}
+	if len(c.Field) == 1 {
+		switch camelCase(c.Field[0]) {
+		case "severity":
+			if _, ok := severityLevel(v); !ok {
+				return fmt.Errorf("logquery: %s: unknown severity %q", c, v)
+			}
+		case "timestamp", "receiveTimestamp":
+			if _, err := parseTime(v); err != nil {
+				return fmt.Errorf("logquery: %s: bad timestamp %q", c, v)
+			}
+		}
+	}
+	return nil
+}
+
+// functions are the supported functions, with their numbers of arguments.
+var functions = map[string][2]int{
+	"log_id":    {1, 1},
+	"sample":    {2, 2},
+	"ip_in_net": {2, 2},
+	"SEARCH":    {1, 2},
+}
+
+func validateCall(c *Call) error {
+	n, ok := functions[c.Name]
+	if !ok {
+		return fmt.Errorf("logquery: unknown function %s", c.Name)
+	}
+	if len(c.Args) < n[0] || len(c.Args) > n[1] {
+		return fmt.Errorf("logquery: wrong number of arguments in %s", c)
+	}
+	switch c.Name {
+	case "log_id":
+		if _, ok := c.Args[0].(Value); !ok {
+			return fmt.Errorf("logquery: %s: argument must be a string", c)
+		}
+	case "sample":
+		f, ok := c.Args[0].(Field)
+		if !ok {
+			return fmt.Errorf("logquery: %s: first argument must be a field", c)
+		}
+		if err := validateField(f); err != nil {
+			return err
+		}
+		v, ok := c.Args[1].(Value)
+		if !ok {
+			return fmt.Errorf("logquery: %s: second argument must be a number", c)
+		}
+		if x, err := strconv.ParseFloat(v.Text, 64); err != nil || x < 0 || x > 1 {
+			return fmt.Errorf("logquery: %s: fraction must be between 0 and 1", c)
+		}
+	case "ip_in_net":
+		f, ok := c.Args[0].(Field)
+		if !ok {
+			return fmt.Errorf("logquery: %s: first argument must be a field", c)
+		}
+		if err := validateField(f); err != nil {
+			return err
+		}
+		v, ok := c.Args[1].(Value)
+		if !ok {
+			return fmt.Errorf("logquery: %s: second argument must be a string", c)
+		}
+		if _, _, err := net.ParseCIDR(v.Text); err != nil {
+			return fmt.Errorf("logquery: %s: %v", c, err)
+		}
+	case "SEARCH":
+		if len(c.Args) == 2 {
+			f, ok := c.Args[0].(Field)
+			if !ok {
+				return fmt.Errorf("logquery: %s: first argument must be a field", c)
+			}
+			if err := validateField(f); err != nil {
+				return err
+			}
+		}
+		if _, ok := c.Args[len(c.Args)-1].(Value); !ok {
+			return fmt.Errorf("logquery: %s: last argument must be a string", c)
+		}
+	}
+	return nil
+}
+
+func severityLevel(s string) (int32, bool) {
+	if n, ok := logtypepb.LogSeverity_value[strings.ToUpper(s)]; ok {
+		return n, true
+	}
+	n, err := strconv.ParseInt(s, 10, 32)
+	return int32(n), err == nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// camelCase converts a snake_case field name to camelCase.
+func camelCase(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,278 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"cloud.google.com/go/logging/logquery"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SharedWriterIdentity is the writer identity of sinks that are not created
+// with a unique writer identity.
+const SharedWriterIdentity = "serviceAccount:cloud-logs@system.gserviceaccount.com"
+
+type configHandler struct {
+	logpb.UnimplementedConfigServiceV2Server
+	s *Server
+}
+
+// CreateSink creates a sink. Its filter must be valid.
+func (h *configHandler) CreateSink(_ context.Context, req *logpb.CreateSinkRequest) (*logpb.LogSink, error) {
+	sink := req.Sink
+	if sink == nil || sink.Name == "" {
+		return nil, invalidArgument("missing sink name")
+	}
+	if sink.Destination == "" {
+		return nil, invalidArgument("missing sink destination")
+	}
+	if _, err := logquery.Compile(sink.Filter); err != nil {
+		return nil, invalidArgument("bad filter %q: %v", sink.Filter, err)
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := req.Parent + "/sinks/" + sink.Name
+	if _, ok := s.sinks[name]; ok {
+		return nil, alreadyExists("sink %q already exists", name)
+	}
+	sink = proto.Clone(sink).(*logpb.LogSink)
+	sink.WriterIdentity = writerIdentity(name, req.UniqueWriterIdentity)
+	sink.CreateTime = timestamppb.New(s.now())
+	sink.UpdateTime = sink.CreateTime
+	s.sinks[name] = sink
+	return sink, nil
+}
+
+func writerIdentity(sinkName string, unique bool) string {
+	if !unique {
+		return SharedWriterIdentity
+	}
+	return "serviceAccount:" + strings.ReplaceAll(sinkName, "/", "-") + "@logtest.iam.gserviceaccount.com"
+}
+
+// GetSink gets a sink.
+func (h *configHandler) GetSink(_ context.Context, req *logpb.GetSinkRequest) (*logpb.LogSink, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sink, ok := s.sinks[req.SinkName]
+	if !ok {
+		return nil, notFound("sink %q not found", req.SinkName)
+	}
+	return sink, nil
+}
+
+// UpdateSink updates the fields of a sink in the update mask. An empty mask
+// updates the destination, filter and include_children fields.
+func (h *configHandler) UpdateSink(_ context.Context, req *logpb.UpdateSinkRequest) (*logpb.LogSink, error) {
+	if req.Sink == nil {
+		return nil, invalidArgument("missing sink")
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.sinks[req.SinkName]
+	if !ok {
+		return nil, notFound("sink %q not found", req.SinkName)
+	}
+	sink := proto.Clone(old).(*logpb.LogSink)
+	paths := req.UpdateMask.GetPaths()
+	if len(paths) == 0 {
+		paths = []string{"destination", "filter", "include_children"}
+	}
+	for _, p := range paths {
+		switch p {
+		case "destination":
+			sink.Destination = req.Sink.Destination
+		case "filter":
+			if _, err := logquery.Compile(req.Sink.Filter); err != nil {
+				return nil, invalidArgument("bad filter %q: %v", req.Sink.Filter, err)
+			}
+			sink.Filter = req.Sink.Filter
+		case "include_children":
+			sink.IncludeChildren = req.Sink.IncludeChildren
+		case "description":
+			sink.Description = req.Sink.Description
+		case "disabled":
+			sink.Disabled = req.Sink.Disabled
+		case "exclusions":
+			sink.Exclusions = req.Sink.Exclusions
+		case "output_version_format":
+			// Deprecated and unchangeable.
+		default:
+			return nil, invalidArgument("unknown path in update mask: %q", p)
+		}
+	}
+	if req.UniqueWriterIdentity {
+		sink.WriterIdentity = writerIdentity(req.SinkName, true)
+	}
+	sink.UpdateTime = timestamppb.New(s.now())
+	s.sinks[req.SinkName] = sink
+	return sink, nil
+}
+
+// DeleteSink deletes a sink.
+func (h *configHandler) DeleteSink(_ context.Context, req *logpb.DeleteSinkRequest) (*emptypb.Empty, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sinks[req.SinkName]; !ok {
+		return nil, notFound("sink %q not found", req.SinkName)
+	}
+	delete(s.sinks, req.SinkName)
+	return &emptypb.Empty{}, nil
+}
+
+// ListSinks lists the sinks of a resource, ordered by name.
+func (h *configHandler) ListSinks(_ context.Context, req *logpb.ListSinksRequest) (*logpb.ListSinksResponse, error) {
+	s := h.s
+	s.mu.Lock()
+	var sinks []*logpb.LogSink
+	for name, sink := range s.sinks {
+		if strings.HasPrefix(name, req.Parent+"/sinks/") {
+			sinks = append(sinks, sink)
+		}
+	}
+	s.mu.Unlock() // safe because no stored sink is ever modified
+	sort.Slice(sinks, func(i, j int) bool { return sinks[i].Name < sinks[j].Name })
+	from, to, nextPageToken, err := page(req.PageSize, req.PageToken, len(sinks))
+	if err != nil {
+		return nil, err
+	}
+	return &logpb.ListSinksResponse{
+		Sinks:         sinks[from:to],
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// CreateExclusion creates an exclusion. Its filter must be valid.
+func (h *configHandler) CreateExclusion(_ context.Context, req *logpb.CreateExclusionRequest) (*logpb.LogExclusion, error) {
+	ex := req.Exclusion
+	if ex == nil || ex.Name == "" {
+		return nil, invalidArgument("missing exclusion name")
+	}
+	if ex.Filter == "" {
+		return nil, invalidArgument("missing exclusion filter")
+	}
+	if _, err := logquery.Compile(ex.Filter); err != nil {
+		return nil, invalidArgument("bad filter %q: %v", ex.Filter, err)
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := req.Parent + "/exclusions/" + ex.Name
+	if _, ok := s.exclusions[name]; ok {
+		return nil, alreadyExists("exclusion %q already exists", name)
+	}
+	ex = proto.Clone(ex).(*logpb.LogExclusion)
+	ex.CreateTime = timestamppb.New(s.now())
+	ex.UpdateTime = ex.CreateTime
+	s.exclusions[name] = ex
+	return ex, nil
+}
+
+// GetExclusion gets an exclusion.
+func (h *configHandler) GetExclusion(_ context.Context, req *logpb.GetExclusionRequest) (*logpb.LogExclusion, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ex, ok := s.exclusions[req.Name]
+	if !ok {
+		return nil, notFound("exclusion %q not found", req.Name)
+	}
+	return ex, nil
+}
+
+// UpdateExclusion updates the fields of an exclusion in the update mask,
+// which must not be empty.
+func (h *configHandler) UpdateExclusion(_ context.Context, req *logpb.UpdateExclusionRequest) (*logpb.LogExclusion, error) {
+	if req.Exclusion == nil {
+		return nil, invalidArgument("missing exclusion")
+	}
+	paths := req.UpdateMask.GetPaths()
+	if len(paths) == 0 {
+		return nil, invalidArgument("empty update mask")
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.exclusions[req.Name]
+	if !ok {
+		return nil, notFound("exclusion %q not found", req.Name)
+	}
+	ex := proto.Clone(old).(*logpb.LogExclusion)
+	for _, p := range paths {
+		switch p {
+		case "description":
+			ex.Description = req.Exclusion.Description
+		case "filter":
+			if req.Exclusion.Filter == "" {
+				return nil, invalidArgument("missing exclusion filter")
+			}
+			if _, err := logquery.Compile(req.Exclusion.Filter); err != nil {
+				return nil, invalidArgument("bad filter %q: %v", req.Exclusion.Filter, err)
+			}
+			ex.Filter = req.Exclusion.Filter
+		case "disabled":
+			ex.Disabled = req.Exclusion.Disabled
+		default:
+			return nil, invalidArgument("unknown path in update mask: %q", p)
+		}
+	}
+	ex.UpdateTime = timestamppb.New(s.now())
+	s.exclusions[req.Name] = ex
+	return ex, nil
+}
+
+// DeleteExclusion deletes an exclusion.
+func (h *configHandler) DeleteExclusion(_ context.Context, req *logpb.DeleteExclusionRequest) (*emptypb.Empty, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.exclusions[req.Name]; !ok {
+		return nil, notFound("exclusion %q not found", req.Name)
+	}
+	delete(s.exclusions, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// ListExclusions lists the exclusions of a resource, ordered by name.
+func (h *configHandler) ListExclusions(_ context.Context, req *logpb.ListExclusionsRequest) (*logpb.ListExclusionsResponse, error) {
+	s := h.s
+	s.mu.Lock()
+	var exs []*logpb.LogExclusion
+	for name, ex := range s.exclusions {
+		if strings.HasPrefix(name, req.Parent+"/exclusions/") {
+			exs = append(exs, ex)
+		}
+	}
+	s.mu.Unlock() // safe because no stored exclusion is ever modified
+	sort.Slice(exs, func(i, j int) bool { return exs[i].Name < exs[j].Name })
+	from, to, nextPageToken, err := page(req.PageSize, req.PageToken, len(exs))
+	if err != nil {
+		return nil, err
+	}
+	return &logpb.ListExclusionsResponse{
+		Exclusions:    exs[from:to],
+		NextPageToken: nextPageToken,
+	}, nil
+}
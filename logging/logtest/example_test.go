@@ -0,0 +1,53 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest_test
+
+import (
+	"context"
+
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/logadmin"
+	"cloud.google.com/go/logging/logtest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func ExampleNewServer() {
+	ctx := context.Background()
+	// Start a fake server running locally.
+	srv := logtest.NewServer()
+	defer srv.Close()
+	// Connect to the server without using TLS.
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer conn.Close()
+	// Use the connection when creating logging and logadmin clients.
+	client, err := logging.NewClient(ctx, "projects/my-project", option.WithGRPCConn(conn))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer client.Close()
+	adminClient, err := logadmin.NewClient(ctx, "projects/my-project", option.WithGRPCConn(conn))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer adminClient.Close()
+	_ = client.Logger("my-log") // TODO: Use the logger.
+	// The entries written to the server are available with srv.Entries.
+	_ = srv.Entries()
+}
@@ -0,0 +1,339 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"cloud.google.com/go/logging/logquery"
+	lpb "google.golang.org/genproto/googleapis/api/label"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// tailBufferSize is the number of writes that a tail session buffers before
+// it suppresses entries.
+const tailBufferSize = 100
+
+type loggingHandler struct {
+	logpb.UnimplementedLoggingServiceV2Server
+	s *Server
+}
+
+// tail is a TailLogEntries session. Its fields other than ch are guarded by
+// the mutex of the server.
+type tail struct {
+	resourceNames []string
+	matcher       *logquery.Matcher
+	ch            chan []*logpb.LogEntry
+	notConsumed   int32
+}
+
+var logNameRegexp = regexp.MustCompile(`^(projects|organizations|folders|billingAccounts)/[^/]+/logs/[^/]+$`)
+
+// WriteLogEntries stores log entries. The fields of the request are the
+// defaults of the fields of the entries. The server assigns the timestamp
+// and insert ID of entries without them. Partial success is not supported:
+// if an entry is invalid, no entry is written.
+func (h *loggingHandler) WriteLogEntries(_ context.Context, req *logpb.WriteLogEntriesRequest) (*logpb.WriteLogEntriesResponse, error) {
+	if len(req.Entries) == 0 {
+		return nil, invalidArgument("no entries")
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := timestamppb.New(s.now())
+	var entries []*logpb.LogEntry
+	for i, e := range req.Entries {
+		e = proto.Clone(e).(*logpb.LogEntry)
+		if e.LogName == "" {
+			e.LogName = req.LogName
+		}
+		if !logNameRegexp.MatchString(e.LogName) {
+			return nil, invalidArgument("entry %d: bad log name %q", i, e.LogName)
+		}
+		if e.Resource == nil {
+			e.Resource = req.Resource
+		}
+		if e.Resource == nil {
+			return nil, invalidArgument("entry %d: missing resource", i)
+		}
+		if len(req.Labels) > 0 && e.Labels == nil {
+			e.Labels = make(map[string]string)
+		}
+		for k, v := range req.Labels {
+			if _, ok := e.Labels[k]; !ok {
+				e.Labels[k] = v
+			}
+		}
+		if e.Timestamp == nil {
+			e.Timestamp = now
+		}
+		e.ReceiveTimestamp = now
+		if e.InsertId == "" {
+			s.nextID++
+			e.InsertId = fmt.Sprintf("logtest-%d", s.nextID)
+		}
+		entries = append(entries, e)
+	}
+	if req.DryRun {
+		return &logpb.WriteLogEntriesResponse{}, nil
+	}
+	s.entries = append(s.entries, entries...)
+	for t := range s.tails {
+		t.send(entries)
+	}
+	return &logpb.WriteLogEntriesResponse{}, nil
+}
+
+// ListLogEntries lists the entries of the resources of the request that
+// match its filter. The order may be "timestamp asc" or "timestamp desc".
+func (h *loggingHandler) ListLogEntries(_ context.Context, req *logpb.ListLogEntriesRequest) (*logpb.ListLogEntriesResponse, error) {
+	if len(req.ResourceNames) == 0 {
+		return nil, invalidArgument("missing resource names")
+	}
+	m, err := logquery.Compile(req.Filter)
+	if err != nil {
+		return nil, invalidArgument("bad filter %q: %v", req.Filter, err)
+	}
+	s := h.s
+	s.mu.Lock()
+	entries := matchingEntries(s.entries, req.ResourceNames, m)
+	s.mu.Unlock() // safe because no stored entry is ever modified
+	switch req.OrderBy {
+	case "", "timestamp", "timestamp asc":
+		sort.SliceStable(entries, func(i, j int) bool { return lessEntry(entries[i], entries[j]) })
+	case "timestamp desc":
+		sort.SliceStable(entries, func(i, j int) bool { return lessEntry(entries[j], entries[i]) })
+	default:
+		return nil, invalidArgument("bad order_by %q", req.OrderBy)
+	}
+	from, to, nextPageToken, err := page(req.PageSize, req.PageToken, len(entries))
+	if err != nil {
+		return nil, err
+	}
+	return &logpb.ListLogEntriesResponse{
+		Entries:       entries[from:to],
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// matchingEntries returns the entries of the resources that match a query.
+func matchingEntries(entries []*logpb.LogEntry, resourceNames []string, m *logquery.Matcher) []*logpb.LogEntry {
+	var es []*logpb.LogEntry
+	for _, e := range entries {
+		if inResources(e, resourceNames) && m.Match(e) {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+func inResources(e *logpb.LogEntry, resourceNames []string) bool {
+	for _, rn := range resourceNames {
+		if strings.HasPrefix(e.LogName, rn+"/logs/") {
+			return true
+		}
+	}
+	return false
+}
+
+func lessEntry(a, b *logpb.LogEntry) bool {
+	ta, tb := a.Timestamp.AsTime(), b.Timestamp.AsTime()
+	if !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return a.InsertId < b.InsertId
+}
+
+// TailLogEntries streams the entries that are written after the session
+// starts and that match its filter. If the client does not receive entries
+// fast enough, they are suppressed and reported as not consumed.
+func (h *loggingHandler) TailLogEntries(stream logpb.LoggingServiceV2_TailLogEntriesServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if len(req.ResourceNames) == 0 {
+		return invalidArgument("missing resource names")
+	}
+	m, err := logquery.Compile(req.Filter)
+	if err != nil {
+		return invalidArgument("bad filter %q: %v", req.Filter, err)
+	}
+	t := &tail{
+		resourceNames: req.ResourceNames,
+		matcher:       m,
+		ch:            make(chan []*logpb.LogEntry, tailBufferSize),
+	}
+	s := h.s
+	s.mu.Lock()
+	s.tails[t] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.tails, t)
+		s.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case entries := <-t.ch:
+			resp := &logpb.TailLogEntriesResponse{Entries: entries}
+			s.mu.Lock()
+			if t.notConsumed > 0 {
+				resp.SuppressionInfo = []*logpb.TailLogEntriesResponse_SuppressionInfo{{
+					Reason:          logpb.TailLogEntriesResponse_SuppressionInfo_NOT_CONSUMED,
+					SuppressedCount: t.notConsumed,
+				}}
+				t.notConsumed = 0
+			}
+			s.mu.Unlock()
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send sends the written entries that match the session to it. It is called
+// with the mutex of the server held.
+func (t *tail) send(entries []*logpb.LogEntry) {
+	es := matchingEntries(entries, t.resourceNames, t.matcher)
+	if len(es) == 0 {
+		return
+	}
+	select {
+	case t.ch <- es:
+	default:
+		t.notConsumed += int32(len(es))
+	}
+}
+
+// ListLogs lists the names of the logs of a resource that have entries.
+func (h *loggingHandler) ListLogs(_ context.Context, req *logpb.ListLogsRequest) (*logpb.ListLogsResponse, error) {
+	resourceNames := req.ResourceNames
+	if len(resourceNames) == 0 {
+		resourceNames = []string{req.Parent}
+	}
+	s := h.s
+	s.mu.Lock()
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range s.entries {
+		if !seen[e.LogName] && inResources(e, resourceNames) {
+			seen[e.LogName] = true
+			names = append(names, e.LogName)
+		}
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+	from, to, nextPageToken, err := page(req.PageSize, req.PageToken, len(names))
+	if err != nil {
+		return nil, err
+	}
+	return &logpb.ListLogsResponse{
+		LogNames:      names[from:to],
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// DeleteLog deletes the entries of a log. The log reappears if it receives
+// new entries.
+func (h *loggingHandler) DeleteLog(_ context.Context, req *logpb.DeleteLogRequest) (*emptypb.Empty, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []*logpb.LogEntry
+	for _, e := range s.entries {
+		if e.LogName != req.LogName {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(s.entries) {
+		return nil, notFound("log %q not found", req.LogName)
+	}
+	s.entries = kept
+	return &emptypb.Empty{}, nil
+}
+
+// resourceDescriptors are the descriptors returned by
+// ListMonitoredResourceDescriptors.
+var resourceDescriptors = []*mrpb.MonitoredResourceDescriptor{
+	{
+		Type:        "global",
+		DisplayName: "Global",
+		Description: "A resource that is not associated with any specific resource.",
+		Labels: []*lpb.LabelDescriptor{
+			{Key: "project_id", Description: "The identifier of the project."},
+		},
+	},
+	{
+		Type:        "gce_instance",
+		DisplayName: "VM Instance",
+		Description: "A virtual machine instance hosted in Compute Engine.",
+		Labels: []*lpb.LabelDescriptor{
+			{Key: "project_id", Description: "The identifier of the project."},
+			{Key: "instance_id", Description: "The numeric VM instance identifier."},
+			{Key: "zone", Description: "The Compute Engine zone of the instance."},
+		},
+	},
+	{
+		Type:        "k8s_container",
+		DisplayName: "Kubernetes Container",
+		Description: "A Kubernetes container instance.",
+		Labels: []*lpb.LabelDescriptor{
+			{Key: "project_id", Description: "The identifier of the project."},
+			{Key: "location", Description: "The location of the cluster."},
+			{Key: "cluster_name", Description: "The name of the cluster."},
+			{Key: "namespace_name", Description: "The name of the namespace."},
+			{Key: "pod_name", Description: "The name of the pod."},
+			{Key: "container_name", Description: "The name of the container."},
+		},
+	},
+	{
+		Type:        "cloud_run_revision",
+		DisplayName: "Cloud Run Revision",
+		Description: "A revision of a Cloud Run service.",
+		Labels: []*lpb.LabelDescriptor{
+			{Key: "project_id", Description: "The identifier of the project."},
+			{Key: "service_name", Description: "The name of the service."},
+			{Key: "revision_name", Description: "The name of the revision."},
+			{Key: "location", Description: "The region of the service."},
+			{Key: "configuration_name", Description: "The name of the configuration."},
+		},
+	},
+}
+
+// ListMonitoredResourceDescriptors lists a fixed set of common monitored
+// resource descriptors.
+func (h *loggingHandler) ListMonitoredResourceDescriptors(_ context.Context, req *logpb.ListMonitoredResourceDescriptorsRequest) (*logpb.ListMonitoredResourceDescriptorsResponse, error) {
+	from, to, nextPageToken, err := page(req.PageSize, req.PageToken, len(resourceDescriptors))
+	if err != nil {
+		return nil, err
+	}
+	return &logpb.ListMonitoredResourceDescriptorsResponse{
+		ResourceDescriptors: resourceDescriptors[from:to],
+		NextPageToken:       nextPageToken,
+	}, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"cloud.google.com/go/logging/logquery"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type metricHandler struct {
+	logpb.UnimplementedMetricsServiceV2Server
+	s *Server
+}
+
+// CreateLogMetric creates a metric. Its filter must be valid.
+func (h *metricHandler) CreateLogMetric(_ context.Context, req *logpb.CreateLogMetricRequest) (*logpb.LogMetric, error) {
+	m := req.Metric
+	if m == nil || m.Name == "" {
+		return nil, invalidArgument("missing metric name")
+	}
+	if m.Filter == "" {
+		return nil, invalidArgument("missing metric filter")
+	}
+	if _, err := logquery.Compile(m.Filter); err != nil {
+		return nil, invalidArgument("bad filter %q: %v", m.Filter, err)
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := req.Parent + "/metrics/" + m.Name
+	if _, ok := s.metrics[name]; ok {
+		return nil, alreadyExists("metric %q already exists", name)
+	}
+	m = proto.Clone(m).(*logpb.LogMetric)
+	m.CreateTime = timestamppb.New(s.now())
+	m.UpdateTime = m.CreateTime
+	s.metrics[name] = m
+	return m, nil
+}
+
+// GetLogMetric gets a metric.
+func (h *metricHandler) GetLogMetric(_ context.Context, req *logpb.GetLogMetricRequest) (*logpb.LogMetric, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.metrics[req.MetricName]
+	if !ok {
+		return nil, notFound("metric %q not found", req.MetricName)
+	}
+	return m, nil
+}
+
+// UpdateLogMetric creates or replaces a metric.
+func (h *metricHandler) UpdateLogMetric(_ context.Context, req *logpb.UpdateLogMetricRequest) (*logpb.LogMetric, error) {
+	m := req.Metric
+	if m == nil {
+		return nil, invalidArgument("missing metric")
+	}
+	if m.Filter == "" {
+		return nil, invalidArgument("missing metric filter")
+	}
+	if _, err := logquery.Compile(m.Filter); err != nil {
+		return nil, invalidArgument("bad filter %q: %v", m.Filter, err)
+	}
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m = proto.Clone(m).(*logpb.LogMetric)
+	m.UpdateTime = timestamppb.New(s.now())
+	if old, ok := s.metrics[req.MetricName]; ok {
+		m.CreateTime = old.CreateTime
+	} else {
+		m.CreateTime = m.UpdateTime
+	}
+	s.metrics[req.MetricName] = m
+	return m, nil
+}
+
+// DeleteLogMetric deletes a metric.
+func (h *metricHandler) DeleteLogMetric(_ context.Context, req *logpb.DeleteLogMetricRequest) (*emptypb.Empty, error) {
+	s := h.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.metrics[req.MetricName]; !ok {
+		return nil, notFound("metric %q not found", req.MetricName)
+	}
+	delete(s.metrics, req.MetricName)
+	return &emptypb.Empty{}, nil
+}
+
+// ListLogMetrics lists the metrics of a project, ordered by name.
+func (h *metricHandler) ListLogMetrics(_ context.Context, req *logpb.ListLogMetricsRequest) (*logpb.ListLogMetricsResponse, error) {
+	s := h.s
+	s.mu.Lock()
+	var metrics []*logpb.LogMetric
+	for name, m := range s.metrics {
+		if strings.HasPrefix(name, req.Parent+"/metrics/") {
+			metrics = append(metrics, m)
+		}
+	}
+	s.mu.Unlock() // safe because no stored metric is ever modified
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	from, to, nextPageToken, err := page(req.PageSize, req.PageToken, len(metrics))
+	if err != nil {
+		return nil, err
+	}
+	return &logpb.ListLogMetricsResponse{
+		Metrics:       metrics[from:to],
+		NextPageToken: nextPageToken,
+	}, nil
+}
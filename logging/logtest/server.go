@@ -0,0 +1,179 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logtest provides a fake Cloud Logging service for testing. It
+// implements the LoggingServiceV2, ConfigServiceV2 and MetricsServiceV2
+// services used by the logging and logadmin packages, in memory.
+//
+// The fake stores the entries that are written and returns them from
+// ListLogEntries and TailLogEntries. Filters are evaluated with the logquery
+// package, which approximates the Logging query language of the service.
+// Sinks, exclusions and metrics are stored, and their filters are validated,
+// but they do not route or exclude entries.
+//
+// This package is EXPERIMENTAL and is subject to change without notice.
+//
+// See the example for usage.
+package logtest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/testutil"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Server is a fake Cloud Logging server.
+type Server struct {
+	srv  *testutil.Server
+	Addr string // The address that the server is listening on.
+
+	mu          sync.Mutex
+	entries     []*logpb.LogEntry
+	nextID      int
+	tails       map[*tail]bool
+	sinks       map[string]*logpb.LogSink      // indexed by full sink name
+	exclusions  map[string]*logpb.LogExclusion // indexed by full exclusion name
+	metrics     map[string]*logpb.LogMetric    // indexed by full metric name
+	timeNowFunc func() time.Time
+}
+
+// NewServer creates a new fake server running in the current process. It
+// panics if the server cannot be started.
+func NewServer() *Server {
+	return NewServerWithPort(0)
+}
+
+// NewServerWithPort creates a new fake server running in the current process
+// at the specified port. It panics if the server cannot be started.
+func NewServerWithPort(port int) *Server {
+	srv, err := testutil.NewServerWithPort(port)
+	if err != nil {
+		panic(fmt.Sprintf("logtest.NewServerWithPort: %v", err))
+	}
+	s := &Server{
+		srv:         srv,
+		Addr:        srv.Addr,
+		tails:       make(map[*tail]bool),
+		sinks:       make(map[string]*logpb.LogSink),
+		exclusions:  make(map[string]*logpb.LogExclusion),
+		metrics:     make(map[string]*logpb.LogMetric),
+		timeNowFunc: time.Now,
+	}
+	logpb.RegisterLoggingServiceV2Server(srv.Gsrv, &loggingHandler{s: s})
+	logpb.RegisterConfigServiceV2Server(srv.Gsrv, &configHandler{s: s})
+	logpb.RegisterMetricsServiceV2Server(srv.Gsrv, &metricHandler{s: s})
+	srv.Start()
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() error {
+	s.srv.Close()
+	return nil
+}
+
+// SetTimeNowFunc registers f as a function to be used instead of time.Now
+// for this server. It is used for the timestamps that the server assigns.
+func (s *Server) SetTimeNowFunc(f func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeNowFunc = f
+}
+
+// Entries returns copies of the entries written to the server, in the order
+// that they were written. The default fields of WriteLogEntries requests are
+// filled in.
+func (s *Server) Entries() []*logpb.LogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	es := make([]*logpb.LogEntry, len(s.entries))
+	for i, e := range s.entries {
+		es[i] = proto.Clone(e).(*logpb.LogEntry)
+	}
+	return es
+}
+
+// ClearEntries removes all entries from the server.
+func (s *Server) ClearEntries() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+}
+
+// Sinks returns copies of the sinks of the server, indexed by their full
+// resource names, such as "projects/P/sinks/S".
+func (s *Server) Sinks() map[string]*logpb.LogSink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]*logpb.LogSink)
+	for name, sink := range s.sinks {
+		m[name] = proto.Clone(sink).(*logpb.LogSink)
+	}
+	return m
+}
+
+// Exclusions returns copies of the exclusions of the server, indexed by their
+// full resource names, such as "projects/P/exclusions/E".
+func (s *Server) Exclusions() map[string]*logpb.LogExclusion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]*logpb.LogExclusion)
+	for name, ex := range s.exclusions {
+		m[name] = proto.Clone(ex).(*logpb.LogExclusion)
+	}
+	return m
+}
+
+// Metrics returns copies of the metrics of the server, indexed by their full
+// resource names, such as "projects/P/metrics/M".
+func (s *Server) Metrics() map[string]*logpb.LogMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]*logpb.LogMetric)
+	for name, metric := range s.metrics {
+		m[name] = proto.Clone(metric).(*logpb.LogMetric)
+	}
+	return m
+}
+
+func (s *Server) now() time.Time {
+	return s.timeNowFunc()
+}
+
+// page returns the bounds of a page of n items.
+func page(pageSize int32, pageToken string, n int) (from, to int, nextPageToken string, err error) {
+	from, to, nextPageToken, err = testutil.PageBounds(int(pageSize), pageToken, n)
+	if err != nil {
+		return 0, 0, "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return from, to, nextPageToken, nil
+}
+
+func notFound(format string, args ...interface{}) error {
+	return status.Errorf(codes.NotFound, format, args...)
+}
+
+func alreadyExists(format string, args ...interface{}) error {
+	return status.Errorf(codes.AlreadyExists, format, args...)
+}
+
+func invalidArgument(format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, format, args...)
+}
@@ -0,0 +1,307 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"cloud.google.com/go/logging/logadmin"
+	"cloud.google.com/go/logging/logtest"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const parent = "projects/P"
+
+func newClients(t *testing.T) (*logtest.Server, *logging.Client, *logadmin.Client) {
+	ctx := context.Background()
+	srv := logtest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	lc, err := logging.NewClient(ctx, parent, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ac, err := logadmin.NewClient(ctx, parent, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, lc, ac
+}
+
+// userEntries returns the entries of the server other than the diagnostic
+// entry that the logging package writes once per process.
+func userEntries(srv *logtest.Server) []*logpb.LogEntry {
+	var es []*logpb.LogEntry
+	for _, e := range srv.Entries() {
+		if !strings.HasSuffix(e.LogName, "/diagnostic-log") {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+func TestEntries(t *testing.T) {
+	ctx := context.Background()
+	srv, lc, ac := newClients(t)
+	lg := lc.Logger("app", logging.CommonLabels(map[string]string{"env": "prod"}))
+	for _, e := range []logging.Entry{
+		{Severity: logging.Info, Payload: "started"},
+		{Severity: logging.Error, Payload: map[string]interface{}{"code": 7}},
+		{Severity: logging.Warning, Payload: "slow", Labels: map[string]string{"env": "dev"}},
+	} {
+		if err := lg.LogSync(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lc.Logger("other").LogSync(ctx, logging.Entry{Payload: "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := userEntries(srv)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	if got := entries[0]; got.LogName != "projects/P/logs/app" || got.Labels["env"] != "prod" || got.InsertId == "" || got.ReceiveTimestamp == nil {
+		t.Errorf("got entry %v", got)
+	}
+	if got := entries[2].Labels["env"]; got != "dev" {
+		t.Errorf("got env label %q, want the label of the entry", got)
+	}
+
+	list := func(opts ...logadmin.EntriesOption) []string {
+		t.Helper()
+		var got []string
+		it := ac.Entries(ctx, opts...)
+		for {
+			e, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, e.Severity.String())
+		}
+		return got
+	}
+	if got := list(logadmin.Filter(`logName = "projects/P/logs/app" AND severity >= WARNING`)); len(got) != 2 {
+		t.Errorf("got %v, want Error and Warning", got)
+	}
+	if got := list(logadmin.Filter(`jsonPayload.code = 7`)); len(got) != 1 || got[0] != "Error" {
+		t.Errorf("got %v, want Error", got)
+	}
+	if got := list(logadmin.Filter(`logName = "projects/P/logs/app" labels.env = prod`), logadmin.NewestFirst()); len(got) != 2 || got[0] != "Error" {
+		t.Errorf("got %v, want Error then Info", got)
+	}
+	if got := list(logadmin.ProjectIDs([]string{"Q"})); len(got) != 0 {
+		t.Errorf("got %v from another project, want none", got)
+	}
+	it := ac.Entries(ctx, logadmin.Filter(`severity =`))
+	if _, err := it.Next(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v for a bad filter, want InvalidArgument", err)
+	}
+
+	var logs []string
+	lit := ac.Logs(ctx)
+	for {
+		l, err := lit.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != "diagnostic-log" {
+			logs = append(logs, l)
+		}
+	}
+	if len(logs) != 2 || logs[0] != "app" || logs[1] != "other" {
+		t.Errorf("got logs %v, want app and other", logs)
+	}
+	if err := ac.DeleteLog(ctx, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(userEntries(srv)); got != 1 {
+		t.Errorf("got %d entries after deleting a log, want 1", got)
+	}
+	if err := ac.DeleteLog(ctx, "app"); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, lc, ac := newClients(t)
+	it := ac.Tail(ctx, logadmin.Filter(`severity >= ERROR`))
+	errc := make(chan error, 1)
+	got := make(chan *logging.Entry)
+	go func() {
+		for {
+			e, err := it.Next()
+			if err != nil {
+				errc <- err
+				return
+			}
+			got <- e
+		}
+	}()
+	lg := lc.Logger("app")
+	// The session may not have started yet, so write until an entry arrives.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-got:
+			if e.Severity != logging.Error {
+				t.Errorf("got severity %v, want Error", e.Severity)
+			}
+			return
+		case err := <-errc:
+			t.Fatal(err)
+		case <-ticker.C:
+			if err := lg.LogSync(ctx, logging.Entry{Severity: logging.Info, Payload: "ignored"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := lg.LogSync(ctx, logging.Entry{Severity: logging.Error, Payload: "tailed"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestSinks(t *testing.T) {
+	ctx := context.Background()
+	srv, _, ac := newClients(t)
+	sink := &logadmin.Sink{ID: "s", Destination: "storage.googleapis.com/b", Filter: "severity >= ERROR"}
+	got, err := ac.CreateSink(ctx, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.WriterIdentity != logtest.SharedWriterIdentity {
+		t.Errorf("got writer identity %q", got.WriterIdentity)
+	}
+	if _, err := ac.CreateSink(ctx, sink); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("got %v, want AlreadyExists", err)
+	}
+	bad := &logadmin.Sink{ID: "bad", Destination: "d", Filter: "severity >="}
+	if _, err := ac.CreateSink(ctx, bad); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v, want InvalidArgument", err)
+	}
+
+	got, err = ac.UpdateSinkOpt(ctx, &logadmin.Sink{ID: "s", Filter: "severity >= WARNING"}, logadmin.SinkOptions{UpdateFilter: true, UniqueWriterIdentity: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Filter != "severity >= WARNING" || got.Destination != sink.Destination || got.WriterIdentity == logtest.SharedWriterIdentity {
+		t.Errorf("got updated sink %+v", got)
+	}
+	if _, err := ac.UpdateSink(ctx, &logadmin.Sink{ID: "missing", Destination: "d"}); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+	if got := srv.Sinks()["projects/P/sinks/s"]; got.GetFilter() != "severity >= WARNING" {
+		t.Errorf("got stored sink %v", got)
+	}
+
+	n := 0
+	it := ac.Sinks(ctx)
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("got %d sinks, want 1", n)
+	}
+	if err := ac.DeleteSink(ctx, "s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ac.Sink(ctx, "s"); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	srv, _, ac := newClients(t)
+	m := &logadmin.Metric{ID: "errors", Description: "d", Filter: "severity >= ERROR"}
+	if err := ac.CreateMetric(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.CreateMetric(ctx, m); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("got %v, want AlreadyExists", err)
+	}
+	m.Description = "d2"
+	if err := ac.UpdateMetric(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ac.Metric(ctx, "errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != "d2" {
+		t.Errorf("got description %q, want d2", got.Description)
+	}
+	if len(srv.Metrics()) != 1 {
+		t.Errorf("got metrics %v, want one", srv.Metrics())
+	}
+	if err := ac.DeleteMetric(ctx, "errors"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.DeleteMetric(ctx, "errors"); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+}
+
+func TestResourceDescriptors(t *testing.T) {
+	ctx := context.Background()
+	_, _, ac := newClients(t)
+	it := ac.ResourceDescriptors(ctx)
+	found := false
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Type == "global" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("global resource descriptor not found")
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SlogHandler returns a slog.Handler that writes records to l. If l was
+// created with RedirectAsJSON, the records are written to the writer of the
+// option.
+//
+// Each record becomes an entry whose JSON payload has a "message" field with
+// the message of the record, and a field for each attribute. Groups become
+// nested objects. The level of the record is mapped to a Severity:
+//
+//	level < LevelInfo                      Debug
+//	LevelInfo <= level < LevelInfo+2       Info
+//	LevelInfo+2 <= level < LevelWarn       Notice
+//	LevelWarn <= level < LevelError        Warning
+//	LevelError <= level < LevelError+4     Error
+//	LevelError+4 <= level < LevelError+8   Critical
+//	LevelError+8 <= level < LevelError+12  Alert
+//	LevelError+12 <= level                 Emergency
+//
+// If opts.AddSource is true, or l populates source locations for the
+// severity of the record, the source of the record is written as the
+// SourceLocation of the entry. If the context of the record has an
+// OpenTelemetry span, its trace and span IDs are written as the Trace and
+// SpanID of the entry.
+//
+// opts.ReplaceAttr is called for the attributes of records, but not for the
+// built-in time, level, message and source, which are written to fields of
+// the entry. If opts is nil, the default options are used.
+func (l *Logger) SlogHandler(opts *slog.HandlerOptions) slog.Handler {
+	h := &slogHandler{l: l}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	return h
+}
+
+type slogHandler struct {
+	l    *Logger
+	opts slog.HandlerOptions
+	// goas are the groups and attributes added with WithGroup and
+	// WithAttrs, in order.
+	goas []groupOrAttrs
+}
+
+// groupOrAttrs is either a group name or a list of attributes.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+func (h *slogHandler) with(goa groupOrAttrs) *slogHandler {
+	h2 := *h
+	h2.goas = make([]groupOrAttrs, len(h.goas)+1)
+	copy(h2.goas, h.goas)
+	h2.goas[len(h.goas)] = goa
+	return &h2
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	payload := make(map[string]interface{})
+	cur := payload
+	var groups []string
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			m := make(map[string]interface{})
+			cur[goa.group] = m
+			cur = m
+			groups = append(groups, goa.group)
+			continue
+		}
+		for _, a := range goa.attrs {
+			h.addAttr(cur, groups, a)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(cur, groups, a)
+		return true
+	})
+	removeEmptyGroups(payload)
+	payload["message"] = r.Message
+
+	e := Entry{
+		Timestamp: r.Time,
+		Severity:  slogSeverity(r.Level),
+		Payload:   payload,
+	}
+	if r.PC != 0 && h.populateSource(e.Severity) {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		e.SourceLocation = &logpb.LogEntrySourceLocation{
+			File:     frame.File,
+			Function: frame.Function,
+			Line:     int64(frame.Line),
+		}
+	}
+	h.populateTraceInfo(ctx, &e)
+	// The Logger is not passed to toLogEntryInternal, so that it does not
+	// populate the source location with the location of the handler.
+	ent, err := toLogEntryInternal(e, nil, h.l.client.parent, 0)
+	if err != nil {
+		h.l.client.error(err)
+		return err
+	}
+	h.l.logEntry(ent)
+	return nil
+}
+
+func (h *slogHandler) populateSource(s Severity) bool {
+	switch h.l.populateSourceLocation {
+	case AlwaysPopulateSourceLocation:
+		return true
+	case PopulateSourceLocationForDebugEntries:
+		if s == Debug {
+			return true
+		}
+	}
+	return h.opts.AddSource
+}
+
+// populateTraceInfo sets the trace and span IDs of e from the OpenTelemetry
+// span of ctx, if any.
+func (h *slogHandler) populateTraceInfo(ctx context.Context, e *Entry) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	// As in populateTraceInfo, the sampled flag of the W3C trace context is
+	// not used, because its definition differs from the one expected by
+	// Cloud Trace.
+	e.Trace = fmt.Sprintf("%s/traces/%s", h.l.client.parent, sc.TraceID())
+	e.SpanID = sc.SpanID().String()
+}
+
+// addAttr adds an attribute to m, where groups are the names of the groups
+// that m is nested in.
+func (h *slogHandler) addAttr(m map[string]interface{}, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup && h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = slogValue(a.Value)
+		return
+	}
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+	if a.Key == "" {
+		// The attributes of a group with an empty key are inlined.
+		for _, ga := range attrs {
+			h.addAttr(m, groups, ga)
+		}
+		return
+	}
+	gm := make(map[string]interface{})
+	groups = append(groups[:len(groups):len(groups)], a.Key)
+	for _, ga := range attrs {
+		h.addAttr(gm, groups, ga)
+	}
+	m[a.Key] = gm
+}
+
+// slogValue returns the JSON value of a resolved slog.Value that is not a
+// group.
+func slogValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	x := v.Any()
+	switch x := x.(type) {
+	case error:
+		return x.Error()
+	case json.Marshaler:
+		return x
+	case fmt.Stringer:
+		return x.String()
+	}
+	if _, err := json.Marshal(x); err != nil {
+		return fmt.Sprint(x)
+	}
+	return x
+}
+
+// removeEmptyGroups removes the groups without attributes from m, and
+// reports whether m is empty.
+func removeEmptyGroups(m map[string]interface{}) bool {
+	for k, v := range m {
+		if gm, ok := v.(map[string]interface{}); ok && removeEmptyGroups(gm) {
+			delete(m, k)
+		}
+	}
+	return len(m) == 0
+}
+
+// slogSeverity returns the Severity of a slog.Level.
+func slogSeverity(level slog.Level) Severity {
+	switch {
+	case level < slog.LevelInfo:
+		return Debug
+	case level < slog.LevelInfo+2:
+		return Info
+	case level < slog.LevelWarn:
+		return Notice
+	case level < slog.LevelError:
+		return Warning
+	case level < slog.LevelError+4:
+		return Error
+	case level < slog.LevelError+8:
+		return Critical
+	case level < slog.LevelError+12:
+		return Alert
+	}
+	return Emergency
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package logging_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slogOutput logs with a handler for a logger that redirects to a buffer,
+// and returns the decoded JSON of the last entry.
+func slogOutput(t *testing.T, opts *slog.HandlerOptions, log func(*slog.Logger)) map[string]interface{} {
+	t.Helper()
+	client, err := fakeClient("projects/P", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var buf strings.Builder
+	logger := client.Logger("slog", logging.RedirectAsJSON(&buf))
+	log(slog.New(logger.SlogHandler(opts)))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &got); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	return got
+}
+
+func TestSlogHandlerPayload(t *testing.T) {
+	got := slogOutput(t, nil, func(l *slog.Logger) {
+		l = l.With("service", "api").WithGroup("req").With("id", 7).WithGroup("empty")
+		l.Warn("request failed",
+			"err", errors.New("boom"),
+			"latency", 1500*time.Millisecond,
+			slog.Group("user", "name", "alice", "admin", true),
+			slog.Group("nothing"))
+	})
+	want := map[string]interface{}{
+		"service": "api",
+		"req": map[string]interface{}{
+			"id": float64(7),
+			"empty": map[string]interface{}{
+				"err":     "boom",
+				"latency": "1.5s",
+				"user":    map[string]interface{}{"name": "alice", "admin": true},
+			},
+		},
+	}
+	// The payload of the entry is written as the message of the redirected
+	// entry.
+	payload, _ := got["message"].(map[string]interface{})
+	if payload["message"] != "request failed" {
+		t.Errorf("got message %v, want %q", payload["message"], "request failed")
+	}
+	delete(payload, "message")
+	if diff := cmp.Diff(want, payload); diff != "" {
+		t.Errorf("payload differs (-want +got):\n%s", diff)
+	}
+	if got["severity"] != "WARNING" {
+		t.Errorf("got severity %v, want WARNING", got["severity"])
+	}
+	if _, ok := got["logging.googleapis.com/sourceLocation"]; ok {
+		t.Error("got a source location without AddSource")
+	}
+}
+
+func TestSlogHandlerOptions(t *testing.T) {
+	opts := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "password" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+	traceID := trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x01}
+	spanID := trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	got := slogOutput(t, opts, func(l *slog.Logger) {
+		l.DebugContext(ctx, "login", "user", "alice", "password", "secret")
+	})
+	if got["severity"] != "DEBUG" {
+		t.Errorf("got severity %v, want DEBUG", got["severity"])
+	}
+	if msg := got["message"].(map[string]interface{}); msg["password"] != nil || msg["user"] != "alice" {
+		t.Errorf("got payload %v, want user without password", msg)
+	}
+	src, _ := got["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	if fn, _ := src["function"].(string); !strings.HasSuffix(fn, "TestSlogHandlerOptions.func2") {
+		t.Errorf("got source location %v, want the function of the test", src)
+	}
+	if want := "projects/P/traces/105445aa7843bc8bf206b12000100001"; got["logging.googleapis.com/trace"] != want {
+		t.Errorf("got trace %v, want %s", got["logging.googleapis.com/trace"], want)
+	}
+	if got["logging.googleapis.com/spanId"] != "0000000000000001" {
+		t.Errorf("got span ID %v, want 0000000000000001", got["logging.googleapis.com/spanId"])
+	}
+}
+
+func TestSlogHandlerLevels(t *testing.T) {
+	client, err := fakeClient("projects/P", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	h := client.Logger("slog").SlogHandler(nil)
+	if h.Enabled(context.Background(), slog.LevelDebug) || !h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("the default level is not Info")
+	}
+	for level, want := range map[slog.Level]string{
+		slog.LevelDebug:      "DEBUG",
+		slog.LevelInfo:       "INFO",
+		slog.LevelInfo + 2:   "NOTICE",
+		slog.LevelError:      "ERROR",
+		slog.LevelError + 4:  "CRITICAL",
+		slog.LevelError + 8:  "ALERT",
+		slog.LevelError + 12: "EMERGENCY",
+	} {
+		got := slogOutput(t, &slog.HandlerOptions{Level: slog.Level(-100)}, func(l *slog.Logger) {
+			l.Log(context.Background(), level, "m")
+		})
+		if got["severity"] != want {
+			t.Errorf("%v: got severity %v, want %s", level, got["severity"], want)
+		}
+	}
+}
@@ -62,6 +62,19 @@ func InternalNewOperation(inner *autogen.OperationsClient, proto *pb.Operation)
 	}
 }
 
+// ResumeOperation returns the long-running operation with the given name,
+// served by c. It allows tracking an operation to resume from its name, for
+// instance after the process that started the operation has restarted.
+//
+// The state of the operation is not fetched: Done reports false until the
+// operation is polled, with Poll, Wait or WaitAll.
+func ResumeOperation(c *autogen.OperationsClient, name string) *Operation {
+	return &Operation{
+		c:     c,
+		proto: &pb.Operation{Name: name},
+	}
+}
+
 // Name returns the name of the long-running operation.
 // The name is assigned by the server and is unique within the service
 // from which the operation is created.
@@ -20,17 +20,23 @@ package longrunning
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
+	autogen "cloud.google.com/go/longrunning/autogen"
+	"cloud.google.com/go/longrunning/longrunningtest"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/duration"
 	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
 	pb "google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
 
@@ -212,3 +218,152 @@ func TestDeleteReturnsError(t *testing.T) {
 		t.Errorf("cancel, got error %s, want %s", got, want)
 	}
 }
+
+func newFakeClient(t *testing.T) (*longrunningtest.Server, *autogen.OperationsClient) {
+	t.Helper()
+	srv := longrunningtest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := autogen.NewOperationsClient(context.Background(), option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return srv, client
+}
+
+func TestResumeOperation(t *testing.T) {
+	srv, client := newFakeClient(t)
+	fop, err := srv.Create("projects/p/operations/op")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fop.CompleteAfterPolls(2, ptypes.DurationProto(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	op := ResumeOperation(client, fop.Name())
+	if op.Name() != fop.Name() || op.Done() {
+		t.Fatalf("got name %q and done %t, want %q and not done", op.Name(), op.Done(), fop.Name())
+	}
+	var resp duration.Duration
+	for i := 0; i < 2; i++ {
+		if err := op.Poll(context.Background(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !op.Done() || resp.Seconds != 1 {
+		t.Errorf("got done %t and response %v, want done and 1s", op.Done(), &resp)
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	srv, client := newFakeClient(t)
+	now := time.Unix(0, 0)
+	srv.SetTimeNowFunc(func() time.Time { return now })
+
+	var fops []*longrunningtest.Op
+	var ops []*Operation
+	for i, name := range []string{"projects/p/operations/a", "projects/p/operations/b", "other/c"} {
+		fop, err := srv.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fop.CompleteAfter(time.Duration(i+1)*time.Second, ptypes.DurationProto(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		fops = append(fops, fop)
+		ops = append(ops, ResumeOperation(client, name))
+	}
+	// Operations that are already done are not polled.
+	done, err := srv.Create("projects/p/operations/done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done.Fail(status.Error(codes.Aborted, "aborted"))
+	ops = append(ops, InternalNewOperation(client, done.Proto()))
+
+	var progress []string
+	opts := &WaitAllOptions{
+		ListName:   "projects/p/operations",
+		OnProgress: func(op *Operation) { progress = append(progress, op.Name()) },
+	}
+	// Advance the clock by one second per poll, whatever the backoff.
+	sl := func(context.Context, time.Duration) error {
+		now = now.Add(time.Second)
+		if err := fops[1].SetMetadata(ptypes.DurationProto(now.Sub(time.Unix(0, 0)))); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}
+	bo := gax.Backoff{Initial: time.Second, Max: time.Second}
+	if err := waitAll(context.Background(), ops, opts, &bo, sl); err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range ops {
+		if !op.Done() {
+			t.Errorf("%s: not done", op.Name())
+		}
+	}
+	if err := ops[3].Poll(context.Background(), nil); status.Code(err) != codes.Aborted {
+		t.Errorf("got %v, want code Aborted", err)
+	}
+	// The listed operations are not polled individually.
+	for i, want := range []int{0, 0, 4} {
+		if got := fops[i].Polls(); got != want {
+			t.Errorf("%s: got %d polls, want %d", fops[i].Name(), got, want)
+		}
+	}
+	want := []string{
+		"projects/p/operations/a", "projects/p/operations/b", // a is done, b has new metadata
+		"projects/p/operations/b",
+		"other/c",
+	}
+	if !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress: got %v, want %v", progress, want)
+	}
+}
+
+type retryService struct {
+	operationsClient
+	errs []error
+}
+
+func (s *retryService) GetOperation(_ context.Context, req *pb.GetOperationRequest, _ ...gax.CallOption) (*pb.Operation, error) {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return &pb.Operation{Name: req.Name, Done: true}, nil
+}
+
+func TestWaitAllRetryDelay(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "try later").WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(42 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &retryService{errs: []error{st.Err()}}
+	op := &Operation{c: s, proto: &pb.Operation{Name: "foo"}}
+	var sleeps []time.Duration
+	sl := func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	bo := gax.Backoff{Initial: time.Second, Max: time.Second}
+	if err := waitAll(context.Background(), []*Operation{op}, &WaitAllOptions{}, &bo, sl); err != nil {
+		t.Fatal(err)
+	}
+	if len(sleeps) != 1 || sleeps[0] != 42*time.Second {
+		t.Errorf("got sleeps %v, want [42s]", sleeps)
+	}
+
+	s.errs = []error{status.Error(codes.Unavailable, "no retry info")}
+	op = &Operation{c: s, proto: &pb.Operation{Name: "foo"}}
+	if err := waitAll(context.Background(), []*Operation{op}, &WaitAllOptions{}, &bo, sl); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want code Unavailable", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package longrunningtest_test
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/internal/testutil"
+	"cloud.google.com/go/longrunning"
+	autogen "cloud.google.com/go/longrunning/autogen"
+	"cloud.google.com/go/longrunning/longrunningtest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func ExampleOperations() {
+	ctx := context.Background()
+	// Serve the Operations service alongside the fake service under test.
+	srv, err := testutil.NewServer()
+	if err != nil {
+		// TODO: Handle error.
+	}
+	ops := longrunningtest.NewOperations()
+	ops.Register(srv.Gsrv)
+	// TODO: Register the fake service under test on srv.Gsrv.
+	srv.Start()
+	defer srv.Close()
+
+	// The fake service creates an operation that completes on the third poll,
+	// and returns fop.Proto() to its caller.
+	fop, err := ops.Create("")
+	if err != nil {
+		// TODO: Handle error.
+	}
+	if err := fop.CompleteAfterPolls(3, durationpb.New(time.Second)); err != nil {
+		// TODO: Handle error.
+	}
+
+	// The client under test waits for the operation.
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	defer conn.Close()
+	client, err := autogen.NewOperationsClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	op := longrunning.InternalNewOperation(client, fop.Proto())
+	var resp durationpb.Duration
+	if err := op.WaitWithInterval(ctx, &resp, time.Second); err != nil {
+		// TODO: Handle error.
+	}
+	// The calls made by the client are available from fop.
+	_ = fop.Polls()
+}
@@ -0,0 +1,467 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package longrunningtest provides a fake google.longrunning.Operations
+// service for testing clients of long-running operations. It implements the
+// service in memory.
+//
+// Tests create operations with Operations.Create, typically from the fake
+// implementation of the method that starts the operation, and decide when
+// and how the operations complete: immediately, after a number of polls, or
+// after a delay. The calls that clients make to poll, cancel and delete the
+// operations can then be observed.
+//
+// The Operations service is usually served by the same gRPC server as the
+// service that returns the operations. In that case, register it on that
+// server with Operations.Register. Otherwise, NewServer starts a server that
+// serves it alone.
+//
+// This package is EXPERIMENTAL and is subject to change without notice.
+//
+// See the example for usage.
+package longrunningtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/testutil"
+	pb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Server is a fake Operations server that serves the Operations service
+// alone.
+type Server struct {
+	srv  *testutil.Server
+	Addr string // The address that the server is listening on.
+
+	*Operations
+}
+
+// NewServer creates a new fake server running in the current process. It
+// panics if the server cannot be started.
+func NewServer() *Server {
+	return NewServerWithPort(0)
+}
+
+// NewServerWithPort creates a new fake server running in the current process
+// at the specified port. It panics if the server cannot be started.
+func NewServerWithPort(port int) *Server {
+	srv, err := testutil.NewServerWithPort(port)
+	if err != nil {
+		panic(fmt.Sprintf("longrunningtest.NewServerWithPort: %v", err))
+	}
+	s := &Server{
+		srv:        srv,
+		Addr:       srv.Addr,
+		Operations: NewOperations(),
+	}
+	s.Register(srv.Gsrv)
+	srv.Start()
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() error {
+	s.srv.Close()
+	return nil
+}
+
+// Operations is an in-memory implementation of the Operations service.
+type Operations struct {
+	pb.UnimplementedOperationsServer
+
+	mu          sync.Mutex
+	ops         map[string]*Op // indexed by operation name, including deleted operations
+	order       []*Op          // in order of creation
+	nextID      int
+	timeNowFunc func() time.Time
+}
+
+// NewOperations returns a new Operations service with no operations.
+func NewOperations() *Operations {
+	return &Operations{
+		ops:         make(map[string]*Op),
+		timeNowFunc: time.Now,
+	}
+}
+
+// Register registers the service on a gRPC server, such as the Gsrv field of
+// a testutil.Server. It must be called before the server is started.
+func (s *Operations) Register(gsrv *grpc.Server) {
+	pb.RegisterOperationsServer(gsrv, s)
+}
+
+// SetTimeNowFunc registers f as a function to be used instead of time.Now
+// for this service. It is used to decide when the operations that complete
+// after a delay are done.
+func (s *Operations) SetTimeNowFunc(f func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeNowFunc = f
+}
+
+// Create creates an operation that is not done. If name is empty, a name of
+// the form "operations/N" is assigned. It is an error to create two
+// operations with the same name, even if the first has been deleted.
+func (s *Operations) Create(name string) (*Op, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name == "" {
+		for {
+			s.nextID++
+			name = fmt.Sprintf("operations/%d", s.nextID)
+			if s.ops[name] == nil {
+				break
+			}
+		}
+	}
+	if s.ops[name] != nil {
+		return nil, fmt.Errorf("longrunningtest: operation %q already exists", name)
+	}
+	op := &Op{
+		s:     s,
+		proto: &pb.Operation{Name: name},
+		done:  make(chan struct{}),
+	}
+	s.ops[name] = op
+	s.order = append(s.order, op)
+	return op, nil
+}
+
+// Op returns the operation with the given name, or nil if there is none.
+// Deleted operations are returned.
+func (s *Operations) Op(name string) *Op {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ops[name]
+}
+
+// Op is an operation of a fake Operations service. Its methods are safe
+// for concurrent use.
+type Op struct {
+	s *Operations // its mu guards the fields below
+
+	proto *pb.Operation
+	done  chan struct{} // closed when the operation is done
+
+	// result holds the result of the operation until it completes. It is
+	// nil if no result has been set.
+	result     *pb.Operation
+	afterPolls int       // poll at which the result is applied, if positive
+	at         time.Time // time at which the result is applied, if not zero
+
+	polls    int
+	canceled bool
+	deleted  bool
+}
+
+// Name returns the name of the operation.
+func (op *Op) Name() string {
+	// The name never changes.
+	return op.proto.Name
+}
+
+// Proto returns a copy of the current state of the operation. It does not
+// count as a poll.
+func (op *Op) Proto() *pb.Operation {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	op.update()
+	return proto.Clone(op.proto).(*pb.Operation)
+}
+
+// SetMetadata sets the metadata of the operation to m. It can be called at
+// any time, for instance to report progress between polls.
+func (op *Op) SetMetadata(m proto.Message) error {
+	a, err := anypb.New(m)
+	if err != nil {
+		return err
+	}
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	op.proto.Metadata = a
+	return nil
+}
+
+// Complete completes the operation successfully with the response resp.
+func (op *Op) Complete(resp proto.Message) error {
+	return op.CompleteAfterPolls(0, resp)
+}
+
+// Fail completes the operation with an error. The status of err, as
+// returned by status.Convert, becomes the error of the operation.
+func (op *Op) Fail(err error) {
+	op.FailAfterPolls(0, err)
+}
+
+// CompleteAfterPolls arranges for the operation to complete successfully with
+// the response resp on the nth poll from now: the next n-1 calls to
+// GetOperation or WaitOperation for it report that it is not done. If n is not
+// positive, the operation completes immediately.
+func (op *Op) CompleteAfterPolls(n int, resp proto.Message) error {
+	r, err := responseResult(resp)
+	if err != nil {
+		return err
+	}
+	op.setResult(r, n, time.Time{})
+	return nil
+}
+
+// FailAfterPolls is like CompleteAfterPolls, but the operation completes with
+// an error, as for Fail.
+func (op *Op) FailAfterPolls(n int, err error) {
+	op.setResult(errorResult(err), n, time.Time{})
+}
+
+// CompleteAfter arranges for the operation to complete successfully with the
+// response resp once d has elapsed, as measured by the time function of the
+// service.
+func (op *Op) CompleteAfter(d time.Duration, resp proto.Message) error {
+	r, err := responseResult(resp)
+	if err != nil {
+		return err
+	}
+	op.setResult(r, 0, op.now().Add(d))
+	return nil
+}
+
+// FailAfter is like CompleteAfter, but the operation completes with an
+// error, as for Fail.
+func (op *Op) FailAfter(d time.Duration, err error) {
+	op.setResult(errorResult(err), 0, op.now().Add(d))
+}
+
+func responseResult(resp proto.Message) (*pb.Operation, error) {
+	a, err := anypb.New(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Operation{Result: &pb.Operation_Response{Response: a}}, nil
+}
+
+func errorResult(err error) *pb.Operation {
+	return &pb.Operation{Result: &pb.Operation_Error{Error: status.Convert(err).Proto()}}
+}
+
+func (op *Op) now() time.Time {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	return op.s.timeNowFunc()
+}
+
+// setResult sets the result of the operation, and when it applies. The
+// result replaces any earlier result that has not been applied. If neither
+// afterPolls nor at is set, the result applies immediately.
+func (op *Op) setResult(r *pb.Operation, afterPolls int, at time.Time) {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	if op.proto.Done {
+		return
+	}
+	op.result = r
+	op.afterPolls = 0
+	if afterPolls > 0 {
+		op.afterPolls = op.polls + afterPolls
+	}
+	op.at = at
+	if op.afterPolls == 0 && at.IsZero() {
+		op.complete()
+	}
+}
+
+// update applies the result of the operation if it is due.
+// op.s.mu must be held.
+func (op *Op) update() {
+	if op.proto.Done || op.result == nil {
+		return
+	}
+	if (op.afterPolls > 0 && op.polls >= op.afterPolls) || (!op.at.IsZero() && !op.s.timeNowFunc().Before(op.at)) {
+		op.complete()
+	}
+}
+
+// complete applies the result of the operation. op.s.mu must be held.
+func (op *Op) complete() {
+	op.proto.Result = op.result.Result
+	op.proto.Done = true
+	op.result = nil
+	close(op.done)
+}
+
+// Done reports whether the operation is done.
+func (op *Op) Done() bool {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	op.update()
+	return op.proto.Done
+}
+
+// Polls returns the number of calls to GetOperation and WaitOperation that
+// have been made for the operation.
+func (op *Op) Polls() int {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	return op.polls
+}
+
+// Canceled reports whether CancelOperation has been called for the
+// operation.
+func (op *Op) Canceled() bool {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	return op.canceled
+}
+
+// Deleted reports whether DeleteOperation has been called for the operation.
+func (op *Op) Deleted() bool {
+	op.s.mu.Lock()
+	defer op.s.mu.Unlock()
+	return op.deleted
+}
+
+// lookup returns the operation with the given name, if it has not been
+// deleted. s.mu must be held.
+func (s *Operations) lookup(name string) (*Op, error) {
+	op := s.ops[name]
+	if op == nil || op.deleted {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", name)
+	}
+	return op, nil
+}
+
+// ListOperations implements the ListOperations method of the Operations
+// service. It lists the operations whose names start with the name of the
+// request followed by a slash, or all operations if the name is empty. The
+// filter of the request is ignored.
+func (s *Operations) ListOperations(_ context.Context, req *pb.ListOperationsRequest) (*pb.ListOperationsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ops []*pb.Operation
+	for _, op := range s.order {
+		if op.deleted || (req.Name != "" && !strings.HasPrefix(op.proto.Name, req.Name+"/")) {
+			continue
+		}
+		op.update()
+		ops = append(ops, op.proto)
+	}
+	from, to, nextPageToken, err := testutil.PageBounds(int(req.PageSize), req.PageToken, len(ops))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res := &pb.ListOperationsResponse{NextPageToken: nextPageToken}
+	for _, op := range ops[from:to] {
+		res.Operations = append(res.Operations, proto.Clone(op).(*pb.Operation))
+	}
+	return res, nil
+}
+
+// GetOperation implements the GetOperation method of the Operations service.
+func (s *Operations) GetOperation(_ context.Context, req *pb.GetOperationRequest) (*pb.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, err := s.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	op.polls++
+	op.update()
+	return proto.Clone(op.proto).(*pb.Operation), nil
+}
+
+// DeleteOperation implements the DeleteOperation method of the Operations
+// service. Deleted operations are no longer returned by the service, but
+// they remain available to the test through Operations.Op.
+func (s *Operations) DeleteOperation(_ context.Context, req *pb.DeleteOperationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, err := s.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	op.deleted = true
+	return &emptypb.Empty{}, nil
+}
+
+// CancelOperation implements the CancelOperation method of the Operations
+// service. If the operation is not done, it completes at once with an error
+// with code Canceled.
+func (s *Operations) CancelOperation(_ context.Context, req *pb.CancelOperationRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, err := s.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	op.canceled = true
+	op.update()
+	if !op.proto.Done {
+		op.result = errorResult(status.Error(codes.Canceled, "operation was canceled"))
+		op.complete()
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WaitOperation implements the WaitOperation method of the Operations
+// service. It counts as a poll, and waits until the operation is done, the
+// timeout of the request has elapsed, or the context is done. The timeout
+// has no default.
+func (s *Operations) WaitOperation(ctx context.Context, req *pb.WaitOperationRequest) (*pb.Operation, error) {
+	if t := req.GetTimeout(); t != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.AsDuration())
+		defer cancel()
+	}
+	s.mu.Lock()
+	op, err := s.lookup(req.Name)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	op.polls++
+	for {
+		op.update()
+		if op.proto.Done || ctx.Err() != nil {
+			res := proto.Clone(op.proto).(*pb.Operation)
+			s.mu.Unlock()
+			return res, nil
+		}
+		// Wake up when the operation completes, or when its result is due.
+		var t *time.Timer
+		var timer <-chan time.Time
+		if op.result != nil && !op.at.IsZero() {
+			t = time.NewTimer(op.at.Sub(s.timeNowFunc()))
+			timer = t.C
+		}
+		s.mu.Unlock()
+		select {
+		case <-op.done:
+		case <-timer:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		s.mu.Lock()
+	}
+}
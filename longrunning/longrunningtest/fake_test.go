@@ -0,0 +1,235 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package longrunningtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning"
+	autogen "cloud.google.com/go/longrunning/autogen"
+	pb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newClient(t *testing.T) (*Server, *autogen.OperationsClient) {
+	t.Helper()
+	srv := NewServer()
+	t.Cleanup(func() { srv.Close() })
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := autogen.NewOperationsClient(context.Background(), option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return srv, client
+}
+
+func TestCompleteAfterPolls(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	fop, err := srv.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fop.Name(), "operations/1"; got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+	if err := fop.SetMetadata(timestamppb.New(time.Unix(1, 0))); err != nil {
+		t.Fatal(err)
+	}
+	if err := fop.CompleteAfterPolls(3, durationpb.New(42*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	op := longrunning.InternalNewOperation(client, fop.Proto())
+	var meta timestamppb.Timestamp
+	if err := op.Metadata(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if got := meta.AsTime(); !got.Equal(time.Unix(1, 0)) {
+		t.Errorf("got metadata %v, want %v", got, time.Unix(1, 0))
+	}
+	var resp durationpb.Duration
+	for i := 1; i <= 3; i++ {
+		if err := op.Poll(ctx, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := op.Done(), i == 3; got != want {
+			t.Errorf("poll %d: got done %t, want %t", i, got, want)
+		}
+	}
+	if got, want := resp.AsDuration(), 42*time.Second; got != want {
+		t.Errorf("got response %v, want %v", got, want)
+	}
+	if got, want := fop.Polls(), 3; got != want {
+		t.Errorf("got %d polls, want %d", got, want)
+	}
+}
+
+func TestFail(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	for _, test := range []struct {
+		desc  string
+		setup func(*Op)
+		polls int
+	}{
+		{"immediately", func(op *Op) { op.Fail(status.Error(codes.NotFound, "nope")) }, 1},
+		{"after polls", func(op *Op) { op.FailAfterPolls(2, status.Error(codes.NotFound, "nope")) }, 2},
+	} {
+		fop, err := srv.Create("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.setup(fop)
+		op := longrunning.InternalNewOperation(client, &pb.Operation{Name: fop.Name()})
+		for i := 1; i < test.polls; i++ {
+			if err := op.Poll(ctx, nil); err != nil || op.Done() {
+				t.Fatalf("%s: poll %d: got done %t, %v, want not done", test.desc, i, op.Done(), err)
+			}
+		}
+		err = op.Poll(ctx, nil)
+		if status.Code(err) != codes.NotFound || !op.Done() {
+			t.Errorf("%s: got done %t, %v, want done with code NotFound", test.desc, op.Done(), err)
+		}
+	}
+}
+
+func TestCompleteAfter(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	now := time.Unix(100, 0)
+	srv.SetTimeNowFunc(func() time.Time { return now })
+	fop, err := srv.Create("projects/p/operations/op")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fop.CompleteAfter(time.Minute, durationpb.New(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	op := longrunning.InternalNewOperation(client, fop.Proto())
+	if err := op.Poll(ctx, nil); err != nil || op.Done() {
+		t.Fatalf("got done %t, %v, want not done", op.Done(), err)
+	}
+	now = now.Add(time.Minute)
+	if err := op.Poll(ctx, nil); err != nil || !op.Done() {
+		t.Fatalf("got done %t, %v, want done", op.Done(), err)
+	}
+}
+
+func TestCancelDelete(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	fop, err := srv.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := longrunning.InternalNewOperation(client, fop.Proto())
+	if err := op.Cancel(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !fop.Canceled() || !fop.Done() {
+		t.Errorf("got canceled %t and done %t, want both", fop.Canceled(), fop.Done())
+	}
+	if err := op.Poll(ctx, nil); status.Code(err) != codes.Canceled {
+		t.Errorf("Poll after Cancel: got %v, want code Canceled", err)
+	}
+
+	if err := op.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !fop.Deleted() {
+		t.Error("got not deleted, want deleted")
+	}
+	if srv.Op(fop.Name()) != fop {
+		t.Error("deleted operation is not available with Op")
+	}
+	_, err = client.GetOperation(ctx, &pb.GetOperationRequest{Name: fop.Name()})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetOperation after Delete: got %v, want code NotFound", err)
+	}
+	if _, err := srv.Create(fop.Name()); err == nil {
+		t.Error("Create with the name of a deleted operation: got nil, want error")
+	}
+}
+
+func TestWaitOperation(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	fop, err := srv.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fop.CompleteAfter(50*time.Millisecond, durationpb.New(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	p, err := client.WaitOperation(ctx, &pb.WaitOperationRequest{Name: fop.Name(), Timeout: durationpb.New(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done {
+		t.Error("WaitOperation: got not done, want done")
+	}
+
+	fop, err = srv.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = client.WaitOperation(ctx, &pb.WaitOperationRequest{Name: fop.Name(), Timeout: durationpb.New(10 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Done {
+		t.Error("WaitOperation with timeout: got done, want not done")
+	}
+}
+
+func TestListOperations(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	names := []string{"projects/p/operations/a", "projects/q/operations/b", "projects/p/operations/c"}
+	for _, name := range names {
+		if _, err := srv.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	it := client.ListOperations(ctx, &pb.ListOperationsRequest{Name: "projects/p/operations", PageSize: 1})
+	var got []string
+	for {
+		op, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, op.Name)
+	}
+	if len(got) != 2 || got[0] != names[0] || got[1] != names[2] {
+		t.Errorf("got %v, want %v", got, []string{names[0], names[2]})
+	}
+}
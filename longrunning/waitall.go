@@ -0,0 +1,217 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package longrunning
+
+import (
+	"context"
+	"time"
+
+	autogen "cloud.google.com/go/longrunning/autogen"
+	"github.com/golang/protobuf/proto"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/iterator"
+	pb "google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WaitAllOptions configure WaitAll. The zero value is valid.
+type WaitAllOptions struct {
+	// Interval is the maximum time between polls. Polls are initially more
+	// frequent, with exponential backoff. If zero, DefaultWaitInterval is
+	// used.
+	Interval time.Duration
+
+	// ListName, if not empty, is the name of a collection of operations,
+	// such as "projects/my-project/operations", that contains the
+	// operations. WaitAll then fetches their state with ListOperations
+	// calls on the collection, one series of pages per poll, rather than
+	// with a GetOperation call for each operation. Operations that are not
+	// listed are polled with GetOperation. If the server does not implement
+	// ListOperations, WaitAll falls back to GetOperation.
+	//
+	// The collection is listed with the client of the first operation, so
+	// the operations should share their client.
+	ListName string
+
+	// ListFilter is the filter of the ListOperations calls, in the syntax
+	// of the service. It can be used to reduce the number of operations
+	// that are listed, for instance to those that are not done, where the
+	// service supports it.
+	ListFilter string
+
+	// OnProgress, if not nil, is called when the state of an operation
+	// changes: when its metadata changes, and when it is done. It is called
+	// by the goroutine that called WaitAll.
+	OnProgress func(op *Operation)
+}
+
+// WaitAll blocks until all the operations are completed. It polls the
+// operations that are not done together, with a backoff that they share, as
+// described in WaitAllOptions. If opts is nil, default options are used.
+//
+// WaitAll returns an error if a request fails or ctx is done, but not if
+// operations complete with failure. Once WaitAll returns nil, call Poll on
+// each operation to get its response or error; Poll does not make a request
+// for completed operations.
+//
+// If a request fails with an error that suggests a retry delay, with a
+// google.rpc.RetryInfo error detail, WaitAll waits for that delay and
+// polls again instead of returning the error.
+func WaitAll(ctx context.Context, ops []*Operation, opts *WaitAllOptions, callOpts ...gax.CallOption) error {
+	if opts == nil {
+		opts = &WaitAllOptions{}
+	}
+	interval := opts.Interval
+	if interval == 0 {
+		interval = DefaultWaitInterval
+	}
+	bo := gax.Backoff{
+		Initial: 1 * time.Second,
+		Max:     interval,
+	}
+	if bo.Max < bo.Initial {
+		bo.Max = bo.Initial
+	}
+	return waitAll(ctx, ops, opts, &bo, gax.Sleep, callOpts...)
+}
+
+type operationLister interface {
+	ListOperations(context.Context, *pb.ListOperationsRequest, ...gax.CallOption) *autogen.OperationIterator
+}
+
+// waitAll implements WaitAll, taking exponentialBackoff and sleeper arguments
+// for testing.
+func waitAll(ctx context.Context, ops []*Operation, opts *WaitAllOptions, bo *gax.Backoff, sl sleeper, callOpts ...gax.CallOption) error {
+	w := &waiter{opts: opts, list: opts.ListName != "", callOpts: callOpts}
+	pending := notDone(ops)
+	for len(pending) > 0 {
+		var delay time.Duration
+		if err := w.poll(ctx, pending); err != nil {
+			d, ok := retryDelay(err)
+			if !ok {
+				return err
+			}
+			delay = d
+		} else {
+			delay = bo.Pause()
+		}
+		if pending = notDone(pending); len(pending) == 0 {
+			break
+		}
+		if err := sl(ctx, delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notDone(ops []*Operation) []*Operation {
+	var res []*Operation
+	for _, op := range ops {
+		if !op.Done() {
+			res = append(res, op)
+		}
+	}
+	return res
+}
+
+type waiter struct {
+	opts     *WaitAllOptions
+	list     bool // whether to list operations
+	callOpts []gax.CallOption
+}
+
+// poll fetches the state of the pending operations.
+func (w *waiter) poll(ctx context.Context, pending []*Operation) error {
+	if w.list {
+		if l, ok := pending[0].c.(operationLister); ok {
+			rest, err := w.listPoll(ctx, l, pending)
+			if status.Code(err) == codes.Unimplemented {
+				w.list = false
+			} else if err != nil {
+				return err
+			} else {
+				pending = rest
+			}
+		} else {
+			w.list = false
+		}
+	}
+	for _, op := range pending {
+		p, err := op.c.GetOperation(ctx, &pb.GetOperationRequest{Name: op.Name()}, w.callOpts...)
+		if err != nil {
+			return err
+		}
+		w.set(op, p)
+	}
+	return nil
+}
+
+// listPoll updates the pending operations that are listed in the collection
+// of the options, and returns the others.
+func (w *waiter) listPoll(ctx context.Context, l operationLister, pending []*Operation) ([]*Operation, error) {
+	byName := make(map[string]*Operation, len(pending))
+	for _, op := range pending {
+		byName[op.Name()] = op
+	}
+	it := l.ListOperations(ctx, &pb.ListOperationsRequest{Name: w.opts.ListName, Filter: w.opts.ListFilter}, w.callOpts...)
+	for {
+		p, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if op := byName[p.Name]; op != nil {
+			w.set(op, p)
+			delete(byName, p.Name)
+		}
+	}
+	var rest []*Operation
+	for _, op := range pending {
+		if byName[op.Name()] != nil {
+			rest = append(rest, op)
+		}
+	}
+	return rest, nil
+}
+
+// set updates the state of an operation, and reports progress if it has
+// changed.
+func (w *waiter) set(op *Operation, p *pb.Operation) {
+	changed := p.Done || !proto.Equal(op.proto.Metadata, p.Metadata)
+	op.proto = p
+	if changed && w.opts.OnProgress != nil {
+		w.opts.OnProgress(op)
+	}
+}
+
+// retryDelay returns the retry delay suggested by the details of an error,
+// if any.
+func retryDelay(err error) (time.Duration, bool) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, d := range s.Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok && ri.RetryDelay != nil {
+			return ri.RetryDelay.AsDuration(), true
+		}
+	}
+	return 0, false
+}
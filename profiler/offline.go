@@ -0,0 +1,202 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/profiler/internal"
+	pb "google.golang.org/genproto/googleapis/devtools/cloudprofiler/v2"
+)
+
+const (
+	defaultOfflinePeriod   = time.Minute
+	defaultOfflineDuration = 10 * time.Second
+)
+
+// nowFunc is stubbed to be overrideable for testing.
+var nowFunc = time.Now
+
+// Sink receives the profiles collected in offline mode. See Config.Sink.
+type Sink interface {
+	// WriteProfile writes a profile. It is called by a single goroutine.
+	// Errors are logged when debug logging is enabled, and do not stop
+	// profile collection.
+	WriteProfile(ctx context.Context, p *Profile) error
+}
+
+// Profile is a profile collected in offline mode.
+type Profile struct {
+	// Type is the type of the profile: "CPU", "HEAP", "HEAP_ALLOC",
+	// "THREADS" or "CONTENTION".
+	Type string
+
+	// Service and ServiceVersion are the service and version of the
+	// configuration.
+	Service        string
+	ServiceVersion string
+
+	// Labels are the labels that the agent attaches to the deployment and to
+	// the profiles, such as "language", "zone" and "instance".
+	Labels map[string]string
+
+	// Start is the time the collection of the profile started, and Duration
+	// is the time period that the profile covers. Duration is zero for the
+	// heap and goroutine profiles, which are snapshots.
+	Start    time.Time
+	Duration time.Duration
+
+	// Data is the profile in the gzipped protocol buffer format of pprof.
+	Data []byte
+}
+
+// collectOffline collects profiles of the enabled types in turn, one every
+// offline period, and writes them to the sink of the configuration.
+func collectOffline(ctx context.Context, a *agent) {
+	debugLog("Cloud Profiler Go Agent version: %s", internal.Version)
+	debugLog("profiler has started in offline mode")
+	for i := 0; config.numProfiles == 0 || i < config.numProfiles; i++ {
+		start := nowFunc()
+		pt := a.profileTypes[i%len(a.profileTypes)]
+		if p, err := a.offlineProfile(ctx, pt, start); err != nil {
+			debugLog("%v", err)
+		} else if err := config.Sink.WriteProfile(ctx, p); err != nil {
+			debugLog("failed to write %v profile: %v", pt, err)
+		} else {
+			debugLog("successfully wrote %v profile", pt)
+		}
+		if d := config.OfflinePeriod - nowFunc().Sub(start); d > 0 {
+			sleep(ctx, d)
+		}
+	}
+
+	if profilingDone != nil {
+		profilingDone <- true
+	}
+}
+
+func (a *agent) offlineProfile(ctx context.Context, pt pb.ProfileType, start time.Time) (*Profile, error) {
+	var duration time.Duration
+	switch pt {
+	case pb.ProfileType_CPU, pb.ProfileType_HEAP_ALLOC, pb.ProfileType_CONTENTION:
+		duration = config.OfflineDuration
+	}
+	data, err := collectProfile(ctx, pt, duration)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string)
+	for k, v := range a.deployment.Labels {
+		labels[k] = v
+	}
+	for k, v := range a.profileLabels {
+		labels[k] = v
+	}
+	return &Profile{
+		Type:           pt.String(),
+		Service:        config.Service,
+		ServiceVersion: config.ServiceVersion,
+		Labels:         labels,
+		Start:          start,
+		Duration:       duration,
+		Data:           data,
+	}, nil
+}
+
+// DirSink is a Sink that writes each profile to a file in a directory, and
+// deletes the oldest profiles when the directory has more than MaxFiles of
+// them. The files are named
+//
+//	<start time>-<service>-<type>.pb.gz
+//
+// with the start time in UTC, for example
+// 20230102T150405.000000000Z-my-service-cpu.pb.gz, so that they sort by
+// time. They can be read with "go tool pprof".
+type DirSink struct {
+	dir      string
+	maxFiles int
+	mu       sync.Mutex
+}
+
+// dirSinkFileRegexp matches the names of the files written by a DirSink.
+var dirSinkFileRegexp = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z-.+\.pb\.gz$`)
+
+// NewDirSink returns a DirSink that writes profiles to dir, creating it if
+// needed. If maxFiles is positive, at most maxFiles profiles are kept in
+// the directory; otherwise, no profile is deleted.
+func NewDirSink(dir string, maxFiles int) (*DirSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirSink{dir: dir, maxFiles: maxFiles}, nil
+}
+
+// WriteProfile implements Sink. The file is written atomically, so that
+// readers of the directory never see a partial profile.
+func (s *DirSink) WriteProfile(_ context.Context, p *Profile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := fmt.Sprintf("%s-%s-%s.pb.gz", p.Start.UTC().Format("20060102T150405.000000000Z"), p.Service, strings.ToLower(p.Type))
+	f, err := os.CreateTemp(s.dir, ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(p.Data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(s.dir, name)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return s.rotate()
+}
+
+// rotate deletes the oldest profiles in excess of maxFiles.
+func (s *DirSink) rotate() error {
+	if s.maxFiles <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	// The entries are sorted by name, and so by time.
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && dirSinkFileRegexp.MatchString(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	for len(names) > s.maxFiles {
+		if err := os.Remove(filepath.Join(s.dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
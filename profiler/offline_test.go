@@ -0,0 +1,205 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+type recordingSink struct {
+	profiles []*Profile
+}
+
+func (s *recordingSink) WriteProfile(_ context.Context, p *Profile) error {
+	s.profiles = append(s.profiles, p)
+	return nil
+}
+
+func TestInitializeConfigOffline(t *testing.T) {
+	oldConfig, oldOnGCE := config, onGCE
+	defer func() {
+		config, onGCE = oldConfig, oldOnGCE
+	}()
+	onGCE = func() bool {
+		t.Fatal("onGCE called in offline mode")
+		return false
+	}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+	if err := initializeConfig(Config{Service: testService, Sink: &recordingSink{}}); err != nil {
+		t.Fatalf("initializeConfig() got error: %v", err)
+	}
+	if config.OfflinePeriod != defaultOfflinePeriod || config.OfflineDuration != defaultOfflineDuration {
+		t.Errorf("got period %v and duration %v, want defaults %v and %v", config.OfflinePeriod, config.OfflineDuration, defaultOfflinePeriod, defaultOfflineDuration)
+	}
+
+	err := initializeConfig(Config{Service: testService, Sink: &recordingSink{}, OfflinePeriod: time.Second, OfflineDuration: time.Minute})
+	if err == nil {
+		t.Error("initializeConfig() with duration longer than period got no error, want error")
+	}
+}
+
+func TestCollectOffline(t *testing.T) {
+	oldConfig, oldStartCPUProfile, oldStopCPUProfile, oldSleep, oldNowFunc, oldProfilingDone := config, startCPUProfile, stopCPUProfile, sleep, nowFunc, profilingDone
+	defer func() {
+		config, startCPUProfile, stopCPUProfile, sleep, nowFunc, profilingDone = oldConfig, oldStartCPUProfile, oldStopCPUProfile, oldSleep, oldNowFunc, oldProfilingDone
+	}()
+
+	now := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	nowFunc = func() time.Time { return now }
+	var sleeps []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+	startCPUProfile = func(w io.Writer) error {
+		_, err := w.Write([]byte("cpu"))
+		return err
+	}
+	stopCPUProfile = func() {}
+	profilingDone = make(chan bool, 1)
+
+	sink := &recordingSink{}
+	if err := initializeConfig(Config{
+		Service:         testService,
+		ServiceVersion:  testSvcVersion,
+		Zone:            testZone,
+		Instance:        testInstance,
+		Sink:            sink,
+		OfflinePeriod:   time.Minute,
+		OfflineDuration: 10 * time.Second,
+		numProfiles:     5,
+	}); err != nil {
+		t.Fatalf("initializeConfig() got error: %v", err)
+	}
+	a, err := initializeAgent(nil)
+	if err != nil {
+		t.Fatalf("initializeAgent() got error: %v", err)
+	}
+	collectOffline(context.Background(), a)
+	<-profilingDone
+
+	type summary struct {
+		Type     string
+		Start    time.Time
+		Duration time.Duration
+	}
+	var got []summary
+	for _, p := range sink.profiles {
+		got = append(got, summary{p.Type, p.Start, p.Duration})
+	}
+	start := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	want := []summary{
+		{"CPU", start, 10 * time.Second},
+		{"HEAP", start.Add(time.Minute), 0},
+		{"THREADS", start.Add(2 * time.Minute), 0},
+		{"HEAP_ALLOC", start.Add(3 * time.Minute), 10 * time.Second},
+		{"CPU", start.Add(4 * time.Minute), 10 * time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles: got %v, want %v", got, want)
+	}
+	wantSleeps := []time.Duration{
+		10 * time.Second, 50 * time.Second,
+		time.Minute,
+		time.Minute,
+		10 * time.Second, 50 * time.Second,
+		10 * time.Second, 50 * time.Second,
+	}
+	if !reflect.DeepEqual(sleeps, wantSleeps) {
+		t.Errorf("sleeps: got %v, want %v", sleeps, wantSleeps)
+	}
+
+	p := sink.profiles[0]
+	if string(p.Data) != "cpu" {
+		t.Errorf("CPU profile data: got %q, want %q", p.Data, "cpu")
+	}
+	if p.Service != testService || p.ServiceVersion != testSvcVersion {
+		t.Errorf("got service %q and version %q, want %q and %q", p.Service, p.ServiceVersion, testService, testSvcVersion)
+	}
+	wantLabels := map[string]string{
+		languageLabel: "go",
+		zoneNameLabel: testZone,
+		versionLabel:  testSvcVersion,
+		instanceLabel: testInstance,
+	}
+	if !reflect.DeepEqual(p.Labels, wantLabels) {
+		t.Errorf("labels: got %v, want %v", p.Labels, wantLabels)
+	}
+	if _, err := profile.Parse(bytes.NewReader(sink.profiles[1].Data)); err != nil {
+		t.Errorf("heap profile: got error parsing profile: %v", err)
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	s, err := NewDirSink(dir, 2)
+	if err != nil {
+		t.Fatalf("NewDirSink() got error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	start := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i, pt := range []string{"CPU", "HEAP", "THREADS"} {
+		p := &Profile{
+			Type:    pt,
+			Service: testService,
+			Start:   start.Add(time.Duration(i) * time.Minute),
+			Data:    []byte(pt),
+		}
+		if err := s.WriteProfile(ctx, p); err != nil {
+			t.Fatalf("WriteProfile(%s) got error: %v", pt, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+	want := []string{
+		"20230102T150505.000000000Z-test-service-heap.pb.gz",
+		"20230102T150605.000000000Z-test-service-threads.pb.gz",
+		"notes.txt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files: got %v, want %v", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, want[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "THREADS" {
+		t.Errorf("got data %q, want %q", data, "THREADS")
+	}
+}
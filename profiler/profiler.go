@@ -182,6 +182,23 @@ type Config struct {
 	// the metadata server is present but is flaky or otherwise misbehave.
 	Zone string
 
+	// Sink enables offline mode when set. In offline mode, the agent does not
+	// connect to the Cloud Profiler service or to the Compute Engine metadata
+	// server, and ProjectID is not required. Instead, it collects profiles of
+	// the enabled types in turn on a local schedule, one every OfflinePeriod,
+	// and writes them to Sink. See DirSink for a Sink that writes profiles to
+	// local files.
+	Sink Sink
+
+	// OfflinePeriod is the interval between the starts of the profiles
+	// collected in offline mode. It defaults to one minute.
+	OfflinePeriod time.Duration
+
+	// OfflineDuration is the duration of the CPU, allocation and contention
+	// profiles collected in offline mode. It defaults to 10 seconds, and
+	// must not exceed OfflinePeriod.
+	OfflineDuration time.Duration
+
 	// numProfiles is the number of profiles which should be collected before
 	// the profile collection loop exits.When numProfiles is 0, profiles will
 	// be collected for the duration of the program. For testing only.
@@ -238,6 +255,16 @@ func start(cfg Config, options ...option.ClientOption) error {
 
 	ctx := context.Background()
 
+	if config.Sink != nil {
+		a, err := initializeAgent(nil)
+		if err != nil {
+			debugLog("failed to start the profiling agent: %v", err)
+			return err
+		}
+		go collectOffline(ctx, a)
+		return nil
+	}
+
 	opts := []option.ClientOption{
 		option.WithEndpoint(config.APIAddr),
 		option.WithScopes(scope),
@@ -360,79 +387,80 @@ func (a *agent) createProfile(ctx context.Context) *pb.Profile {
 }
 
 func (a *agent) profileAndUpload(ctx context.Context, p *pb.Profile) {
-	var prof bytes.Buffer
 	pt := p.GetProfileType()
+	if !a.enabled(pt) {
+		debugLog("skipping collection of disabled profile type: %v", pt)
+		return
+	}
 
-	ptEnabled := false
-	for _, enabled := range a.profileTypes {
-		if enabled == pt {
-			ptEnabled = true
-			break
+	var duration time.Duration
+	switch pt {
+	case pb.ProfileType_CPU, pb.ProfileType_HEAP_ALLOC, pb.ProfileType_CONTENTION:
+		var err error
+		if duration, err = ptypes.Duration(p.Duration); err != nil {
+			debugLog("failed to get profile duration for %v profile: %v", pt, err)
+			return
 		}
 	}
-
-	if !ptEnabled {
-		debugLog("skipping collection of disabled profile type: %v", pt)
+	prof, err := collectProfile(ctx, pt, duration)
+	if err != nil {
+		debugLog("%v", err)
 		return
 	}
 
+	p.ProfileBytes = prof
+	p.Labels = a.profileLabels
+	req := pb.UpdateProfileRequest{Profile: p}
+
+	// Upload profile, discard profile in case of error.
+	debugLog("start uploading profile")
+	if _, err := a.client.UpdateProfile(ctx, &req); err != nil {
+		debugLog("failed to upload profile: %v", err)
+	}
+}
+
+// enabled reports whether collection of the profile type is enabled.
+func (a *agent) enabled(pt pb.ProfileType) bool {
+	for _, enabled := range a.profileTypes {
+		if enabled == pt {
+			return true
+		}
+	}
+	return false
+}
+
+// collectProfile collects a profile of the given type. The duration is used
+// by the CPU, allocation and contention profiles, which record the activity
+// of the program over a time period.
+func collectProfile(ctx context.Context, pt pb.ProfileType, duration time.Duration) ([]byte, error) {
+	var prof bytes.Buffer
 	switch pt {
 	case pb.ProfileType_CPU:
-		duration, err := ptypes.Duration(p.Duration)
-		if err != nil {
-			debugLog("failed to get profile duration for CPU profile: %v", err)
-			return
-		}
 		if err := startCPUProfile(&prof); err != nil {
-			debugLog("failed to start CPU profile: %v", err)
-			return
+			return nil, fmt.Errorf("failed to start CPU profile: %v", err)
 		}
 		sleep(ctx, duration)
 		stopCPUProfile()
 	case pb.ProfileType_HEAP:
 		if err := heapProfile(&prof); err != nil {
-			debugLog("failed to write heap profile: %v", err)
-			return
+			return nil, fmt.Errorf("failed to write heap profile: %v", err)
 		}
 	case pb.ProfileType_HEAP_ALLOC:
-		duration, err := ptypes.Duration(p.Duration)
-		if err != nil {
-			debugLog("failed to get profile duration for allocation profile: %v", err)
-			return
-		}
 		if err := deltaAllocProfile(ctx, duration, config.AllocForceGC, &prof); err != nil {
-			debugLog("failed to collect allocation profile: %v", err)
-			return
+			return nil, fmt.Errorf("failed to collect allocation profile: %v", err)
 		}
 	case pb.ProfileType_THREADS:
 		if err := pprof.Lookup("goroutine").WriteTo(&prof, 0); err != nil {
-			debugLog("failed to collect goroutine profile: %v", err)
-			return
+			return nil, fmt.Errorf("failed to collect goroutine profile: %v", err)
 		}
 	case pb.ProfileType_CONTENTION:
-		duration, err := ptypes.Duration(p.Duration)
-		if err != nil {
-			debugLog("failed to get profile duration: %v", err)
-			return
-		}
 		if err := deltaMutexProfile(ctx, duration, &prof); err != nil {
-			debugLog("failed to collect mutex profile: %v", err)
-			return
+			return nil, fmt.Errorf("failed to collect mutex profile: %v", err)
 		}
 	default:
-		debugLog("unexpected profile type: %v", pt)
-		return
-	}
-
-	p.ProfileBytes = prof.Bytes()
-	p.Labels = a.profileLabels
-	req := pb.UpdateProfileRequest{Profile: p}
-
-	// Upload profile, discard profile in case of error.
-	debugLog("start uploading profile")
-	if _, err := a.client.UpdateProfile(ctx, &req); err != nil {
-		debugLog("failed to upload profile: %v", err)
+		return nil, fmt.Errorf("unexpected profile type: %v", pt)
 	}
+	return prof.Bytes(), nil
 }
 
 // deltaMutexProfile writes mutex profile changes over a time period specified
@@ -573,7 +601,10 @@ func initializeConfig(cfg Config) error {
 		// configuration.
 		config.ProjectID = projectID
 	}
-	if onGCE() {
+	switch {
+	case config.Sink != nil:
+		// Offline mode needs neither the project ID nor the metadata server.
+	case onGCE():
 		var err error
 		if config.ProjectID == "" {
 			if config.ProjectID, err = getProjectID(); err != nil {
@@ -595,15 +626,24 @@ func initializeConfig(cfg Config) error {
 				debugLog("failed to get instance name from Compute Engine metadata, will use empty name: %v", err)
 			}
 		}
-	} else {
-		if config.ProjectID == "" {
-			return fmt.Errorf("project ID must be specified in the configuration if running outside of GCP")
-		}
+	case config.ProjectID == "":
+		return fmt.Errorf("project ID must be specified in the configuration if running outside of GCP")
 	}
 
 	if config.APIAddr == "" {
 		config.APIAddr = apiAddress
 	}
+	if config.Sink != nil {
+		if config.OfflinePeriod <= 0 {
+			config.OfflinePeriod = defaultOfflinePeriod
+		}
+		if config.OfflineDuration <= 0 {
+			config.OfflineDuration = defaultOfflineDuration
+		}
+		if config.OfflineDuration > config.OfflinePeriod {
+			return fmt.Errorf("offline profile duration %v exceeds the offline period %v", config.OfflineDuration, config.OfflinePeriod)
+		}
+	}
 	return nil
 }
 
@@ -23,3 +23,15 @@ func ExampleStart() {
 		//TODO: Handle error.
 	}
 }
+
+func ExampleStart_offline() {
+	// Write profiles to local files instead of uploading them, keeping the
+	// latest 100 profiles.
+	sink, err := profiler.NewDirSink("/var/tmp/profiles", 100)
+	if err != nil {
+		//TODO: Handle error.
+	}
+	if err := profiler.Start(profiler.Config{Service: "my-service", ServiceVersion: "v1", Sink: sink}); err != nil {
+		//TODO: Handle error.
+	}
+}
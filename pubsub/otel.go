@@ -0,0 +1,43 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+// startPublishSpan starts a span for a publish request, with the topic and
+// the number of messages as attributes, and returns a function that ends it.
+// The span is only created if OpenTelemetry tracing is enabled, so that the
+// spans seen with OpenCensus are unchanged.
+func startPublishSpan(ctx context.Context, topic string, numMessages int) (context.Context, func(error)) {
+	if !trace.IsOpenTelemetryTracingEnabled() {
+		return ctx, func(error) {}
+	}
+	ctx = trace.StartSpan(ctx, "cloud.google.com/go/pubsub.Topic.publishMessageBundle")
+	if span := ottrace.SpanFromContext(ctx); span.IsRecording() {
+		span.SetAttributes(
+			semconv.MessagingSystemKey.String("gcp_pubsub"),
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingBatchMessageCount(numMessages),
+		)
+	}
+	return ctx, func(err error) { trace.EndSpan(ctx, err) }
+}
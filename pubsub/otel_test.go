@@ -0,0 +1,101 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/internal/trace"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+const publishSpanName = "cloud.google.com/go/pubsub.Topic.publishMessageBundle"
+
+func publishOne(t *testing.T) {
+	ctx := context.Background()
+	c, srv := newFake(t)
+	defer c.Close()
+	defer srv.Close()
+	topic := mustCreateTopic(t, c, "t")
+	defer topic.Stop()
+	if _, err := topic.Publish(ctx, &Message{Data: []byte("hello")}).Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishSpanOpenTelemetry(t *testing.T) {
+	defer func(enabled bool) { trace.OpenTelemetryTracingEnabled = enabled }(trace.OpenTelemetryTracingEnabled)
+	trace.OpenTelemetryTracingEnabled = true
+	sr := tracetest.NewSpanRecorder()
+	defer func(tp ottrace.TracerProvider) { otel.SetTracerProvider(tp) }(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	publishOne(t)
+
+	var got map[string]string
+	for _, s := range sr.Ended() {
+		if s.Name() != publishSpanName {
+			continue
+		}
+		got = make(map[string]string)
+		for _, kv := range s.Attributes() {
+			got[string(kv.Key)] = kv.Value.Emit()
+		}
+	}
+	if got == nil {
+		t.Fatalf("no %s span", publishSpanName)
+	}
+	if got["messaging.destination.name"] != "projects/P/topics/t" || got["messaging.batch.message_count"] != "1" {
+		t.Errorf("span attributes: got %v", got)
+	}
+}
+
+type spanNames struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (e *spanNames) ExportSpan(s *octrace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.names = append(e.names, s.Name)
+}
+
+func TestPublishSpanOpenCensus(t *testing.T) {
+	defer func(enabled bool) { trace.OpenTelemetryTracingEnabled = enabled }(trace.OpenTelemetryTracingEnabled)
+	trace.OpenTelemetryTracingEnabled = false
+	e := &spanNames{}
+	octrace.RegisterExporter(e)
+	defer octrace.UnregisterExporter(e)
+	octrace.ApplyConfig(octrace.Config{DefaultSampler: octrace.AlwaysSample()})
+	defer octrace.ApplyConfig(octrace.Config{DefaultSampler: octrace.ProbabilitySampler(1e-4)})
+
+	publishOne(t)
+
+	// Publishing creates no new OpenCensus span.
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range e.names {
+		if name == publishSpanName {
+			t.Errorf("got a %s span with OpenCensus, want none", name)
+		}
+	}
+}
@@ -675,6 +675,9 @@ func (t *Topic) publishMessageBundle(ctx context.Context, bms []*bundledMessage)
 	if err != nil {
 		log.Printf("pubsub: cannot create context with tag in publishMessageBundle: %v", err)
 	}
+	ctx, endSpan := startPublishSpan(ctx, t.name, len(bms))
+	defer func() { endSpan(err) }()
+
 	pbMsgs := make([]*pb.PubsubMessage, len(bms))
 	var orderingKey string
 	for i, bm := range bms {
@@ -56,6 +56,17 @@ one from the replayer instead of calling grpc.Dial:
 	defer rep.Close()
 	conn, err := rep.Connection()
 
+# File Formats
+
+By default, a Recorder writes a compact binary format. To write a text format
+that can be reviewed in diffs and edited by hand, pass an option to
+NewRecorder:
+
+	rec, err := rpcreplay.NewRecorder("service.replay", nil, rpcreplay.WithFormat(rpcreplay.TextFormat))
+
+Replayers read files in either format. Convert rewrites a file in the other
+format.
+
 # Initial State
 
 A test might use random or time-sensitive values, for instance to create unique
@@ -135,6 +146,22 @@ name, since it has no other information at the time the stream is opened. Two st
 with the same method name that are started concurrently may replay in the wrong
 order.
 
+Set Replayer.Unordered to match a stream by the first message sent on it, even if
+the program receives from the stream first, and to match each message sent on a
+stream to any recorded message sent on it, rather than to the next one.
+
+# Matching Requests
+
+Requests that contain values that differ from run to run, such as timestamps, UUIDs
+and request IDs, do not match their recorded counterparts. A Replayer can ignore
+such fields, or compare only some fields, using field masks:
+
+	rep.IgnoreFields("/google.pubsub.v1.Subscriber/StreamingPull",
+	    &fieldmaskpb.FieldMask{Paths: []string{"client_id"}})
+
+For other differences, a Replayer's Normalize function can modify copies of
+both the incoming and the recorded requests before they are compared.
+
 # Other Replayer Differences
 
 Besides the differences in replay mentioned above, other differences may cause issues
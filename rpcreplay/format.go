@@ -0,0 +1,233 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcreplay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	pb "cloud.google.com/go/rpcreplay/proto/rpcreplay"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// A Format is a format of replay files.
+type Format int
+
+const (
+	// BinaryFormat is a compact format of length-prefixed protocol buffers.
+	// It is the default.
+	BinaryFormat Format = iota
+
+	// TextFormat is a format of indented JSON objects, one for each entry,
+	// that can be reviewed in diffs and edited by hand. Messages are
+	// written in the JSON mapping of protocol buffers, with an "@type" field
+	// that holds their type. An entry refers to its request or stream by
+	// its position in the file, counting from 1, in the "refIndex" field.
+	TextFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case BinaryFormat:
+		return "BinaryFormat"
+	case TextFormat:
+		return "TextFormat"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// A RecorderOption is an option for NewRecorder and NewRecorderWriter.
+type RecorderOption interface {
+	apply(*Recorder)
+}
+
+type formatOption Format
+
+func (o formatOption) apply(r *Recorder) { r.format = Format(o) }
+
+// WithFormat returns a RecorderOption that sets the format of the replay
+// file. Replayers detect the format of the files they read.
+func WithFormat(f Format) RecorderOption {
+	return formatOption(f)
+}
+
+func (f Format) writeHeader(w io.Writer, initial []byte) error {
+	switch f {
+	case BinaryFormat:
+		return writeHeader(w, initial)
+	case TextFormat:
+		return writeTextHeader(w, initial)
+	}
+	return fmt.Errorf("rpcreplay: unknown format %v", f)
+}
+
+func (f Format) writeEntry(w io.Writer, e *entry) error {
+	if f == TextFormat {
+		return writeTextEntry(w, e)
+	}
+	return writeEntry(w, e)
+}
+
+// Convert reads a replay file in either format from r, and writes it to w in
+// the given format.
+func Convert(w io.Writer, r io.Reader, to Format) error {
+	initial, er, err := newEntryReader(r)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := to.writeHeader(bw, initial); err != nil {
+		return err
+	}
+	for {
+		e, err := er.readEntry()
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			break
+		}
+		if err := to.writeEntry(bw, e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// An entryReader reads the entries of a replay file.
+type entryReader interface {
+	// readEntry returns the next entry, or nil at the end of the file.
+	readEntry() (*entry, error)
+}
+
+type binaryReader struct {
+	r io.Reader
+}
+
+func (br binaryReader) readEntry() (*entry, error) { return readEntry(br.r) }
+
+// newEntryReader reads the header of a replay file in either format, and
+// returns the initial state and a reader for the entries.
+func newEntryReader(r io.Reader) ([]byte, entryReader, error) {
+	br := bufio.NewReader(r)
+	if isText(br) {
+		dec := json.NewDecoder(br)
+		initial, err := readTextHeader(dec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return initial, textReader{dec}, nil
+	}
+	initial, err := readHeader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	return initial, binaryReader{br}, nil
+}
+
+// isText reports whether a replay file is in TextFormat: its first
+// non-whitespace byte begins a JSON object.
+func isText(br *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := br.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{':
+			return true
+		}
+		return false
+	}
+}
+
+// Text format:
+//   a header object, with the magic string and the initial state
+//   a sequence of Entry protos in the JSON mapping
+
+// textHeader is the header of a replay file in TextFormat.
+type textHeader struct {
+	Magic   string `json:"magic"`
+	Initial []byte `json:"initial"`
+}
+
+func writeTextHeader(w io.Writer, initial []byte) error {
+	b, err := json.MarshalIndent(textHeader{Magic: magic, Initial: initial}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func readTextHeader(dec *json.Decoder) ([]byte, error) {
+	var h textHeader
+	if err := dec.Decode(&h); err != nil {
+		if err == io.EOF {
+			err = errors.New("rpcreplay: empty replay file")
+		}
+		return nil, err
+	}
+	if h.Magic != magic {
+		return nil, errors.New("rpcreplay: not a replay file (header does not have magic string)")
+	}
+	return h.Initial, nil
+}
+
+func writeTextEntry(w io.Writer, e *entry) error {
+	pe, err := e.toProto()
+	if err != nil {
+		return err
+	}
+	b, err := protojson.Marshal(proto.MessageV2(pe))
+	if err != nil {
+		return err
+	}
+	// The output of protojson is deliberately unstable, so it is reformatted
+	// to keep replay files diffable.
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+type textReader struct {
+	dec *json.Decoder
+}
+
+func (tr textReader) readEntry() (*entry, error) {
+	var raw json.RawMessage
+	if err := tr.dec.Decode(&raw); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pe pb.Entry
+	if err := protojson.Unmarshal(raw, proto.MessageV2(&pe)); err != nil {
+		return nil, fmt.Errorf("rpcreplay: %v", err)
+	}
+	return entryFromProto(&pe)
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcreplay
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// A maskRule restricts the fields of the requests of a method that are
+// compared on replay.
+type maskRule struct {
+	method string // empty for all methods
+	fields fieldTree
+	ignore bool // whether fields are ignored, rather than the only ones compared
+}
+
+// A fieldTree holds the paths of a field mask. A nil subtree stands for the
+// whole field.
+type fieldTree map[string]fieldTree
+
+func newFieldTree(paths []string) fieldTree {
+	t := fieldTree{}
+	for _, p := range paths {
+		cur := t
+		names := strings.Split(p, ".")
+		for i, name := range names {
+			sub, ok := cur[name]
+			if ok && sub == nil {
+				break // the whole field is already in the mask
+			}
+			if i == len(names)-1 {
+				cur[name] = nil
+				break
+			}
+			if !ok {
+				sub = fieldTree{}
+				cur[name] = sub
+			}
+			cur = sub
+		}
+	}
+	return t
+}
+
+// IgnoreFields causes the fields in mask to be ignored when requests of the
+// method are matched to recorded requests, and when the first messages sent
+// on its streams are matched. If method is empty, the fields are ignored for
+// all methods. The paths of the mask use the field names of the proto
+// definitions, as in "request_id" or "messages.publish_time". A path through a
+// repeated or map field applies to each of its elements. Paths that do not
+// name a field of a request are skipped.
+//
+// IgnoreFields must be called before replaying.
+func (rep *Replayer) IgnoreFields(method string, mask *fieldmaskpb.FieldMask) {
+	rep.masks = append(rep.masks, maskRule{method: method, fields: newFieldTree(mask.GetPaths()), ignore: true})
+}
+
+// CompareFields causes only the fields in mask to be compared when requests of
+// the method are matched to recorded requests, and when the first messages
+// sent on its streams are matched. If method is empty, the mask applies to
+// all methods. The paths are as for IgnoreFields.
+//
+// CompareFields must be called before replaying.
+func (rep *Replayer) CompareFields(method string, mask *fieldmaskpb.FieldMask) {
+	rep.masks = append(rep.masks, maskRule{method: method, fields: newFieldTree(mask.GetPaths())})
+}
+
+// normalize returns a copy of a request with the Normalize function and the
+// field masks of the method applied. It returns m if there is nothing to
+// apply.
+func (rep *Replayer) normalize(method string, m proto.Message) proto.Message {
+	if m == nil || (rep.Normalize == nil && len(rep.masks) == 0) {
+		return m
+	}
+	m = proto.Clone(m)
+	if rep.Normalize != nil {
+		rep.Normalize(method, m)
+	}
+	for _, r := range rep.masks {
+		if r.method != "" && r.method != method {
+			continue
+		}
+		if r.ignore {
+			clearFields(proto.MessageReflect(m), r.fields)
+		} else {
+			keepFields(proto.MessageReflect(m), r.fields)
+		}
+	}
+	return m
+}
+
+// requestsMatch reports whether a normalized incoming request matches a
+// recorded request.
+func (rep *Replayer) requestsMatch(method string, normalized, recorded proto.Message) bool {
+	return proto.Equal(normalized, rep.normalize(method, recorded))
+}
+
+// clearFields clears the fields of a message that are in a tree.
+func clearFields(m protoreflect.Message, t fieldTree) {
+	fields := m.Descriptor().Fields()
+	for name, sub := range t {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil || !m.Has(fd) {
+			continue
+		}
+		if sub == nil {
+			m.Clear(fd)
+			continue
+		}
+		forEachMessage(m, fd, func(m protoreflect.Message) { clearFields(m, sub) })
+	}
+}
+
+// keepFields clears the fields of a message that are not in a tree.
+func keepFields(m protoreflect.Message, t fieldTree) {
+	var fds []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fds = append(fds, fd)
+		return true
+	})
+	for _, fd := range fds {
+		sub, ok := t[string(fd.Name())]
+		switch {
+		case !ok:
+			m.Clear(fd)
+		case sub != nil:
+			forEachMessage(m, fd, func(m protoreflect.Message) { keepFields(m, sub) })
+		}
+	}
+}
+
+// forEachMessage calls f with the message of a singular message field, or
+// with each message of a repeated or map field. It does nothing for other
+// fields.
+func forEachMessage(m protoreflect.Message, fd protoreflect.FieldDescriptor, f func(protoreflect.Message)) {
+	switch {
+	case fd.IsList():
+		if fd.Message() == nil {
+			return
+		}
+		l := m.Mutable(fd).List()
+		for i := 0; i < l.Len(); i++ {
+			f(l.Get(i).Message())
+		}
+	case fd.IsMap():
+		if fd.MapValue().Message() == nil {
+			return
+		}
+		m.Mutable(fd).Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+			f(v.Message())
+			return true
+		})
+	case fd.Message() != nil:
+		f(m.Mutable(fd).Message())
+	}
+}
@@ -38,11 +38,12 @@ import (
 
 // A Recorder records RPCs for later playback.
 type Recorder struct {
-	mu   sync.Mutex
-	w    *bufio.Writer
-	f    *os.File
-	next int
-	err  error
+	mu     sync.Mutex
+	w      *bufio.Writer
+	f      *os.File
+	format Format
+	next   int
+	err    error
 	// BeforeFunc defines a function that can inspect and modify requests and responses
 	// written to the replay file. It does not modify messages sent to the service.
 	// It is run once before a request is written to the replay file, and once before a response
@@ -57,12 +58,12 @@ type Recorder struct {
 // also store the initial bytes for retrieval during replay.
 //
 // You must call Close on the Recorder to ensure that all data is written.
-func NewRecorder(filename string, initial []byte) (*Recorder, error) {
+func NewRecorder(filename string, initial []byte, opts ...RecorderOption) (*Recorder, error) {
 	f, err := os.Create(filename)
 	if err != nil {
 		return nil, err
 	}
-	rec, err := NewRecorderWriter(f, initial)
+	rec, err := NewRecorderWriter(f, initial, opts...)
 	if err != nil {
 		_ = f.Close()
 		return nil, err
@@ -75,12 +76,15 @@ func NewRecorder(filename string, initial []byte) (*Recorder, error) {
 // bytes will also be written to w for retrieval during replay.
 //
 // You must call Close on the Recorder to ensure that all data is written.
-func NewRecorderWriter(w io.Writer, initial []byte) (*Recorder, error) {
-	bw := bufio.NewWriter(w)
-	if err := writeHeader(bw, initial); err != nil {
+func NewRecorderWriter(w io.Writer, initial []byte, opts ...RecorderOption) (*Recorder, error) {
+	rec := &Recorder{w: bufio.NewWriter(w), next: 1}
+	for _, opt := range opts {
+		opt.apply(rec)
+	}
+	if err := rec.format.writeHeader(rec.w, initial); err != nil {
 		return nil, err
 	}
-	return &Recorder{w: bw, next: 1}, nil
+	return rec, nil
 }
 
 // DialOptions returns the options that must be passed to grpc.Dial
@@ -158,7 +162,7 @@ func (r *Recorder) writeEntry(e *entry) (int, error) {
 	if r.err != nil {
 		return 0, r.err
 	}
-	err := writeEntry(r.w, e)
+	err := r.format.writeEntry(r.w, e)
 	if err != nil {
 		r.err = err
 		return 0, err
@@ -254,6 +258,24 @@ type Replayer struct {
 	// If the function returns an error, the error will be returned to the client.
 	// This is only executed for unary RPCs; streaming RPCs are not supported.
 	BeforeFunc func(string, proto.Message) error
+
+	// Normalize defines a function that modifies requests before they are
+	// compared. Unlike BeforeFunc, it is called on copies of both the incoming
+	// and the recorded requests, and on the messages sent on streams, so it
+	// can clear or canonicalize values that differ from run to run, such as
+	// timestamps, UUIDs and request IDs. It is called with the method name and
+	// the message, and must not call methods of the Replayer.
+	Normalize func(string, proto.Message)
+
+	// Unordered makes replay independent of the interleaving of RPCs during
+	// recording. When it is true, a stream is matched to a recorded stream
+	// when its first message is sent, and receives on the stream wait until
+	// then. Each message sent on a stream is matched to any remaining recorded
+	// message sent on the stream, rather than to the next one. Set Unordered
+	// before replaying.
+	Unordered bool
+
+	masks []maskRule // see IgnoreFields and CompareFields
 }
 
 // A call represents a unary RPC, with a request and response (or error).
@@ -273,7 +295,8 @@ type stream struct {
 	recvs       []message
 }
 
-// NewReplayer creates a Replayer that reads from filename.
+// NewReplayer creates a Replayer that reads from filename, which may be in
+// either Format.
 func NewReplayer(filename string) (*Replayer, error) {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -283,7 +306,8 @@ func NewReplayer(filename string) (*Replayer, error) {
 	return NewReplayerReader(f)
 }
 
-// NewReplayerReader creates a Replayer that reads from r.
+// NewReplayerReader creates a Replayer that reads from r, which may be in
+// either Format.
 func NewReplayerReader(r io.Reader) (*Replayer, error) {
 	rep := &Replayer{
 		log: func(string, ...interface{}) {},
@@ -298,8 +322,7 @@ func NewReplayerReader(r io.Reader) (*Replayer, error) {
 // It matches requests with responses, with each pair grouped
 // into a call struct.
 func (rep *Replayer) read(r io.Reader) error {
-	r = bufio.NewReader(r)
-	bytes, err := readHeader(r)
+	bytes, er, err := newEntryReader(r)
 	if err != nil {
 		return err
 	}
@@ -308,7 +331,7 @@ func (rep *Replayer) read(r io.Reader) error {
 	callsByIndex := map[int]*call{}
 	streamsByIndex := map[int]*stream{}
 	for i := 1; ; i++ {
-		e, err := readEntry(r)
+		e, err := er.readEntry()
 		if err != nil {
 			return err
 		}
@@ -435,7 +458,11 @@ func (rep *Replayer) interceptUnary(_ context.Context, method string, req, res i
 
 func (rep *Replayer) interceptStream(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, method string, _ grpc.Streamer, _ ...grpc.CallOption) (grpc.ClientStream, error) {
 	rep.log("create-stream %s", method)
-	return &repClientStream{ctx: ctx, rep: rep, method: method}, nil
+	rcs := &repClientStream{ctx: ctx, rep: rep, method: method}
+	if rep.Unordered {
+		rcs.bound = make(chan struct{})
+	}
+	return rcs, nil
 }
 
 type repClientStream struct {
@@ -443,12 +470,25 @@ type repClientStream struct {
 	rep    *Replayer
 	method string
 	str    *stream
+
+	// In unordered mode, the stream is matched once, by the first send.
+	bound    chan struct{} // closed when the stream is matched
+	bindOnce sync.Once
+	bindErr  error
 }
 
 func (rcs *repClientStream) Context() context.Context { return rcs.ctx }
 
 func (rcs *repClientStream) SendMsg(req interface{}) error {
-	if rcs.str == nil {
+	if rcs.bound != nil {
+		rcs.bindOnce.Do(func() {
+			rcs.bindErr = rcs.setStream(rcs.method, req.(proto.Message))
+			close(rcs.bound)
+		})
+		if rcs.bindErr != nil {
+			return rcs.bindErr
+		}
+	} else if rcs.str == nil {
 		if err := rcs.setStream(rcs.method, req.(proto.Message)); err != nil {
 			return err
 		}
@@ -457,12 +497,31 @@ func (rcs *repClientStream) SendMsg(req interface{}) error {
 		return fmt.Errorf("replayer: no more sends for stream %s, created at index %d",
 			rcs.str.method, rcs.str.createIndex)
 	}
-	// TODO(jba): Do not assume that the sends happen in the same order on replay.
-	msg := rcs.str.sends[0]
-	rcs.str.sends = rcs.str.sends[1:]
+	i := 0
+	if rcs.bound != nil {
+		if i = rcs.rep.matchSend(rcs.method, rcs.str.sends, req.(proto.Message)); i < 0 {
+			return fmt.Errorf("replayer: send not found for stream %s, created at index %d: %v",
+				rcs.str.method, rcs.str.createIndex, req)
+		}
+	}
+	msg := rcs.str.sends[i]
+	rcs.str.sends = append(rcs.str.sends[:i:i], rcs.str.sends[i+1:]...)
 	return msg.err
 }
 
+// matchSend returns the index of the first recorded send that matches a
+// message sent on a stream, or -1. A recorded send without a message, which
+// failed, matches any message.
+func (rep *Replayer) matchSend(method string, sends []message, req proto.Message) int {
+	nreq := rep.normalize(method, req)
+	for i, m := range sends {
+		if m.msg == nil || rep.requestsMatch(method, nreq, m.msg) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (rcs *repClientStream) setStream(method string, req proto.Message) error {
 	str := rcs.rep.extractStream(method, req)
 	if str == nil {
@@ -476,7 +535,16 @@ func (rcs *repClientStream) setStream(method string, req proto.Message) error {
 }
 
 func (rcs *repClientStream) RecvMsg(m interface{}) error {
-	if rcs.str == nil {
+	if rcs.bound != nil {
+		select {
+		case <-rcs.bound:
+			if rcs.bindErr != nil {
+				return rcs.bindErr
+			}
+		case <-rcs.ctx.Done():
+			return status.FromContextError(rcs.ctx.Err()).Err()
+		}
+	} else if rcs.str == nil {
 		// Receive before send; fall back to matching stream by method only.
 		if err := rcs.setStream(rcs.method, nil); err != nil {
 			return err
@@ -510,15 +578,16 @@ func (rcs *repClientStream) CloseSend() error {
 }
 
 // extractCall finds the first call in the list with the same method
-// and request. It returns nil if it can't find such a call.
+// and request, after normalization. It returns nil if it can't find such a call.
 func (rep *Replayer) extractCall(method string, req proto.Message) *call {
 	rep.mu.Lock()
 	defer rep.mu.Unlock()
+	nreq := rep.normalize(method, req)
 	for i, call := range rep.calls {
 		if call == nil {
 			continue
 		}
-		if method == call.method && proto.Equal(req, call.request) {
+		if method == call.method && rep.requestsMatch(method, nreq, call.request) {
 			rep.calls[i] = nil // nil out this call so we don't reuse it
 			return call
 		}
@@ -527,11 +596,12 @@ func (rep *Replayer) extractCall(method string, req proto.Message) *call {
 }
 
 // extractStream find the first stream in the list with the same method and the same
-// first request sent. If req is nil, that means a receive occurred before a send, so
+// first request sent, after normalization. If req is nil, that means a receive occurred before a send, so
 // it matches only on method.
 func (rep *Replayer) extractStream(method string, req proto.Message) *stream {
 	rep.mu.Lock()
 	defer rep.mu.Unlock()
+	nreq := rep.normalize(method, req)
 	for i, stream := range rep.streams {
 		// Skip stream if it is nil (already extracted) or its method doesn't match.
 		if stream == nil || stream.method != method {
@@ -539,7 +609,7 @@ func (rep *Replayer) extractStream(method string, req proto.Message) *stream {
 		}
 		// If there is a first request, skip stream if it has no requests or its first
 		// request doesn't match.
-		if req != nil && len(stream.sends) > 0 && !proto.Equal(req, stream.sends[0].msg) {
+		if req != nil && len(stream.sends) > 0 && !rep.requestsMatch(method, nreq, stream.sends[0].msg) {
 			continue
 		}
 		rep.streams[i] = nil // nil out this stream so we don't reuse it
@@ -562,13 +632,13 @@ func Fprint(w io.Writer, filename string) error {
 // FprintReader reads the entries from r and writes them to w in human-readable form.
 // It is intended for debugging.
 func FprintReader(w io.Writer, r io.Reader) error {
-	initial, err := readHeader(r)
+	initial, er, err := newEntryReader(r)
 	if err != nil {
 		return err
 	}
 	fmt.Fprintf(w, "initial state: %q\n", string(initial))
 	for i := 1; ; i++ {
-		e, err := readEntry(r)
+		e, err := er.readEntry()
 		if err != nil {
 			return err
 		}
@@ -674,11 +744,24 @@ func readHeader(r io.Reader) ([]byte, error) {
 }
 
 func writeEntry(w io.Writer, e *entry) error {
+	pe, err := e.toProto()
+	if err != nil {
+		return err
+	}
+	bytes, err := proto.Marshal(pe)
+	if err != nil {
+		return err
+	}
+	return writeRecord(w, bytes)
+}
+
+// toProto converts an entry to the proto that is saved in replay files.
+func (e *entry) toProto() (*pb.Entry, error) {
 	var m proto.Message
 	if e.msg.err != nil && e.msg.err != io.EOF {
 		s, ok := status.FromError(e.msg.err)
 		if !ok {
-			return fmt.Errorf("rpcreplay: error %w is not a Status", e.msg.err)
+			return nil, fmt.Errorf("rpcreplay: error %w is not a Status", e.msg.err)
 		}
 		m = s.Proto()
 	} else {
@@ -689,21 +772,16 @@ func writeEntry(w io.Writer, e *entry) error {
 	if m != nil {
 		a, err = ptypes.MarshalAny(m)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	pe := &pb.Entry{
+	return &pb.Entry{
 		Kind:     e.kind,
 		Method:   e.method,
 		Message:  a,
 		IsError:  e.msg.err != nil,
 		RefIndex: int32(e.refIndex),
-	}
-	bytes, err := proto.Marshal(pe)
-	if err != nil {
-		return err
-	}
-	return writeRecord(w, bytes)
+	}, nil
 }
 
 func readEntry(r io.Reader) (*entry, error) {
@@ -718,6 +796,11 @@ func readEntry(r io.Reader) (*entry, error) {
 	if err := proto.Unmarshal(buf, &pe); err != nil {
 		return nil, err
 	}
+	return entryFromProto(&pe)
+}
+
+// entryFromProto converts a proto read from a replay file to an entry.
+func entryFromProto(pe *pb.Entry) (*entry, error) {
 	var msg message
 	if pe.Message != nil {
 		var any ptypes.DynamicAny
@@ -28,10 +28,13 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func TestRecordIO(t *testing.T) {
@@ -247,12 +250,64 @@ func TestReplay(t *testing.T) {
 	replay(t, buf, testService)
 }
 
-func record(t *testing.T, run func(*testing.T, *grpc.ClientConn)) *bytes.Buffer {
+func TestTextFormat(t *testing.T) {
+	text := record(t, testService, WithFormat(TextFormat)).Bytes()
+	for _, want := range []string{
+		`"magic": "RPCReplay"`,
+		`"method": "/intstore.IntStore/Set"`,
+		`"@type": "type.googleapis.com/intstore.Item"`,
+		`"name": "a"`,
+	} {
+		if !bytes.Contains(text, []byte(want)) {
+			t.Errorf("text replay file does not contain %s:\n%s", want, text)
+		}
+	}
+	replay(t, bytes.NewBuffer(text), testService)
+
+	// Converting to binary and back preserves the file.
+	var bin, text2 bytes.Buffer
+	if err := Convert(&bin, bytes.NewReader(text), BinaryFormat); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(bin.Bytes(), []byte(magic)) {
+		t.Errorf("converted file does not begin with magic string")
+	}
+	if err := Convert(&text2, bytes.NewReader(bin.Bytes()), TextFormat); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(text2.Bytes(), text) {
+		t.Errorf("round trip:\ngot\n%s\nwant\n%s", text2.Bytes(), text)
+	}
+	replay(t, &bin, testService)
+
+	var fprinted strings.Builder
+	if err := FprintReader(&fprinted, bytes.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fprinted.String(), "kind: CREATE_STREAM, method: /intstore.IntStore/ListItems") {
+		t.Errorf("FprintReader: missing stream creation in\n%s", fprinted.String())
+	}
+
+	// Errors in text files.
+	for _, contents := range []string{
+		"",
+		`{"magic": "gRPCReplay"}`,
+		`{"magic": "RPCReplay"} {"kind": "REQUEST", "method": "m"}`,
+		`{"magic": "RPCReplay"} {"kind": "RESPONSE", "refIndex": 1, "message": {"@type": "type.googleapis.com/intstore.Item"}}`,
+		`{"magic": "RPCReplay"} {"kind": "NOPE"}`,
+	} {
+		if _, err := NewReplayerReader(strings.NewReader(contents)); err == nil {
+			t.Errorf("%q: got nil, want error", contents)
+		}
+	}
+}
+
+func record(t *testing.T, run func(*testing.T, *grpc.ClientConn), opts ...RecorderOption) *bytes.Buffer {
 	srv := newIntStoreServer()
 	defer srv.stop()
 
 	buf := &bytes.Buffer{}
-	rec, err := NewRecorderWriter(buf, initialState)
+	rec, err := NewRecorderWriter(buf, initialState, opts...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -622,3 +677,195 @@ func TestOutOfOrderStreamReplay(t *testing.T) {
 	buf = record(t, func(t *testing.T, conn *grpc.ClientConn) { run(t, conn, 1, 2) })
 	replay(t, buf, func(t *testing.T, conn *grpc.ClientConn) { run(t, conn, 2, 1) })
 }
+
+func TestMaskFields(t *testing.T) {
+	newStatus := func() *spb.Status {
+		return &spb.Status{
+			Code:    5,
+			Message: "not found",
+			Details: []*anypb.Any{
+				{TypeUrl: "a", Value: []byte("x")},
+				{TypeUrl: "b", Value: []byte("y")},
+			},
+		}
+	}
+	withTypes := &spb.Status{
+		Code:    5,
+		Details: []*anypb.Any{{TypeUrl: "a"}, {TypeUrl: "b"}},
+	}
+	for _, test := range []struct {
+		rule maskRule
+		want *spb.Status
+	}{
+		{
+			maskRule{fields: newFieldTree([]string{"message", "details.value", "no_such_field"}), ignore: true},
+			withTypes,
+		},
+		{
+			// The whole field wins over its subfields.
+			maskRule{fields: newFieldTree([]string{"code", "details.type_url", "details"}), ignore: true},
+			&spb.Status{Message: "not found"},
+		},
+		{
+			maskRule{fields: newFieldTree([]string{"code", "details.type_url"})},
+			withTypes,
+		},
+	} {
+		rep := &Replayer{masks: []maskRule{test.rule}}
+		if got := rep.normalize("m", newStatus()); !proto.Equal(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.rule, got, test.want)
+		}
+	}
+	// A rule for another method does not apply.
+	rep := &Replayer{masks: []maskRule{{method: "other", fields: newFieldTree([]string{"code"}), ignore: true}}}
+	if got := rep.normalize("m", newStatus()); !proto.Equal(got, newStatus()) {
+		t.Errorf("got %v, want unchanged", got)
+	}
+}
+
+func TestReplayNormalize(t *testing.T) {
+	buf := record(t, func(t *testing.T, conn *grpc.ClientConn) {
+		client := ipb.NewIntStoreClient(conn)
+		ctx := context.Background()
+		if _, err := client.Set(ctx, &ipb.Item{Name: "a", Value: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Get(ctx, &ipb.GetRequest{Name: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, test := range []struct {
+		desc    string
+		setup   func(*Replayer)
+		set     *ipb.Item
+		get     string
+		wantErr bool
+	}{
+		{"exact", func(*Replayer) {}, &ipb.Item{Name: "a", Value: 1}, "a", false},
+		{"different value", func(*Replayer) {}, &ipb.Item{Name: "a", Value: 2}, "a", true},
+		{
+			"ignored value",
+			func(rep *Replayer) {
+				rep.IgnoreFields("/intstore.IntStore/Set", &fieldmaskpb.FieldMask{Paths: []string{"value"}})
+			},
+			&ipb.Item{Name: "a", Value: 2}, "a", false,
+		},
+		{
+			"compared name",
+			func(rep *Replayer) {
+				rep.CompareFields("", &fieldmaskpb.FieldMask{Paths: []string{"name"}})
+			},
+			&ipb.Item{Name: "a", Value: 2}, "a", false,
+		},
+		{
+			"normalized",
+			func(rep *Replayer) {
+				rep.Normalize = func(_ string, m proto.Message) {
+					switch m := m.(type) {
+					case *ipb.Item:
+						m.Value = 0
+					case *ipb.GetRequest:
+						m.Name = strings.ToLower(m.Name)
+					}
+				}
+			},
+			&ipb.Item{Name: "a", Value: 2}, "A", false,
+		},
+	} {
+		rep, err := NewReplayerReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.setup(rep)
+		conn, err := rep.Connection()
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := ipb.NewIntStoreClient(conn)
+		ctx := context.Background()
+		_, err1 := client.Set(ctx, test.set)
+		_, err2 := client.Get(ctx, &ipb.GetRequest{Name: test.get})
+		if gotErr := err1 != nil || err2 != nil; gotErr != test.wantErr {
+			t.Errorf("%s: got errors %v, %v; want error: %t", test.desc, err1, err2, test.wantErr)
+		}
+		conn.Close()
+	}
+}
+
+func TestUnorderedStreamReplay(t *testing.T) {
+	buf := record(t, func(t *testing.T, conn *grpc.ClientConn) {
+		chatc, err := ipb.NewIntStoreClient(conn).StreamChat(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"d", "e"} {
+			if err := chatc.Send(&ipb.Item{Name: name}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := chatc.Recv(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := chatc.CloseSend(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := chatc.Recv(); err != io.EOF {
+			t.Fatalf("got %v, want EOF", err)
+		}
+	})
+	rep, err := NewReplayerReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Unordered = true
+	conn, err := rep.Connection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := ipb.NewIntStoreClient(conn)
+
+	// A receive before the first send waits for it.
+	chatc, err := client.StreamChat(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recvd := make(chan string, 2)
+	go func() {
+		for {
+			item, err := chatc.Recv()
+			if err != nil {
+				close(recvd)
+				return
+			}
+			recvd <- item.Name
+		}
+	}()
+	// The sends are replayed in a different order.
+	for _, name := range []string{"d", "e"} {
+		if err := chatc.Send(&ipb.Item{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for name := range recvd {
+		got = append(got, name)
+	}
+	if want := []string{"d", "e"}; !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := chatc.Send(&ipb.Item{Name: "f"}); err == nil {
+		t.Error("send of unrecorded message: got nil, want error")
+	}
+
+	// A receive on a stream that is never matched waits for the context.
+	ctx, cancel := context.WithCancel(context.Background())
+	chatc, err = client.StreamChat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := chatc.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("got %v, want Canceled", err)
+	}
+}
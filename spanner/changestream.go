@@ -0,0 +1,647 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/internal/trace"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultChangeStreamHeartbeatInterval is the heartbeat interval that is used
+// when ChangeStreamOptions.HeartbeatInterval is not set.
+const defaultChangeStreamHeartbeatInterval = 10 * time.Second
+
+var validChangeStreamName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// ModType is the type of a modification in a DataChangeRecord.
+type ModType string
+
+const (
+	// ModTypeInsert indicates that the rows in the record were inserted.
+	ModTypeInsert ModType = "INSERT"
+	// ModTypeUpdate indicates that the rows in the record were updated.
+	ModTypeUpdate ModType = "UPDATE"
+	// ModTypeDelete indicates that the rows in the record were deleted.
+	ModTypeDelete ModType = "DELETE"
+)
+
+// DataChangeRecord contains a set of changes to a table that were committed in
+// one transaction. A transaction can produce multiple data change records if it
+// touched more than one table or more than one partition.
+type DataChangeRecord struct {
+	// CommitTimestamp is the time at which the change was committed.
+	CommitTimestamp time.Time
+	// RecordSequence orders the records within a transaction and partition.
+	RecordSequence string
+	// ServerTransactionID is a globally unique identifier of the transaction
+	// that produced the change.
+	ServerTransactionID string
+	// IsLastRecordInTransactionInPartition is true for the last record of the
+	// transaction in this partition.
+	IsLastRecordInTransactionInPartition bool
+	// TableName is the name of the table that was modified.
+	TableName string
+	// ColumnTypes describes the columns that appear in Mods.
+	ColumnTypes []*ChangeStreamColumnType
+	// Mods contains the modified rows.
+	Mods []*ChangeStreamMod
+	// ModType is the kind of modification that was made to the rows in Mods.
+	ModType ModType
+	// ValueCaptureType is the value capture type of the change stream, e.g.
+	// OLD_AND_NEW_VALUES or NEW_ROW.
+	ValueCaptureType string
+	// NumberOfRecordsInTransaction is the number of data change records that
+	// were produced by the transaction across all partitions.
+	NumberOfRecordsInTransaction int64
+	// NumberOfPartitionsInTransaction is the number of partitions that
+	// returned data change records for the transaction.
+	NumberOfPartitionsInTransaction int64
+	// TransactionTag is the transaction tag of the transaction, if any.
+	TransactionTag string
+	// IsSystemTransaction is true if the change was made by a system
+	// transaction, such as a TTL deletion.
+	IsSystemTransaction bool
+}
+
+// ChangeStreamColumnType describes a column in a DataChangeRecord.
+type ChangeStreamColumnType struct {
+	// Name is the name of the column.
+	Name string
+	// Type is the JSON representation of the type of the column, e.g.
+	// {"code": "INT64"}.
+	Type interface{}
+	// IsPrimaryKey is true if the column is part of the primary key.
+	IsPrimaryKey bool
+	// OrdinalPosition is the position of the column in the table.
+	OrdinalPosition int64
+}
+
+// ChangeStreamMod contains the values of one modified row. The values are
+// decoded from their JSON representation, which means that INT64 and NUMERIC
+// values are strings and FLOAT64 values are float64.
+type ChangeStreamMod struct {
+	// Keys contains the primary key values of the modified row.
+	Keys map[string]interface{}
+	// NewValues contains the new values of the modified non-key columns.
+	NewValues map[string]interface{}
+	// OldValues contains the old values of the modified non-key columns. It
+	// is only set for change streams that capture old values.
+	OldValues map[string]interface{}
+}
+
+// HeartbeatRecord indicates that all changes with a commit timestamp before
+// Timestamp have been returned for the partition.
+type HeartbeatRecord struct {
+	Timestamp time.Time
+}
+
+// ChildPartitionsRecord is returned when a partition ends and one or more child
+// partitions take over the changes from StartTimestamp onwards.
+type ChildPartitionsRecord struct {
+	// StartTimestamp is the timestamp from which the child partitions should
+	// be queried.
+	StartTimestamp time.Time
+	// RecordSequence orders the child partitions records within a partition.
+	RecordSequence string
+	// ChildPartitions are the partitions that replace the current partition.
+	ChildPartitions []*ChildPartition
+}
+
+// ChildPartition is one child partition of a ChildPartitionsRecord.
+type ChildPartition struct {
+	// Token is the partition token of the child partition.
+	Token string
+	// ParentPartitionTokens contains the tokens of all parents of the child
+	// partition. A child partition with more than one parent is the result of
+	// a merge.
+	ParentPartitionTokens []string
+}
+
+// ChangeStreamRecord is a record returned by a ChangeStreamReader. Exactly one
+// of DataChange, Heartbeat and ChildPartitions is set.
+type ChangeStreamRecord struct {
+	// PartitionToken is the token of the partition that returned the record.
+	// It is empty for records that are returned by the initial query.
+	PartitionToken string
+
+	DataChange      *DataChangeRecord
+	Heartbeat       *HeartbeatRecord
+	ChildPartitions *ChildPartitionsRecord
+}
+
+// timestamp returns the timestamp up to which the partition of the record has
+// been read once the record has been processed.
+func (r *ChangeStreamRecord) timestamp() time.Time {
+	switch {
+	case r.DataChange != nil:
+		return r.DataChange.CommitTimestamp
+	case r.Heartbeat != nil:
+		return r.Heartbeat.Timestamp
+	case r.ChildPartitions != nil:
+		return r.ChildPartitions.StartTimestamp
+	}
+	return time.Time{}
+}
+
+// ChangeStreamOptions configures a ChangeStreamReader.
+type ChangeStreamOptions struct {
+	// StartTimestamp is the inclusive start of the change stream query. It
+	// must be set unless Checkpoint is set, and it must be within the
+	// retention period of the change stream.
+	StartTimestamp time.Time
+
+	// EndTimestamp is the inclusive end of the change stream query. The zero
+	// value means that the reader keeps reading changes until its context is
+	// cancelled.
+	EndTimestamp time.Time
+
+	// HeartbeatInterval is the interval at which partitions without changes
+	// return a heartbeat record. Defaults to 10 seconds.
+	HeartbeatInterval time.Duration
+
+	// Checkpoint resumes a previous read from the state that was returned by
+	// ChangeStreamReader.Checkpoint. StartTimestamp is ignored if Checkpoint
+	// is set.
+	Checkpoint *ChangeStreamCheckpoint
+
+	// Priority is the RPC priority to use for the change stream queries.
+	Priority sppb.RequestOptions_Priority
+}
+
+// ChangeStreamCheckpoint contains the state of a ChangeStreamReader. It can be
+// stored, for example as JSON, and passed to ChangeStreamOptions.Checkpoint to
+// resume reading the change stream. Records that have been delivered after the
+// checkpoint was taken may be delivered again after resuming.
+type ChangeStreamCheckpoint struct {
+	// Partitions contains the partitions that were still being read or
+	// waiting to be read when the checkpoint was taken.
+	Partitions []*ChangeStreamPartition
+}
+
+// ChangeStreamPartition is the state of one change stream partition.
+type ChangeStreamPartition struct {
+	// Token is the partition token. It is empty for the initial query.
+	Token string
+	// Watermark is the timestamp up to which all records of the partition
+	// have been processed. Reading the partition resumes from here.
+	Watermark time.Time
+	// ParentTokens contains the parents of the partition that must finish
+	// before the partition is read.
+	ParentTokens []string
+}
+
+// changeStreamQuerier executes a change stream query and calls f for every
+// returned row.
+type changeStreamQuerier func(ctx context.Context, stmt Statement, f func(*Row) error) error
+
+// ChangeStreamReader reads the records of a change stream. It starts with a
+// query for the initial partitions of the stream, follows child partitions as
+// partitions split and merge, and tracks the watermark of every partition.
+//
+// Use Client.ChangeStreamReader to create a ChangeStreamReader.
+type ChangeStreamReader struct {
+	streamName string
+	opts       ChangeStreamOptions
+	query      changeStreamQuerier
+
+	mu sync.Mutex
+	// partitions contains all partitions that are being read or waiting to
+	// be read, keyed by partition token.
+	partitions map[string]*changeStreamPartitionState
+	// finished contains the tokens of the partitions that have been read
+	// completely. A child partition is read once none of its parents is in
+	// partitions anymore.
+	finished map[string]bool
+	reading  bool
+}
+
+type changeStreamPartitionState struct {
+	ChangeStreamPartition
+	running bool
+}
+
+// ChangeStreamReader returns a reader for the change stream with the given
+// name. The reader executes the READ_<streamName> table-valued function with
+// single-use read-only transactions.
+func (c *Client) ChangeStreamReader(streamName string, opts ChangeStreamOptions) (*ChangeStreamReader, error) {
+	return newChangeStreamReader(streamName, opts, func(ctx context.Context, stmt Statement, f func(*Row) error) error {
+		return c.Single().QueryWithOptions(ctx, stmt, QueryOptions{Priority: opts.Priority}).Do(f)
+	})
+}
+
+func newChangeStreamReader(streamName string, opts ChangeStreamOptions, query changeStreamQuerier) (*ChangeStreamReader, error) {
+	if !validChangeStreamName.MatchString(streamName) {
+		return nil, spannerErrorf(codes.InvalidArgument, "invalid change stream name %q", streamName)
+	}
+	if opts.HeartbeatInterval == 0 {
+		opts.HeartbeatInterval = defaultChangeStreamHeartbeatInterval
+	}
+	if opts.HeartbeatInterval < time.Millisecond {
+		return nil, spannerErrorf(codes.InvalidArgument, "heartbeat interval must be at least 1ms, got %v", opts.HeartbeatInterval)
+	}
+	r := &ChangeStreamReader{
+		streamName: streamName,
+		opts:       opts,
+		query:      query,
+		partitions: map[string]*changeStreamPartitionState{},
+		finished:   map[string]bool{},
+	}
+	if opts.Checkpoint != nil {
+		if len(opts.Checkpoint.Partitions) == 0 {
+			return nil, spannerErrorf(codes.InvalidArgument, "checkpoint contains no partitions")
+		}
+		for _, p := range opts.Checkpoint.Partitions {
+			r.partitions[p.Token] = &changeStreamPartitionState{
+				ChangeStreamPartition: ChangeStreamPartition{
+					Token:        p.Token,
+					Watermark:    p.Watermark,
+					ParentTokens: append([]string(nil), p.ParentTokens...),
+				},
+			}
+		}
+		return r, nil
+	}
+	if opts.StartTimestamp.IsZero() {
+		return nil, spannerErrorf(codes.InvalidArgument, "either a start timestamp or a checkpoint must be set")
+	}
+	if !opts.EndTimestamp.IsZero() && opts.EndTimestamp.Before(opts.StartTimestamp) {
+		return nil, spannerErrorf(codes.InvalidArgument, "end timestamp %v is before start timestamp %v", opts.EndTimestamp, opts.StartTimestamp)
+	}
+	r.partitions[""] = &changeStreamPartitionState{
+		ChangeStreamPartition: ChangeStreamPartition{Watermark: opts.StartTimestamp},
+	}
+	return r, nil
+}
+
+// Read reads the change stream and calls f for every record until all
+// partitions have been read up to the end timestamp, ctx is done, or f returns
+// an error. f is called concurrently for records of different partitions, but
+// records of one partition are delivered in order and one at a time.
+//
+// The watermark of a partition advances once f returns nil for a record of the
+// partition. If Read returns an error, Checkpoint can be used to resume
+// reading with a new ChangeStreamReader.
+//
+// Read can only be called once at a time.
+func (r *ChangeStreamReader) Read(ctx context.Context, f func(context.Context, *ChangeStreamRecord) error) (err error) {
+	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.ChangeStreamReader.Read")
+	defer func() { trace.EndSpan(ctx, err) }()
+
+	r.mu.Lock()
+	if r.reading {
+		r.mu.Unlock()
+		return spannerErrorf(codes.FailedPrecondition, "change stream reader is already reading")
+	}
+	r.reading = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.reading = false
+		r.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	var schedule func()
+	schedule = func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, p := range r.readyLocked() {
+			p.running = true
+			wg.Add(1)
+			go func(p ChangeStreamPartition) {
+				defer wg.Done()
+				if err := r.readPartition(ctx, p, f); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				r.mu.Lock()
+				delete(r.partitions, p.Token)
+				r.finished[p.Token] = true
+				r.mu.Unlock()
+				if ctx.Err() == nil {
+					schedule()
+				}
+			}(p.ChangeStreamPartition)
+		}
+	}
+	schedule()
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return ToSpannerError(err)
+	}
+	return nil
+}
+
+// readyLocked returns the partitions that are not running and whose parents
+// have all been read. r.mu must be held.
+func (r *ChangeStreamReader) readyLocked() []*changeStreamPartitionState {
+	var ready []*changeStreamPartitionState
+	for _, p := range r.partitions {
+		if p.running {
+			continue
+		}
+		blocked := false
+		for _, parent := range p.ParentTokens {
+			if _, ok := r.partitions[parent]; ok {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			ready = append(ready, p)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Token < ready[j].Token })
+	return ready
+}
+
+func (r *ChangeStreamReader) statement(p ChangeStreamPartition) Statement {
+	var end NullTime
+	if !r.opts.EndTimestamp.IsZero() {
+		end = NullTime{Time: r.opts.EndTimestamp, Valid: true}
+	}
+	var token NullString
+	if p.Token != "" {
+		token = NullString{StringVal: p.Token, Valid: true}
+	}
+	return Statement{
+		SQL: fmt.Sprintf("SELECT ChangeRecord FROM READ_%s ("+
+			"start_timestamp => @startTimestamp, "+
+			"end_timestamp => @endTimestamp, "+
+			"partition_token => @partitionToken, "+
+			"heartbeat_milliseconds => @heartbeatMilliseconds)", r.streamName),
+		Params: map[string]interface{}{
+			"startTimestamp":        p.Watermark,
+			"endTimestamp":          end,
+			"partitionToken":        token,
+			"heartbeatMilliseconds": r.opts.HeartbeatInterval.Milliseconds(),
+		},
+	}
+}
+
+func (r *ChangeStreamReader) readPartition(ctx context.Context, p ChangeStreamPartition, f func(context.Context, *ChangeStreamRecord) error) error {
+	return r.query(ctx, r.statement(p), func(row *Row) error {
+		records, err := decodeChangeStreamRow(row, p.Token)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			if rec.ChildPartitions != nil {
+				r.addChildPartitions(rec.ChildPartitions)
+			}
+			if err := f(ctx, rec); err != nil {
+				return err
+			}
+			r.advanceWatermark(p.Token, rec.timestamp())
+		}
+		return nil
+	})
+}
+
+// addChildPartitions registers the child partitions of a record. Children that
+// are already known, for example because another parent of a merged partition
+// returned them first, are ignored.
+func (r *ChangeStreamReader) addChildPartitions(rec *ChildPartitionsRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, child := range rec.ChildPartitions {
+		if _, ok := r.partitions[child.Token]; ok || r.finished[child.Token] {
+			continue
+		}
+		r.partitions[child.Token] = &changeStreamPartitionState{
+			ChangeStreamPartition: ChangeStreamPartition{
+				Token:        child.Token,
+				Watermark:    rec.StartTimestamp,
+				ParentTokens: append([]string(nil), child.ParentPartitionTokens...),
+			},
+		}
+	}
+}
+
+func (r *ChangeStreamReader) advanceWatermark(token string, ts time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.partitions[token]; ok && ts.After(p.Watermark) {
+		p.Watermark = ts
+	}
+}
+
+// Checkpoint returns the current state of the reader. It can be called while
+// Read is running.
+func (r *ChangeStreamReader) Checkpoint() *ChangeStreamCheckpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := &ChangeStreamCheckpoint{}
+	for _, p := range r.partitions {
+		cp.Partitions = append(cp.Partitions, &ChangeStreamPartition{
+			Token:        p.Token,
+			Watermark:    p.Watermark,
+			ParentTokens: append([]string(nil), p.ParentTokens...),
+		})
+	}
+	sort.Slice(cp.Partitions, func(i, j int) bool { return cp.Partitions[i].Token < cp.Partitions[j].Token })
+	return cp
+}
+
+// Watermark returns the timestamp up to which all partitions of the change
+// stream have been processed. It returns the zero time once all partitions
+// have been read.
+func (r *ChangeStreamReader) Watermark() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var min time.Time
+	for _, p := range r.partitions {
+		if min.IsZero() || p.Watermark.Before(min) {
+			min = p.Watermark
+		}
+	}
+	return min
+}
+
+// The following types mirror the ChangeRecord column that is returned by the
+// READ_<stream> table-valued function.
+
+type changeRecordRow struct {
+	DataChangeRecord      []*dataChangeRecordRow      `spanner:"data_change_record"`
+	HeartbeatRecord       []*heartbeatRecordRow       `spanner:"heartbeat_record"`
+	ChildPartitionsRecord []*childPartitionsRecordRow `spanner:"child_partitions_record"`
+}
+
+type dataChangeRecordRow struct {
+	CommitTimestamp                      time.Time        `spanner:"commit_timestamp"`
+	RecordSequence                       string           `spanner:"record_sequence"`
+	ServerTransactionID                  string           `spanner:"server_transaction_id"`
+	IsLastRecordInTransactionInPartition bool             `spanner:"is_last_record_in_transaction_in_partition"`
+	TableName                            string           `spanner:"table_name"`
+	ColumnTypes                          []*columnTypeRow `spanner:"column_types"`
+	Mods                                 []*modRow        `spanner:"mods"`
+	ModType                              string           `spanner:"mod_type"`
+	ValueCaptureType                     string           `spanner:"value_capture_type"`
+	NumberOfRecordsInTransaction         int64            `spanner:"number_of_records_in_transaction"`
+	NumberOfPartitionsInTransaction      int64            `spanner:"number_of_partitions_in_transaction"`
+	TransactionTag                       NullString       `spanner:"transaction_tag"`
+	IsSystemTransaction                  NullBool         `spanner:"is_system_transaction"`
+}
+
+type columnTypeRow struct {
+	Name            string   `spanner:"name"`
+	Type            NullJSON `spanner:"type"`
+	IsPrimaryKey    bool     `spanner:"is_primary_key"`
+	OrdinalPosition int64    `spanner:"ordinal_position"`
+}
+
+type modRow struct {
+	Keys      NullJSON `spanner:"keys"`
+	NewValues NullJSON `spanner:"new_values"`
+	OldValues NullJSON `spanner:"old_values"`
+}
+
+type heartbeatRecordRow struct {
+	Timestamp time.Time `spanner:"timestamp"`
+}
+
+type childPartitionsRecordRow struct {
+	StartTimestamp  time.Time            `spanner:"start_timestamp"`
+	RecordSequence  string               `spanner:"record_sequence"`
+	ChildPartitions []*childPartitionRow `spanner:"child_partitions"`
+}
+
+type childPartitionRow struct {
+	Token                 string   `spanner:"token"`
+	ParentPartitionTokens []string `spanner:"parent_partition_tokens"`
+}
+
+// decodeChangeStreamRow decodes the ChangeRecord column of a change stream
+// query. Unknown fields are ignored, so that new fields that are added to the
+// change stream records do not break existing readers.
+func decodeChangeStreamRow(row *Row, token string) ([]*ChangeStreamRecord, error) {
+	if len(row.fields) != 1 {
+		return nil, spannerErrorf(codes.FailedPrecondition, "change stream row has %d columns, want 1", len(row.fields))
+	}
+	var changeRecords []*changeRecordRow
+	if err := decodeValue(row.vals[0], row.fields[0].Type, &changeRecords, withLenient{lenient: true}); err != nil {
+		return nil, err
+	}
+	var records []*ChangeStreamRecord
+	for _, cr := range changeRecords {
+		if cr == nil {
+			continue
+		}
+		for _, dc := range cr.DataChangeRecord {
+			if dc == nil {
+				continue
+			}
+			rec, err := dc.toDataChangeRecord()
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, &ChangeStreamRecord{PartitionToken: token, DataChange: rec})
+		}
+		for _, hb := range cr.HeartbeatRecord {
+			if hb == nil {
+				continue
+			}
+			records = append(records, &ChangeStreamRecord{PartitionToken: token, Heartbeat: &HeartbeatRecord{Timestamp: hb.Timestamp}})
+		}
+		for _, cp := range cr.ChildPartitionsRecord {
+			if cp == nil {
+				continue
+			}
+			rec := &ChildPartitionsRecord{StartTimestamp: cp.StartTimestamp, RecordSequence: cp.RecordSequence}
+			for _, c := range cp.ChildPartitions {
+				if c == nil {
+					continue
+				}
+				rec.ChildPartitions = append(rec.ChildPartitions, &ChildPartition{Token: c.Token, ParentPartitionTokens: c.ParentPartitionTokens})
+			}
+			records = append(records, &ChangeStreamRecord{PartitionToken: token, ChildPartitions: rec})
+		}
+	}
+	return records, nil
+}
+
+func (r *dataChangeRecordRow) toDataChangeRecord() (*DataChangeRecord, error) {
+	rec := &DataChangeRecord{
+		CommitTimestamp:                      r.CommitTimestamp,
+		RecordSequence:                       r.RecordSequence,
+		ServerTransactionID:                  r.ServerTransactionID,
+		IsLastRecordInTransactionInPartition: r.IsLastRecordInTransactionInPartition,
+		TableName:                            r.TableName,
+		ModType:                              ModType(r.ModType),
+		ValueCaptureType:                     r.ValueCaptureType,
+		NumberOfRecordsInTransaction:         r.NumberOfRecordsInTransaction,
+		NumberOfPartitionsInTransaction:      r.NumberOfPartitionsInTransaction,
+		TransactionTag:                       r.TransactionTag.StringVal,
+		IsSystemTransaction:                  r.IsSystemTransaction.Bool,
+	}
+	for _, ct := range r.ColumnTypes {
+		if ct == nil {
+			continue
+		}
+		rec.ColumnTypes = append(rec.ColumnTypes, &ChangeStreamColumnType{
+			Name:            ct.Name,
+			Type:            ct.Type.Value,
+			IsPrimaryKey:    ct.IsPrimaryKey,
+			OrdinalPosition: ct.OrdinalPosition,
+		})
+	}
+	for i, m := range r.Mods {
+		if m == nil {
+			continue
+		}
+		mod := &ChangeStreamMod{}
+		for _, v := range []struct {
+			name string
+			src  NullJSON
+			dst  *map[string]interface{}
+		}{
+			{"keys", m.Keys, &mod.Keys},
+			{"new_values", m.NewValues, &mod.NewValues},
+			{"old_values", m.OldValues, &mod.OldValues},
+		} {
+			if !v.src.Valid || v.src.Value == nil {
+				continue
+			}
+			values, ok := v.src.Value.(map[string]interface{})
+			if !ok {
+				return nil, spannerErrorf(codes.FailedPrecondition, "mod %d: %s is %T, want a JSON object", i, v.name, v.src.Value)
+			}
+			*v.dst = values
+		}
+		rec.Mods = append(rec.Mods, mod)
+	}
+	return rec, nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	proto3 "github.com/golang/protobuf/ptypes/struct"
+)
+
+func changeRecordType() *sppb.Type {
+	return listType(structType(
+		mkField("data_change_record", listType(structType(
+			mkField("commit_timestamp", timeType()),
+			mkField("record_sequence", stringType()),
+			mkField("server_transaction_id", stringType()),
+			mkField("is_last_record_in_transaction_in_partition", boolType()),
+			mkField("table_name", stringType()),
+			mkField("column_types", listType(structType(
+				mkField("name", stringType()),
+				mkField("type", jsonType()),
+				mkField("is_primary_key", boolType()),
+				mkField("ordinal_position", intType()),
+			))),
+			mkField("mods", listType(structType(
+				mkField("keys", jsonType()),
+				mkField("new_values", jsonType()),
+				mkField("old_values", jsonType()),
+			))),
+			mkField("mod_type", stringType()),
+			mkField("value_capture_type", stringType()),
+			mkField("number_of_records_in_transaction", intType()),
+			mkField("number_of_partitions_in_transaction", intType()),
+			mkField("transaction_tag", stringType()),
+			mkField("is_system_transaction", boolType()),
+			// Fields that are unknown to the client must be ignored.
+			mkField("some_future_field", stringType()),
+		))),
+		mkField("heartbeat_record", listType(structType(
+			mkField("timestamp", timeType()),
+		))),
+		mkField("child_partitions_record", listType(structType(
+			mkField("start_timestamp", timeType()),
+			mkField("record_sequence", stringType()),
+			mkField("child_partitions", listType(structType(
+				mkField("token", stringType()),
+				mkField("parent_partition_tokens", listType(stringType())),
+			))),
+		))),
+	))
+}
+
+func changeRecordRowProto(dataChanges, heartbeats, childPartitions []*proto3.Value) *Row {
+	return &Row{
+		fields: []*sppb.StructType_Field{mkField("ChangeRecord", changeRecordType())},
+		vals: []*proto3.Value{listProto(listProto(
+			listProto(dataChanges...),
+			listProto(heartbeats...),
+			listProto(childPartitions...),
+		))},
+	}
+}
+
+func dataChangeProto(ts time.Time, table, keys, newValues string) *proto3.Value {
+	return listProto(
+		timeProto(ts),
+		stringProto("00000001"),
+		stringProto("tx1"),
+		boolProto(true),
+		stringProto(table),
+		listProto(listProto(stringProto("SingerId"), stringProto(`{"code":"INT64"}`), boolProto(true), intProto(1))),
+		listProto(listProto(stringProto(keys), stringProto(newValues), nullProto())),
+		stringProto("INSERT"),
+		stringProto("OLD_AND_NEW_VALUES"),
+		intProto(1),
+		intProto(1),
+		stringProto(""),
+		boolProto(false),
+		stringProto("ignored"),
+	)
+}
+
+func heartbeatProto(ts time.Time) *proto3.Value {
+	return listProto(timeProto(ts))
+}
+
+func childPartitionsProto(ts time.Time, children map[string][]string) *proto3.Value {
+	var tokens []string
+	for token := range children {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	var cps []*proto3.Value
+	for _, token := range tokens {
+		var parents []*proto3.Value
+		for _, p := range children[token] {
+			parents = append(parents, stringProto(p))
+		}
+		cps = append(cps, listProto(stringProto(token), listProto(parents...)))
+	}
+	return listProto(timeProto(ts), stringProto("00000001"), listProto(cps...))
+}
+
+// fakeChangeStream returns predefined rows for each partition token and
+// records the statements that were executed.
+type fakeChangeStream struct {
+	rows map[string][]*Row
+
+	mu      sync.Mutex
+	queries []Statement
+	// started records the order in which partitions were queried.
+	started []string
+	// done records the partitions that were queried completely.
+	done map[string]bool
+	// parentsDone records whether all parents were done when a partition
+	// was started.
+	parents map[string][]string
+	errs    []string
+}
+
+func (s *fakeChangeStream) query(ctx context.Context, stmt Statement, f func(*Row) error) error {
+	token := ""
+	if t := stmt.Params["partitionToken"].(NullString); t.Valid {
+		token = t.StringVal
+	}
+	s.mu.Lock()
+	s.queries = append(s.queries, stmt)
+	s.started = append(s.started, token)
+	for _, p := range s.parents[token] {
+		if !s.done[p] {
+			s.errs = append(s.errs, token+" started before parent "+p+" finished")
+		}
+	}
+	s.mu.Unlock()
+	for _, row := range s.rows[token] {
+		if err := f(row); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.done[token] = true
+	s.mu.Unlock()
+	return nil
+}
+
+func TestChangeStreamReader_FollowsChildPartitions(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	t1, t2, t3 := start.Add(time.Minute), start.Add(2*time.Minute), start.Add(3*time.Minute)
+	s := &fakeChangeStream{
+		rows: map[string][]*Row{
+			"": {changeRecordRowProto(nil, nil, []*proto3.Value{
+				childPartitionsProto(start, map[string][]string{"a": nil, "b": nil}),
+			})},
+			"a": {
+				changeRecordRowProto([]*proto3.Value{dataChangeProto(t1, "Singers", `{"SingerId":"1"}`, `{"Name":"Alice"}`)}, nil, nil),
+				changeRecordRowProto(nil, nil, []*proto3.Value{
+					childPartitionsProto(t2, map[string][]string{"c": {"a", "b"}}),
+				}),
+			},
+			"b": {
+				changeRecordRowProto(nil, []*proto3.Value{heartbeatProto(t1)}, nil),
+				changeRecordRowProto(nil, nil, []*proto3.Value{
+					childPartitionsProto(t2, map[string][]string{"c": {"a", "b"}}),
+				}),
+			},
+			"c": {
+				changeRecordRowProto([]*proto3.Value{dataChangeProto(t3, "Singers", `{"SingerId":"2"}`, `{"Name":"Bob"}`)}, nil, nil),
+			},
+		},
+		done:    map[string]bool{},
+		parents: map[string][]string{"c": {"a", "b"}},
+	}
+	r, err := newChangeStreamReader("SingersStream", ChangeStreamOptions{StartTimestamp: start, EndTimestamp: end}, s.query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu      sync.Mutex
+		changes []*DataChangeRecord
+		tokens  []string
+		beats   int
+	)
+	err = r.Read(context.Background(), func(ctx context.Context, rec *ChangeStreamRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case rec.DataChange != nil:
+			changes = append(changes, rec.DataChange)
+			tokens = append(tokens, rec.PartitionToken)
+		case rec.Heartbeat != nil:
+			beats++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.errs) > 0 {
+		t.Fatalf("partitions read out of order: %v", s.errs)
+	}
+	if got, want := len(s.started), 4; got != want {
+		t.Fatalf("number of queries mismatch\n got: %v (%v)\nwant: %v", got, s.started, want)
+	}
+	if g, w := s.started[len(s.started)-1], "c"; g != w {
+		t.Fatalf("last partition mismatch\n got: %v\nwant: %v", g, w)
+	}
+	for _, q := range s.queries {
+		if !strings.HasPrefix(q.SQL, "SELECT ChangeRecord FROM READ_SingersStream (") {
+			t.Fatalf("unexpected SQL: %v", q.SQL)
+		}
+		if g, w := q.Params["endTimestamp"], (NullTime{Time: end, Valid: true}); g != w {
+			t.Fatalf("end timestamp mismatch\n got: %v\nwant: %v", g, w)
+		}
+		if g, w := q.Params["heartbeatMilliseconds"], int64(10000); g != w {
+			t.Fatalf("heartbeat mismatch\n got: %v\nwant: %v", g, w)
+		}
+	}
+	if g, w := s.queries[len(s.queries)-1].Params["startTimestamp"], t2; g != w {
+		t.Fatalf("child start timestamp mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := len(changes), 2; g != w {
+		t.Fatalf("data change count mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := beats, 1; g != w {
+		t.Fatalf("heartbeat count mismatch\n got: %v\nwant: %v", g, w)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].CommitTimestamp.Before(changes[j].CommitTimestamp) })
+	first := changes[0]
+	if g, w := first.TableName, "Singers"; g != w {
+		t.Fatalf("table name mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := first.ModType, ModTypeInsert; g != w {
+		t.Fatalf("mod type mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := first.Mods[0].Keys["SingerId"], "1"; g != w {
+		t.Fatalf("key mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := first.Mods[0].NewValues["Name"], "Alice"; g != w {
+		t.Fatalf("new value mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if first.Mods[0].OldValues != nil {
+		t.Fatalf("old values mismatch\n got: %v\nwant: nil", first.Mods[0].OldValues)
+	}
+	if g, w := first.ColumnTypes[0].Name, "SingerId"; g != w || !first.ColumnTypes[0].IsPrimaryKey {
+		t.Fatalf("column type mismatch\n got: %v\nwant: %v primary key", first.ColumnTypes[0], w)
+	}
+	if cp := r.Checkpoint(); len(cp.Partitions) != 0 {
+		t.Fatalf("checkpoint should be empty after reading all partitions, got %v", cp.Partitions)
+	}
+}
+
+func TestChangeStreamReader_CheckpointAndResume(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	t1, t2 := start.Add(time.Minute), start.Add(2*time.Minute)
+	s := &fakeChangeStream{
+		rows: map[string][]*Row{
+			"": {changeRecordRowProto(nil, nil, []*proto3.Value{
+				childPartitionsProto(start, map[string][]string{"a": nil}),
+			})},
+			"a": {
+				changeRecordRowProto([]*proto3.Value{dataChangeProto(t1, "Singers", `{"SingerId":"1"}`, `{}`)}, nil, nil),
+				changeRecordRowProto([]*proto3.Value{dataChangeProto(t2, "Singers", `{"SingerId":"2"}`, `{}`)}, nil, nil),
+			},
+		},
+		done: map[string]bool{},
+	}
+	r, err := newChangeStreamReader("SingersStream", ChangeStreamOptions{StartTimestamp: start}, s.query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	err = r.Read(context.Background(), func(ctx context.Context, rec *ChangeStreamRecord) error {
+		if rec.DataChange != nil && rec.DataChange.CommitTimestamp.Equal(t2) {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("error mismatch\n got: %v\nwant: %v", err, errStop)
+	}
+	cp := r.Checkpoint()
+	if g, w := len(cp.Partitions), 1; g != w {
+		t.Fatalf("checkpoint partition count mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := cp.Partitions[0].Token, "a"; g != w {
+		t.Fatalf("checkpoint token mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := cp.Partitions[0].Watermark, t1; !g.Equal(w) {
+		t.Fatalf("checkpoint watermark mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := r.Watermark(), t1; !g.Equal(w) {
+		t.Fatalf("watermark mismatch\n got: %v\nwant: %v", g, w)
+	}
+
+	s.queries, s.started = nil, nil
+	resumed, err := newChangeStreamReader("SingersStream", ChangeStreamOptions{Checkpoint: cp}, s.query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.Read(context.Background(), func(ctx context.Context, rec *ChangeStreamRecord) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := s.started, []string{"a"}; len(g) != 1 || g[0] != w[0] {
+		t.Fatalf("resumed partitions mismatch\n got: %v\nwant: %v", g, w)
+	}
+	if g, w := s.queries[0].Params["startTimestamp"], t1; g != w {
+		t.Fatalf("resumed start timestamp mismatch\n got: %v\nwant: %v", g, w)
+	}
+}
+
+func TestChangeStreamReader_InvalidOptions(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name   string
+		stream string
+		opts   ChangeStreamOptions
+	}{
+		{"invalid name", "Singers; DROP TABLE Singers", ChangeStreamOptions{StartTimestamp: start}},
+		{"no start", "SingersStream", ChangeStreamOptions{}},
+		{"end before start", "SingersStream", ChangeStreamOptions{StartTimestamp: start, EndTimestamp: start.Add(-time.Second)}},
+		{"empty checkpoint", "SingersStream", ChangeStreamOptions{Checkpoint: &ChangeStreamCheckpoint{}}},
+		{"heartbeat too small", "SingersStream", ChangeStreamOptions{StartTimestamp: start, HeartbeatInterval: time.Microsecond}},
+	} {
+		if _, err := newChangeStreamReader(test.stream, test.opts, nil); err == nil {
+			t.Errorf("%s: missing expected error", test.name)
+		}
+	}
+}
@@ -26,6 +26,8 @@ import (
 
 	"cloud.google.com/go/internal/trace"
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"google.golang.org/api/option"
 	"google.golang.org/api/option/internaloption"
 	gtransport "google.golang.org/api/transport/grpc"
@@ -146,6 +148,14 @@ type ClientConfig struct {
 	// Logger is the logger to use for this client. If it is nil, all logging
 	// will be directed to the standard logger.
 	Logger *log.Logger
+
+	// OpenTelemetryMeterProvider is the MeterProvider for the OpenTelemetry
+	// metrics of the session pool. If it is nil, the global MeterProvider is
+	// used if OpenTelemetry tracing is enabled with the environment variable
+	// GOOGLE_API_GO_EXPERIMENTAL_TELEMETRY_PLATFORM_TRACING=opentelemetry,
+	// and no OpenTelemetry metrics are recorded otherwise. The OpenCensus
+	// views in this package are recorded in either case.
+	OpenTelemetryMeterProvider metric.MeterProvider
 }
 
 func contextWithOutgoingMetadata(ctx context.Context, md metadata.MD) context.Context {
@@ -223,6 +233,7 @@ func NewClientWithConfig(ctx context.Context, database string, config ClientConf
 	sc := newSessionClient(pool, database, config.UserAgent, sessionLabels, metadata.Pairs(resourcePrefixHeader, database), config.Logger, config.CallOptions)
 	// Create a session pool.
 	config.SessionPoolConfig.sessionLabels = sessionLabels
+	config.SessionPoolConfig.meterProvider = meterProvider(config)
 	sp, err := newSessionPool(sc, config.SessionPoolConfig)
 	if err != nil {
 		sc.close()
@@ -464,6 +475,7 @@ func checkNestedTxn(ctx context.Context) error {
 func (c *Client) ReadWriteTransaction(ctx context.Context, f func(context.Context, *ReadWriteTransaction) error) (commitTimestamp time.Time, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.ReadWriteTransaction")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, c.ct)
 	resp, err := c.rwTransaction(ctx, f, TransactionOptions{})
 	return resp.CommitTs, err
 }
@@ -478,6 +490,7 @@ func (c *Client) ReadWriteTransaction(ctx context.Context, f func(context.Contex
 func (c *Client) ReadWriteTransactionWithOptions(ctx context.Context, f func(context.Context, *ReadWriteTransaction) error, options TransactionOptions) (resp CommitResponse, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.ReadWriteTransactionWithOptions")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, c.ct, attribute.String("db.spanner.transaction_tag", options.TransactionTag))
 	resp, err = c.rwTransaction(ctx, f, options)
 	return resp, err
 }
@@ -591,6 +604,7 @@ func (c *Client) Apply(ctx context.Context, ms []*Mutation, opts ...ApplyOption)
 
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Apply")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, c.ct, attribute.Int("db.spanner.mutation_count", len(ms)))
 
 	if !ao.atLeastOnce {
 		resp, err := c.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, t *ReadWriteTransaction) error {
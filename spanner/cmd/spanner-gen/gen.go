@@ -0,0 +1,501 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// schema is the schema that code is generated for.
+type schema struct {
+	Package string
+	Sources []string
+	Tables  []*table
+	// StdImports and Imports are the imported standard library and other
+	// packages.
+	StdImports []string
+	Imports    []string
+}
+
+type table struct {
+	Name    string
+	GoName  string
+	Columns []*column
+	Key     []*column
+	Indexes []*index
+}
+
+type column struct {
+	Name      string
+	GoName    string
+	GoType    string
+	Generated bool
+}
+
+type index struct {
+	Name   string
+	GoName string
+}
+
+// WritableColumns returns the columns that can be written with mutations.
+func (t *table) WritableColumns() []*column {
+	var cols []*column
+	for _, c := range t.Columns {
+		if !c.Generated {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// UpdatableColumns returns the columns that can be written with mutations,
+// except for the key columns.
+func (t *table) UpdatableColumns() []*column {
+	key := make(map[*column]bool)
+	for _, c := range t.Key {
+		key[c] = true
+	}
+	var cols []*column
+	for _, c := range t.WritableColumns() {
+		if !key[c] {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// VarName returns the prefix of the unexported identifiers of a table.
+func (t *table) VarName() string {
+	r := []rune(t.GoName)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// methods are the names of the generated methods of row structs, which
+// columns must not use.
+var methods = map[string]bool{
+	"Key": true, "Insert": true, "InsertOrUpdate": true, "Replace": true,
+	"Update": true, "Delete": true,
+}
+
+// buildSchema applies DDL statements to an empty schema and returns the
+// result.
+func buildSchema(pkg string, sources []string, ddls []*spansql.DDL) (*schema, error) {
+	var tables []*spansql.CreateTable
+	var indexes []*spansql.CreateIndex
+	findTable := func(name spansql.ID) (int, error) {
+		for i, t := range tables {
+			if strings.EqualFold(string(t.Name), string(name)) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown table %s", name)
+	}
+	for _, ddl := range ddls {
+		for _, stmt := range ddl.List {
+			switch stmt := stmt.(type) {
+			case *spansql.CreateTable:
+				ct := *stmt
+				ct.Columns = append([]spansql.ColumnDef(nil), stmt.Columns...)
+				tables = append(tables, &ct)
+			case *spansql.CreateIndex:
+				indexes = append(indexes, stmt)
+			case *spansql.DropTable:
+				i, err := findTable(stmt.Name)
+				if err != nil {
+					return nil, err
+				}
+				tables = append(tables[:i], tables[i+1:]...)
+			case *spansql.DropIndex:
+				for i, ci := range indexes {
+					if strings.EqualFold(string(ci.Name), string(stmt.Name)) {
+						indexes = append(indexes[:i], indexes[i+1:]...)
+						break
+					}
+				}
+			case *spansql.AlterTable:
+				i, err := findTable(stmt.Name)
+				if err != nil {
+					return nil, err
+				}
+				ct := tables[i]
+				switch alt := stmt.Alteration.(type) {
+				case spansql.AddColumn:
+					ct.Columns = append(ct.Columns, alt.Def)
+				case spansql.DropColumn:
+					for j, cd := range ct.Columns {
+						if strings.EqualFold(string(cd.Name), string(alt.Name)) {
+							ct.Columns = append(ct.Columns[:j], ct.Columns[j+1:]...)
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+
+	s := &schema{Package: pkg, Sources: sources}
+	imports := map[string]bool{"context": true, "fmt": true, "cloud.google.com/go/spanner": true}
+	names := make(map[string]string)
+	declare := func(name, what string) error {
+		if prev, ok := names[name]; ok {
+			return fmt.Errorf("the Go name %s of %s conflicts with %s", name, what, prev)
+		}
+		names[name] = what
+		return nil
+	}
+	for _, ct := range tables {
+		t := &table{Name: string(ct.Name), GoName: goName(string(ct.Name))}
+		what := "table " + t.Name
+		for _, n := range []string{t.GoName, t.GoName + "Key", t.GoName + "TableName", t.GoName + "Columns", "Read" + t.GoName, "Read" + t.GoName + "Rows"} {
+			if err := declare(n, what); err != nil {
+				return nil, err
+			}
+		}
+		fields := make(map[string]bool)
+		for _, cd := range ct.Columns {
+			c := &column{Name: string(cd.Name), GoName: goName(string(cd.Name)), Generated: cd.Generated != nil}
+			if methods[c.GoName] || fields[c.GoName] {
+				return nil, fmt.Errorf("the Go name %s of column %s of table %s is not unique", c.GoName, c.Name, t.Name)
+			}
+			fields[c.GoName] = true
+			if err := declare(t.GoName+c.GoName+"Column", fmt.Sprintf("column %s of table %s", c.Name, t.Name)); err != nil {
+				return nil, err
+			}
+			typ, imp := goType(cd.Type, cd.NotNull)
+			c.GoType = typ
+			if imp != "" {
+				imports[imp] = true
+			}
+			t.Columns = append(t.Columns, c)
+		}
+		for _, kp := range ct.PrimaryKey {
+			var kc *column
+			for _, c := range t.Columns {
+				if strings.EqualFold(c.Name, string(kp.Column)) {
+					kc = c
+				}
+			}
+			if kc == nil {
+				return nil, fmt.Errorf("unknown key column %s of table %s", kp.Column, t.Name)
+			}
+			t.Key = append(t.Key, kc)
+		}
+		for _, ci := range indexes {
+			if !strings.EqualFold(string(ci.Table), t.Name) {
+				continue
+			}
+			ix := &index{Name: string(ci.Name), GoName: goName(string(ci.Name))}
+			if err := declare("Read"+t.GoName+"Using"+ix.GoName, "index "+ix.Name); err != nil {
+				return nil, err
+			}
+			t.Indexes = append(t.Indexes, ix)
+		}
+		s.Tables = append(s.Tables, t)
+	}
+	for imp := range imports {
+		if strings.Contains(strings.SplitN(imp, "/", 2)[0], ".") {
+			s.Imports = append(s.Imports, imp)
+		} else {
+			s.StdImports = append(s.StdImports, imp)
+		}
+	}
+	sort.Strings(s.StdImports)
+	sort.Strings(s.Imports)
+	return s, nil
+}
+
+// goName returns the exported Go name of a Spanner identifier, converting
+// snake_case to CamelCase.
+func goName(id string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range id {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X" + id
+	}
+	return b.String()
+}
+
+// goType returns the Go type of the values of a column, and the package
+// that it needs, if any. Nullable scalar columns use the Null types of the
+// spanner package, and array elements are always nullable.
+func goType(t spansql.Type, notNull bool) (typ, imp string) {
+	if t.Array {
+		elem, imp := goType(spansql.Type{Base: t.Base}, false)
+		return "[]" + elem, imp
+	}
+	switch t.Base {
+	case spansql.Bool:
+		if notNull {
+			return "bool", ""
+		}
+		return "spanner.NullBool", ""
+	case spansql.Int64:
+		if notNull {
+			return "int64", ""
+		}
+		return "spanner.NullInt64", ""
+	case spansql.Float64:
+		if notNull {
+			return "float64", ""
+		}
+		return "spanner.NullFloat64", ""
+	case spansql.Numeric:
+		if notNull {
+			return "big.Rat", "math/big"
+		}
+		return "spanner.NullNumeric", ""
+	case spansql.String:
+		if notNull {
+			return "string", ""
+		}
+		return "spanner.NullString", ""
+	case spansql.Bytes:
+		return "[]byte", ""
+	case spansql.Date:
+		if notNull {
+			return "civil.Date", "cloud.google.com/go/civil"
+		}
+		return "spanner.NullDate", ""
+	case spansql.Timestamp:
+		if notNull {
+			return "time.Time", "time"
+		}
+		return "spanner.NullTime", ""
+	case spansql.JSON:
+		return "spanner.NullJSON", ""
+	}
+	return "spanner.GenericColumnValue", ""
+}
+
+// generate returns the formatted Go source for s.
+func generate(s *schema) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, s); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+var codeTemplate = template.Must(template.New("code").Parse(`// Code generated by spanner-gen. DO NOT EDIT.
+// Source: {{range $i, $s := .Sources}}{{if $i}}, {{end}}{{$s}}{{end}}
+
+package {{.Package}}
+
+import (
+{{- range .StdImports}}
+	"{{.}}"
+{{- end}}
+{{range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+
+// spannerReader is implemented by *spanner.ReadOnlyTransaction and
+// *spanner.ReadWriteTransaction.
+type spannerReader interface {
+	Read(ctx context.Context, table string, keys spanner.KeySet, columns []string) *spanner.RowIterator
+	ReadRow(ctx context.Context, table string, key spanner.Key, columns []string) (*spanner.Row, error)
+	ReadUsingIndex(ctx context.Context, table, index string, keys spanner.KeySet, columns []string) *spanner.RowIterator
+}
+{{range $t := .Tables}}
+// {{.GoName}}TableName is the name of table {{.Name}}.
+const {{.GoName}}TableName = "{{.Name}}"
+
+// The names of the columns of table {{.Name}}.
+const (
+{{- range .Columns}}
+	{{$t.GoName}}{{.GoName}}Column = "{{.Name}}"
+{{- end}}
+)
+
+// {{.GoName}}Columns returns the names of the columns of table {{.Name}}.
+func {{.GoName}}Columns() []string {
+	return []string{ {{- range $i, $c := .Columns}}{{if $i}}, {{end}}{{$t.GoName}}{{$c.GoName}}Column{{end -}} }
+}
+
+// {{.VarName}}WritableColumns are the columns of table {{.Name}} that are
+// written by mutations.
+var {{.VarName}}WritableColumns = []string{ {{- range $i, $c := .WritableColumns}}{{if $i}}, {{end}}{{$t.GoName}}{{$c.GoName}}Column{{end -}} }
+
+// {{.GoName}} is a row of table {{.Name}}.
+type {{.GoName}} struct {
+{{- range .Columns}}
+	{{.GoName}} {{.GoType}} ` + "`" + `spanner:"{{.Name}}"` + "`" + `
+{{- end}}
+}
+
+// {{.GoName}}Key is the primary key of a row of table {{.Name}}.
+type {{.GoName}}Key struct {
+{{- range .Key}}
+	{{.GoName}} {{.GoType}}
+{{- end}}
+}
+
+// Key returns k as a spanner.Key.
+func (k {{.GoName}}Key) Key() spanner.Key {
+	return spanner.Key{ {{- range $i, $c := .Key}}{{if $i}}, {{end}}k.{{$c.GoName}}{{end -}} }
+}
+
+// Key returns the primary key of r.
+func (r *{{.GoName}}) Key() {{.GoName}}Key {
+	return {{.GoName}}Key{ {{- range $i, $c := .Key}}{{if $i}}, {{end}}{{$c.GoName}}: r.{{$c.GoName}}{{end -}} }
+}
+
+// Insert returns a mutation that inserts r.
+func (r *{{.GoName}}) Insert() *spanner.Mutation {
+	return spanner.Insert({{.GoName}}TableName, {{.VarName}}WritableColumns, r.values({{.VarName}}WritableColumns))
+}
+
+// InsertOrUpdate returns a mutation that inserts r, or updates all columns
+// of the row if it exists.
+func (r *{{.GoName}}) InsertOrUpdate() *spanner.Mutation {
+	return spanner.InsertOrUpdate({{.GoName}}TableName, {{.VarName}}WritableColumns, r.values({{.VarName}}WritableColumns))
+}
+
+// Replace returns a mutation that inserts r, or replaces the row if it
+// exists.
+func (r *{{.GoName}}) Replace() *spanner.Mutation {
+	return spanner.Replace({{.GoName}}TableName, {{.VarName}}WritableColumns, r.values({{.VarName}}WritableColumns))
+}
+
+// Update returns a mutation that updates the given columns of the row with
+// the values of r. If no columns are given, all columns are updated. The key
+// columns are always included. Update panics if a column is not a writable
+// column of the table.
+func (r *{{.GoName}}) Update(columns ...string) *spanner.Mutation {
+	if len(columns) == 0 {
+		return spanner.Update({{.GoName}}TableName, {{.VarName}}WritableColumns, r.values({{.VarName}}WritableColumns))
+	}
+{{- if .Key}}
+	cols := []string{ {{- range $i, $c := .Key}}{{if $i}}, {{end}}{{$t.GoName}}{{$c.GoName}}Column{{end -}} }
+{{- else}}
+	var cols []string
+{{- end}}
+	for _, c := range columns {
+		switch c {
+{{- if .Key}}
+		case {{range $i, $c := .Key}}{{if $i}}, {{end}}{{$t.GoName}}{{$c.GoName}}Column{{end}}:
+{{- end}}
+{{- if .UpdatableColumns}}
+		case {{range $i, $c := .UpdatableColumns}}{{if $i}}, {{end}}{{$t.GoName}}{{$c.GoName}}Column{{end}}:
+			cols = append(cols, c)
+{{- end}}
+		default:
+			panic(fmt.Sprintf("{{.GoName}}.Update: %q is not a writable column of table {{.Name}}", c))
+		}
+	}
+	return spanner.Update({{.GoName}}TableName, cols, r.values(cols))
+}
+
+// Delete returns a mutation that deletes the row with the key of r.
+func (r *{{.GoName}}) Delete() *spanner.Mutation {
+	return spanner.Delete({{.GoName}}TableName, r.Key().Key())
+}
+
+func (r *{{.GoName}}) values(columns []string) []interface{} {
+	vs := make([]interface{}, len(columns))
+	for i, c := range columns {
+		switch c {
+{{- range .Columns}}
+		case {{$t.GoName}}{{.GoName}}Column:
+			vs[i] = r.{{.GoName}}
+{{- end}}
+		}
+	}
+	return vs
+}
+
+// Read{{.GoName}} reads the row of table {{.Name}} with the given key. If
+// there is no such row, the error has code NotFound.
+func Read{{.GoName}}(ctx context.Context, txn spannerReader, key {{.GoName}}Key) (*{{.GoName}}, error) {
+	row, err := txn.ReadRow(ctx, {{.GoName}}TableName, key.Key(), {{.GoName}}Columns())
+	if err != nil {
+		return nil, err
+	}
+	return scan{{.GoName}}(row)
+}
+
+// Read{{.GoName}}Rows reads the rows of table {{.Name}} whose keys are in
+// keys, in primary key order.
+func Read{{.GoName}}Rows(ctx context.Context, txn spannerReader, keys spanner.KeySet) ([]*{{.GoName}}, error) {
+	return read{{.GoName}}(txn.Read(ctx, {{.GoName}}TableName, keys, {{.GoName}}Columns()))
+}
+{{range .Indexes}}
+// Read{{$t.GoName}}Using{{.GoName}} reads the rows of table {{$t.Name}} whose keys
+// in index {{.Name}} are in keys. It reads the primary keys of the rows from
+// the index, and then the rows from the table, so txn must not be a
+// single-use transaction. The rows are returned in primary key order.
+func Read{{$t.GoName}}Using{{.GoName}}(ctx context.Context, txn spannerReader, keys spanner.KeySet) ([]*{{$t.GoName}}, error) {
+	var pks []spanner.KeySet
+	iter := txn.ReadUsingIndex(ctx, {{$t.GoName}}TableName, "{{.Name}}", keys, []string{ {{- range $i, $c := $t.Key}}{{if $i}}, {{end}}{{$t.GoName}}{{$c.GoName}}Column{{end -}} })
+	err := iter.Do(func(row *spanner.Row) error {
+		var k {{$t.GoName}}Key
+		if err := row.Columns({{range $i, $c := $t.Key}}{{if $i}}, {{end}}&k.{{$c.GoName}}{{end}}); err != nil {
+			return err
+		}
+		pks = append(pks, k.Key())
+		return nil
+	})
+	if err != nil || len(pks) == 0 {
+		return nil, err
+	}
+	return read{{$t.GoName}}(txn.Read(ctx, {{$t.GoName}}TableName, spanner.KeySets(pks...), {{$t.GoName}}Columns()))
+}
+{{end}}
+func scan{{.GoName}}(row *spanner.Row) (*{{.GoName}}, error) {
+	r := &{{.GoName}}{}
+	if err := row.Columns({{range $i, $c := .Columns}}{{if $i}}, {{end}}&r.{{$c.GoName}}{{end}}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func read{{.GoName}}(iter *spanner.RowIterator) ([]*{{.GoName}}, error) {
+	var rs []*{{.GoName}}
+	err := iter.Do(func(row *spanner.Row) error {
+		r, err := scan{{.GoName}}(row)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+		return nil
+	})
+	return rs, err
+}
+{{end}}`))
@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner/spansql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func parse(t *testing.T, ddls ...string) []*spansql.DDL {
+	t.Helper()
+	var parsed []*spansql.DDL
+	for _, s := range ddls {
+		ddl, err := spansql.ParseDDL("test.sql", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, ddl)
+	}
+	return parsed
+}
+
+// TestExample checks that the generated code of the example package is up
+// to date.
+func TestExample(t *testing.T) {
+	schemaSQL, err := os.ReadFile("internal/example/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("internal/example/schema_spanner.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := buildSchema("example", []string{"schema.sql"}, parse(t, string(schemaSQL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("generated code differs, run go generate in internal/example (-want +got):\n%s", diff)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	s, err := buildSchema("p", nil, parse(t,
+		"CREATE TABLE a_b (Id INT64 NOT NULL, Old STRING(MAX)) PRIMARY KEY (Id); CREATE TABLE Gone (Id INT64) PRIMARY KEY (Id)",
+		"ALTER TABLE a_b ADD COLUMN price NUMERIC NOT NULL; ALTER TABLE a_b DROP COLUMN Old; DROP TABLE Gone",
+		"CREATE TABLE Keyless (V JSON) PRIMARY KEY ()",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Tables) != 2 {
+		t.Fatalf("got %d tables, want 2", len(s.Tables))
+	}
+	var cols []string
+	for _, c := range s.Tables[0].Columns {
+		cols = append(cols, c.GoName+" "+c.GoType)
+	}
+	if diff := cmp.Diff([]string{"Id int64", "Price big.Rat"}, cols); diff != "" {
+		t.Errorf("columns of AB differ (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"context", "fmt", "math/big"}, s.StdImports); diff != "" {
+		t.Errorf("imports differ (-want +got):\n%s", diff)
+	}
+	if _, err := generate(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	for _, ddl := range []string{
+		"CREATE TABLE T (a_b INT64, AB INT64) PRIMARY KEY (a_b)",
+		"CREATE TABLE T (Key INT64) PRIMARY KEY (Key)",
+		"CREATE TABLE T (A INT64) PRIMARY KEY (A); CREATE TABLE t_key (A INT64) PRIMARY KEY (A)",
+		"CREATE TABLE T (A INT64) PRIMARY KEY (A); CREATE TABLE TA (Column INT64) PRIMARY KEY (Column); ALTER TABLE T ADD COLUMN AColumn INT64",
+	} {
+		_, err := buildSchema("p", nil, parse(t, ddl))
+		if err == nil || !strings.Contains(err.Error(), "Go name") {
+			t.Errorf("%s: got %v, want a Go name error", ddl, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package example contains the code that spanner-gen generates for
+// schema.sql. It is compiled and tested with the spanner module, and the
+// tests of spanner-gen check that it is up to date.
+package example
+
+//go:generate go run cloud.google.com/go/spanner/cmd/spanner-gen -o schema_spanner.go schema.sql
@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package example
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/spannertest"
+	"cloud.google.com/go/spanner/spansql"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newClient(t *testing.T) *spanner.Client {
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	srv.SetLogger(t.Logf)
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ddl, err := spansql.ParseDDL("schema.sql", string(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.UpdateDDL(ddl); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := spanner.NewClient(context.Background(), "projects/p/instances/i/databases/d", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		conn.Close()
+	})
+	return client
+}
+
+func TestGeneratedCode(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	singers := []*Singers{
+		{SingerId: 1, FirstName: spanner.NullString{StringVal: "Marc", Valid: true}, LastName: "Richards", Birthday: spanner.NullDate{Date: civil.Date{Year: 1970, Month: 9, Day: 3}, Valid: true}},
+		{SingerId: 2, LastName: "Smith", Tags: []spanner.NullString{{StringVal: "pop", Valid: true}}},
+		{SingerId: 3, LastName: "Smith"},
+	}
+	var ms []*spanner.Mutation
+	for _, s := range singers {
+		ms = append(ms, s.Insert())
+	}
+	ms = append(ms, (&Albums{SingerId: 1, AlbumId: 1, Title: "Total Junk", Cover: []byte{1}}).Insert())
+	if _, err := client.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadSingers(ctx, client.Single(), SingersKey{SingerId: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignore := cmp.FilterPath(func(p cmp.Path) bool { return p.Last().String() == ".LastNameLower" }, cmp.Ignore())
+	if diff := cmp.Diff(singers[1], got, ignore); diff != "" {
+		t.Errorf("ReadSingers: rows differ (-want +got):\n%s", diff)
+	}
+	if _, err := ReadSingers(ctx, client.Single(), SingersKey{SingerId: 4}); spanner.ErrCode(err) != codes.NotFound {
+		t.Errorf("ReadSingers of a missing row: got %v, want NotFound", err)
+	}
+
+	// A partial update does not change the other columns.
+	update := &Singers{SingerId: 1, Rating: spanner.NullFloat64{Float64: 4.5, Valid: true}}
+	if _, err := client.Apply(ctx, []*spanner.Mutation{update.Update(SingersRatingColumn)}); err != nil {
+		t.Fatal(err)
+	}
+	all, err := ReadSingersRows(ctx, client.Single(), spanner.AllKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].LastName != "Richards" || all[0].Rating.Float64 != 4.5 {
+		t.Errorf("ReadSingersRows: got %+v, want the updated row first", all)
+	}
+
+	// ReadSingersUsingSingersByLastName is not tested, because spannertest
+	// does not support index reads.
+
+	album, err := ReadAlbums(ctx, client.Single(), AlbumsKey{SingerId: 1, AlbumId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Apply(ctx, []*spanner.Mutation{album.Delete()}); err != nil {
+		t.Fatal(err)
+	}
+	if albums, err := ReadAlbumsRows(ctx, client.Single(), spanner.AllKeys()); err != nil || len(albums) != 0 {
+		t.Errorf("ReadAlbumsRows after Delete: got %v, %v, want no rows", albums, err)
+	}
+}
+
+func TestUpdateColumns(t *testing.T) {
+	s := &Singers{SingerId: 1, LastName: "Smith"}
+	// Update does not panic for key columns or writable columns.
+	s.Update(SingersSingerIdColumn, SingersLastNameColumn)
+
+	for _, col := range []string{SingersLastNameLowerColumn, "NoSuchColumn"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Update(%q) did not panic", col)
+				}
+			}()
+			s.Update(col)
+		}()
+	}
+}
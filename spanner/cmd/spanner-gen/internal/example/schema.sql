@@ -0,0 +1,23 @@
+-- The schema of the example package.
+
+CREATE TABLE Singers (
+	SingerId INT64 NOT NULL,
+	first_name STRING(1024),
+	last_name STRING(1024) NOT NULL,
+	LastNameLower STRING(1024) AS (LOWER(last_name)) STORED,
+	Rating FLOAT64,
+	Birthday DATE,
+	Tags ARRAY<STRING(MAX)>,
+	UpdatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (SingerId);
+
+CREATE INDEX SingersByLastName ON Singers(last_name);
+
+CREATE TABLE Albums (
+	SingerId INT64 NOT NULL,
+	AlbumId INT64 NOT NULL,
+	Title STRING(MAX) NOT NULL,
+	Released TIMESTAMP NOT NULL,
+	Cover BYTES(MAX),
+) PRIMARY KEY (SingerId, AlbumId),
+	INTERLEAVE IN PARENT Singers ON DELETE CASCADE;
@@ -0,0 +1,342 @@
+// Code generated by spanner-gen. DO NOT EDIT.
+// Source: schema.sql
+
+package example
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// spannerReader is implemented by *spanner.ReadOnlyTransaction and
+// *spanner.ReadWriteTransaction.
+type spannerReader interface {
+	Read(ctx context.Context, table string, keys spanner.KeySet, columns []string) *spanner.RowIterator
+	ReadRow(ctx context.Context, table string, key spanner.Key, columns []string) (*spanner.Row, error)
+	ReadUsingIndex(ctx context.Context, table, index string, keys spanner.KeySet, columns []string) *spanner.RowIterator
+}
+
+// SingersTableName is the name of table Singers.
+const SingersTableName = "Singers"
+
+// The names of the columns of table Singers.
+const (
+	SingersSingerIdColumn      = "SingerId"
+	SingersFirstNameColumn     = "first_name"
+	SingersLastNameColumn      = "last_name"
+	SingersLastNameLowerColumn = "LastNameLower"
+	SingersRatingColumn        = "Rating"
+	SingersBirthdayColumn      = "Birthday"
+	SingersTagsColumn          = "Tags"
+	SingersUpdatedAtColumn     = "UpdatedAt"
+)
+
+// SingersColumns returns the names of the columns of table Singers.
+func SingersColumns() []string {
+	return []string{SingersSingerIdColumn, SingersFirstNameColumn, SingersLastNameColumn, SingersLastNameLowerColumn, SingersRatingColumn, SingersBirthdayColumn, SingersTagsColumn, SingersUpdatedAtColumn}
+}
+
+// singersWritableColumns are the columns of table Singers that are
+// written by mutations.
+var singersWritableColumns = []string{SingersSingerIdColumn, SingersFirstNameColumn, SingersLastNameColumn, SingersRatingColumn, SingersBirthdayColumn, SingersTagsColumn, SingersUpdatedAtColumn}
+
+// Singers is a row of table Singers.
+type Singers struct {
+	SingerId      int64                `spanner:"SingerId"`
+	FirstName     spanner.NullString   `spanner:"first_name"`
+	LastName      string               `spanner:"last_name"`
+	LastNameLower spanner.NullString   `spanner:"LastNameLower"`
+	Rating        spanner.NullFloat64  `spanner:"Rating"`
+	Birthday      spanner.NullDate     `spanner:"Birthday"`
+	Tags          []spanner.NullString `spanner:"Tags"`
+	UpdatedAt     spanner.NullTime     `spanner:"UpdatedAt"`
+}
+
+// SingersKey is the primary key of a row of table Singers.
+type SingersKey struct {
+	SingerId int64
+}
+
+// Key returns k as a spanner.Key.
+func (k SingersKey) Key() spanner.Key {
+	return spanner.Key{k.SingerId}
+}
+
+// Key returns the primary key of r.
+func (r *Singers) Key() SingersKey {
+	return SingersKey{SingerId: r.SingerId}
+}
+
+// Insert returns a mutation that inserts r.
+func (r *Singers) Insert() *spanner.Mutation {
+	return spanner.Insert(SingersTableName, singersWritableColumns, r.values(singersWritableColumns))
+}
+
+// InsertOrUpdate returns a mutation that inserts r, or updates all columns
+// of the row if it exists.
+func (r *Singers) InsertOrUpdate() *spanner.Mutation {
+	return spanner.InsertOrUpdate(SingersTableName, singersWritableColumns, r.values(singersWritableColumns))
+}
+
+// Replace returns a mutation that inserts r, or replaces the row if it
+// exists.
+func (r *Singers) Replace() *spanner.Mutation {
+	return spanner.Replace(SingersTableName, singersWritableColumns, r.values(singersWritableColumns))
+}
+
+// Update returns a mutation that updates the given columns of the row with
+// the values of r. If no columns are given, all columns are updated. The key
+// columns are always included. Update panics if a column is not a writable
+// column of the table.
+func (r *Singers) Update(columns ...string) *spanner.Mutation {
+	if len(columns) == 0 {
+		return spanner.Update(SingersTableName, singersWritableColumns, r.values(singersWritableColumns))
+	}
+	cols := []string{SingersSingerIdColumn}
+	for _, c := range columns {
+		switch c {
+		case SingersSingerIdColumn:
+		case SingersFirstNameColumn, SingersLastNameColumn, SingersRatingColumn, SingersBirthdayColumn, SingersTagsColumn, SingersUpdatedAtColumn:
+			cols = append(cols, c)
+		default:
+			panic(fmt.Sprintf("Singers.Update: %q is not a writable column of table Singers", c))
+		}
+	}
+	return spanner.Update(SingersTableName, cols, r.values(cols))
+}
+
+// Delete returns a mutation that deletes the row with the key of r.
+func (r *Singers) Delete() *spanner.Mutation {
+	return spanner.Delete(SingersTableName, r.Key().Key())
+}
+
+func (r *Singers) values(columns []string) []interface{} {
+	vs := make([]interface{}, len(columns))
+	for i, c := range columns {
+		switch c {
+		case SingersSingerIdColumn:
+			vs[i] = r.SingerId
+		case SingersFirstNameColumn:
+			vs[i] = r.FirstName
+		case SingersLastNameColumn:
+			vs[i] = r.LastName
+		case SingersLastNameLowerColumn:
+			vs[i] = r.LastNameLower
+		case SingersRatingColumn:
+			vs[i] = r.Rating
+		case SingersBirthdayColumn:
+			vs[i] = r.Birthday
+		case SingersTagsColumn:
+			vs[i] = r.Tags
+		case SingersUpdatedAtColumn:
+			vs[i] = r.UpdatedAt
+		}
+	}
+	return vs
+}
+
+// ReadSingers reads the row of table Singers with the given key. If
+// there is no such row, the error has code NotFound.
+func ReadSingers(ctx context.Context, txn spannerReader, key SingersKey) (*Singers, error) {
+	row, err := txn.ReadRow(ctx, SingersTableName, key.Key(), SingersColumns())
+	if err != nil {
+		return nil, err
+	}
+	return scanSingers(row)
+}
+
+// ReadSingersRows reads the rows of table Singers whose keys are in
+// keys, in primary key order.
+func ReadSingersRows(ctx context.Context, txn spannerReader, keys spanner.KeySet) ([]*Singers, error) {
+	return readSingers(txn.Read(ctx, SingersTableName, keys, SingersColumns()))
+}
+
+// ReadSingersUsingSingersByLastName reads the rows of table Singers whose keys
+// in index SingersByLastName are in keys. It reads the primary keys of the rows from
+// the index, and then the rows from the table, so txn must not be a
+// single-use transaction. The rows are returned in primary key order.
+func ReadSingersUsingSingersByLastName(ctx context.Context, txn spannerReader, keys spanner.KeySet) ([]*Singers, error) {
+	var pks []spanner.KeySet
+	iter := txn.ReadUsingIndex(ctx, SingersTableName, "SingersByLastName", keys, []string{SingersSingerIdColumn})
+	err := iter.Do(func(row *spanner.Row) error {
+		var k SingersKey
+		if err := row.Columns(&k.SingerId); err != nil {
+			return err
+		}
+		pks = append(pks, k.Key())
+		return nil
+	})
+	if err != nil || len(pks) == 0 {
+		return nil, err
+	}
+	return readSingers(txn.Read(ctx, SingersTableName, spanner.KeySets(pks...), SingersColumns()))
+}
+
+func scanSingers(row *spanner.Row) (*Singers, error) {
+	r := &Singers{}
+	if err := row.Columns(&r.SingerId, &r.FirstName, &r.LastName, &r.LastNameLower, &r.Rating, &r.Birthday, &r.Tags, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func readSingers(iter *spanner.RowIterator) ([]*Singers, error) {
+	var rs []*Singers
+	err := iter.Do(func(row *spanner.Row) error {
+		r, err := scanSingers(row)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+		return nil
+	})
+	return rs, err
+}
+
+// AlbumsTableName is the name of table Albums.
+const AlbumsTableName = "Albums"
+
+// The names of the columns of table Albums.
+const (
+	AlbumsSingerIdColumn = "SingerId"
+	AlbumsAlbumIdColumn  = "AlbumId"
+	AlbumsTitleColumn    = "Title"
+	AlbumsReleasedColumn = "Released"
+	AlbumsCoverColumn    = "Cover"
+)
+
+// AlbumsColumns returns the names of the columns of table Albums.
+func AlbumsColumns() []string {
+	return []string{AlbumsSingerIdColumn, AlbumsAlbumIdColumn, AlbumsTitleColumn, AlbumsReleasedColumn, AlbumsCoverColumn}
+}
+
+// albumsWritableColumns are the columns of table Albums that are
+// written by mutations.
+var albumsWritableColumns = []string{AlbumsSingerIdColumn, AlbumsAlbumIdColumn, AlbumsTitleColumn, AlbumsReleasedColumn, AlbumsCoverColumn}
+
+// Albums is a row of table Albums.
+type Albums struct {
+	SingerId int64     `spanner:"SingerId"`
+	AlbumId  int64     `spanner:"AlbumId"`
+	Title    string    `spanner:"Title"`
+	Released time.Time `spanner:"Released"`
+	Cover    []byte    `spanner:"Cover"`
+}
+
+// AlbumsKey is the primary key of a row of table Albums.
+type AlbumsKey struct {
+	SingerId int64
+	AlbumId  int64
+}
+
+// Key returns k as a spanner.Key.
+func (k AlbumsKey) Key() spanner.Key {
+	return spanner.Key{k.SingerId, k.AlbumId}
+}
+
+// Key returns the primary key of r.
+func (r *Albums) Key() AlbumsKey {
+	return AlbumsKey{SingerId: r.SingerId, AlbumId: r.AlbumId}
+}
+
+// Insert returns a mutation that inserts r.
+func (r *Albums) Insert() *spanner.Mutation {
+	return spanner.Insert(AlbumsTableName, albumsWritableColumns, r.values(albumsWritableColumns))
+}
+
+// InsertOrUpdate returns a mutation that inserts r, or updates all columns
+// of the row if it exists.
+func (r *Albums) InsertOrUpdate() *spanner.Mutation {
+	return spanner.InsertOrUpdate(AlbumsTableName, albumsWritableColumns, r.values(albumsWritableColumns))
+}
+
+// Replace returns a mutation that inserts r, or replaces the row if it
+// exists.
+func (r *Albums) Replace() *spanner.Mutation {
+	return spanner.Replace(AlbumsTableName, albumsWritableColumns, r.values(albumsWritableColumns))
+}
+
+// Update returns a mutation that updates the given columns of the row with
+// the values of r. If no columns are given, all columns are updated. The key
+// columns are always included. Update panics if a column is not a writable
+// column of the table.
+func (r *Albums) Update(columns ...string) *spanner.Mutation {
+	if len(columns) == 0 {
+		return spanner.Update(AlbumsTableName, albumsWritableColumns, r.values(albumsWritableColumns))
+	}
+	cols := []string{AlbumsSingerIdColumn, AlbumsAlbumIdColumn}
+	for _, c := range columns {
+		switch c {
+		case AlbumsSingerIdColumn, AlbumsAlbumIdColumn:
+		case AlbumsTitleColumn, AlbumsReleasedColumn, AlbumsCoverColumn:
+			cols = append(cols, c)
+		default:
+			panic(fmt.Sprintf("Albums.Update: %q is not a writable column of table Albums", c))
+		}
+	}
+	return spanner.Update(AlbumsTableName, cols, r.values(cols))
+}
+
+// Delete returns a mutation that deletes the row with the key of r.
+func (r *Albums) Delete() *spanner.Mutation {
+	return spanner.Delete(AlbumsTableName, r.Key().Key())
+}
+
+func (r *Albums) values(columns []string) []interface{} {
+	vs := make([]interface{}, len(columns))
+	for i, c := range columns {
+		switch c {
+		case AlbumsSingerIdColumn:
+			vs[i] = r.SingerId
+		case AlbumsAlbumIdColumn:
+			vs[i] = r.AlbumId
+		case AlbumsTitleColumn:
+			vs[i] = r.Title
+		case AlbumsReleasedColumn:
+			vs[i] = r.Released
+		case AlbumsCoverColumn:
+			vs[i] = r.Cover
+		}
+	}
+	return vs
+}
+
+// ReadAlbums reads the row of table Albums with the given key. If
+// there is no such row, the error has code NotFound.
+func ReadAlbums(ctx context.Context, txn spannerReader, key AlbumsKey) (*Albums, error) {
+	row, err := txn.ReadRow(ctx, AlbumsTableName, key.Key(), AlbumsColumns())
+	if err != nil {
+		return nil, err
+	}
+	return scanAlbums(row)
+}
+
+// ReadAlbumsRows reads the rows of table Albums whose keys are in
+// keys, in primary key order.
+func ReadAlbumsRows(ctx context.Context, txn spannerReader, keys spanner.KeySet) ([]*Albums, error) {
+	return readAlbums(txn.Read(ctx, AlbumsTableName, keys, AlbumsColumns()))
+}
+
+func scanAlbums(row *spanner.Row) (*Albums, error) {
+	r := &Albums{}
+	if err := row.Columns(&r.SingerId, &r.AlbumId, &r.Title, &r.Released, &r.Cover); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func readAlbums(iter *spanner.RowIterator) ([]*Albums, error) {
+	var rs []*Albums
+	err := iter.Do(func(row *spanner.Row) error {
+		r, err := scanAlbums(row)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+		return nil
+	})
+	return rs, err
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+spanner-gen generates typed Go code for the tables of a Cloud Spanner schema.
+
+Usage:
+
+	spanner-gen [-package name] [-o file] schema.sql...
+
+The schema files are parsed with spansql and applied in order, so they may be
+a single schema or a sequence of migrations. For each table T, spanner-gen
+generates:
+
+  - a struct T with a field for each column, and a struct TKey for the
+    primary key;
+  - constants for the names of the table and its columns, and a function
+    TColumns that returns the column names;
+  - methods Insert, InsertOrUpdate, Replace, Update and Delete that return
+    mutations for a row;
+  - functions ReadT and ReadTRows that read rows by key, and a function
+    ReadTUsingI for each index I of the table.
+
+Generated columns are read but not written. The generated code reads columns
+with Row.Columns, without reflection.
+
+spanner-gen is typically run with go generate:
+
+	//go:generate go run cloud.google.com/go/spanner/cmd/spanner-gen -o schema_spanner.go schema.sql
+
+The package name defaults to the package of the go:generate directive. The
+code for one package must be generated into one file.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+var (
+	pkg = flag.String("package", os.Getenv("GOPACKAGE"), "the package name of the generated code")
+	out = flag.String("o", "", "the output file; the default is the first schema file with the suffix _spanner.go")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("spanner-gen: ")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: spanner-gen [-package name] [-o file] schema.sql...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var ddls []*spansql.DDL
+	for _, name := range flag.Args() {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ddl, err := spansql.ParseDDL(name, string(b))
+		if err != nil {
+			log.Fatal(err)
+		}
+		ddls = append(ddls, ddl)
+	}
+	var sources []string
+	for _, name := range flag.Args() {
+		sources = append(sources, filepath.ToSlash(filepath.Base(name)))
+	}
+	s, err := buildSchema(*pkg, sources, ddls)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		first := flag.Arg(0)
+		*out = strings.TrimSuffix(first, filepath.Ext(first)) + "_spanner.go"
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
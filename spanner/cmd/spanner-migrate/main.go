@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+spanner-migrate applies the numbered schema migrations in a directory to a
+Cloud Spanner database.
+
+Usage:
+
+	spanner-migrate -database projects/P/instances/I/databases/D -dir migrations [-dry-run]
+
+With -dry-run, the pending migrations are validated against an in-memory copy
+of the schema of the database, and the database is not changed.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/migrate"
+)
+
+var (
+	db       = flag.String("database", "", "the database to migrate, in the form projects/P/instances/I/databases/D")
+	dir      = flag.String("dir", ".", "the directory of the migration files")
+	table    = flag.String("table", migrate.DefaultTrackingTable, "the table that records the applied migrations")
+	batch    = flag.Int("batch", migrate.DefaultMaxStatementsPerBatch, "the maximum number of statements per schema update")
+	dryRun   = flag.Bool("dry-run", false, "validate the pending migrations without applying them")
+	showOnly = flag.Bool("pending", false, "only list the pending migrations")
+)
+
+func main() {
+	flag.Parse()
+	if *db == "" {
+		fmt.Fprintln(os.Stderr, "spanner-migrate: -database is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	migrations, err := migrate.Load(os.DirFS(*dir))
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := spanner.NewClient(ctx, *db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	admin, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer admin.Close()
+
+	r := migrate.NewRunner(client, admin, migrate.Config{
+		TrackingTable:         *table,
+		MaxStatementsPerBatch: *batch,
+		Logf:                  log.Printf,
+	})
+	var (
+		ms   []*migrate.Migration
+		verb string
+	)
+	switch {
+	case *showOnly:
+		ms, err = r.Pending(ctx, migrations)
+		verb = "pending"
+	case *dryRun:
+		ms, err = r.DryRun(ctx, migrations)
+		verb = "validated"
+	default:
+		ms, err = r.Run(ctx, migrations)
+		verb = "applied"
+	}
+	for _, m := range ms {
+		fmt.Printf("%s %d %s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(ms) == 0 {
+		fmt.Println("the database is up to date")
+	}
+}
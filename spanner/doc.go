@@ -353,5 +353,11 @@ This client has been instrumented to use OpenCensus tracing
 (http://opencensus.io). To enable tracing, see "Enabling Tracing for a Program"
 at https://godoc.org/go.opencensus.io/trace. OpenCensus tracing requires Go 1.8
 or higher.
+
+To use OpenTelemetry instead, set the environment variable
+GOOGLE_API_GO_EXPERIMENTAL_TELEMETRY_PLATFORM_TRACING to "opentelemetry". The
+client then creates spans with the global TracerProvider, and records the
+session pool metrics with the global MeterProvider, or with the MeterProvider
+set in ClientConfig.OpenTelemetryMeterProvider.
 */
 package spanner // import "cloud.google.com/go/spanner"
@@ -0,0 +1,416 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+// This file implements the subset of Avro object container files that is
+// needed for the tables of a database: records whose fields are nullable
+// booleans, longs, doubles, strings, bytes, or arrays of those, without
+// compression. See https://avro.apache.org/docs/1.11.1/specification/.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// avroBlockSize is the size after which a block of records is written.
+const avroBlockSize = 1 << 20
+
+// avroType returns the Avro type of the values of a column of Spanner type
+// t, which is a union with null.
+func avroType(t *sppb.Type) interface{} {
+	var base interface{}
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		base = "boolean"
+	case sppb.TypeCode_INT64:
+		base = "long"
+	case sppb.TypeCode_FLOAT64:
+		base = "double"
+	case sppb.TypeCode_BYTES:
+		base = "bytes"
+	case sppb.TypeCode_ARRAY:
+		base = map[string]interface{}{"type": "array", "items": avroType(t.ArrayElementType)}
+	default:
+		// STRING, TIMESTAMP, DATE, NUMERIC and JSON values are strings.
+		base = "string"
+	}
+	return []interface{}{"null", base}
+}
+
+// avroSchema returns the Avro schema of the rows of a table. The Spanner
+// type of each column is recorded in the sqlType attribute of its field.
+func avroSchema(t *Table) ([]byte, error) {
+	fields := make([]map[string]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		fields[i] = map[string]interface{}{
+			"name":    c.Name,
+			"type":    avroType(c.spannerType),
+			"sqlType": c.Type,
+		}
+	}
+	return json.Marshal(map[string]interface{}{
+		"type":      "record",
+		"name":      t.Name,
+		"namespace": "spannerexport",
+		"fields":    fields,
+	})
+}
+
+// avroWriter writes an Avro object container file.
+type avroWriter struct {
+	w     io.Writer
+	types []*sppb.Type
+	sync  [16]byte
+	block bytes.Buffer
+	count int64
+}
+
+func newAvroWriter(w io.Writer, t *Table) (*avroWriter, error) {
+	schema, err := avroSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	aw := &avroWriter{w: w}
+	for _, c := range t.Columns {
+		aw.types = append(aw.types, c.spannerType)
+	}
+	if _, err := rand.Read(aw.sync[:]); err != nil {
+		return nil, err
+	}
+	var hdr bytes.Buffer
+	hdr.Write(avroMagic)
+	writeLong(&hdr, 2)
+	writeBytes(&hdr, []byte("avro.schema"))
+	writeBytes(&hdr, schema)
+	writeBytes(&hdr, []byte("avro.codec"))
+	writeBytes(&hdr, []byte("null"))
+	writeLong(&hdr, 0)
+	hdr.Write(aw.sync[:])
+	if _, err := w.Write(hdr.Bytes()); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// write writes a row, whose values are given in the form returned by
+// fromProto.
+func (aw *avroWriter) write(row []interface{}) error {
+	for i, v := range row {
+		if err := writeAvroValue(&aw.block, aw.types[i], v); err != nil {
+			return err
+		}
+	}
+	aw.count++
+	if aw.block.Len() >= avroBlockSize {
+		return aw.flush()
+	}
+	return nil
+}
+
+func (aw *avroWriter) flush() error {
+	if aw.count == 0 {
+		return nil
+	}
+	var hdr bytes.Buffer
+	writeLong(&hdr, aw.count)
+	writeLong(&hdr, int64(aw.block.Len()))
+	if _, err := aw.w.Write(hdr.Bytes()); err != nil {
+		return err
+	}
+	if _, err := aw.w.Write(aw.block.Bytes()); err != nil {
+		return err
+	}
+	if _, err := aw.w.Write(aw.sync[:]); err != nil {
+		return err
+	}
+	aw.block.Reset()
+	aw.count = 0
+	return nil
+}
+
+// close writes the last block of records.
+func (aw *avroWriter) close() error {
+	return aw.flush()
+}
+
+func writeAvroValue(b *bytes.Buffer, t *sppb.Type, v interface{}) error {
+	if v == nil {
+		writeLong(b, 0)
+		return nil
+	}
+	writeLong(b, 1)
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		if v.(bool) {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+	case sppb.TypeCode_INT64:
+		writeLong(b, v.(int64))
+	case sppb.TypeCode_FLOAT64:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v.(float64)))
+		b.Write(buf[:])
+	case sppb.TypeCode_BYTES:
+		writeBytes(b, v.([]byte))
+	case sppb.TypeCode_ARRAY:
+		elems := v.([]interface{})
+		if len(elems) > 0 {
+			writeLong(b, int64(len(elems)))
+			for _, e := range elems {
+				if err := writeAvroValue(b, t.ArrayElementType, e); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(b, 0)
+	default:
+		writeBytes(b, []byte(v.(string)))
+	}
+	return nil
+}
+
+func writeLong(b *bytes.Buffer, n int64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutVarint(buf[:], n)])
+}
+
+func writeBytes(b *bytes.Buffer, p []byte) {
+	writeLong(b, int64(len(p)))
+	b.Write(p)
+}
+
+// avroReader reads the rows of an Avro object container file that was
+// written by avroWriter.
+type avroReader struct {
+	r     *bufio.Reader
+	types []*sppb.Type
+	sync  [16]byte
+	// remaining is the number of records left in the current block.
+	remaining int64
+}
+
+func newAvroReader(r io.Reader, t *Table) (*avroReader, error) {
+	ar := &avroReader{r: bufio.NewReader(r)}
+	for _, c := range t.Columns {
+		ar.types = append(ar.types, c.spannerType)
+	}
+	magic := make([]byte, len(avroMagic))
+	if _, err := io.ReadFull(ar.r, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, avroMagic) {
+		return nil, errors.New("dump: not an Avro object container file")
+	}
+	meta := make(map[string][]byte)
+	for {
+		n, err := ar.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		if n < 0 {
+			// A negative count is followed by the size of the block.
+			n = -n
+			if _, err := ar.readLong(); err != nil {
+				return nil, err
+			}
+		}
+		for ; n > 0; n-- {
+			k, err := ar.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			v, err := ar.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			meta[string(k)] = v
+		}
+	}
+	if codec := string(meta["avro.codec"]); codec != "" && codec != "null" {
+		return nil, fmt.Errorf("dump: unsupported Avro codec %q", codec)
+	}
+	if err := checkAvroSchema(meta["avro.schema"], t); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(ar.r, ar.sync[:]); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// checkAvroSchema checks that the fields of an Avro schema are the columns
+// of t.
+func checkAvroSchema(schema []byte, t *Table) error {
+	var s struct {
+		Fields []struct {
+			Name    string `json:"name"`
+			SQLType string `json:"sqlType"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return fmt.Errorf("dump: invalid Avro schema: %v", err)
+	}
+	if len(s.Fields) != len(t.Columns) {
+		return fmt.Errorf("dump: Avro schema has %d fields, table %s has %d columns", len(s.Fields), t.Name, len(t.Columns))
+	}
+	for i, f := range s.Fields {
+		if c := t.Columns[i]; f.Name != c.Name || f.SQLType != c.Type {
+			return fmt.Errorf("dump: Avro field %s %s does not match column %s %s", f.Name, f.SQLType, c.Name, c.Type)
+		}
+	}
+	return nil
+}
+
+// read returns the next row, or io.EOF at the end of the file.
+func (ar *avroReader) read() ([]interface{}, error) {
+	for ar.remaining == 0 {
+		n, err := ar.readLong()
+		if err != nil {
+			// The file ends after the sync marker of a block.
+			return nil, err
+		}
+		if _, err := ar.readLong(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		ar.remaining = n
+		if n == 0 {
+			if err := ar.readSync(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	row := make([]interface{}, len(ar.types))
+	for i, t := range ar.types {
+		v, err := ar.readValue(t)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		row[i] = v
+	}
+	ar.remaining--
+	if ar.remaining == 0 {
+		if err := ar.readSync(); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+func (ar *avroReader) readSync() error {
+	var sync [16]byte
+	if _, err := io.ReadFull(ar.r, sync[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if sync != ar.sync {
+		return errors.New("dump: invalid Avro sync marker")
+	}
+	return nil
+}
+
+func (ar *avroReader) readValue(t *sppb.Type) (interface{}, error) {
+	branch, err := ar.readLong()
+	if err != nil {
+		return nil, err
+	}
+	switch branch {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("dump: invalid Avro union branch %d", branch)
+	}
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		b, err := ar.r.ReadByte()
+		return b != 0, err
+	case sppb.TypeCode_INT64:
+		return ar.readLong()
+	case sppb.TypeCode_FLOAT64:
+		var buf [8]byte
+		if _, err := io.ReadFull(ar.r, buf[:]); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), nil
+	case sppb.TypeCode_BYTES:
+		return ar.readBytes()
+	case sppb.TypeCode_ARRAY:
+		elems := []interface{}{}
+		for {
+			n, err := ar.readLong()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return elems, nil
+			}
+			if n < 0 {
+				n = -n
+				if _, err := ar.readLong(); err != nil {
+					return nil, err
+				}
+			}
+			for ; n > 0; n-- {
+				e, err := ar.readValue(t.ArrayElementType)
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, e)
+			}
+		}
+	default:
+		b, err := ar.readBytes()
+		return string(b), err
+	}
+}
+
+func (ar *avroReader) readLong() (int64, error) {
+	return binary.ReadVarint(ar.r)
+}
+
+func (ar *avroReader) readBytes() ([]byte, error) {
+	n, err := ar.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("dump: invalid Avro length %d", n)
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(ar.r, b)
+	return b, err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
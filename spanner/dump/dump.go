@@ -0,0 +1,339 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package dump exports a Cloud Spanner database to files, and imports it again.
+
+Export reads every table of a database at one timestamp, using the
+partitions of a BatchReadOnlyTransaction to read in parallel, and writes one
+file per partition in Avro or CSV format. A manifest file, manifest.json,
+records the schema of the database, the read timestamp and the files of each
+table:
+
+	m, err := dump.Export(ctx, client, adminClient, dump.DirStore("/tmp/db"), dump.ExportOptions{})
+	if err != nil {
+		// TODO: Handle error.
+	}
+	fmt.Println("exported at", m.ReadTimestamp)
+
+Import creates the schema of the manifest in an empty database, and loads the
+rows of the tables in interleave order, so that parent rows exist before their
+child rows:
+
+	err := dump.Import(ctx, client, adminClient, dump.DirStore("/tmp/db"), dump.ImportOptions{})
+
+The files can be stored in a local directory with DirStore, or in a bucket,
+such as a Cloud Storage bucket, with BucketStore.
+
+# File formats
+
+Avro files are object container files without compression. The rows of a
+table are records whose fields are the columns of the table. BOOL, INT64,
+FLOAT64 and BYTES columns are stored as boolean, long, double and bytes
+values, and other columns as strings. ARRAY columns are stored as arrays. All
+values are nullable. The Spanner type of a column is recorded in the sqlType
+attribute of its field.
+
+CSV files have a header row with the names of the columns. NULL values are
+written as \N. BYTES values are base64-encoded, and ARRAY values are written
+as JSON arrays of the values in the format of the Cloud Spanner API.
+*/
+package dump // import "cloud.google.com/go/spanner/dump"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// ManifestName is the name of the manifest file of an export.
+const ManifestName = "manifest.json"
+
+// Format is the file format of an export.
+type Format string
+
+const (
+	// Avro is the Avro object container file format.
+	Avro Format = "avro"
+	// CSV is the comma-separated values format.
+	CSV Format = "csv"
+)
+
+// Manifest describes an export.
+type Manifest struct {
+	// ReadTimestamp is the timestamp at which the tables were read.
+	ReadTimestamp time.Time `json:"readTimestamp"`
+	// Format is the format of the files of the tables.
+	Format Format `json:"format"`
+	// Schema is the schema of the database, as DDL statements.
+	Schema []string `json:"schema"`
+	// Tables are the exported tables, with parent tables before their
+	// interleaved child tables.
+	Tables []*Table `json:"tables"`
+}
+
+// Table describes an exported table.
+type Table struct {
+	// Name is the name of the table.
+	Name string `json:"name"`
+	// Parent is the name of the table that the table is interleaved in, if
+	// any.
+	Parent string `json:"parent,omitempty"`
+	// Columns are the exported columns of the table. Generated columns are
+	// not exported.
+	Columns []*Column `json:"columns"`
+	// Files are the names of the files of the table.
+	Files []string `json:"files"`
+	// RowCount is the number of exported rows.
+	RowCount int64 `json:"rowCount"`
+
+	// dependencies are the tables whose rows must be imported before the
+	// rows of this table.
+	dependencies []string
+}
+
+// Column describes a column of an exported table.
+type Column struct {
+	// Name is the name of the column.
+	Name string `json:"name"`
+	// Type is the Spanner type of the column, such as STRING(MAX).
+	Type string `json:"type"`
+
+	spannerType *sppb.Type
+}
+
+func (t *Table) columnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// tablesFromSchema returns the tables of a schema, in the order of the
+// schema, which creates parent tables before their child tables.
+func tablesFromSchema(schema []string) ([]*Table, error) {
+	var tables []*Table
+	for _, s := range schema {
+		stmt, err := spansql.ParseDDLStmt(s)
+		if err != nil {
+			return nil, fmt.Errorf("dump: parsing schema: %v", err)
+		}
+		ct, ok := stmt.(*spansql.CreateTable)
+		if !ok {
+			continue
+		}
+		t := &Table{Name: string(ct.Name)}
+		if ct.Interleave != nil {
+			t.Parent = string(ct.Interleave.Parent)
+			t.dependencies = append(t.dependencies, t.Parent)
+		}
+		for _, tc := range ct.Constraints {
+			if fk, ok := tc.Constraint.(spansql.ForeignKey); ok && !strings.EqualFold(string(fk.RefTable), t.Name) {
+				t.dependencies = append(t.dependencies, string(fk.RefTable))
+			}
+		}
+		for _, cd := range ct.Columns {
+			if cd.Generated != nil {
+				continue
+			}
+			t.Columns = append(t.Columns, &Column{Name: string(cd.Name), Type: cd.Type.SQL(), spannerType: spannerType(cd.Type)})
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// resolve sets the Spanner types of the columns of the tables of m from the
+// schema of m, and checks that they match.
+func (m *Manifest) resolve() error {
+	tables, err := tablesFromSchema(m.Schema)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*Table, len(tables))
+	for _, t := range tables {
+		byName[strings.ToLower(t.Name)] = t
+	}
+	for _, t := range m.Tables {
+		st, ok := byName[strings.ToLower(t.Name)]
+		if !ok {
+			return fmt.Errorf("dump: table %s is not in the schema", t.Name)
+		}
+		t.dependencies = st.dependencies
+		cols := make(map[string]*Column, len(st.Columns))
+		for _, c := range st.Columns {
+			cols[c.Name] = c
+		}
+		for _, c := range t.Columns {
+			sc, ok := cols[c.Name]
+			if !ok || sc.Type != c.Type {
+				return fmt.Errorf("dump: column %s %s of table %s does not match the schema", c.Name, c.Type, t.Name)
+			}
+			c.spannerType = sc.spannerType
+		}
+	}
+	return nil
+}
+
+// spannerType returns the type of the API for a spansql type.
+func spannerType(t spansql.Type) *sppb.Type {
+	var code sppb.TypeCode
+	switch t.Base {
+	case spansql.Bool:
+		code = sppb.TypeCode_BOOL
+	case spansql.Int64:
+		code = sppb.TypeCode_INT64
+	case spansql.Float64:
+		code = sppb.TypeCode_FLOAT64
+	case spansql.Numeric:
+		code = sppb.TypeCode_NUMERIC
+	case spansql.String:
+		code = sppb.TypeCode_STRING
+	case spansql.Bytes:
+		code = sppb.TypeCode_BYTES
+	case spansql.Date:
+		code = sppb.TypeCode_DATE
+	case spansql.Timestamp:
+		code = sppb.TypeCode_TIMESTAMP
+	case spansql.JSON:
+		code = sppb.TypeCode_JSON
+	}
+	if t.Array {
+		return &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: code}}
+	}
+	return &sppb.Type{Code: code}
+}
+
+// Store stores the files of an export.
+type Store interface {
+	// Create creates the file with the given name, and returns a writer for
+	// its contents. The file is complete when the writer is closed.
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+	// Open returns a reader for the file with the given name.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// DirStore returns a Store for the files in a local directory, which is
+// created if it does not exist.
+func DirStore(dir string) Store {
+	return dirStore(dir)
+}
+
+type dirStore string
+
+func (d dirStore) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+func (d dirStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+// A Bucket is a bucket of objects, such as a Cloud Storage bucket. The
+// package does not depend on the Cloud Storage client; a
+// *storage.BucketHandle can be adapted with
+//
+//	type gcsBucket struct{ *storage.BucketHandle }
+//
+//	func (b gcsBucket) NewWriter(ctx context.Context, object string) io.WriteCloser {
+//		return b.Object(object).NewWriter(ctx)
+//	}
+//
+//	func (b gcsBucket) NewReader(ctx context.Context, object string) (io.ReadCloser, error) {
+//		return b.Object(object).NewReader(ctx)
+//	}
+type Bucket interface {
+	// NewWriter returns a writer for the contents of an object. The object
+	// is complete when the writer is closed.
+	NewWriter(ctx context.Context, object string) io.WriteCloser
+	// NewReader returns a reader for the contents of an object.
+	NewReader(ctx context.Context, object string) (io.ReadCloser, error)
+}
+
+// BucketStore returns a Store for the objects in a bucket whose names start
+// with prefix. The prefix is typically a "directory" that ends with a slash.
+func BucketStore(bkt Bucket, prefix string) Store {
+	return &bucketStore{bkt: bkt, prefix: prefix}
+}
+
+type bucketStore struct {
+	bkt    Bucket
+	prefix string
+}
+
+func (b *bucketStore) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return b.bkt.NewWriter(ctx, b.prefix+name), nil
+}
+
+func (b *bucketStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.bkt.NewReader(ctx, b.prefix+name)
+}
+
+// ReadManifest reads the manifest of the export in store.
+func ReadManifest(ctx context.Context, store Store) (*Manifest, error) {
+	r, err := store.Open(ctx, ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	m := &Manifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("dump: reading manifest: %v", err)
+	}
+	switch m.Format {
+	case Avro, CSV:
+	default:
+		return nil, fmt.Errorf("dump: unknown format %q", m.Format)
+	}
+	if err := m.resolve(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func writeManifest(ctx context.Context, store Store, m *Manifest) (err error) {
+	w, err := store.Create(ctx, ManifestName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// fileName returns the name of the file of partition i of a table.
+func fileName(table string, i int, format Format) string {
+	return path.Clean(fmt.Sprintf("%s-%05d.%s", table, i, format))
+}
+
+var errFormat = errors.New("dump: format must be Avro or CSV")
@@ -0,0 +1,321 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/spannertest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var testSchema = []string{
+	`CREATE TABLE Singers (
+		SingerId INT64 NOT NULL,
+		Name STRING(MAX),
+		Active BOOL,
+		Rating FLOAT64,
+		Photo BYTES(MAX),
+		Born DATE,
+		Tags ARRAY<STRING(MAX)>,
+	) PRIMARY KEY (SingerId)`,
+	`CREATE TABLE Albums (
+		SingerId INT64 NOT NULL,
+		AlbumId INT64 NOT NULL,
+		Title STRING(MAX),
+		Scores ARRAY<INT64>,
+	) PRIMARY KEY (SingerId, AlbumId),
+	INTERLEAVE IN PARENT Singers ON DELETE CASCADE`,
+	`CREATE INDEX AlbumsByTitle ON Albums(Title)`,
+}
+
+func testTable(t *testing.T) *Table {
+	tables, err := tablesFromSchema(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tables[0]
+}
+
+func TestRowFormats(t *testing.T) {
+	table := testTable(t)
+	rows := [][]*structpb.Value{
+		{
+			structpb.NewStringValue("1"),
+			structpb.NewStringValue("Alice, \"the\" singer"),
+			structpb.NewBoolValue(true),
+			structpb.NewNumberValue(4.5),
+			structpb.NewStringValue("AAEC"),
+			structpb.NewStringValue("1970-01-02"),
+			structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("a"), structpb.NewNullValue()}}),
+		},
+		{
+			structpb.NewStringValue("-2"),
+			structpb.NewNullValue(),
+			structpb.NewBoolValue(false),
+			floatProto(math.Inf(-1)),
+			structpb.NewNullValue(),
+			structpb.NewNullValue(),
+			structpb.NewListValue(&structpb.ListValue{}),
+		},
+	}
+	for _, format := range []Format{Avro, CSV} {
+		var buf bytes.Buffer
+		w, err := newRowWriter(&buf, table, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err := w.write(row); err != nil {
+				t.Fatalf("%s: write: %v", format, err)
+			}
+		}
+		if err := w.close(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := newRowReader(&buf, table, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		var got [][]*structpb.Value
+		for {
+			row, err := r.read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: read: %v", format, err)
+			}
+			got = append(got, row)
+		}
+		if diff := cmp.Diff(rows, got, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: rows differ (-want +got):\n%s", format, diff)
+		}
+	}
+}
+
+func TestAvroSchemaMismatch(t *testing.T) {
+	table := testTable(t)
+	var buf bytes.Buffer
+	w, err := newAvroWriter(&buf, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	other := *table
+	other.Columns = table.Columns[:2]
+	if _, err := newAvroReader(&buf, &other); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func TestLoadOrder(t *testing.T) {
+	tables := []*Table{
+		{Name: "C", dependencies: []string{"B", "Other"}},
+		{Name: "B", dependencies: []string{"a"}},
+		{Name: "A"},
+	}
+	sorted, err := loadOrder(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, t := range sorted {
+		got = append(got, t.Name)
+	}
+	if want := "[A B C]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	tables[2].dependencies = []string{"C"}
+	if _, err := loadOrder(tables); err == nil {
+		t.Error("cycle: got nil, want error")
+	}
+}
+
+type testDB struct {
+	client *spanner.Client
+	admin  *database.DatabaseAdminClient
+}
+
+func newTestDB(t *testing.T) *testDB {
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLogger(t.Logf)
+	ctx := context.Background()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := database.NewDatabaseAdminClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		admin.Close()
+		conn.Close()
+		srv.Close()
+	})
+	return &testDB{client: client, admin: admin}
+}
+
+func (db *testDB) rows(t *testing.T, table string, columns ...string) []string {
+	var rows []string
+	err := db.client.Single().Read(context.Background(), table, spanner.AllKeys(), columns).Do(func(r *spanner.Row) error {
+		rows = append(rows, fmt.Sprint(r))
+		return nil
+	})
+	if err != nil && err != iterator.Done {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := newTestDB(t)
+	if err := updateDDL(ctx, src.client, src.admin, testSchema); err != nil {
+		t.Fatal(err)
+	}
+	var ms []*spanner.Mutation
+	for i := int64(1); i <= 20; i++ {
+		ms = append(ms, spanner.Insert("Singers",
+			[]string{"SingerId", "Name", "Active", "Rating", "Photo", "Born", "Tags"},
+			[]interface{}{i, fmt.Sprintf("Singer %d", i), i%2 == 0, float64(i) / 3, []byte{byte(i)}, civil.Date{Year: 2000, Month: 1, Day: int(i)}, []string{"x", "y"}}))
+		ms = append(ms, spanner.Insert("Albums",
+			[]string{"SingerId", "AlbumId", "Title", "Scores"},
+			[]interface{}{i, i * 10, spanner.NullString{}, []int64{i, 2 * i}}))
+	}
+	if _, err := src.client.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{Avro, CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			store := DirStore(t.TempDir())
+			m, err := Export(ctx, src.client, src.admin, store, ExportOptions{Format: format})
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if len(m.Tables) != 2 || m.Tables[0].RowCount != 20 || m.Tables[1].RowCount != 20 {
+				t.Fatalf("got manifest tables %+v, want 20 rows in each of 2 tables", m.Tables)
+			}
+
+			dst := newTestDB(t)
+			if err := Import(ctx, dst.client, dst.admin, store, ImportOptions{BatchSize: 7}); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			for _, table := range m.Tables {
+				cols := table.columnNames()
+				if diff := cmp.Diff(src.rows(t, table.Name, cols...), dst.rows(t, table.Name, cols...)); diff != "" {
+					t.Errorf("table %s differs (-want +got):\n%s", table.Name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestExportTables(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	if err := updateDDL(ctx, db.client, db.admin, testSchema); err != nil {
+		t.Fatal(err)
+	}
+	// The fake server has no read timestamp before the first commit.
+	if _, err := db.client.Apply(ctx, []*spanner.Mutation{spanner.Insert("Singers", []string{"SingerId"}, []interface{}{1})}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Export(ctx, db.client, db.admin, DirStore(t.TempDir()), ExportOptions{Tables: []string{"albums"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tables) != 1 || m.Tables[0].Name != "Albums" || len(m.Schema) == 0 {
+		t.Errorf("got %+v, want only table Albums and the whole schema", m)
+	}
+	if _, err := Export(ctx, db.client, db.admin, DirStore(t.TempDir()), ExportOptions{Tables: []string{"Unknown"}}); err == nil {
+		t.Error("unknown table: got nil, want error")
+	}
+}
+
+// memBucket is a Bucket in memory.
+type memBucket map[string][]byte
+
+type memWriter struct {
+	bytes.Buffer
+	b    memBucket
+	name string
+}
+
+func (w *memWriter) Close() error {
+	w.b[w.name] = w.Bytes()
+	return nil
+}
+
+func (b memBucket) NewWriter(ctx context.Context, object string) io.WriteCloser {
+	return &memWriter{b: b, name: object}
+}
+
+func (b memBucket) NewReader(ctx context.Context, object string) (io.ReadCloser, error) {
+	data, ok := b[object]
+	if !ok {
+		return nil, fmt.Errorf("no object %q", object)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestBucketStore(t *testing.T) {
+	ctx := context.Background()
+	bkt := memBucket{}
+	store := BucketStore(bkt, "exports/1/")
+	m := &Manifest{Format: CSV, Schema: testSchema}
+	if err := writeManifest(ctx, store, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bkt["exports/1/"+ManifestName]; !ok {
+		t.Fatalf("got objects %v, want the manifest under the prefix", bkt)
+	}
+	got, err := ReadManifest(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m.Schema, got.Schema); diff != "" {
+		t.Errorf("schema differs (-want +got):\n%s", diff)
+	}
+	if _, err := ReadManifest(ctx, BucketStore(bkt, "exports/2/")); err == nil {
+		t.Error("missing manifest: got nil, want error")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DefaultParallelism is the default number of partitions that Export reads
+// at the same time.
+const DefaultParallelism = 4
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// Format is the format of the files. The default is Avro.
+	Format Format
+	// TimestampBound is the timestamp at which the tables are read. It must
+	// be a strong or exact staleness bound. The default is a strong read.
+	TimestampBound spanner.TimestampBound
+	// Tables are the names of the tables to export. The default is all
+	// tables. The manifest always contains the whole schema.
+	Tables []string
+	// Parallelism is the number of partitions that are read at the same
+	// time. The default is DefaultParallelism.
+	Parallelism int
+}
+
+// Export exports the tables of the database of client to store, and returns
+// the manifest of the export, which is also written to store. The schema of
+// the database is read with admin.
+//
+// The tables are read in one BatchReadOnlyTransaction, so that the export is
+// consistent. Each partition of a table is written to its own file. If the
+// server does not support partitioned reads, each table is read with a
+// single read.
+func Export(ctx context.Context, client *spanner.Client, admin *database.DatabaseAdminClient, store Store, opts ExportOptions) (*Manifest, error) {
+	if opts.Format == "" {
+		opts.Format = Avro
+	}
+	if opts.Format != Avro && opts.Format != CSV {
+		return nil, errFormat
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = DefaultParallelism
+	}
+	resp, err := admin.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: client.DatabaseName()})
+	if err != nil {
+		return nil, err
+	}
+	tables, err := tablesFromSchema(resp.Statements)
+	if err != nil {
+		return nil, err
+	}
+	if tables, err = selectTables(tables, opts.Tables); err != nil {
+		return nil, err
+	}
+	m := &Manifest{Format: opts.Format, Schema: resp.Statements, Tables: tables}
+
+	txn, err := client.BatchReadOnlyTransaction(ctx, opts.TimestampBound)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Cleanup(ctx)
+	defer txn.Close()
+	if m.ReadTimestamp, err = txn.Timestamp(); err != nil {
+		return nil, err
+	}
+
+	// Read at most opts.Parallelism partitions at the same time, and stop
+	// at the first error.
+	gctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, opts.Parallelism)
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, t := range tables {
+		parts, err := txn.PartitionRead(ctx, t.Name, spanner.AllKeys(), t.columnNames(), spanner.PartitionOptions{})
+		if err != nil && spanner.ErrCode(err) != codes.Unimplemented {
+			cancel()
+			wg.Wait()
+			return nil, fmt.Errorf("dump: partitioning table %s: %w", t.Name, err)
+		}
+		if err != nil {
+			// Read the whole table with one read.
+			parts = []*spanner.Partition{nil}
+		}
+		t.Files = make([]string, len(parts))
+		for i, p := range parts {
+			t, i, p := t, i, p
+			t.Files[i] = fileName(t.Name, i, opts.Format)
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				var iter *spanner.RowIterator
+				if p != nil {
+					iter = txn.Execute(gctx, p)
+				} else {
+					iter = txn.Read(gctx, t.Name, spanner.AllKeys(), t.columnNames())
+				}
+				n, err := exportRows(gctx, store, t, t.Files[i], opts.Format, iter)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("dump: exporting table %s: %w", t.Name, err)
+						cancel()
+					})
+					return
+				}
+				atomic.AddInt64(&t.RowCount, n)
+			}()
+		}
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := writeManifest(ctx, store, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// selectTables returns the tables with the given names, or all tables if
+// names is empty.
+func selectTables(tables []*Table, names []string) ([]*Table, error) {
+	if len(names) == 0 {
+		return tables, nil
+	}
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		want[strings.ToLower(n)] = true
+	}
+	var selected []*Table
+	for _, t := range tables {
+		if want[strings.ToLower(t.Name)] {
+			selected = append(selected, t)
+			delete(want, strings.ToLower(t.Name))
+		}
+	}
+	for n := range want {
+		return nil, fmt.Errorf("dump: table %s not found", n)
+	}
+	return selected, nil
+}
+
+// exportRows writes the rows of iter to a new file, and returns the number
+// of rows.
+func exportRows(ctx context.Context, store Store, t *Table, name string, format Format, iter *spanner.RowIterator) (n int64, err error) {
+	defer iter.Stop()
+	f, err := store.Create(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w, err := newRowWriter(f, t, format)
+	if err != nil {
+		return 0, err
+	}
+	values := make([]*structpb.Value, len(t.Columns))
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		for i := range values {
+			var v spanner.GenericColumnValue
+			if err := row.Column(i, &v); err != nil {
+				return n, err
+			}
+			values[i] = v.Value
+		}
+		if err := w.write(values); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, w.close()
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// DefaultBatchSize is the default number of rows that Import writes with one
+// Apply.
+const DefaultBatchSize = 500
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// BatchSize is the number of rows that are written with one Apply. The
+	// default is DefaultBatchSize. The number of mutated cells of a batch
+	// must not exceed the limits of Cloud Spanner.
+	BatchSize int
+	// SkipSchema skips the creation of the schema, for a database that
+	// already has the schema of the manifest.
+	SkipSchema bool
+}
+
+// Import imports the export in store into the database of client, which
+// must be empty unless opts.SkipSchema is set. The schema is created with
+// admin.
+//
+// The tables of the schema are created first. Then the rows of the tables
+// are inserted in batches, with the rows of parent tables and of tables that
+// are referenced by foreign keys before the rows of the tables that refer to
+// them. Finally the other statements of the schema, such as CREATE INDEX,
+// are applied, so that indexes are built after the data is loaded.
+func Import(ctx context.Context, client *spanner.Client, admin *database.DatabaseAdminClient, store Store, opts ImportOptions) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	m, err := ReadManifest(ctx, store)
+	if err != nil {
+		return err
+	}
+	var tableDDL, otherDDL []string
+	for _, s := range m.Schema {
+		stmt, err := spansql.ParseDDLStmt(s)
+		if err != nil {
+			return fmt.Errorf("dump: parsing schema: %v", err)
+		}
+		if _, ok := stmt.(*spansql.CreateTable); ok {
+			tableDDL = append(tableDDL, s)
+		} else {
+			otherDDL = append(otherDDL, s)
+		}
+	}
+	if !opts.SkipSchema {
+		if err := updateDDL(ctx, client, admin, tableDDL); err != nil {
+			return fmt.Errorf("dump: creating tables: %w", err)
+		}
+	}
+	tables, err := loadOrder(m.Tables)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		for _, name := range t.Files {
+			if err := importFile(ctx, client, store, t, name, m.Format, opts.BatchSize); err != nil {
+				return fmt.Errorf("dump: importing %s into table %s: %w", name, t.Name, err)
+			}
+		}
+	}
+	if !opts.SkipSchema {
+		if err := updateDDL(ctx, client, admin, otherDDL); err != nil {
+			return fmt.Errorf("dump: applying schema: %w", err)
+		}
+	}
+	return nil
+}
+
+func updateDDL(ctx context.Context, client *spanner.Client, admin *database.DatabaseAdminClient, stmts []string) error {
+	if len(stmts) == 0 {
+		return nil
+	}
+	op, err := admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   client.DatabaseName(),
+		Statements: stmts,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// loadOrder sorts tables so that each table comes after the tables that it
+// depends on. Dependencies on tables that are not in tables are ignored.
+func loadOrder(tables []*Table) ([]*Table, error) {
+	byName := make(map[string]*Table, len(tables))
+	for _, t := range tables {
+		byName[strings.ToLower(t.Name)] = t
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*Table]int)
+	var sorted []*Table
+	var visit func(t *Table) error
+	visit = func(t *Table) error {
+		switch state[t] {
+		case visiting:
+			return fmt.Errorf("dump: cyclic dependency on table %s", t.Name)
+		case done:
+			return nil
+		}
+		state[t] = visiting
+		for _, d := range t.dependencies {
+			if dt, ok := byName[strings.ToLower(d)]; ok {
+				if err := visit(dt); err != nil {
+					return err
+				}
+			}
+		}
+		state[t] = done
+		sorted = append(sorted, t)
+		return nil
+	}
+	for _, t := range tables {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// importFile inserts the rows of a file into table t, batchSize rows at a
+// time.
+func importFile(ctx context.Context, client *spanner.Client, store Store, t *Table, name string, format Format, batchSize int) error {
+	f, err := store.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := newRowReader(f, t, format)
+	if err != nil {
+		return err
+	}
+	cols := t.columnNames()
+	var ms []*spanner.Mutation
+	for {
+		row, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		vals := make([]interface{}, len(row))
+		for i, v := range row {
+			vals[i] = spanner.GenericColumnValue{Type: t.Columns[i].spannerType, Value: v}
+		}
+		ms = append(ms, spanner.Insert(t.Name, cols, vals))
+		if len(ms) == batchSize {
+			if _, err := client.Apply(ctx, ms); err != nil {
+				return err
+			}
+			ms = nil
+		}
+	}
+	if len(ms) > 0 {
+		if _, err := client.Apply(ctx, ms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
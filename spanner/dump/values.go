@@ -0,0 +1,333 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// csvNull is the representation of NULL values in CSV files.
+const csvNull = `\N`
+
+// fromProto converts a value in the format of the Cloud Spanner API to the Go
+// value that is stored in Avro files: nil, bool, int64, float64, string,
+// []byte or []interface{}.
+func fromProto(t *sppb.Type, v *structpb.Value) (interface{}, error) {
+	if _, ok := v.GetKind().(*structpb.Value_NullValue); ok {
+		return nil, nil
+	}
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		if b, ok := v.GetKind().(*structpb.Value_BoolValue); ok {
+			return b.BoolValue, nil
+		}
+	case sppb.TypeCode_INT64:
+		if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			return strconv.ParseInt(s.StringValue, 10, 64)
+		}
+	case sppb.TypeCode_FLOAT64:
+		switch k := v.GetKind().(type) {
+		case *structpb.Value_NumberValue:
+			return k.NumberValue, nil
+		case *structpb.Value_StringValue:
+			return parseSpecialFloat(k.StringValue)
+		}
+	case sppb.TypeCode_BYTES:
+		if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			return base64.StdEncoding.DecodeString(s.StringValue)
+		}
+	case sppb.TypeCode_ARRAY:
+		if l, ok := v.GetKind().(*structpb.Value_ListValue); ok {
+			elems := make([]interface{}, len(l.ListValue.Values))
+			for i, e := range l.ListValue.Values {
+				ev, err := fromProto(t.ArrayElementType, e)
+				if err != nil {
+					return nil, err
+				}
+				elems[i] = ev
+			}
+			return elems, nil
+		}
+	default:
+		if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+			return s.StringValue, nil
+		}
+	}
+	return nil, fmt.Errorf("dump: invalid value %v for type %v", v, t.Code)
+}
+
+// toProto converts a value returned by fromProto back to the format of the
+// Cloud Spanner API.
+func toProto(t *sppb.Type, v interface{}) (*structpb.Value, error) {
+	if v == nil {
+		return structpb.NewNullValue(), nil
+	}
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		if b, ok := v.(bool); ok {
+			return structpb.NewBoolValue(b), nil
+		}
+	case sppb.TypeCode_INT64:
+		if n, ok := v.(int64); ok {
+			return structpb.NewStringValue(strconv.FormatInt(n, 10)), nil
+		}
+	case sppb.TypeCode_FLOAT64:
+		if f, ok := v.(float64); ok {
+			return floatProto(f), nil
+		}
+	case sppb.TypeCode_BYTES:
+		if b, ok := v.([]byte); ok {
+			return structpb.NewStringValue(base64.StdEncoding.EncodeToString(b)), nil
+		}
+	case sppb.TypeCode_ARRAY:
+		if elems, ok := v.([]interface{}); ok {
+			l := &structpb.ListValue{Values: make([]*structpb.Value, len(elems))}
+			for i, e := range elems {
+				ev, err := toProto(t.ArrayElementType, e)
+				if err != nil {
+					return nil, err
+				}
+				l.Values[i] = ev
+			}
+			return structpb.NewListValue(l), nil
+		}
+	default:
+		if s, ok := v.(string); ok {
+			return structpb.NewStringValue(s), nil
+		}
+	}
+	return nil, fmt.Errorf("dump: invalid value %v for type %v", v, t.Code)
+}
+
+// floatProto returns the API value of a FLOAT64, which encodes NaN and
+// infinities as strings.
+func floatProto(f float64) *structpb.Value {
+	switch {
+	case math.IsNaN(f):
+		return structpb.NewStringValue("NaN")
+	case math.IsInf(f, 1):
+		return structpb.NewStringValue("Infinity")
+	case math.IsInf(f, -1):
+		return structpb.NewStringValue("-Infinity")
+	}
+	return structpb.NewNumberValue(f)
+}
+
+func parseSpecialFloat(s string) (float64, error) {
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	return 0, fmt.Errorf("dump: invalid FLOAT64 value %q", s)
+}
+
+// csvField returns the CSV representation of a value in the format of the
+// Cloud Spanner API.
+func csvField(t *sppb.Type, v *structpb.Value) (string, error) {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		return csvNull, nil
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(k.BoolValue), nil
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(k.NumberValue, 'g', -1, 64), nil
+	case *structpb.Value_StringValue:
+		return k.StringValue, nil
+	case *structpb.Value_ListValue:
+		b, err := protojson.Marshal(k.ListValue)
+		return string(b), err
+	}
+	return "", fmt.Errorf("dump: invalid value %v for type %v", v, t.Code)
+}
+
+// parseCSVField parses the CSV representation of a value of type t.
+func parseCSVField(t *sppb.Type, s string) (*structpb.Value, error) {
+	if s == csvNull {
+		return structpb.NewNullValue(), nil
+	}
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("dump: invalid BOOL value %q", s)
+		}
+		return structpb.NewBoolValue(b), nil
+	case sppb.TypeCode_INT64:
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("dump: invalid INT64 value %q", s)
+		}
+	case sppb.TypeCode_FLOAT64:
+		if f, err := parseSpecialFloat(s); err == nil {
+			return floatProto(f), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("dump: invalid FLOAT64 value %q", s)
+		}
+		return structpb.NewNumberValue(f), nil
+	case sppb.TypeCode_ARRAY:
+		l := &structpb.ListValue{}
+		if err := protojson.Unmarshal([]byte(s), l); err != nil {
+			return nil, fmt.Errorf("dump: invalid ARRAY value %q: %v", s, err)
+		}
+		return structpb.NewListValue(l), nil
+	}
+	return structpb.NewStringValue(s), nil
+}
+
+// rowWriter writes the rows of a table to a file.
+type rowWriter interface {
+	write(row []*structpb.Value) error
+	close() error
+}
+
+// rowReader reads the rows of a table from a file. read returns io.EOF at
+// the end of the file.
+type rowReader interface {
+	read() ([]*structpb.Value, error)
+}
+
+func newRowWriter(w io.Writer, t *Table, format Format) (rowWriter, error) {
+	switch format {
+	case Avro:
+		aw, err := newAvroWriter(w, t)
+		if err != nil {
+			return nil, err
+		}
+		return &avroRowWriter{aw: aw}, nil
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.columnNames()); err != nil {
+			return nil, err
+		}
+		return &csvRowWriter{cw: cw, t: t}, nil
+	}
+	return nil, errFormat
+}
+
+func newRowReader(r io.Reader, t *Table, format Format) (rowReader, error) {
+	switch format {
+	case Avro:
+		ar, err := newAvroReader(r, t)
+		if err != nil {
+			return nil, err
+		}
+		return &avroRowReader{ar: ar}, nil
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(t.Columns)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		for i, name := range header {
+			if name != t.Columns[i].Name {
+				return nil, fmt.Errorf("dump: CSV column %s does not match column %s of table %s", name, t.Columns[i].Name, t.Name)
+			}
+		}
+		return &csvRowReader{cr: cr, t: t}, nil
+	}
+	return nil, errFormat
+}
+
+type avroRowWriter struct {
+	aw *avroWriter
+}
+
+func (w *avroRowWriter) write(row []*structpb.Value) error {
+	vs := make([]interface{}, len(row))
+	for i, v := range row {
+		gv, err := fromProto(w.aw.types[i], v)
+		if err != nil {
+			return err
+		}
+		vs[i] = gv
+	}
+	return w.aw.write(vs)
+}
+
+func (w *avroRowWriter) close() error { return w.aw.close() }
+
+type avroRowReader struct {
+	ar *avroReader
+}
+
+func (r *avroRowReader) read() ([]*structpb.Value, error) {
+	vs, err := r.ar.read()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]*structpb.Value, len(vs))
+	for i, v := range vs {
+		if row[i], err = toProto(r.ar.types[i], v); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+type csvRowWriter struct {
+	cw *csv.Writer
+	t  *Table
+}
+
+func (w *csvRowWriter) write(row []*structpb.Value) error {
+	fields := make([]string, len(row))
+	for i, v := range row {
+		f, err := csvField(w.t.Columns[i].spannerType, v)
+		if err != nil {
+			return err
+		}
+		fields[i] = f
+	}
+	return w.cw.Write(fields)
+}
+
+func (w *csvRowWriter) close() error {
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+type csvRowReader struct {
+	cr *csv.Reader
+	t  *Table
+}
+
+func (r *csvRowReader) read() ([]*structpb.Value, error) {
+	fields, err := r.cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]*structpb.Value, len(fields))
+	for i, f := range fields {
+		if row[i], err = parseCSVField(r.t.Columns[i].spannerType, f); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
@@ -791,3 +791,30 @@ func ExampleNewReadWriteStmtBasedTransaction() {
 		time.Sleep(delay)
 	}
 }
+
+func ExampleClient_ChangeStreamReader() {
+	ctx := context.Background()
+	client, err := spanner.NewClient(ctx, myDB)
+	if err != nil {
+		// TODO: Handle error.
+	}
+	reader, err := client.ChangeStreamReader("SingersStream", spanner.ChangeStreamOptions{
+		StartTimestamp: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		// TODO: Handle error.
+	}
+	err = reader.Read(ctx, func(ctx context.Context, rec *spanner.ChangeStreamRecord) error {
+		if rec.DataChange != nil {
+			for _, mod := range rec.DataChange.Mods {
+				fmt.Println(rec.DataChange.TableName, rec.DataChange.ModType, mod.Keys)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Store the checkpoint and pass it to ChangeStreamOptions.Checkpoint
+		// to resume reading the change stream later.
+		_ = reader.Checkpoint()
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package migrate applies numbered schema migrations to a Cloud Spanner
+database.
+
+A migration is a file of DDL statements whose name starts with its version
+number, such as 0001_create_singers.sql. Migrations are parsed with spansql,
+and applied in the order of their versions with the UpdateDatabaseDdl method
+of the database admin client. Consecutive migrations are batched into one
+UpdateDatabaseDdl operation where possible.
+
+The versions and checksums of the applied migrations are recorded in a
+tracking table in the database. A Runner refuses to apply migrations if the
+recorded history does not match the migration files, for example because an
+applied migration was changed or deleted.
+
+	migrations, err := migrate.Load(os.DirFS("migrations"))
+	if err != nil {
+		// TODO: Handle error.
+	}
+	r := migrate.NewRunner(client, adminClient, migrate.Config{})
+	applied, err := r.Run(ctx, migrations)
+	if err != nil {
+		// TODO: Handle error.
+	}
+
+DryRun validates the pending migrations against an in-memory copy of the
+schema of the database, using spannertest, without changing the database.
+*/
+package migrate // import "cloud.google.com/go/spanner/migrate"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner/spansql"
+)
+
+// Migration is a numbered set of DDL statements.
+type Migration struct {
+	// Version is the version number of the migration. Migrations are applied
+	// in ascending order of their versions.
+	Version int64
+	// Name is the name of the file of the migration.
+	Name string
+	// Statements are the DDL statements of the migration.
+	Statements []spansql.DDLStmt
+	// Source is the content of the file of the migration.
+	Source string
+}
+
+// Checksum returns a checksum of the source of the migration. Trailing
+// whitespace and leading or trailing blank lines do not change the checksum,
+// but any other change of the file does. If Source is empty, the
+// checksum is computed from the SQL of the statements instead.
+func (m *Migration) Checksum() string {
+	h := sha256.New()
+	if m.Source != "" {
+		io.WriteString(h, normalizeSource(m.Source))
+	} else {
+		for _, stmt := range m.Statements {
+			fmt.Fprintf(h, "%s;\n", stmt.SQL())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeSource removes trailing whitespace and leading and trailing blank
+// lines.
+func normalizeSource(src string) string {
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+// SQL returns the statements of the migration as SQL strings.
+func (m *Migration) SQL() []string {
+	stmts := make([]string, len(m.Statements))
+	for i, stmt := range m.Statements {
+		stmts[i] = stmt.SQL()
+	}
+	return stmts
+}
+
+// versionRegexp matches the version number at the start of the name of a
+// migration file.
+var versionRegexp = regexp.MustCompile(`^(\d+)[_.-]`)
+
+// Parse parses the migration in the file with the given name and content.
+// The name must start with the version number of the migration, followed by
+// an underscore, a dot or a hyphen.
+func Parse(name, content string) (*Migration, error) {
+	match := versionRegexp.FindStringSubmatch(path.Base(name))
+	if match == nil {
+		return nil, fmt.Errorf("migrate: %s: file name does not start with a version number", name)
+	}
+	version, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %s: invalid version number: %v", name, err)
+	}
+	ddl, err := spansql.ParseDDL(name, content)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %v", err)
+	}
+	if len(ddl.List) == 0 {
+		return nil, fmt.Errorf("migrate: %s: no statements", name)
+	}
+	return &Migration{Version: version, Name: name, Statements: ddl.List, Source: content}, nil
+}
+
+// Load parses the .sql files in the root directory of fsys as migrations,
+// and returns them sorted by version. Use os.DirFS to load the migrations in
+// a local directory.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []*Migration
+	for _, name := range names {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		m, err := Parse(name, string(content))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, m)
+	}
+	if err := sortMigrations(migrations); err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// sortMigrations sorts migrations by version, and checks that no two
+// migrations have the same version.
+func sortMigrations(migrations []*Migration) error {
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return fmt.Errorf("migrate: %s and %s have the same version %d", migrations[i-1].Name, migrations[i].Name, migrations[i].Version)
+		}
+	}
+	return nil
+}
+
+// HistoryError is returned when the migrations that are recorded in the
+// tracking table of a database do not match the migration files.
+type HistoryError struct {
+	// Version is the version of the migration that does not match.
+	Version int64
+	// Reason describes the mismatch.
+	Reason string
+}
+
+func (e *HistoryError) Error() string {
+	return fmt.Sprintf("migrate: history diverges at version %d: %s", e.Version, e.Reason)
+}
+
+// appliedMigration is a row of the tracking table.
+type appliedMigration struct {
+	Version  int64
+	Name     string
+	Checksum string
+}
+
+// pending checks that the applied migrations match migrations, which are
+// sorted by version, and returns the migrations that have not been applied.
+func pending(migrations []*Migration, applied []appliedMigration) ([]*Migration, error) {
+	byVersion := make(map[int64]*Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	var latest int64 = -1
+	done := make(map[int64]bool, len(applied))
+	for _, a := range applied {
+		m, ok := byVersion[a.Version]
+		if !ok {
+			return nil, &HistoryError{Version: a.Version, Reason: fmt.Sprintf("migration %s was applied, but there is no migration file with its version", a.Name)}
+		}
+		if a.Checksum != m.Checksum() {
+			return nil, &HistoryError{Version: a.Version, Reason: fmt.Sprintf("%s has changed since it was applied", m.Name)}
+		}
+		done[a.Version] = true
+		if a.Version > latest {
+			latest = a.Version
+		}
+	}
+	var result []*Migration
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		if m.Version < latest {
+			return nil, &HistoryError{Version: m.Version, Reason: fmt.Sprintf("%s has not been applied, but migration %d has", m.Name, latest)}
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// batches splits migrations into batches of at most maxStatements
+// statements, which are applied with one UpdateDatabaseDdl operation each. A
+// migration is never split across batches, and a migration that alters the
+// database itself is applied in a batch of its own.
+func batches(migrations []*Migration, maxStatements int) [][]*Migration {
+	var (
+		result [][]*Migration
+		batch  []*Migration
+		n      int
+	)
+	for _, m := range migrations {
+		alone := altersDatabase(m)
+		if len(batch) > 0 && (alone || n+len(m.Statements) > maxStatements || altersDatabase(batch[0])) {
+			result = append(result, batch)
+			batch, n = nil, 0
+		}
+		batch = append(batch, m)
+		n += len(m.Statements)
+	}
+	if len(batch) > 0 {
+		result = append(result, batch)
+	}
+	return result
+}
+
+func altersDatabase(m *Migration) bool {
+	for _, stmt := range m.Statements {
+		if _, ok := stmt.(*spansql.AlterDatabase); ok {
+			return true
+		}
+	}
+	return false
+}
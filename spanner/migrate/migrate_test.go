@@ -0,0 +1,272 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"cloud.google.com/go/spanner/spannertest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var testFiles = fstest.MapFS{
+	"0001_singers.sql": {Data: []byte(`
+		-- The singers.
+		CREATE TABLE Singers (
+			SingerId INT64 NOT NULL,
+			Name STRING(MAX),
+		) PRIMARY KEY (SingerId);
+		CREATE INDEX SingersByName ON Singers(Name);
+	`)},
+	"0002_albums.sql": {Data: []byte(`
+		CREATE TABLE Albums (
+			SingerId INT64 NOT NULL,
+			AlbumId INT64 NOT NULL,
+			Title STRING(MAX),
+		) PRIMARY KEY (SingerId, AlbumId),
+		INTERLEAVE IN PARENT Singers ON DELETE CASCADE;
+	`)},
+	"0010_rating.sql": {Data: []byte(`ALTER TABLE Singers ADD COLUMN Rating FLOAT64;`)},
+	"README.md":       {Data: []byte("not a migration")},
+}
+
+func TestLoad(t *testing.T) {
+	ms, err := Load(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range ms {
+		got = append(got, fmt.Sprintf("%d:%s:%d", m.Version, m.Name, len(m.Statements)))
+	}
+	if want := "[1:0001_singers.sql:2 2:0002_albums.sql:1 10:0010_rating.sql:1]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The checksum is that of the source, without trailing whitespace.
+	src := string(testFiles["0001_singers.sql"].Data)
+	for _, test := range []struct {
+		desc    string
+		content string
+		same    bool
+	}{
+		{"trailing whitespace", strings.ReplaceAll(src, ";\n", "; \t\n") + "\n\n", true},
+		{"changed comment", strings.Replace(src, "The singers.", "All singers.", 1), false},
+		{"reformatted", "CREATE TABLE Singers (SingerId INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (SingerId);\nCREATE INDEX SingersByName ON Singers (Name)", false},
+	} {
+		m, err := Parse("0001_singers.sql", test.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Checksum() == ms[0].Checksum(); got != test.same {
+			t.Errorf("%s: same checksum: got %t, want %t", test.desc, got, test.same)
+		}
+	}
+	// Without a source, the checksum is that of the statements.
+	noSource := &Migration{Version: 1, Statements: ms[0].Statements}
+	if noSource.Checksum() == ms[0].Checksum() || noSource.Checksum() != (&Migration{Statements: ms[0].Statements}).Checksum() {
+		t.Error("checksum of a migration without source does not depend on the statements only")
+	}
+
+	for _, files := range []fstest.MapFS{
+		{"singers.sql": {Data: []byte("CREATE TABLE T (A INT64) PRIMARY KEY (A)")}},
+		{"1_a.sql": {Data: []byte("CREATE TABLE T (A INT64) PRIMARY KEY (A)")}, "01_b.sql": {Data: []byte("DROP TABLE T")}},
+		{"1_a.sql": {Data: []byte("CREATE TABLE")}},
+	} {
+		if _, err := Load(files); err == nil {
+			t.Errorf("%v: got nil, want error", files)
+		}
+	}
+}
+
+func TestPending(t *testing.T) {
+	ms, err := Load(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := func(ms ...*Migration) []appliedMigration {
+		var as []appliedMigration
+		for _, m := range ms {
+			as = append(as, appliedMigration{Version: m.Version, Name: m.Name, Checksum: m.Checksum()})
+		}
+		return as
+	}
+	got, err := pending(ms, applied(ms[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != ms[1] {
+		t.Errorf("got %v, want the last two migrations", got)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		applied []appliedMigration
+	}{
+		{"deleted file", append(applied(ms...), appliedMigration{Version: 11, Name: "0011_gone.sql"})},
+		{"changed file", []appliedMigration{{Version: 1, Name: ms[0].Name, Checksum: "x"}}},
+		{"skipped migration", applied(ms[0], ms[2])},
+	} {
+		var herr *HistoryError
+		if _, err := pending(ms, test.applied); !errors.As(err, &herr) {
+			t.Errorf("%s: got %v, want a HistoryError", test.desc, err)
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	parse := func(version int64, sql string) *Migration {
+		m, err := Parse(fmt.Sprintf("%d.sql", version), sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	ms := []*Migration{
+		parse(1, "CREATE TABLE A (A INT64) PRIMARY KEY (A); CREATE TABLE B (B INT64) PRIMARY KEY (B)"),
+		parse(2, "CREATE TABLE C (C INT64) PRIMARY KEY (C)"),
+		parse(3, "CREATE TABLE D (D INT64) PRIMARY KEY (D)"),
+		parse(4, "ALTER DATABASE db SET OPTIONS (optimizer_version=2)"),
+		parse(5, "DROP TABLE A"),
+	}
+	var got []string
+	for _, b := range batches(ms, 3) {
+		var vs []int64
+		for _, m := range b {
+			vs = append(vs, m.Version)
+		}
+		got = append(got, fmt.Sprint(vs))
+	}
+	if want := "[[1 2] [3] [4] [5]]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func setupRunner(t *testing.T) (*Runner, *database.DatabaseAdminClient, func()) {
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLogger(t.Logf)
+	ctx := context.Background()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := database.NewDatabaseAdminClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(client, admin, Config{Logf: t.Logf})
+	return r, admin, func() {
+		client.Close()
+		admin.Close()
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestRun(t *testing.T) {
+	r, admin, cleanup := setupRunner(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	ms, err := Load(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A dry run does not change the database.
+	validated, err := r.DryRun(ctx, ms)
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if len(validated) != 3 {
+		t.Errorf("DryRun: got %d migrations, want 3", len(validated))
+	}
+
+	applied, err := r.Run(ctx, ms[:2])
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("Run: got %d migrations, want 2", len(applied))
+	}
+	resp, err := admin.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: "projects/p/instances/i/databases/d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for _, stmt := range resp.Statements {
+		if strings.HasPrefix(stmt, "CREATE TABLE") {
+			tables = append(tables, strings.Fields(stmt)[2])
+		}
+	}
+	sort.Strings(tables)
+	if got, want := fmt.Sprint(tables), "[Albums SchemaMigrations Singers]"; got != want {
+		t.Errorf("got tables %v, want %v", got, want)
+	}
+
+	pendingMs, err := r.Pending(ctx, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pendingMs) != 1 || pendingMs[0].Version != 10 {
+		t.Errorf("Pending: got %v, want migration 10", pendingMs)
+	}
+	if applied, err := r.Run(ctx, ms); err != nil || len(applied) != 1 {
+		t.Errorf("Run: got %d migrations, %v, want 1 migration", len(applied), err)
+	}
+	if applied, err := r.Run(ctx, ms); err != nil || len(applied) != 0 {
+		t.Errorf("Run: got %d migrations, %v, want none", len(applied), err)
+	}
+
+	// A changed migration is refused.
+	changed, err := Parse("0002_albums.sql", "CREATE TABLE Albums (AlbumId INT64 NOT NULL) PRIMARY KEY (AlbumId)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var herr *HistoryError
+	if _, err := r.Run(ctx, []*Migration{ms[0], changed, ms[2]}); !errors.As(err, &herr) || herr.Version != 2 {
+		t.Errorf("changed migration: got %v, want a HistoryError for version 2", err)
+	}
+}
+
+func TestDryRunInvalid(t *testing.T) {
+	r, _, cleanup := setupRunner(t)
+	defer cleanup()
+
+	m, err := Parse("1_drop.sql", "DROP TABLE Unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DryRun(context.Background(), []*Migration{m}); err == nil {
+		t.Error("got nil, want error")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"cloud.google.com/go/spanner/spannertest"
+	"cloud.google.com/go/spanner/spansql"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// DefaultTrackingTable is the default name of the table that records the
+	// applied migrations.
+	DefaultTrackingTable = "SchemaMigrations"
+	// DefaultMaxStatementsPerBatch is the default maximum number of
+	// statements that are applied with one UpdateDatabaseDdl operation.
+	DefaultMaxStatementsPerBatch = 10
+)
+
+// Config configures a Runner.
+type Config struct {
+	// TrackingTable is the name of the table that records the applied
+	// migrations. It is created by Run if it does not exist. The default is
+	// DefaultTrackingTable.
+	TrackingTable string
+	// MaxStatementsPerBatch is the maximum number of statements that are
+	// applied with one UpdateDatabaseDdl operation, unless a single migration
+	// has more statements. The default is DefaultMaxStatementsPerBatch.
+	MaxStatementsPerBatch int
+	// Logf, if not nil, is called with a message before each batch of
+	// migrations is applied.
+	Logf func(format string, args ...interface{})
+}
+
+// Runner applies migrations to the database of a client.
+type Runner struct {
+	client *spanner.Client
+	admin  *database.DatabaseAdminClient
+	config Config
+}
+
+// NewRunner returns a Runner for the database of client, which uses admin to
+// update the schema of the database.
+func NewRunner(client *spanner.Client, admin *database.DatabaseAdminClient, config Config) *Runner {
+	if config.TrackingTable == "" {
+		config.TrackingTable = DefaultTrackingTable
+	}
+	if config.MaxStatementsPerBatch <= 0 {
+		config.MaxStatementsPerBatch = DefaultMaxStatementsPerBatch
+	}
+	return &Runner{client: client, admin: admin, config: config}
+}
+
+// Pending returns the migrations that have not been applied to the database.
+// It returns a *HistoryError if the applied migrations do not match
+// migrations.
+func (r *Runner) Pending(ctx context.Context, migrations []*Migration) ([]*Migration, error) {
+	ddl, err := r.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.pending(ctx, ddl, migrations)
+}
+
+// Run applies the pending migrations to the database, and returns the
+// migrations that were applied. If a migration fails, the migrations that
+// were applied before it are returned together with the error.
+func (r *Runner) Run(ctx context.Context, migrations []*Migration) ([]*Migration, error) {
+	ddl, err := r.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := r.pending(ctx, ddl, migrations)
+	if err != nil || len(todo) == 0 {
+		return nil, err
+	}
+	if !r.hasTrackingTable(ddl) {
+		if err := r.updateDDL(ctx, []string{r.trackingTableDDL()}); err != nil {
+			return nil, fmt.Errorf("migrate: creating tracking table %s: %w", r.config.TrackingTable, err)
+		}
+	}
+	var applied []*Migration
+	for _, batch := range batches(todo, r.config.MaxStatementsPerBatch) {
+		var stmts []string
+		for _, m := range batch {
+			stmts = append(stmts, m.SQL()...)
+		}
+		r.logf("applying migrations %d to %d (%d statements)", batch[0].Version, batch[len(batch)-1].Version, len(stmts))
+		op, err := r.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   r.client.DatabaseName(),
+			Statements: stmts,
+		})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		done := batch
+		if err != nil {
+			// Record the migrations whose statements all completed.
+			done = completed(batch, op)
+		}
+		if rerr := r.record(ctx, done); rerr != nil {
+			return applied, fmt.Errorf("migrate: recording applied migrations: %w", rerr)
+		}
+		applied = append(applied, done...)
+		if err != nil {
+			failed := batch[len(batch)-1]
+			if len(done) < len(batch) {
+				failed = batch[len(done)]
+			}
+			return applied, fmt.Errorf("migrate: applying %s: %w", failed.Name, err)
+		}
+	}
+	return applied, nil
+}
+
+// DryRun validates the pending migrations without changing the database. It
+// applies the schema of the database and the pending migrations to an
+// in-memory fake Cloud Spanner, and returns the migrations that Run would
+// apply. DryRun can only validate statements that are supported by
+// spannertest.
+func (r *Runner) DryRun(ctx context.Context, migrations []*Migration) ([]*Migration, error) {
+	ddl, err := r.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := r.pending(ctx, ddl, migrations)
+	if err != nil || len(todo) == 0 {
+		return nil, err
+	}
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	defer srv.Close()
+	srv.SetLogger(func(string, ...interface{}) {})
+	if err := srv.UpdateDDL(ddl); err != nil {
+		return nil, fmt.Errorf("migrate: loading the current schema: %w", err)
+	}
+	for _, m := range todo {
+		if err := srv.UpdateDDL(&spansql.DDL{List: m.Statements}); err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w", m.Name, err)
+		}
+	}
+	return todo, nil
+}
+
+// schema returns the current schema of the database.
+func (r *Runner) schema(ctx context.Context) (*spansql.DDL, error) {
+	resp, err := r.admin.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: r.client.DatabaseName()})
+	if err != nil {
+		return nil, err
+	}
+	ddl := &spansql.DDL{}
+	for _, s := range resp.Statements {
+		stmt, err := spansql.ParseDDLStmt(s)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: parsing the schema of the database: %v", err)
+		}
+		ddl.List = append(ddl.List, stmt)
+	}
+	return ddl, nil
+}
+
+// pending returns the migrations that have not been applied to the database
+// with the schema ddl.
+func (r *Runner) pending(ctx context.Context, ddl *spansql.DDL, migrations []*Migration) ([]*Migration, error) {
+	sorted := append([]*Migration(nil), migrations...)
+	if err := sortMigrations(sorted); err != nil {
+		return nil, err
+	}
+	var applied []appliedMigration
+	if r.hasTrackingTable(ddl) {
+		stmt := spanner.NewStatement(fmt.Sprintf("SELECT Version, Name, Checksum FROM %s ORDER BY Version", r.config.TrackingTable))
+		err := r.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			var a appliedMigration
+			if err := row.ToStruct(&a); err != nil {
+				return err
+			}
+			applied = append(applied, a)
+			return nil
+		})
+		if err != nil && err != iterator.Done {
+			return nil, fmt.Errorf("migrate: reading %s: %w", r.config.TrackingTable, err)
+		}
+	}
+	return pending(sorted, applied)
+}
+
+func (r *Runner) hasTrackingTable(ddl *spansql.DDL) bool {
+	for _, stmt := range ddl.List {
+		if ct, ok := stmt.(*spansql.CreateTable); ok && strings.EqualFold(string(ct.Name), r.config.TrackingTable) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Runner) trackingTableDDL() string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+	Version INT64 NOT NULL,
+	Name STRING(MAX) NOT NULL,
+	Checksum STRING(64) NOT NULL,
+	AppliedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (Version)`, r.config.TrackingTable)
+}
+
+func (r *Runner) updateDDL(ctx context.Context, stmts []string) error {
+	op, err := r.admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   r.client.DatabaseName(),
+		Statements: stmts,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// record inserts the migrations into the tracking table.
+func (r *Runner) record(ctx context.Context, migrations []*Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+	var ms []*spanner.Mutation
+	for _, m := range migrations {
+		ms = append(ms, spanner.Insert(r.config.TrackingTable,
+			[]string{"Version", "Name", "Checksum", "AppliedAt"},
+			[]interface{}{m.Version, m.Name, m.Checksum(), spanner.CommitTimestamp}))
+	}
+	_, err := r.client.Apply(ctx, ms)
+	return err
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.config.Logf != nil {
+		r.config.Logf(format, args...)
+	}
+}
+
+// completed returns the leading migrations of a batch whose statements were
+// all applied by op before it failed. The number of applied statements is
+// the number of commit timestamps in the metadata of the operation.
+func completed(batch []*Migration, op *database.UpdateDatabaseDdlOperation) []*Migration {
+	if op == nil {
+		return nil
+	}
+	md, err := op.Metadata()
+	if err != nil || md == nil {
+		return nil
+	}
+	n := len(md.CommitTimestamps)
+	for i, m := range batch {
+		if n < len(m.Statements) {
+			return batch[:i]
+		}
+		n -= len(m.Statements)
+	}
+	return batch
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	"cloud.google.com/go/spanner/internal"
+	"go.opencensus.io/stats"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+// otMeterName is the instrumentation scope of the OpenTelemetry metrics of
+// the Spanner client.
+const otMeterName = "cloud.google.com/go/spanner"
+
+var (
+	otAttrNumInUseSessions = attribute.String("type", "num_in_use_sessions")
+	otAttrNumBeingPrepared = attribute.String("type", "num_sessions_being_prepared")
+	otAttrNumReadSessions  = attribute.String("type", "num_read_sessions")
+	otAttrNumWriteSessions = attribute.String("type", "num_write_prepared_sessions")
+)
+
+// meterProvider returns the MeterProvider for the OpenTelemetry metrics of a
+// client, or nil if the client does not record OpenTelemetry metrics.
+func meterProvider(config ClientConfig) metric.MeterProvider {
+	if config.OpenTelemetryMeterProvider != nil {
+		return config.OpenTelemetryMeterProvider
+	}
+	if trace.IsOpenTelemetryTracingEnabled() {
+		return otel.GetMeterProvider()
+	}
+	return nil
+}
+
+// openTelemetryMetrics holds the OpenTelemetry instruments of a session pool.
+// The counters are updated by sessionPool.recordStat, and the gauges are
+// observed from the state of the pool.
+type openTelemetryMetrics struct {
+	attrs                   []attribute.KeyValue
+	getSessionTimeoutsCount metric.Int64Counter
+	acquiredSessionsCount   metric.Int64Counter
+	releasedSessionsCount   metric.Int64Counter
+	registration            metric.Registration
+}
+
+func newOpenTelemetryMetrics(mp metric.MeterProvider, p *sessionPool, ct *commonTags) (*openTelemetryMetrics, error) {
+	meter := mp.Meter(otMeterName, metric.WithInstrumentationVersion(internal.Version))
+	m := &openTelemetryMetrics{
+		attrs: []attribute.KeyValue{
+			attribute.String("client_id", ct.clientID),
+			attribute.String("database", ct.database),
+			attribute.String("instance_id", ct.instance),
+			attribute.String("library_version", ct.libVersion),
+		},
+	}
+	var err error
+	if m.getSessionTimeoutsCount, err = meter.Int64Counter("get_session_timeouts",
+		metric.WithDescription("The number of get sessions timeouts due to pool exhaustion.")); err != nil {
+		return nil, err
+	}
+	if m.acquiredSessionsCount, err = meter.Int64Counter("num_acquired_sessions",
+		metric.WithDescription("The number of sessions acquired from the session pool.")); err != nil {
+		return nil, err
+	}
+	if m.releasedSessionsCount, err = meter.Int64Counter("num_released_sessions",
+		metric.WithDescription("The number of sessions released by the user and pool maintainer.")); err != nil {
+		return nil, err
+	}
+	openSessionCount, err := meter.Int64ObservableGauge("open_session_count",
+		metric.WithDescription("Number of sessions currently opened"))
+	if err != nil {
+		return nil, err
+	}
+	maxAllowedSessionsCount, err := meter.Int64ObservableGauge("max_allowed_sessions",
+		metric.WithDescription("The maximum number of sessions allowed. Configurable by the user."))
+	if err != nil {
+		return nil, err
+	}
+	sessionsCount, err := meter.Int64ObservableGauge("num_sessions_in_pool",
+		metric.WithDescription("The number of sessions currently in use."))
+	if err != nil {
+		return nil, err
+	}
+	maxInUseSessionsCount, err := meter.Int64ObservableGauge("max_in_use_sessions",
+		metric.WithDescription("The maximum number of sessions in use during the last 10 minute interval."))
+	if err != nil {
+		return nil, err
+	}
+	attrs := metric.WithAttributes(m.attrs...)
+	withType := func(t attribute.KeyValue) metric.ObserveOption {
+		return metric.WithAttributes(append(m.attrs[:len(m.attrs):len(m.attrs)], t)...)
+	}
+	m.registration, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		o.ObserveInt64(openSessionCount, int64(p.numOpened), attrs)
+		o.ObserveInt64(maxAllowedSessionsCount, int64(p.MaxOpened), attrs)
+		o.ObserveInt64(maxInUseSessionsCount, int64(p.maxNumInUse), attrs)
+		o.ObserveInt64(sessionsCount, int64(p.numInUse), withType(otAttrNumInUseSessions))
+		o.ObserveInt64(sessionsCount, int64(p.prepareReqs), withType(otAttrNumBeingPrepared))
+		o.ObserveInt64(sessionsCount, int64(p.numReads), withType(otAttrNumReadSessions))
+		o.ObserveInt64(sessionsCount, int64(p.numWrites), withType(otAttrNumWriteSessions))
+		return nil
+	}, openSessionCount, maxAllowedSessionsCount, sessionsCount, maxInUseSessionsCount)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// record adds n to the OpenTelemetry counter that corresponds to the
+// OpenCensus measure m. The gauges are not recorded here, as they are
+// observed by the callback of the meter.
+func (m *openTelemetryMetrics) record(ctx context.Context, measure *stats.Int64Measure, n int64) {
+	if m == nil {
+		return
+	}
+	var counter metric.Int64Counter
+	switch measure {
+	case GetSessionTimeoutsCount:
+		counter = m.getSessionTimeoutsCount
+	case AcquiredSessionsCount:
+		counter = m.acquiredSessionsCount
+	case ReleasedSessionsCount:
+		counter = m.releasedSessionsCount
+	default:
+		return
+	}
+	counter.Add(ctx, n, metric.WithAttributes(m.attrs...))
+}
+
+// unregister stops the observation of the gauges of a closed session pool.
+func (m *openTelemetryMetrics) unregister() {
+	if m == nil {
+		return
+	}
+	m.registration.Unregister()
+}
+
+// setSpanAttributes adds semantic-convention attributes to the current span
+// if OpenTelemetry tracing is enabled.
+func setSpanAttributes(ctx context.Context, ct *commonTags, attrs ...attribute.KeyValue) {
+	if !trace.IsOpenTelemetryTracingEnabled() {
+		return
+	}
+	span := ottrace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs = append(attrs, semconv.DBSystemSpanner)
+	if ct != nil {
+		attrs = append(attrs, semconv.DBName(ct.database), attribute.String("db.instance", ct.instance))
+	}
+	span.SetAttributes(attrs...)
+}
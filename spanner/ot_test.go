@@ -0,0 +1,145 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/internal/trace"
+	stestutil "cloud.google.com/go/spanner/internal/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestOTMetrics_SessionPool(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	_, client, teardown := setupMockedTestServerWithConfig(t, ClientConfig{
+		SessionPoolConfig:          SessionPoolConfig{MinOpened: 25, MaxOpened: 400},
+		OpenTelemetryMeterProvider: mp,
+	})
+	defer teardown()
+
+	iter := client.Single().Read(context.Background(), "Users", AllKeys(), []string{"email"})
+	iter.Do(func(*Row) error { return nil })
+	waitFor(t, func() error {
+		client.idleSessions.mu.Lock()
+		defer client.idleSessions.mu.Unlock()
+		if client.idleSessions.numOpened != 25 {
+			return errInvalidSessionPool
+		}
+		return nil
+	})
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != otMeterName {
+			t.Errorf("unexpected scope: %v", sm.Scope.Name)
+		}
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					got[m.Name] += dp.Value
+					checkOTCommonAttrs(t, m.Name, dp.Attributes)
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					if v, ok := dp.Attributes.Value("type"); ok {
+						got[m.Name+"/"+v.AsString()] = dp.Value
+					} else {
+						got[m.Name] = dp.Value
+					}
+					checkOTCommonAttrs(t, m.Name, dp.Attributes)
+				}
+			}
+		}
+	}
+	for name, want := range map[string]int64{
+		"open_session_count":                       25,
+		"max_allowed_sessions":                     400,
+		"max_in_use_sessions":                      1,
+		"num_acquired_sessions":                    1,
+		"num_released_sessions":                    1,
+		"num_sessions_in_pool/num_in_use_sessions": 0,
+	} {
+		if got[name] != want {
+			t.Errorf("%s: got %d, want %d", name, got[name], want)
+		}
+	}
+}
+
+func checkOTCommonAttrs(t *testing.T, name string, attrs attribute.Set) {
+	t.Helper()
+	for _, key := range []attribute.Key{"client_id", "database", "instance_id", "library_version"} {
+		if !attrs.HasValue(key) {
+			t.Errorf("%s: missing attribute %q", name, key)
+		}
+	}
+	if v, _ := attrs.Value("database"); v.AsString() != "[DATABASE]" {
+		t.Errorf("%s: database: got %q, want %q", name, v.AsString(), "[DATABASE]")
+	}
+}
+
+func TestOTTracing_Attributes(t *testing.T) {
+	defer func(enabled bool) { trace.OpenTelemetryTracingEnabled = enabled }(trace.OpenTelemetryTracingEnabled)
+	trace.OpenTelemetryTracingEnabled = true
+	sr := tracetest.NewSpanRecorder()
+	defer func(tp ottrace.TracerProvider) { otel.SetTracerProvider(tp) }(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	_, client, teardown := setupMockedTestServer(t)
+	defer teardown()
+
+	iter := client.Single().Query(context.Background(), NewStatement(stestutil.SelectSingerIDAlbumIDAlbumTitleFromAlbums))
+	if err := iter.Do(func(*Row) error { return nil }); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+
+	var found bool
+	for _, span := range sr.Ended() {
+		if span.Name() != "cloud.google.com/go/spanner.Query" {
+			continue
+		}
+		found = true
+		want := map[attribute.Key]string{
+			"db.system":    "spanner",
+			"db.name":      "[DATABASE]",
+			"db.statement": stestutil.SelectSingerIDAlbumIDAlbumTitleFromAlbums,
+		}
+		got := make(map[attribute.Key]string)
+		for _, kv := range span.Attributes() {
+			got[kv.Key] = kv.Value.Emit()
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("attribute %s: got %q, want %q", k, got[k], v)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("no Query span was recorded")
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/googleapis/gax-go/v2"
+	"github.com/googleapis/gax-go/v2/apierror"
 	edpb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -63,7 +64,14 @@ func TestRetryInfoTransactionOutcomeUnknownError(t *testing.T) {
 	if gotDelay, ok := ExtractRetryDelay(err); ok {
 		t.Errorf("Got unexpected delay\nGot: %v\nWant: %v", gotDelay, 0)
 	}
-	if !testEqual(err.(*Error).err, &TransactionOutcomeUnknownError{status.FromContextError(context.DeadlineExceeded).Err()}) {
+	// The wrapped error is an APIError, which in turn wraps the
+	// TransactionOutcomeUnknownError.
+	var apiErr *apierror.APIError
+	if !errorAs(err, &apiErr) {
+		t.Errorf("Missing expected APIError wrapped error")
+	}
+	var outcome *TransactionOutcomeUnknownError
+	if !errorAs(err, &outcome) || !testEqual(outcome, &TransactionOutcomeUnknownError{status.FromContextError(context.DeadlineExceeded).Err()}) {
 		t.Errorf("Missing expected TransactionOutcomeUnknownError wrapped error")
 	}
 }
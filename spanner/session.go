@@ -36,6 +36,7 @@ import (
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/metric"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
@@ -463,6 +464,10 @@ type SessionPoolConfig struct {
 
 	// sessionLabels for the sessions created in the session pool.
 	sessionLabels map[string]string
+
+	// meterProvider is the MeterProvider for the OpenTelemetry metrics of
+	// the session pool, or nil if no OpenTelemetry metrics are recorded.
+	meterProvider metric.MeterProvider
 }
 
 // DefaultSessionPoolConfig is the default configuration for the session pool
@@ -587,6 +592,10 @@ type sessionPool struct {
 
 	// tagMap is a map of all tags that are associated with the emitted metrics.
 	tagMap *tag.Map
+
+	// otMetrics holds the OpenTelemetry instruments of the pool, or nil if
+	// no OpenTelemetry metrics are recorded.
+	otMetrics *openTelemetryMetrics
 }
 
 // newSessionPool creates a new session pool.
@@ -633,6 +642,12 @@ func newSessionPool(sc *sessionClient, config SessionPoolConfig) (*sessionPool,
 		logf(pool.sc.logger, "Failed to create tag map, error: %v", err)
 	}
 	pool.tagMap = tag.FromContext(ctx)
+	if config.meterProvider != nil {
+		ct := &commonTags{clientID: sc.id, database: database, instance: instance, libVersion: internal.Version}
+		if pool.otMetrics, err = newOpenTelemetryMetrics(config.meterProvider, pool, ct); err != nil {
+			logf(pool.sc.logger, "Failed to create OpenTelemetry metrics, error: %v", err)
+		}
+	}
 
 	// On GCE VM, within the same region an healthcheck ping takes on average
 	// 10ms to finish, given a 5 minutes interval and 10 healthcheck workers, a
@@ -666,6 +681,7 @@ func (p *sessionPool) recordStat(ctx context.Context, m *stats.Int64Measure, n i
 		logf(p.sc.logger, "Failed to tag metrics, error: %v", err)
 	}
 	recordStat(ctx, m, n)
+	p.otMetrics.record(ctx, m, n)
 }
 
 func (p *sessionPool) initPool(numSessions uint64) error {
@@ -757,6 +773,7 @@ func (p *sessionPool) close(ctx context.Context) {
 	}
 	p.valid = false
 	p.mu.Unlock()
+	p.otMetrics.unregister()
 	p.hc.close()
 	// destroy all the sessions
 	p.hc.mu.Lock()
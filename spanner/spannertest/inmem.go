@@ -808,7 +808,16 @@ func (s *server) Rollback(ctx context.Context, req *spannerpb.RollbackRequest) (
 	return &emptypb.Empty{}, nil
 }
 
-// TODO: PartitionQuery, PartitionRead
+// TODO: Implement PartitionQuery and PartitionRead. Until then they return
+// Unimplemented, so that callers can fall back to unpartitioned reads.
+
+func (s *server) PartitionQuery(ctx context.Context, req *spannerpb.PartitionQueryRequest) (*spannerpb.PartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "PartitionQuery is not supported")
+}
+
+func (s *server) PartitionRead(ctx context.Context, req *spannerpb.PartitionReadRequest) (*spannerpb.PartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "PartitionRead is not supported")
+}
 
 func parseQueryParams(p *structpb.Struct, types map[string]*spannerpb.Type) (queryParams, error) {
 	params := make(queryParams)
@@ -0,0 +1,224 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+)
+
+// conn is a connection to a database. Connections are cheap, as the sessions
+// are managed by the shared spanner.Client.
+type conn struct {
+	client *spanner.Client
+	// ownsClient is set if the connection was made by Driver.Open, and
+	// closes its client.
+	ownsClient bool
+	// tx is the current transaction of the connection, if any.
+	tx transaction
+}
+
+// transaction is a read-write or read-only transaction of a connection.
+type transaction interface {
+	driver.Tx
+	exec(ctx context.Context, stmt spanner.Statement) (int64, error)
+	query(ctx context.Context, stmt spanner.Statement) (*rows, error)
+}
+
+var (
+	_ driver.Conn               = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+)
+
+// Prepare implements driver.Conn.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext implements driver.ConnPrepareContext. Statements are not
+// prepared on the server, so this only returns a statement that holds the
+// query.
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+// Close implements driver.Conn. It rolls back the current transaction of the
+// connection, if any, and closes the client of a connection made by
+// Driver.Open.
+func (c *conn) Close() error {
+	var err error
+	if c.tx != nil {
+		err = c.tx.Rollback()
+	}
+	if c.ownsClient {
+		c.client.Close()
+	}
+	return err
+}
+
+// Begin implements driver.Conn.
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("sqldriver: connection already has a transaction")
+	}
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelSerializable:
+	default:
+		return nil, fmt.Errorf("sqldriver: isolation level %v is not supported", sql.IsolationLevel(opts.Isolation))
+	}
+	if opts.ReadOnly {
+		tx := c.client.ReadOnlyTransaction().WithTimestampBound(timestampBound(ctx))
+		c.tx = &readOnlyTx{conn: c, tx: tx}
+		return c.tx, nil
+	}
+	tx, err := newReadWriteTx(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	c.tx = tx
+	return tx, nil
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if isDDL(query) {
+		return nil, errDDL
+	}
+	stmt, err := newStatement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	var n int64
+	if c.tx != nil {
+		n, err = c.tx.exec(ctx, stmt)
+	} else {
+		_, err = c.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			var err error
+			n, err = tx.Update(ctx, stmt)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result(n), nil
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := newStatement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.tx != nil {
+		return c.tx.query(ctx, stmt)
+	}
+	ro := c.client.Single().WithTimestampBound(timestampBound(ctx))
+	return newRows(iteratorSource{ro.Query(ctx, stmt)})
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	return checkNamedValue(v)
+}
+
+// Ping implements driver.Pinger.
+func (c *conn) Ping(ctx context.Context) error {
+	iter := c.client.Single().Query(ctx, spanner.NewStatement("SELECT 1"))
+	defer iter.Stop()
+	_, err := iter.Next()
+	return err
+}
+
+// result is the driver.Result of a DML statement.
+type result int64
+
+// LastInsertId implements driver.Result. Cloud Spanner does not generate
+// row IDs.
+func (r result) LastInsertId() (int64, error) {
+	return 0, errors.New("sqldriver: LastInsertId is not supported")
+}
+
+// RowsAffected implements driver.Result.
+func (r result) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// stmt is a statement returned by conn.PrepareContext.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+var (
+	_ driver.StmtExecContext   = (*stmt)(nil)
+	_ driver.StmtQueryContext  = (*stmt)(nil)
+	_ driver.NamedValueChecker = (*stmt)(nil)
+)
+
+// Close implements driver.Stmt.
+func (s *stmt) Close() error { return nil }
+
+// NumInput implements driver.Stmt. The number of parameters is not checked
+// by database/sql.
+func (s *stmt) NumInput() int { return -1 }
+
+// Exec implements driver.Stmt.
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+// Query implements driver.Stmt.
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (s *stmt) CheckNamedValue(v *driver.NamedValue) error {
+	return checkNamedValue(v)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
@@ -0,0 +1,195 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package sqldriver is a database/sql driver for Cloud Spanner, built on
+spanner.Client.
+
+The driver is registered with the name "spanner". The data source name is the
+name of a database:
+
+	db, err := sql.Open("spanner", "projects/my-project/instances/my-instance/databases/my-db")
+
+All connections of a sql.DB share one spanner.Client, which is closed when the
+sql.DB is closed. Use NewConnector and sql.OpenDB to use an existing client
+instead.
+
+# Statements
+
+Queries that are not executed in a transaction use a single-use read-only
+transaction. DML statements that are not executed in a transaction are executed
+in a read-write transaction of their own, which is retried if it is aborted.
+DDL statements are not supported; use the database admin client to update the
+schema of a database.
+
+Parameters are given as named parameters, which correspond to @name
+parameters in the SQL string:
+
+	db.QueryContext(ctx, "SELECT Name FROM Singers WHERE SingerId=@id", sql.Named("id", 1))
+
+or as positional parameters, which correspond to ? placeholders, or to the
+parameters @p1, @p2 and so on:
+
+	db.QueryContext(ctx, "SELECT Name FROM Singers WHERE SingerId=?", 1)
+
+Parameter values are passed to spanner.Statement unchanged if the Spanner
+client supports their type, such as spanner.NullNumeric, spanner.NullJSON and
+civil.Date, instead of being converted by their driver.Valuer implementation.
+
+Columns can be scanned into the Go types that spanner.Row.Column supports for
+the column type. JSON columns must be scanned into a spanner.NullJSON.
+
+# Transactions
+
+A sql.Tx is a read-write transaction, unless sql.TxOptions.ReadOnly is set.
+Cloud Spanner may abort a read-write transaction at any time. The driver then
+retries the transaction by executing the statements of the transaction again,
+and continues if they return the same results as before. If the results differ,
+the transaction fails with ErrAbortedDueToConcurrentModification.
+
+Read-only transactions and single-use queries use a strong read, unless the
+context specifies another timestamp bound:
+
+	ctx = sqldriver.WithTimestampBound(ctx, spanner.ExactStaleness(15*time.Second))
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+*/
+package sqldriver // import "cloud.google.com/go/spanner/sqldriver"
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+)
+
+// DriverName is the name of the driver registered with database/sql.
+const DriverName = "spanner"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// databaseNameRegexp matches the name of a Cloud Spanner database.
+var databaseNameRegexp = regexp.MustCompile(`^projects/[^/]+/instances/[^/]+/databases/[^/]+$`)
+
+// Driver is the database/sql driver for Cloud Spanner.
+type Driver struct{}
+
+// Open returns a new connection to the database with the given name. Each
+// call creates a new spanner.Client, which is closed when the connection is
+// closed; use sql.Open, which calls OpenConnector, to share a client between
+// connections.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	if err := checkDatabaseName(name); err != nil {
+		return nil, err
+	}
+	client, err := spanner.NewClient(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{client: client, ownsClient: true}, nil
+}
+
+// OpenConnector returns a connector for the database with the given name.
+// The connector creates its spanner.Client when the first connection is made.
+func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	if err := checkDatabaseName(name); err != nil {
+		return nil, err
+	}
+	return &connector{driver: d, database: name}, nil
+}
+
+func checkDatabaseName(name string) error {
+	if !databaseNameRegexp.MatchString(name) {
+		return fmt.Errorf("sqldriver: invalid database name %q, want projects/PROJECT/instances/INSTANCE/databases/DATABASE", name)
+	}
+	return nil
+}
+
+// NewConnector returns a connector that uses client for all connections. The
+// client is not closed when the sql.DB is closed.
+func NewConnector(client *spanner.Client) driver.Connector {
+	return &connector{driver: &Driver{}, client: client, external: true}
+}
+
+type connector struct {
+	driver   *Driver
+	database string
+	external bool
+
+	mu     sync.Mutex
+	client *spanner.Client
+}
+
+// Connect implements driver.Connector.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		client, err := spanner.NewClient(ctx, c.database)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	return &conn{client: c.client}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close closes the client of the connector, unless it was given to
+// NewConnector. It is called by sql.DB.Close.
+func (c *connector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil && !c.external {
+		c.client.Close()
+		c.client = nil
+	}
+	return nil
+}
+
+var (
+	// ErrAbortedDueToConcurrentModification is returned by the operations of
+	// a read-write transaction when the transaction was aborted by Cloud
+	// Spanner, and executing its statements again returned different
+	// results.
+	ErrAbortedDueToConcurrentModification = errors.New("sqldriver: transaction was aborted due to a concurrent modification")
+
+	errReadOnly = errors.New("sqldriver: statement cannot be executed in a read-only transaction")
+	errDDL      = errors.New("sqldriver: DDL statements are not supported, use the database admin client")
+)
+
+type timestampBoundKey struct{}
+
+// WithTimestampBound returns a context that makes read-only transactions and
+// single-use queries read at the given timestamp bound.
+func WithTimestampBound(ctx context.Context, tb spanner.TimestampBound) context.Context {
+	return context.WithValue(ctx, timestampBoundKey{}, tb)
+}
+
+func timestampBound(ctx context.Context) spanner.TimestampBound {
+	if tb, ok := ctx.Value(timestampBoundKey{}).(spanner.TimestampBound); ok {
+		return tb
+	}
+	return spanner.StrongRead()
+}
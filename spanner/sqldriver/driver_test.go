@@ -0,0 +1,386 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	. "cloud.google.com/go/spanner/internal/testutil"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func setupTestDB(t *testing.T) (*MockedSpannerInMemTestServer, *sql.DB, func()) {
+	server, opts, serverTeardown := NewMockedSpannerInMemTestServer(t)
+	ctx := context.Background()
+	client, err := spanner.NewClientWithConfig(ctx, "projects/[PROJECT]/instances/[INSTANCE]/databases/[DATABASE]",
+		spanner.ClientConfig{SessionPoolConfig: spanner.SessionPoolConfig{MinOpened: 1}}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(NewConnector(client))
+	return server, db, func() {
+		db.Close()
+		client.Close()
+		serverTeardown()
+	}
+}
+
+func drainRequests(server InMemSpannerServer) []interface{} {
+	var reqs []interface{}
+	for {
+		select {
+		case req := <-server.ReceivedRequests():
+			reqs = append(reqs, req)
+		default:
+			return reqs
+		}
+	}
+}
+
+func TestOpenConnector(t *testing.T) {
+	d := &Driver{}
+	if _, err := d.OpenConnector("projects/p/instances/i/databases/d"); err != nil {
+		t.Errorf("valid name: %v", err)
+	}
+	if _, err := d.OpenConnector("projects/p/instances/i"); err == nil {
+		t.Error("invalid name: got nil, want error")
+	}
+}
+
+func TestOpenClosesClient(t *testing.T) {
+	// Reserve a port for the server, which the client reaches as an emulator.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	_, _, serverTeardown := NewMockedSpannerInMemTestServerWithAddr(t, addr)
+	defer serverTeardown()
+	t.Setenv("SPANNER_EMULATOR_HOST", addr)
+
+	cn, err := (&Driver{}).Open("projects/p/instances/i/databases/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := cn.(*conn).client
+	if _, err := client.Single().Query(context.Background(), spanner.NewStatement(SelectFooFromBar)).Next(); err != nil {
+		t.Fatalf("query before Close: %v", err)
+	}
+	if err := cn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Single().Query(context.Background(), spanner.NewStatement(SelectFooFromBar)).Next(); err == nil {
+		t.Error("query after Close: got nil, want error from the closed client")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	_, db, teardown := setupTestDB(t)
+	defer teardown()
+
+	rows, err := db.QueryContext(context.Background(), SelectSingerIDAlbumIDAlbumTitleFromAlbums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cols, []string{"SingerId", "AlbumId", "AlbumTitle"}; !cmp.Equal(got, want) {
+		t.Errorf("columns: got %v, want %v", got, want)
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := types[2].DatabaseTypeName(), "STRING"; got != want {
+		t.Errorf("type name: got %q, want %q", got, want)
+	}
+	var n int
+	for rows.Next() {
+		var singerID, albumID int64
+		var title string
+		if err := rows.Scan(&singerID, &albumID, &title); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if int64(n) != SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount {
+		t.Errorf("got %d rows, want %d", n, SelectSingerIDAlbumIDAlbumTitleFromAlbumsRowCount)
+	}
+
+	if _, err := db.QueryContext(context.Background(), "SELECT * FROM Unknown"); err == nil {
+		t.Error("unknown query: got nil, want error")
+	}
+}
+
+func TestExec(t *testing.T) {
+	server, db, teardown := setupTestDB(t)
+	defer teardown()
+	ctx := context.Background()
+
+	drainRequests(server.TestSpanner)
+	res, err := db.ExecContext(ctx, UpdateBarSetFoo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != UpdateBarSetFooRowCount {
+		t.Errorf("rows affected: got %d, want %d", n, UpdateBarSetFooRowCount)
+	}
+	var commits int
+	for _, req := range drainRequests(server.TestSpanner) {
+		if _, ok := req.(*sppb.CommitRequest); ok {
+			commits++
+		}
+	}
+	if commits != 1 {
+		t.Errorf("got %d commits, want 1", commits)
+	}
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE Foo (Id INT64) PRIMARY KEY (Id)"); err != errDDL {
+		t.Errorf("DDL: got %v, want %v", err, errDDL)
+	}
+}
+
+func TestParameters(t *testing.T) {
+	server, db, teardown := setupTestDB(t)
+	defer teardown()
+	ctx := context.Background()
+
+	const query = "UPDATE Singers SET Name=? WHERE SingerId=? AND Comment!='?'"
+	const want = "UPDATE Singers SET Name=@p1 WHERE SingerId=@p2 AND Comment!='?'"
+	server.TestSpanner.PutStatementResult(want, &StatementResult{Type: StatementResultUpdateCount, UpdateCount: 1})
+	server.TestSpanner.PutStatementResult("UPDATE Singers SET Rating=@rating, Birthday=@birthday WHERE SingerId=@p1",
+		&StatementResult{Type: StatementResultUpdateCount, UpdateCount: 1})
+
+	drainRequests(server.TestSpanner)
+	if _, err := db.ExecContext(ctx, query, "name", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE Singers SET Rating=@rating, Birthday=@birthday WHERE SingerId=@p1",
+		sql.Named("rating", spanner.NullNumeric{Numeric: *big.NewRat(3, 2), Valid: true}),
+		sql.Named("birthday", civil.Date{Year: 2000, Month: 1, Day: 2}),
+		int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	var got []*sppb.ExecuteSqlRequest
+	for _, req := range drainRequests(server.TestSpanner) {
+		if req, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			got = append(got, req)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d requests, want 2", len(got))
+	}
+	if got[0].Sql != want {
+		t.Errorf("SQL: got %q, want %q", got[0].Sql, want)
+	}
+	if v := got[0].Params.Fields["p1"].GetStringValue(); v != "name" {
+		t.Errorf("p1: got %q, want %q", v, "name")
+	}
+	if typ := got[1].ParamTypes["rating"].Code; typ != sppb.TypeCode_NUMERIC {
+		t.Errorf("rating: got type %v, want NUMERIC", typ)
+	}
+	if typ := got[1].ParamTypes["birthday"].Code; typ != sppb.TypeCode_DATE {
+		t.Errorf("birthday: got type %v, want DATE", typ)
+	}
+	if typ := got[1].ParamTypes["p1"].Code; typ != sppb.TypeCode_INT64 {
+		t.Errorf("p1: got type %v, want INT64", typ)
+	}
+
+	if _, err := db.ExecContext(ctx, query, "name"); err == nil {
+		t.Error("missing argument: got nil, want error")
+	}
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+		n        int
+	}{
+		{"SELECT 1", "SELECT 1", 0},
+		{"SELECT ? FROM T WHERE A=?", "SELECT @p1 FROM T WHERE A=@p2", 2},
+		{`SELECT '?', "?", ` + "`?`" + `, ?`, `SELECT '?', "?", ` + "`?`" + `, @p1`, 1},
+		{`SELECT 'it\'s ?', ?`, `SELECT 'it\'s ?', @p1`, 1},
+		{"SELECT '''a ' ?''', ?", "SELECT '''a ' ?''', @p1", 1},
+		{"SELECT ? -- ?\n, ? # ?\n /* ? */ FROM T", "SELECT @p1 -- ?\n, @p2 # ?\n /* ? */ FROM T", 2},
+	} {
+		got, n, err := replacePlaceholders(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if got != test.want || n != test.n {
+			t.Errorf("%q: got %q, %d, want %q, %d", test.in, got, n, test.want, test.n)
+		}
+	}
+	if _, _, err := replacePlaceholders("SELECT 'a, ?"); err == nil {
+		t.Error("unterminated string: got nil, want error")
+	}
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	for _, v := range []interface{}{
+		spanner.NullNumeric{Numeric: *big.NewRat(1, 2), Valid: true},
+		spanner.NullJSON{Value: map[string]int{"a": 1}, Valid: true},
+		spanner.NullDate{Date: civil.Date{Year: 2000, Month: 1, Day: 1}, Valid: true},
+		civil.Date{Year: 2000, Month: 1, Day: 1},
+		[]int64{1, 2},
+	} {
+		nv := &driver.NamedValue{Value: v}
+		if err := checkNamedValue(nv); err != nil {
+			t.Errorf("%T: %v", v, err)
+		}
+		if !cmp.Equal(nv.Value, v, cmp.AllowUnexported(big.Rat{}, big.Int{})) {
+			t.Errorf("%T: value was converted to %v", v, nv.Value)
+		}
+	}
+	nv := &driver.NamedValue{Value: sql.NullString{String: "a", Valid: true}}
+	if err := checkNamedValue(nv); err != nil {
+		t.Fatal(err)
+	}
+	if nv.Value != "a" {
+		t.Errorf("sql.NullString: got %v, want %q", nv.Value, "a")
+	}
+}
+
+func TestReadWriteTransaction_RetryAborted(t *testing.T) {
+	server, db, teardown := setupTestDB(t)
+	defer teardown()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	rows, err := tx.QueryContext(ctx, SelectFooFromBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Transaction aborted")},
+	})
+	drainRequests(server.TestSpanner)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got, want := ids, []int64{1, 2}; !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var begins, commits int
+	for _, req := range drainRequests(server.TestSpanner) {
+		switch req.(type) {
+		case *sppb.BeginTransactionRequest:
+			begins++
+		case *sppb.CommitRequest:
+			commits++
+		}
+	}
+	if begins != 1 || commits != 2 {
+		t.Errorf("got %d begins and %d commits, want 1 and 2", begins, commits)
+	}
+}
+
+func TestReadWriteTransaction_ConcurrentModification(t *testing.T) {
+	server, db, teardown := setupTestDB(t)
+	defer teardown()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, UpdateBarSetFoo); err != nil {
+		t.Fatal(err)
+	}
+	// The statement updates a different number of rows when it is retried.
+	server.TestSpanner.PutStatementResult(UpdateBarSetFoo, &StatementResult{Type: StatementResultUpdateCount, UpdateCount: 1})
+	server.TestSpanner.PutExecutionTime(MethodCommitTransaction, SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Transaction aborted")},
+	})
+	if err := tx.Commit(); err != ErrAbortedDueToConcurrentModification {
+		t.Errorf("Commit: got %v, want %v", err, ErrAbortedDueToConcurrentModification)
+	}
+}
+
+func TestReadOnlyTransaction(t *testing.T) {
+	server, db, teardown := setupTestDB(t)
+	defer teardown()
+	ctx := WithTimestampBound(context.Background(), spanner.ExactStaleness(10*time.Second))
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, UpdateBarSetFoo); err != errReadOnly {
+		t.Errorf("Exec: got %v, want %v", err, errReadOnly)
+	}
+	drainRequests(server.TestSpanner)
+	var id int64
+	if err := tx.QueryRowContext(ctx, SelectFooFromBar).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var begin *sppb.BeginTransactionRequest
+	for _, req := range drainRequests(server.TestSpanner) {
+		if req, ok := req.(*sppb.BeginTransactionRequest); ok {
+			begin = req
+		}
+	}
+	if begin == nil {
+		t.Fatal("no BeginTransaction request")
+	}
+	ro := begin.Options.GetReadOnly()
+	if ro == nil || ro.GetExactStaleness().AsDuration() != 10*time.Second {
+		t.Errorf("transaction options: got %v, want exact staleness of 10s", begin.Options)
+	}
+
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted}); err == nil {
+		t.Error("unsupported isolation level: got nil, want error")
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"io"
+	"reflect"
+
+	"cloud.google.com/go/spanner"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+)
+
+// rowSource is the source of the rows of a query.
+type rowSource interface {
+	next() (*spanner.Row, error)
+	// iterator returns the current iterator of the query, which has the
+	// metadata of the results.
+	iterator() *spanner.RowIterator
+}
+
+// iteratorSource is the rowSource of a query that is not executed in a
+// read-write transaction.
+type iteratorSource struct {
+	it *spanner.RowIterator
+}
+
+func (s iteratorSource) next() (*spanner.Row, error)    { return s.it.Next() }
+func (s iteratorSource) iterator() *spanner.RowIterator { return s.it }
+
+// rows implements driver.Rows for the results of a query.
+type rows struct {
+	src    rowSource
+	fields []*sppb.StructType_Field
+	// first is the first row, which is read when the rows are created to
+	// get the columns of the results.
+	first    *spanner.Row
+	firstErr error
+}
+
+var (
+	_ driver.Rows                           = (*rows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+)
+
+// newRows returns the rows of src. It reads the first row, so that the error
+// of an invalid query is returned by Query and not by Rows.Next.
+func newRows(src rowSource) (*rows, error) {
+	r := &rows{src: src}
+	r.first, r.firstErr = src.next()
+	if r.firstErr != nil && r.firstErr != iterator.Done {
+		src.iterator().Stop()
+		return nil, r.firstErr
+	}
+	if md := src.iterator().Metadata; md != nil && md.RowType != nil {
+		r.fields = md.RowType.Fields
+	}
+	return r, nil
+}
+
+// Columns implements driver.Rows.
+func (r *rows) Columns() []string {
+	cols := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		cols[i] = f.Name
+	}
+	return cols
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return typeName(r.fields[index].Type)
+}
+
+func typeName(t *sppb.Type) string {
+	if t.Code == sppb.TypeCode_ARRAY {
+		return "ARRAY<" + typeName(t.ArrayElementType) + ">"
+	}
+	return t.Code.String()
+}
+
+// Close implements driver.Rows.
+func (r *rows) Close() error {
+	r.src.iterator().Stop()
+	return nil
+}
+
+// Next implements driver.Rows.
+func (r *rows) Next(dest []driver.Value) error {
+	var (
+		row *spanner.Row
+		err error
+	)
+	if r.first != nil || r.firstErr != nil {
+		row, err = r.first, r.firstErr
+		r.first, r.firstErr = nil, nil
+	} else {
+		row, err = r.src.next()
+	}
+	if err == iterator.Done {
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+	for i := range dest {
+		if dest[i], err = columnValue(row, i, r.fields[i].Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnValue returns the value of column i of row, which has type t. NULL
+// values are returned as nil, except for JSON columns, whose values are
+// always returned as spanner.NullJSON. ARRAY columns are returned as slices
+// of the corresponding spanner.Null* type.
+func columnValue(row *spanner.Row, i int, t *sppb.Type) (driver.Value, error) {
+	pg := t.TypeAnnotation == sppb.TypeAnnotationCode_PG_NUMERIC || t.TypeAnnotation == sppb.TypeAnnotationCode_PG_JSONB
+	switch t.Code {
+	case sppb.TypeCode_BOOL:
+		var v spanner.NullBool
+		return nullable(row.Column(i, &v), v)
+	case sppb.TypeCode_INT64:
+		var v spanner.NullInt64
+		return nullable(row.Column(i, &v), v)
+	case sppb.TypeCode_FLOAT64:
+		var v spanner.NullFloat64
+		return nullable(row.Column(i, &v), v)
+	case sppb.TypeCode_STRING:
+		var v spanner.NullString
+		return nullable(row.Column(i, &v), v)
+	case sppb.TypeCode_BYTES:
+		var v []byte
+		return v, row.Column(i, &v)
+	case sppb.TypeCode_TIMESTAMP:
+		var v spanner.NullTime
+		return nullable(row.Column(i, &v), v)
+	case sppb.TypeCode_DATE:
+		var v spanner.NullDate
+		return nullable(row.Column(i, &v), v)
+	case sppb.TypeCode_NUMERIC:
+		if pg {
+			var v spanner.PGNumeric
+			if err := row.Column(i, &v); err != nil || !v.Valid {
+				return nil, err
+			}
+			return v.Numeric, nil
+		}
+		var v spanner.NullNumeric
+		if err := row.Column(i, &v); err != nil || !v.Valid {
+			return nil, err
+		}
+		return v.Numeric, nil
+	case sppb.TypeCode_JSON:
+		if pg {
+			var v spanner.PGJsonB
+			return v, row.Column(i, &v)
+		}
+		var v spanner.NullJSON
+		return v, row.Column(i, &v)
+	case sppb.TypeCode_ARRAY:
+		return arrayValue(row, i, t.ArrayElementType)
+	}
+	var v spanner.GenericColumnValue
+	return v, row.Column(i, &v)
+}
+
+// arrayElementTypes are the Go types of the elements of ARRAY columns.
+var arrayElementTypes = map[sppb.TypeCode]reflect.Type{
+	sppb.TypeCode_BOOL:      reflect.TypeOf(spanner.NullBool{}),
+	sppb.TypeCode_INT64:     reflect.TypeOf(spanner.NullInt64{}),
+	sppb.TypeCode_FLOAT64:   reflect.TypeOf(spanner.NullFloat64{}),
+	sppb.TypeCode_STRING:    reflect.TypeOf(spanner.NullString{}),
+	sppb.TypeCode_BYTES:     reflect.TypeOf([]byte(nil)),
+	sppb.TypeCode_TIMESTAMP: reflect.TypeOf(spanner.NullTime{}),
+	sppb.TypeCode_DATE:      reflect.TypeOf(spanner.NullDate{}),
+	sppb.TypeCode_NUMERIC:   reflect.TypeOf(spanner.NullNumeric{}),
+	sppb.TypeCode_JSON:      reflect.TypeOf(spanner.NullJSON{}),
+}
+
+// arrayValue returns the value of the ARRAY column i of row, whose elements
+// have type t.
+func arrayValue(row *spanner.Row, i int, t *sppb.Type) (driver.Value, error) {
+	elem, ok := arrayElementTypes[t.Code]
+	if !ok {
+		var v spanner.GenericColumnValue
+		return v, row.Column(i, &v)
+	}
+	v := reflect.New(reflect.SliceOf(elem))
+	if err := row.Column(i, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// nullable returns the value of v, or nil if v is NULL.
+func nullable(err error, v spanner.NullableValue) (driver.Value, error) {
+	if err != nil || v.IsNull() {
+		return nil, err
+	}
+	return v.(driver.Valuer).Value()
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+)
+
+// newStatement returns the spanner.Statement for a query and its arguments.
+// Positional arguments are named p1, p2 and so on, and ? placeholders in the
+// query are replaced by the corresponding parameters.
+func newStatement(query string, args []driver.NamedValue) (spanner.Statement, error) {
+	sql, n, err := replacePlaceholders(query)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	positional := 0
+	stmt := spanner.Statement{SQL: sql, Params: make(map[string]interface{}, len(args))}
+	for _, arg := range args {
+		name := arg.Name
+		if name == "" {
+			positional++
+			name = "p" + strconv.Itoa(positional)
+		}
+		stmt.Params[name] = arg.Value
+	}
+	if n > 0 && n != positional {
+		return spanner.Statement{}, fmt.Errorf("sqldriver: query has %d placeholders, but %d positional arguments were given", n, positional)
+	}
+	return stmt, nil
+}
+
+// replacePlaceholders replaces the ? placeholders of query that are not in a
+// string literal, a quoted identifier or a comment by @p1, @p2 and so on. It
+// returns the new query and the number of placeholders.
+func replacePlaceholders(query string) (string, int, error) {
+	if !strings.Contains(query, "?") {
+		return query, 0, nil
+	}
+	var (
+		b strings.Builder
+		n int
+	)
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '?':
+			n++
+			b.WriteString("@p" + strconv.Itoa(n))
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			end, err := skipQuoted(query, i)
+			if err != nil {
+				return "", 0, err
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case c == '#' || strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", 0, fmt.Errorf("sqldriver: unterminated comment in %q", query)
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 4
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), n, nil
+}
+
+// skipQuoted returns the index after the quoted string or identifier that
+// starts at query[start], which may be triple-quoted.
+func skipQuoted(query string, start int) (int, error) {
+	q := query[start : start+1]
+	if strings.HasPrefix(query[start:], q+q+q) {
+		q = q + q + q
+	}
+	for i := start + len(q); i < len(query); i++ {
+		if query[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(query[i:], q) {
+			return i + len(q), nil
+		}
+	}
+	return 0, fmt.Errorf("sqldriver: unterminated quoted string in %q", query)
+}
+
+// firstKeyword returns the first keyword of a statement in upper case,
+// skipping leading white space and comments.
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(query, "--") || strings.HasPrefix(query, "#"):
+			end := strings.IndexByte(query, '\n')
+			if end < 0 {
+				return ""
+			}
+			query = query[end:]
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query, "*/")
+			if end < 0 {
+				return ""
+			}
+			query = query[end+2:]
+		default:
+			end := strings.IndexFunc(query, func(r rune) bool { return !unicode.IsLetter(r) })
+			if end < 0 {
+				end = len(query)
+			}
+			return strings.ToUpper(query[:end])
+		}
+	}
+}
+
+// isDDL reports whether query is a DDL statement.
+func isDDL(query string) bool {
+	switch firstKeyword(query) {
+	case "CREATE", "ALTER", "DROP", "GRANT", "REVOKE":
+		return true
+	}
+	return false
+}
+
+// checkNamedValue implements driver.NamedValueChecker. Values of types that
+// the Spanner client encodes itself are not converted, even if they implement
+// driver.Valuer, so that for example a spanner.NullNumeric is sent as a
+// NUMERIC and not as a STRING.
+func checkNamedValue(v *driver.NamedValue) error {
+	switch v.Value.(type) {
+	case spanner.NullInt64, spanner.NullString, spanner.NullFloat64, spanner.NullBool,
+		spanner.NullTime, spanner.NullDate, spanner.NullNumeric, spanner.NullJSON,
+		spanner.PGNumeric, spanner.PGJsonB, spanner.GenericColumnValue,
+		civil.Date, big.Rat, *big.Rat:
+		return nil
+	case driver.Valuer:
+		value, err := driver.DefaultParameterConverter.ConvertValue(v.Value)
+		if err != nil {
+			return err
+		}
+		v.Value = value
+	}
+	// Other values are checked by the Spanner client when the statement is
+	// executed.
+	return nil
+}
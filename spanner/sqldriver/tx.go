@@ -0,0 +1,291 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldriver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// readOnlyTx is a read-only transaction of a connection.
+type readOnlyTx struct {
+	conn *conn
+	tx   *spanner.ReadOnlyTransaction
+}
+
+func (t *readOnlyTx) exec(ctx context.Context, stmt spanner.Statement) (int64, error) {
+	return 0, errReadOnly
+}
+
+func (t *readOnlyTx) query(ctx context.Context, stmt spanner.Statement) (*rows, error) {
+	return newRows(iteratorSource{t.tx.Query(ctx, stmt)})
+}
+
+// Commit implements driver.Tx.
+func (t *readOnlyTx) Commit() error {
+	t.tx.Close()
+	t.conn.tx = nil
+	return nil
+}
+
+// Rollback implements driver.Tx.
+func (t *readOnlyTx) Rollback() error {
+	return t.Commit()
+}
+
+// readWriteTx is a read-write transaction of a connection. It records the
+// statements that are executed and a checksum of their results, so that they
+// can be replayed on a new transaction if Cloud Spanner aborts the current
+// one.
+type readWriteTx struct {
+	conn *conn
+	// ctx is the context given to BeginTx, which is used to commit or roll
+	// back the transaction.
+	ctx context.Context
+	tx  *spanner.ReadWriteStmtBasedTransaction
+	// released is set when the session of tx has been returned to the pool.
+	released bool
+	records  []record
+}
+
+// record is a statement that has been executed in a read-write transaction.
+type record interface {
+	// replay executes the statement again in t, and returns
+	// ErrAbortedDueToConcurrentModification if the result differs.
+	replay(ctx context.Context, t *readWriteTx) error
+}
+
+func newReadWriteTx(ctx context.Context, c *conn) (*readWriteTx, error) {
+	tx, err := spanner.NewReadWriteStmtBasedTransaction(ctx, c.client)
+	if err != nil {
+		return nil, err
+	}
+	return &readWriteTx{conn: c, ctx: ctx, tx: tx}, nil
+}
+
+func (t *readWriteTx) exec(ctx context.Context, stmt spanner.Statement) (int64, error) {
+	for {
+		n, err := t.tx.Update(ctx, stmt)
+		if isAborted(err) {
+			if err := t.retry(ctx, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		t.records = append(t.records, &execRecord{stmt: stmt, count: n, code: spanner.ErrCode(err)})
+		return n, err
+	}
+}
+
+func (t *readWriteTx) query(ctx context.Context, stmt spanner.Statement) (*rows, error) {
+	r := &queryRecord{ctx: ctx, tx: t, stmt: stmt, it: t.tx.Query(ctx, stmt), hash: sha256.New()}
+	t.records = append(t.records, r)
+	return newRows(r)
+}
+
+// Commit implements driver.Tx. If the commit is aborted, the transaction is
+// retried and committed again.
+func (t *readWriteTx) Commit() error {
+	defer func() { t.conn.tx = nil }()
+	for {
+		_, err := t.tx.Commit(t.ctx)
+		t.released = true
+		if !isAborted(err) {
+			return err
+		}
+		if err := t.retry(t.ctx, err); err != nil {
+			return err
+		}
+	}
+}
+
+// Rollback implements driver.Tx.
+func (t *readWriteTx) Rollback() error {
+	if !t.released {
+		t.tx.Rollback(t.ctx)
+		t.released = true
+	}
+	t.conn.tx = nil
+	return nil
+}
+
+// retry starts a new transaction after the current one was aborted with err,
+// and replays the recorded statements on it.
+func (t *readWriteTx) retry(ctx context.Context, err error) error {
+	for {
+		if delay, ok := spanner.ExtractRetryDelay(err); ok {
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+		if !t.released {
+			t.tx.Rollback(ctx)
+		}
+		tx, err := spanner.NewReadWriteStmtBasedTransaction(ctx, t.conn.client)
+		if err != nil {
+			t.released = true
+			return err
+		}
+		t.tx, t.released = tx, false
+		err = t.replay(ctx)
+		if !isAborted(err) {
+			return err
+		}
+	}
+}
+
+func (t *readWriteTx) replay(ctx context.Context) error {
+	for _, r := range t.records {
+		if err := r.replay(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execRecord records a DML statement and its update count.
+type execRecord struct {
+	stmt  spanner.Statement
+	count int64
+	code  codes.Code
+}
+
+func (r *execRecord) replay(ctx context.Context, t *readWriteTx) error {
+	n, err := t.tx.Update(ctx, r.stmt)
+	if isAborted(err) {
+		return err
+	}
+	if n != r.count || spanner.ErrCode(err) != r.code {
+		return ErrAbortedDueToConcurrentModification
+	}
+	return nil
+}
+
+// queryRecord records a query and a checksum of the rows that have been
+// returned by it so far.
+type queryRecord struct {
+	ctx  context.Context
+	tx   *readWriteTx
+	stmt spanner.Statement
+	it   *spanner.RowIterator
+	hash hash.Hash
+	// n is the number of rows that have been returned.
+	n int
+	// done is set when the iterator returned iterator.Done, and code is
+	// the code of the error that ended the iteration otherwise.
+	done bool
+	code codes.Code
+}
+
+// next returns the next row of the query, and retries the transaction if it
+// is aborted.
+func (r *queryRecord) next() (*spanner.Row, error) {
+	for {
+		row, err := r.it.Next()
+		switch {
+		case isAborted(err):
+			if err := r.tx.retry(r.ctx, err); err != nil {
+				return nil, err
+			}
+			continue
+		case err == iterator.Done:
+			r.done = true
+		case err != nil:
+			r.code = spanner.ErrCode(err)
+		default:
+			if err := hashRow(r.hash, row); err != nil {
+				return nil, err
+			}
+			r.n++
+		}
+		return row, err
+	}
+}
+
+func (r *queryRecord) iterator() *spanner.RowIterator {
+	return r.it
+}
+
+func (r *queryRecord) replay(ctx context.Context, t *readWriteTx) error {
+	r.it.Stop()
+	r.it = t.tx.Query(ctx, r.stmt)
+	h := sha256.New()
+	for i := 0; i < r.n; i++ {
+		row, err := r.it.Next()
+		if isAborted(err) {
+			return err
+		}
+		if err != nil {
+			return ErrAbortedDueToConcurrentModification
+		}
+		if err := hashRow(h, row); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(h.Sum(nil), r.hash.Sum(nil)) {
+		return ErrAbortedDueToConcurrentModification
+	}
+	if r.done || r.code != codes.OK {
+		// The end of the results has been seen, so it must not have
+		// changed either.
+		_, err := r.it.Next()
+		if isAborted(err) {
+			return err
+		}
+		if r.done != (err == iterator.Done) || (!r.done && spanner.ErrCode(err) != r.code) {
+			return ErrAbortedDueToConcurrentModification
+		}
+	}
+	return nil
+}
+
+// hashRow adds the values of row to h.
+func hashRow(h hash.Hash, row *spanner.Row) error {
+	for i := 0; i < row.Size(); i++ {
+		var v spanner.GenericColumnValue
+		if err := row.Column(i, &v); err != nil {
+			return err
+		}
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		h.Write(b)
+	}
+	return nil
+}
+
+func isAborted(err error) bool {
+	return err != nil && spanner.ErrCode(err) == codes.Aborted
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -26,6 +26,8 @@ import (
 	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/googleapis/gax-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"google.golang.org/api/iterator"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -181,6 +183,7 @@ func (ro ReadOptions) merge(opts ReadOptions) ReadOptions {
 func (t *txReadOnly) ReadWithOptions(ctx context.Context, table string, keys KeySet, columns []string, opts *ReadOptions) (ri *RowIterator) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Read")
 	defer func() { trace.EndSpan(ctx, ri.err) }()
+	setSpanAttributes(ctx, t.ct, semconv.DBSQLTable(table), semconv.DBOperation("Read"))
 	var (
 		sh  *sessionHandle
 		ts  *sppb.TransactionSelector
@@ -426,6 +429,7 @@ func (t *txReadOnly) AnalyzeQuery(ctx context.Context, statement Statement) (*sp
 func (t *txReadOnly) query(ctx context.Context, statement Statement, options QueryOptions) (ri *RowIterator) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Query")
 	defer func() { trace.EndSpan(ctx, ri.err) }()
+	setSpanAttributes(ctx, t.ct, semconv.DBStatement(statement.SQL))
 	req, sh, err := t.prepareExecuteSQL(ctx, statement, options)
 	if err != nil {
 		return &RowIterator{err: err}
@@ -965,6 +969,7 @@ func (t *ReadWriteTransaction) UpdateWithOptions(ctx context.Context, stmt State
 func (t *ReadWriteTransaction) update(ctx context.Context, stmt Statement, opts QueryOptions) (rowCount int64, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.Update")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, t.ct, semconv.DBStatement(stmt.SQL))
 	req, sh, err := t.prepareExecuteSQL(ctx, stmt, opts)
 	if err != nil {
 		return 0, err
@@ -1013,6 +1018,7 @@ func (t *ReadWriteTransaction) BatchUpdateWithOptions(ctx context.Context, stmts
 func (t *ReadWriteTransaction) batchUpdateWithOptions(ctx context.Context, stmts []Statement, opts QueryOptions) (_ []int64, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/spanner.BatchUpdate")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, t.ct, attribute.Int("db.spanner.statement_count", len(stmts)))
 
 	sh, ts, err := t.acquire(ctx)
 	if err != nil {
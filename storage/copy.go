@@ -84,6 +84,7 @@ type Copier struct {
 func (c *Copier) Run(ctx context.Context) (attrs *ObjectAttrs, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/storage.Copier.Run")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, c.dst.bucket, c.dst.object)
 
 	if err := c.src.validate(); err != nil {
 		return nil, err
@@ -182,6 +183,7 @@ type Composer struct {
 func (c *Composer) Run(ctx context.Context) (attrs *ObjectAttrs, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/storage.Composer.Run")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, c.dst.bucket, c.dst.object)
 
 	if err := c.dst.validate(); err != nil {
 		return nil, err
@@ -0,0 +1,46 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+
+	"cloud.google.com/go/internal/trace"
+	"go.opentelemetry.io/otel/attribute"
+	ottrace "go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys of the OpenTelemetry spans of the client.
+const (
+	attrKeyBucket = attribute.Key("gcp.gcs.bucket")
+	attrKeyObject = attribute.Key("gcp.gcs.object")
+)
+
+// setSpanAttributes adds the bucket and, if it is not empty, the object of an
+// operation to the current span if OpenTelemetry tracing is enabled.
+func setSpanAttributes(ctx context.Context, bucket, object string) {
+	if !trace.IsOpenTelemetryTracingEnabled() {
+		return
+	}
+	span := ottrace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs := []attribute.KeyValue{attrKeyBucket.String(bucket)}
+	if object != "" {
+		attrs = append(attrs, attrKeyObject.String(object))
+	}
+	span.SetAttributes(attrs...)
+}
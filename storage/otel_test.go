@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/internal/trace"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	ottrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+)
+
+func TestOTelSpanAttributes(t *testing.T) {
+	defer func(enabled bool) { trace.OpenTelemetryTracingEnabled = enabled }(trace.OpenTelemetryTracingEnabled)
+	trace.OpenTelemetryTracingEnabled = true
+	sr := tracetest.NewSpanRecorder()
+	defer func(tp ottrace.TracerProvider) { otel.SetTracerProvider(tp) }(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	hc, close := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bucket": "b", "name": "o"}`))
+	})
+	defer close()
+	ctx := context.Background()
+	c, err := NewClient(ctx, option.WithHTTPClient(hc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Bucket("b").Object("o").Attrs(ctx); err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	got := make(map[string]string)
+	for _, kv := range spans[0].Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	if got["gcp.gcs.bucket"] != "b" || got["gcp.gcs.object"] != "o" {
+		t.Errorf("span attributes: got %v, want bucket b and object o", got)
+	}
+}
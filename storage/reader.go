@@ -89,6 +89,7 @@ func (o *ObjectHandle) NewReader(ctx context.Context) (*Reader, error) {
 func (o *ObjectHandle) NewRangeReader(ctx context.Context, offset, length int64) (r *Reader, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/storage.Object.NewRangeReader")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, o.bucket, o.object)
 
 	if err := o.validate(); err != nil {
 		return nil, err
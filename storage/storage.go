@@ -915,6 +915,7 @@ func (o *ObjectHandle) Key(encryptionKey []byte) *ObjectHandle {
 func (o *ObjectHandle) Attrs(ctx context.Context) (attrs *ObjectAttrs, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/storage.Object.Attrs")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, o.bucket, o.object)
 
 	if err := o.validate(); err != nil {
 		return nil, err
@@ -929,6 +930,7 @@ func (o *ObjectHandle) Attrs(ctx context.Context) (attrs *ObjectAttrs, err error
 func (o *ObjectHandle) Update(ctx context.Context, uattrs ObjectAttrsToUpdate) (oa *ObjectAttrs, err error) {
 	ctx = trace.StartSpan(ctx, "cloud.google.com/go/storage.Object.Update")
 	defer func() { trace.EndSpan(ctx, err) }()
+	setSpanAttributes(ctx, o.bucket, o.object)
 
 	if err := o.validate(); err != nil {
 		return nil, err